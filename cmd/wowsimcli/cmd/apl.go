@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	lintWarnings bool
+	rotationFile string
+
+	tuneMaxSims    int32
+	tuneIterations int32
+	tunePasses     int32
+	tuneFormat     string
+	tuneQuiet      bool
+)
+
+var aplCmd = &cobra.Command{
+	Use:   "apl",
+	Short: "APL rotation tools",
+}
+
+var aplLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "find unreachable and redundant APL items",
+	Long: `check the APL rotation of every player for items which never run or can be simplified, such as items shadowed by an earlier cast of the same spell, conditions which are always true or false, casts of unknown spells and prepull casts which finish after the pull.
+
+Exits with status 1 if anything was found.`,
+	Run: aplLintMain,
+}
+
+var aplToTextCmd = &cobra.Command{
+	Use:   "totext",
+	Short: "print APL rotations in the text syntax",
+	Long: `print the APL rotation of every player in a SimulationCraft style text syntax, one item per line:
+
+  actions=cast_spell,spell_id=25292,if=aura_remaining_time(aura_id=10060)<3s&current_mana_percent>20%
+  actions+=/call_action_list,name=aoe,if=number_targets>=3
+
+Use --rotation to print a single APLRotation in protojson format instead.`,
+	Run: aplToTextMain,
+}
+
+var aplFromTextCmd = &cobra.Command{
+	Use:   "fromtext <file>",
+	Short: "convert an APL rotation in the text syntax to protojson",
+	Long:  "parse an APL rotation written in the text syntax printed by totext, and print it as an APLRotation in protojson format which can be imported in the UI.",
+	Args:  cobra.ExactArgs(1),
+	Run:   aplFromTextMain,
+}
+
+var aplTuneCmd = &cobra.Command{
+	Use:   "tune",
+	Short: "search tunable APL thresholds for the highest DPS",
+	Long: `search the consts marked tunable in the first player's APL rotation for the values with the highest raid DPS, one const at a time with paired RNG.
+Mark consts in the text syntax with a tuning range in the const's unit, for example:
+
+  actions=cast_spell,spell_id=25292,if=current_mana_percent>const(val="20%",tuning=(min=10,max=50,step=5))
+
+The tuned rotation is compared against the original with a new seed, and printed in the text syntax.`,
+	Run: aplTuneMain,
+}
+
+func init() {
+	addRaidSimInputFlags(aplLintCmd)
+	aplLintCmd.Flags().BoolVar(&lintWarnings, "warnings", true, "also print the validation warnings shown in the UI")
+	aplLintCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	aplCmd.AddCommand(aplLintCmd)
+
+	addRaidSimInputFlags(aplToTextCmd)
+	aplToTextCmd.Flags().StringVar(&rotationFile, "rotation", "", "location of an APLRotation file in protojson format, to use instead of the input")
+	aplToTextCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	aplCmd.AddCommand(aplToTextCmd)
+
+	aplFromTextCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	aplCmd.AddCommand(aplFromTextCmd)
+
+	addRaidSimInputFlags(aplTuneCmd)
+	aplTuneCmd.Flags().StringVar(&rotationFile, "rotation", "", "location of the rotation to tune instead of the first player's, in the text syntax or APLRotation protojson format")
+	aplTuneCmd.Flags().Int32Var(&tuneMaxSims, "max-sims", 0, "total number of sims to run, defaults to the sim core's")
+	aplTuneCmd.Flags().Int32Var(&tuneIterations, "iterations", 0, "iterations per sim during the search, defaults to the input's")
+	aplTuneCmd.Flags().Int32Var(&tunePasses, "passes", 0, "maximum passes over all tunables, defaults to the sim core's")
+	aplTuneCmd.Flags().StringVar(&tuneFormat, "format", "text", "output format: text or json (the whole APLTuneResult)")
+	aplTuneCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	aplTuneCmd.Flags().BoolVar(&tuneQuiet, "quiet", false, "don't print a progress bar")
+	aplCmd.AddCommand(aplTuneCmd)
+}
+
+func writeAPLOutput(output string) {
+	if outfile == "" {
+		fmt.Print(output)
+	} else if err := os.WriteFile(outfile, []byte(output), 0666); err != nil {
+		log.Fatalf("failed to write output file:: %s", err)
+	}
+}
+
+func aplLintMain(cmd *cobra.Command, args []string) {
+	input, err := readRaidSimRequest()
+	if err != nil {
+		log.Fatalf("failed to load input: %s", err)
+	}
+
+	result := core.ComputeStats(&proto.ComputeStatsRequest{
+		Raid:      input.Raid,
+		Encounter: input.Encounter,
+	})
+	if result.ErrorResult != "" {
+		log.Fatalf("failed to compute stats: %s", result.ErrorResult)
+	}
+
+	output := &strings.Builder{}
+	found := false
+	for partyIdx, party := range result.RaidStats.Parties {
+		for playerIdx, playerStats := range party.Players {
+			stats := playerStats.RotationStats
+			if stats == nil {
+				continue
+			}
+			player := input.Raid.Parties[partyIdx].Players[playerIdx]
+
+			var lines []string
+			addLines := func(label string, actionStats *proto.APLActionStats) {
+				var messages []string
+				if lintWarnings {
+					messages = append(messages, actionStats.Warnings...)
+				}
+				messages = append(messages, actionStats.Lints...)
+				for _, message := range messages {
+					lines = append(lines, fmt.Sprintf("  %s: %s", label, message))
+				}
+			}
+			for i, actionStats := range stats.PrepullActions {
+				addLines(fmt.Sprintf("Prepull #%d", i+1), actionStats)
+			}
+			for i, actionStats := range stats.PriorityList {
+				addLines(fmt.Sprintf("Priority List #%d", i+1), actionStats)
+			}
+			for i, listStats := range stats.ActionLists {
+				label := fmt.Sprintf("Action List '%s'", player.Rotation.ActionLists[i].Name)
+				if lintWarnings {
+					for _, warning := range listStats.Warnings {
+						lines = append(lines, fmt.Sprintf("  %s: %s", label, warning))
+					}
+				}
+				for j, actionStats := range listStats.Items {
+					addLines(fmt.Sprintf("%s #%d", label, j+1), actionStats)
+				}
+			}
+
+			if len(lines) > 0 {
+				found = true
+				fmt.Fprintf(output, "%s (party %d, slot %d):\n%s\n", player.Name, partyIdx+1, playerIdx+1, strings.Join(lines, "\n"))
+			}
+		}
+	}
+	if !found {
+		output.WriteString("No issues found.\n")
+	}
+
+	writeAPLOutput(output.String())
+
+	if found {
+		os.Exit(1)
+	}
+}
+
+func aplToTextMain(cmd *cobra.Command, args []string) {
+	if rotationFile != "" {
+		data, err := os.ReadFile(rotationFile)
+		if err != nil {
+			log.Fatalf("failed to load rotation file: %s", err)
+		}
+		rotation := &proto.APLRotation{}
+		if err := protojson.Unmarshal(data, rotation); err != nil {
+			log.Fatalf("failed to parse rotation file: %s", err)
+		}
+		text, err := core.APLRotationToText(rotation)
+		if err != nil {
+			log.Fatalf("failed to convert rotation: %s", err)
+		}
+		writeAPLOutput(text)
+		return
+	}
+
+	input, err := readRaidSimRequest()
+	if err != nil {
+		log.Fatalf("failed to load input: %s", err)
+	}
+
+	var sections []string
+	for partyIdx, party := range input.Raid.Parties {
+		for playerIdx, player := range party.Players {
+			if player.Rotation == nil || player.Rotation.Type != proto.APLRotation_TypeAPL {
+				continue
+			}
+			text, err := core.APLRotationToText(player.Rotation)
+			if err != nil {
+				log.Fatalf("failed to convert rotation of %s: %s", player.Name, err)
+			}
+			sections = append(sections, fmt.Sprintf("# %s (party %d, slot %d)\n%s", player.Name, partyIdx+1, playerIdx+1, text))
+		}
+	}
+	if len(sections) == 0 {
+		log.Fatalf("no players with an APL rotation")
+	}
+	writeAPLOutput(strings.Join(sections, "\n"))
+}
+
+func aplFromTextMain(cmd *cobra.Command, args []string) {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		log.Fatalf("failed to load rotation text: %s", err)
+	}
+	rotation, err := core.APLRotationFromText(string(data))
+	if err != nil {
+		log.Fatalf("failed to parse rotation text: %s", err)
+	}
+	output, err := protojson.MarshalOptions{Multiline: true}.Marshal(rotation)
+	if err != nil {
+		log.Fatalf("failed to marshal rotation: %s", err)
+	}
+	writeAPLOutput(string(output) + "\n")
+}
+
+func aplTuneMain(cmd *cobra.Command, args []string) {
+	if tuneFormat != "text" && tuneFormat != "json" {
+		log.Fatalf("unknown output format %q", tuneFormat)
+	}
+
+	input, err := readRaidSimRequest()
+	if err != nil {
+		log.Fatalf("failed to load input: %s", err)
+	}
+	if input.SimOptions == nil {
+		input.SimOptions = simOptionsFromSettings(nil)
+	}
+	input.SimOptions.DebugFirstIteration = false
+
+	request := &proto.APLTuneRequest{
+		BaseSettings: input,
+		TuneSettings: &proto.APLTuneSettings{
+			MaxSims:          tuneMaxSims,
+			IterationsPerSim: tuneIterations,
+			MaxPasses:        tunePasses,
+		},
+	}
+	if rotationFile != "" {
+		data, err := os.ReadFile(rotationFile)
+		if err != nil {
+			log.Fatalf("failed to load rotation file: %s", err)
+		}
+		request.Rotation = &proto.APLRotation{}
+		if protojson.Unmarshal(data, request.Rotation) != nil {
+			if request.Rotation, err = core.APLRotationFromText(string(data)); err != nil {
+				log.Fatalf("failed to parse rotation file: %s", err)
+			}
+		}
+	}
+
+	progress := make(chan *proto.ProgressMetrics, 100)
+	core.RunAPLTuneAsync(request, progress, "cmd-apl-tune")
+
+	var result *proto.APLTuneResult
+	for status := range progress {
+		if status.FinalAplTuneResult != nil {
+			result = status.FinalAplTuneResult
+			break
+		}
+		if !tuneQuiet {
+			printProgressBar(status)
+		}
+	}
+	if !tuneQuiet {
+		fmt.Fprintln(os.Stderr)
+	}
+	if result == nil {
+		log.Fatalf("tuning finished without a result")
+	}
+	if result.Error != nil {
+		log.Fatalf("tuning failed: %s", result.Error.Message)
+	}
+
+	var output string
+	switch tuneFormat {
+	case "text":
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%-24s %12s %12s\n", "Tunable", "Original", "Tuned")
+		for _, value := range result.Values {
+			fmt.Fprintf(&sb, "%-24s %12s %12s\n", value.Name, value.Original, value.Tuned)
+		}
+		fmt.Fprintf(&sb, "\nDPS %.2f -> %.2f (%+.2f, 95%% CI %.2f, p-value %.4f) after %d sims\n\n",
+			result.Dps.Baseline, result.Dps.Variant, result.Dps.Mean, result.Dps.Ci95, result.Dps.PValue, result.SimsRun)
+		text, err := core.APLRotationToText(result.Rotation)
+		if err != nil {
+			log.Fatalf("failed to convert rotation: %s", err)
+		}
+		sb.WriteString(text)
+		output = sb.String()
+	case "json":
+		data, err := protojson.MarshalOptions{Multiline: true}.Marshal(result)
+		if err != nil {
+			log.Fatalf("failed to marshal result: %s", err)
+		}
+		output = string(data) + "\n"
+	default:
+		log.Fatalf("unknown output format %q", tuneFormat)
+	}
+	writeAPLOutput(output)
+}
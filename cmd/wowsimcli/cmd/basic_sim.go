@@ -18,22 +18,15 @@ var simCmd = &cobra.Command{
 }
 
 func init() {
-	simCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (RaidSimRequest in protojson format)")
+	addRaidSimInputFlags(simCmd)
 	simCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
 	simCmd.Flags().BoolVar(&verbose, "verbose", false, "print information during runtime")
-	simCmd.MarkFlagRequired("infile")
 }
 
 func simMain(cmd *cobra.Command, args []string) {
-	data, err := os.ReadFile(infile)
+	input, err := readRaidSimRequest()
 	if err != nil {
-		log.Fatalf("failed to load input json file %q: %v", infile, err)
-	}
-	input := &proto.RaidSimRequest{}
-
-	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, input)
-	if err != nil {
-		log.Fatalf("failed to load input json file: %s", err)
+		log.Fatalf("failed to load input: %s", err)
 	}
 
 	var output []byte
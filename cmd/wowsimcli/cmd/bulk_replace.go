@@ -6,12 +6,12 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/wowsims/sod/sim/core"
 	"github.com/wowsims/sod/sim/core/proto"
-	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var (
@@ -29,24 +29,17 @@ var bulkCmd = &cobra.Command{
 }
 
 func init() {
-	bulkCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (RaidSimRequest in protojson format)")
+	addRaidSimInputFlags(bulkCmd)
 	bulkCmd.Flags().StringVar(&replacefile, "replacefile", "", "location of replacement items file. Writes a CSV result of the items replaced instead of JSON")
 	bulkCmd.Flags().StringVar(&outfile, "output", "", "location of output file, defaults to stdout")
 	bulkCmd.Flags().BoolVar(&verbose, "verbose", false, "print information during runtime")
-	bulkCmd.MarkFlagRequired("infile")
 	bulkCmd.MarkFlagRequired("replacefile")
 }
 
 func bulkSimMain(cmd *cobra.Command, args []string) {
-	data, err := os.ReadFile(infile)
+	input, err := readRaidSimRequest()
 	if err != nil {
-		log.Fatalf("failed to load input json file %q: %v", infile, err)
-	}
-	input := &proto.RaidSimRequest{}
-
-	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, input)
-	if err != nil {
-		log.Fatalf("failed to load input json file: %s", err)
+		log.Fatalf("failed to load input: %s", err)
 	}
 
 	output := BulkSim(input, replacefile, verbose)
@@ -65,9 +58,11 @@ func bulkSimMain(cmd *cobra.Command, args []string) {
 }
 
 type ItemReplacementInput struct {
-	Combinations bool              `json:"combinations"`
-	FastMode     bool              `json:"fast_mode"`
-	Items        []*proto.ItemSpec // spec for replacement
+	Combinations bool                  `json:"combinations"`
+	FastMode     bool                  `json:"fast_mode"`
+	Items        []*proto.ItemSpec     // spec for replacement
+	SimRunes     bool                  `json:"sim_runes"`
+	RunesToSim   []*proto.BulkRuneSlot `json:"runes_to_sim"`
 }
 
 type ReplaceIter struct {
@@ -94,6 +89,8 @@ func BulkSim(input *proto.RaidSimRequest, replaceFile string, verbose bool) stri
 			Items:              replaceInput.Items,
 			IterationsPerCombo: input.SimOptions.Iterations,
 			FastMode:           replaceInput.FastMode,
+			SimRunes:           replaceInput.SimRunes,
+			RunesToSim:         replaceInput.RunesToSim,
 		},
 	}
 	progress := make(chan *proto.ProgressMetrics, 100)
@@ -155,7 +152,7 @@ func printCombos(results *proto.BulkSimResult) string {
 	result := ""
 	foundBase := false
 	for i := 0; i < len(results.Results); i++ {
-		if len(results.Results[i].ItemsAdded) == 0 {
+		if isBaseCombo(results.Results[i]) {
 			foundBase = true
 		}
 		result += printCombo(results.Results[i])
@@ -167,16 +164,23 @@ func printCombos(results *proto.BulkSimResult) string {
 }
 
 func printCombo(combo *proto.BulkComboResult) string {
-	itemtext := "["
-	if len(combo.ItemsAdded) == 0 {
-		itemtext += "BASE RESULT"
+	var changes []string
+	for _, item := range combo.ItemsAdded {
+		changes = append(changes, fmt.Sprintf("%s@%s", core.ItemsByID[item.Item.Id].Name, item.Slot.String()))
 	}
-	for j, item := range combo.ItemsAdded {
-		if j != 0 {
-			itemtext += ";"
-		}
-		itemtext += fmt.Sprintf("%s@%s", core.ItemsByID[item.Item.Id].Name, item.Slot.String())
+	for _, runeChange := range combo.RunesChanged {
+		changes = append(changes, fmt.Sprintf("rune %d@%s", runeChange.Rune, runeChange.Slot.String()))
 	}
-	itemtext += "]"
+	if combo.TalentLoadout != nil {
+		changes = append(changes, fmt.Sprintf("talents %s", combo.TalentLoadout.TalentsString))
+	}
+	if isBaseCombo(combo) {
+		changes = append(changes, "BASE RESULT")
+	}
+	itemtext := "[" + strings.Join(changes, ";") + "]"
 	return fmt.Sprintf("%s,%0.1f\n", itemtext, combo.UnitMetrics.Dps.Avg)
 }
+
+func isBaseCombo(combo *proto.BulkComboResult) bool {
+	return len(combo.ItemsAdded) == 0 && len(combo.RunesChanged) == 0 && combo.TalentLoadout == nil
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	compareIterations int32
+	compareSeed       int64
+	compareFormat     string
+	compareActions    int
+	compareQuiet      bool
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <baseline> <variant>...",
+	Short: "compare setups with paired RNG",
+	Long: `sim each setup with the same seeds and compare the variants against the baseline iteration by iteration.
+
+Setups are input files (RaidSimRequest, IndividualSimSettings or RaidSimSettings in protojson format) or wowsims export links.
+Pairing the iterations cancels most of the noise, so small differences are significant after far fewer iterations.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  compareMain,
+}
+
+func init() {
+	compareCmd.Flags().Int32Var(&compareIterations, "iterations", 0, "iterations per setup, defaults to the baseline's")
+	compareCmd.Flags().Int64Var(&compareSeed, "seed", 0, "random seed, defaults to the baseline's or a random one")
+	compareCmd.Flags().StringVar(&compareFormat, "format", "text", "output format: text or json (the whole CompareResult)")
+	compareCmd.Flags().IntVar(&compareActions, "actions", 10, "number of action differences to print per variant")
+	compareCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	compareCmd.Flags().BoolVar(&compareQuiet, "quiet", false, "don't print a progress bar")
+}
+
+func compareMain(cmd *cobra.Command, args []string) {
+	request := &proto.CompareRequest{}
+	for _, input := range args {
+		raidSimRequest, err := readRaidSimRequestInput(input)
+		if err != nil {
+			log.Fatalf("failed to load %s: %s", input, err)
+		}
+		request.Requests = append(request.Requests, raidSimRequest)
+	}
+
+	request.SimOptions = simOptionsFromSettings(nil)
+	if baseOptions := request.Requests[0].SimOptions; baseOptions != nil {
+		if baseOptions.Iterations > 0 {
+			request.SimOptions.Iterations = baseOptions.Iterations
+		}
+		if baseOptions.RandomSeed != 0 {
+			request.SimOptions.RandomSeed = baseOptions.RandomSeed
+		}
+	}
+	if compareIterations > 0 {
+		request.SimOptions.Iterations = compareIterations
+	}
+	if compareSeed != 0 {
+		request.SimOptions.RandomSeed = compareSeed
+	}
+	request.SimOptions.DebugFirstIteration = false
+
+	progress := make(chan *proto.ProgressMetrics, 100)
+	core.RunCompareAsync(request, progress, "cmd-compare")
+
+	var result *proto.CompareResult
+	for status := range progress {
+		if status.FinalCompareResult != nil {
+			result = status.FinalCompareResult
+			break
+		}
+		if !compareQuiet {
+			printProgressBar(status)
+		}
+	}
+	if !compareQuiet {
+		fmt.Fprintln(os.Stderr)
+	}
+	if result == nil {
+		log.Fatalf("compare finished without a result")
+	}
+	if result.Error != nil {
+		log.Fatalf("compare failed: %s", result.Error.Message)
+	}
+
+	var output string
+	switch compareFormat {
+	case "text":
+		output = printCompareResult(args, result)
+	case "json":
+		data, err := protojson.MarshalOptions{Multiline: true}.Marshal(result)
+		if err != nil {
+			log.Fatalf("failed to marshal result: %s", err)
+		}
+		output = string(data) + "\n"
+	default:
+		log.Fatalf("unknown output format %q", compareFormat)
+	}
+
+	if outfile == "" {
+		fmt.Print(output)
+	} else if err := os.WriteFile(outfile, []byte(output), 0666); err != nil {
+		log.Fatalf("failed to write output file:: %s", err)
+	}
+}
+
+func printCompareResult(names []string, result *proto.CompareResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Baseline: %s\n", names[0])
+	fmt.Fprintf(&sb, "%d paired iterations\n", result.Iterations)
+
+	for i, variant := range result.Variants {
+		fmt.Fprintf(&sb, "\nVariant: %s\n", names[i+1])
+		fmt.Fprintf(&sb, "%-6s %12s %12s %12s %10s %10s\n", "Metric", "Baseline", "Variant", "Delta", "95% CI", "p-value")
+		for _, metric := range []struct {
+			name  string
+			delta *proto.CompareDelta
+		}{{"DPS", variant.Dps}, {"HPS", variant.Hps}, {"TPS", variant.Tps}, {"DTPS", variant.Dtps}} {
+			// Skip metrics neither setup has, e.g. HPS of a DPS.
+			if metric.delta == nil || (metric.delta.Baseline == 0 && metric.delta.Variant == 0) {
+				continue
+			}
+			fmt.Fprintf(&sb, "%-6s %12.2f %12.2f %+12.2f %10.2f %10.4f\n", metric.name,
+				metric.delta.Baseline, metric.delta.Variant, metric.delta.Mean, metric.delta.Ci95, metric.delta.PValue)
+		}
+
+		if len(variant.Actions) > 0 && compareActions > 0 {
+			fmt.Fprintf(&sb, "\n%-24s %10s %10s %10s %10s\n", "Action", "DPS", "Casts", "Hits", "Crits")
+			for _, action := range variant.Actions[:min(compareActions, len(variant.Actions))] {
+				fmt.Fprintf(&sb, "%-24s %+10.2f %+10.2f %+10.2f %+10.2f\n", actionIDName(action.Id), action.Dps, action.Casts, action.Hits, action.Crits)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func actionIDName(id *proto.ActionID) string {
+	var name string
+	switch rawId := id.GetRawId().(type) {
+	case *proto.ActionID_SpellId:
+		name = fmt.Sprintf("spell %d", rawId.SpellId)
+	case *proto.ActionID_ItemId:
+		name = fmt.Sprintf("item %d", rawId.ItemId)
+	case *proto.ActionID_OtherId:
+		name = rawId.OtherId.String()
+	}
+	if id.GetRank() > 0 {
+		name += fmt.Sprintf(" r%d", id.Rank)
+	}
+	if id.GetTag() != 0 {
+		name += fmt.Sprintf(" (%d)", id.Tag)
+	}
+	return name
+}
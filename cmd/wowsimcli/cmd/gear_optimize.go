@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+var (
+	optimizeSettingsFile string
+	outputJson           bool
+)
+
+var gearOptimizeCmd = &cobra.Command{
+	Use:   "optimize",
+	Short: "search the item database for the best gear",
+	Long:  "search the item database for the best gear, pruning items by EP and then simming the most promising combinations",
+	Run:   gearOptimizeMain,
+}
+
+func init() {
+	addRaidSimInputFlags(gearOptimizeCmd)
+	gearOptimizeCmd.Flags().StringVar(&optimizeSettingsFile, "settings", "", "location of optimizer settings file (GearOptimizeSettings in protojson format)")
+	gearOptimizeCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	gearOptimizeCmd.Flags().BoolVar(&outputJson, "json", false, "write the GearOptimizeResult as protojson instead of a summary")
+	gearOptimizeCmd.Flags().BoolVar(&verbose, "verbose", false, "print information during runtime")
+	gearOptimizeCmd.MarkFlagRequired("settings")
+}
+
+func gearOptimizeMain(cmd *cobra.Command, args []string) {
+	input, err := readRaidSimRequest()
+	if err != nil {
+		log.Fatalf("failed to load input: %s", err)
+	}
+	settings := &proto.GearOptimizeSettings{}
+	readProtoJson(optimizeSettingsFile, settings)
+
+	progress := make(chan *proto.ProgressMetrics, 100)
+	core.RunGearOptimizeAsync(&proto.GearOptimizeRequest{
+		BaseSettings:         input,
+		GearOptimizeSettings: settings,
+	}, progress, "cmd-gear-optimize")
+
+	var result *proto.GearOptimizeResult
+	for status := range progress {
+		if status.FinalGearOptimizeResult != nil {
+			result = status.FinalGearOptimizeResult
+			break
+		}
+		if verbose {
+			fmt.Printf("Sim Progress: %d / %d sims, best %0.1f DPS\n", status.CompletedSims, status.TotalSims, status.Dps)
+		}
+	}
+	if result == nil {
+		log.Fatalf("gear optimizer finished without a result")
+	}
+	if result.Error != nil {
+		log.Fatalf("gear optimizer failed: %s", result.Error.Message)
+	}
+
+	var output string
+	if outputJson {
+		output = protojson.Format(result)
+	} else {
+		output = printGearOptimizeResult(result)
+	}
+
+	if outfile == "" {
+		fmt.Print(output)
+	} else {
+		err := os.WriteFile(outfile, []byte(output), 0666)
+		if err != nil {
+			log.Fatalf("failed to write output file:: %s", err)
+		}
+		if verbose {
+			fmt.Printf("Wrote output file: `%s` successfully.\n", outfile)
+		}
+	}
+}
+
+func readProtoJson(filename string, msg googleProto.Message) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		log.Fatalf("failed to load input json file %q: %v", filename, err)
+	}
+	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, msg)
+	if err != nil {
+		log.Fatalf("failed to load input json file %q: %s", filename, err)
+	}
+}
+
+func printGearOptimizeResult(result *proto.GearOptimizeResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Equipped gear: %0.1f DPS, %0.1f EP\n", result.EquippedGearResult.UnitMetrics.Dps.Avg, result.EquippedGearResult.Ep)
+	for i, candidate := range result.Results {
+		fmt.Fprintf(&sb, "\n#%d: %0.1f DPS (+/- %0.1f), %0.1f EP\n", i+1, candidate.UnitMetrics.Dps.Avg, candidate.UnitMetrics.Dps.Stdev, candidate.Ep)
+		if len(candidate.ItemsChanged) == 0 {
+			sb.WriteString("  (equipped gear)\n")
+		}
+		for _, changed := range candidate.ItemsChanged {
+			fmt.Fprintf(&sb, "  %-18s %s\n", changed.Slot.String(), core.ItemsByID[changed.Item.Id].Name)
+		}
+	}
+	fmt.Fprintf(&sb, "\n%d sims run\n", result.SimsRun)
+	return sb.String()
+}
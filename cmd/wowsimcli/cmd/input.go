@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	goproto "google.golang.org/protobuf/proto"
+)
+
+// Same default as the UI.
+const defaultIterations = 3000
+
+var inputLink string
+
+// Adds the --infile and --link flags used by readRaidSimRequest.
+func addRaidSimInputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (RaidSimRequest, IndividualSimSettings or RaidSimSettings in protojson format)")
+	cmd.Flags().StringVar(&inputLink, "link", "", "wowsims export link to use instead of an input file")
+}
+
+// Reads the RaidSimRequest from --link or --infile. Settings exported from the
+// UI are turned into a request the same way the UI does before simming.
+func readRaidSimRequest() (*proto.RaidSimRequest, error) {
+	if inputLink != "" {
+		return readRaidSimRequestLink(inputLink)
+	}
+	return readRaidSimRequestFile(infile)
+}
+
+// Reads a RaidSimRequest from a share link if input is a URL, or else a file.
+func readRaidSimRequestInput(input string) (*proto.RaidSimRequest, error) {
+	if strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://") {
+		return readRaidSimRequestLink(input)
+	}
+	return readRaidSimRequestFile(input)
+}
+
+func readRaidSimRequestLink(link string) (*proto.RaidSimRequest, error) {
+	settings, err := decodeLinkSettings(link)
+	if err != nil {
+		return nil, err
+	}
+	return raidSimRequestFromSettings(settings)
+}
+
+func readRaidSimRequestFile(path string) (*proto.RaidSimRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load input json file %q: %w", path, err)
+	}
+
+	// The messages only share a few fields, so strict parsing tells them apart.
+	for _, msg := range []goproto.Message{&proto.RaidSimRequest{}, &proto.IndividualSimSettings{}, &proto.RaidSimSettings{}} {
+		if protojson.Unmarshal(data, msg) == nil {
+			return raidSimRequestFromSettings(msg)
+		}
+	}
+
+	// Requests from older versions may have fields which have since been removed.
+	request := &proto.RaidSimRequest{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, request); err != nil {
+		return nil, fmt.Errorf("failed to load input json file %q: %w", path, err)
+	}
+	return request, nil
+}
+
+func raidSimRequestFromSettings(settings goproto.Message) (*proto.RaidSimRequest, error) {
+	switch settings := settings.(type) {
+	case *proto.RaidSimRequest:
+		return settings, nil
+	case *proto.IndividualSimSettings:
+		return raidSimRequestFromIndividualSettings(settings)
+	case *proto.RaidSimSettings:
+		return raidSimRequestFromRaidSettings(settings)
+	}
+	return nil, fmt.Errorf("unsupported settings type %T", settings)
+}
+
+func raidSimRequestFromIndividualSettings(settings *proto.IndividualSimSettings) (*proto.RaidSimRequest, error) {
+	if settings.Player == nil {
+		return nil, errors.New("settings have no player")
+	}
+
+	raid := core.SinglePlayerRaidProto(settings.Player, settings.PartyBuffs, settings.RaidBuffs, settings.Debuffs)
+	raid.Tanks = settings.Tanks
+	raid.TargetDummies = settings.TargetDummies
+
+	return &proto.RaidSimRequest{
+		Raid:       raid,
+		Encounter:  settings.Encounter,
+		SimOptions: simOptionsFromSettings(settings.Settings),
+	}, nil
+}
+
+func raidSimRequestFromRaidSettings(settings *proto.RaidSimSettings) (*proto.RaidSimRequest, error) {
+	if settings.Raid == nil {
+		return nil, errors.New("settings have no raid")
+	}
+
+	raid := goproto.Clone(settings.Raid).(*proto.Raid)
+	applyBlessings(raid, settings.Blessings)
+
+	return &proto.RaidSimRequest{
+		Raid:       raid,
+		Encounter:  settings.Encounter,
+		SimOptions: simOptionsFromSettings(settings.Settings),
+	}, nil
+}
+
+func simOptionsFromSettings(settings *proto.SimSettings) *proto.SimOptions {
+	options := &proto.SimOptions{
+		Iterations:          settings.GetIterations(),
+		RandomSeed:          settings.GetFixedRngSeed(),
+		DebugFirstIteration: true,
+	}
+	if options.Iterations <= 0 {
+		options.Iterations = defaultIterations
+	}
+	if options.RandomSeed == 0 {
+		options.RandomSeed = rand.Int63()
+	}
+	return options
+}
+
+// Gives players the blessings assigned to their spec, for as many paladins as
+// there are in the active parties. Same as the raid sim UI.
+func applyBlessings(raid *proto.Raid, assignments *proto.BlessingsAssignments) {
+	numActiveParties := int(raid.NumActiveParties)
+	if numActiveParties == 0 {
+		numActiveParties = len(raid.Parties)
+	}
+
+	var players []*proto.Player
+	numPaladins := 0
+	for i, party := range raid.Parties {
+		if i >= numActiveParties {
+			break
+		}
+		for _, player := range party.Players {
+			if player == nil || player.Class == proto.Class_ClassUnknown {
+				continue
+			}
+			players = append(players, player)
+			if player.Class == proto.Class_ClassPaladin {
+				numPaladins++
+			}
+		}
+	}
+
+	for i, paladin := range assignments.GetPaladins() {
+		if i >= numPaladins {
+			break
+		}
+		for _, player := range players {
+			spec := int(core.PlayerProtoToSpec(player))
+			if spec >= len(paladin.Blessings) {
+				continue
+			}
+			if player.Buffs == nil {
+				player.Buffs = &proto.IndividualBuffs{}
+			}
+
+			switch paladin.Blessings[spec] {
+			case proto.Blessings_BlessingOfKings:
+				player.Buffs.BlessingOfKings = true
+			case proto.Blessings_BlessingOfMight:
+				player.Buffs.BlessingOfMight = proto.TristateEffect_TristateEffectImproved
+			case proto.Blessings_BlessingOfWisdom:
+				player.Buffs.BlessingOfWisdom = proto.TristateEffect_TristateEffectImproved
+			case proto.Blessings_BlessingOfSanctuary:
+				player.Buffs.BlessingOfSanctuary = true
+			}
+		}
+	}
+}
@@ -18,6 +18,13 @@ func Execute(version string) {
 	rootCmd.AddCommand(simCmd)
 	rootCmd.AddCommand(bulkCmd)
 	rootCmd.AddCommand(decodeLinkCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(gearOptimizeCmd)
+	rootCmd.AddCommand(workerCmd)
+	rootCmd.AddCommand(statWeightsCmd)
+	rootCmd.AddCommand(sweepCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(aplCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
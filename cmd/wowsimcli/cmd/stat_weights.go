@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	statWeightsLink       string
+	statWeightsStats      string
+	statWeightsRefStat    string
+	statWeightsMetric     string
+	statWeightsFormat     string
+	statWeightsIterations int32
+	statWeightsQuiet      bool
+)
+
+var statWeightsCmd = &cobra.Command{
+	Use:   "statweights",
+	Short: "calculate stat weights and EP values",
+	Long:  "calculate stat weights and EP values for a single player, with a Pawn import string",
+	Run:   statWeightsMain,
+}
+
+func init() {
+	statWeightsCmd.Flags().StringVar(&infile, "infile", "", "location of input file (IndividualSimSettings or StatWeightsRequest in protojson format)")
+	statWeightsCmd.Flags().StringVar(&statWeightsLink, "link", "", "wowsims export link to use instead of an input file")
+	statWeightsCmd.Flags().StringVar(&statWeightsStats, "stats", "", "comma separated stats to weigh, e.g. 'strength,agility,attackpower,meleehit,meleecrit'. Defaults to the stats with EP weights in the settings")
+	statWeightsCmd.Flags().StringVar(&statWeightsRefStat, "ref", "", "stat the EP values are relative to. Defaults to the DPS reference stat of the settings")
+	statWeightsCmd.Flags().StringVar(&statWeightsMetric, "metric", "dps", "metric to print weights for: dps, hps, tps or dtps")
+	statWeightsCmd.Flags().StringVar(&statWeightsFormat, "format", "text", "output format: text, csv, json (the whole StatWeightsResult) or pawn")
+	statWeightsCmd.Flags().Int32Var(&statWeightsIterations, "iterations", 0, "iterations per sim, defaults to the settings or 3000")
+	statWeightsCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	statWeightsCmd.Flags().BoolVar(&statWeightsQuiet, "quiet", false, "don't print a progress bar")
+	statWeightsCmd.MarkFlagsMutuallyExclusive("infile", "link")
+}
+
+func statWeightsMain(cmd *cobra.Command, args []string) {
+	request, err := loadStatWeightsRequest()
+	if err != nil {
+		log.Fatalf("failed to load input: %s", err)
+	}
+
+	progress := make(chan *proto.ProgressMetrics, 100)
+	core.StatWeightsAsync(request, progress, "cmd-stat-weights")
+
+	var result *proto.StatWeightsResult
+	for status := range progress {
+		if status.FinalWeightResult != nil {
+			result = status.FinalWeightResult
+			break
+		}
+		if !statWeightsQuiet {
+			printProgressBar(status)
+		}
+	}
+	if !statWeightsQuiet {
+		fmt.Fprintln(os.Stderr)
+	}
+	if result == nil {
+		log.Fatalf("stat weights finished without a result")
+	}
+	if result.Error != nil {
+		log.Fatalf("stat weights failed: %s", result.Error.Message)
+	}
+
+	values, err := statWeightValuesForMetric(result, statWeightsMetric)
+	if err != nil {
+		log.Fatal(err)
+	}
+	unitStats := weighedUnitStats(request)
+
+	var output string
+	switch statWeightsFormat {
+	case "text":
+		output = printStatWeights(values, unitStats) + "\n" + pawnString(request.Player, values, unitStats) + "\n"
+	case "csv":
+		output = statWeightsCSV(values, unitStats)
+	case "json":
+		output = protojson.Format(result)
+	case "pawn":
+		output = pawnString(request.Player, values, unitStats) + "\n"
+	default:
+		log.Fatalf("unknown output format %q", statWeightsFormat)
+	}
+
+	if outfile == "" {
+		fmt.Print(output)
+	} else if err := os.WriteFile(outfile, []byte(output), 0666); err != nil {
+		log.Fatalf("failed to write output file:: %s", err)
+	}
+}
+
+// Reads the request from --infile or --link, applying the other flags on top.
+func loadStatWeightsRequest() (*proto.StatWeightsRequest, error) {
+	var request *proto.StatWeightsRequest
+	var settings *proto.IndividualSimSettings
+
+	switch {
+	case statWeightsLink != "":
+		linkSettings, err := decodeLinkSettings(statWeightsLink)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if settings, ok = linkSettings.(*proto.IndividualSimSettings); !ok {
+			return nil, errors.New("stat weights need an individual sim link, not a raid sim link")
+		}
+	case infile != "":
+		data, err := os.ReadFile(infile)
+		if err != nil {
+			return nil, err
+		}
+		// The request has no fields in common with the settings besides the
+		// player, buffs and encounter, so strict parsing tells them apart.
+		request = &proto.StatWeightsRequest{}
+		if err := protojson.Unmarshal(data, request); err != nil {
+			request = nil
+			settings = &proto.IndividualSimSettings{}
+			if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, settings); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.New("either --infile or --link is required")
+	}
+
+	if settings != nil {
+		request = statWeightsRequestFromSettings(settings)
+	}
+	if request.Player == nil {
+		return nil, errors.New("input has no player")
+	}
+	if request.SimOptions == nil {
+		request.SimOptions = &proto.SimOptions{}
+	}
+	if statWeightsIterations > 0 {
+		request.SimOptions.Iterations = statWeightsIterations
+	}
+	if request.SimOptions.Iterations <= 0 {
+		request.SimOptions.Iterations = 3000
+	}
+	if request.SimOptions.RandomSeed == 0 {
+		request.SimOptions.RandomSeed = time.Now().UnixNano()
+	}
+
+	if statWeightsStats != "" {
+		request.StatsToWeigh = nil
+		request.PseudoStatsToWeigh = nil
+		for _, name := range strings.Split(statWeightsStats, ",") {
+			stat, pseudoStat, err := parseUnitStat(name)
+			if err != nil {
+				return nil, err
+			}
+			if stat != nil {
+				request.StatsToWeigh = append(request.StatsToWeigh, *stat)
+			} else {
+				request.PseudoStatsToWeigh = append(request.PseudoStatsToWeigh, *pseudoStat)
+			}
+		}
+	}
+	if len(request.StatsToWeigh) == 0 && len(request.PseudoStatsToWeigh) == 0 {
+		return nil, errors.New("no stats to weigh, pass them with --stats")
+	}
+
+	if statWeightsRefStat != "" {
+		stat, _, err := parseUnitStat(statWeightsRefStat)
+		if err != nil || stat == nil {
+			return nil, fmt.Errorf("invalid reference stat %q", statWeightsRefStat)
+		}
+		request.EpReferenceStat = *stat
+	}
+	return request, nil
+}
+
+// Builds the same request as the stat weights button of the individual sim UI.
+func statWeightsRequestFromSettings(settings *proto.IndividualSimSettings) *proto.StatWeightsRequest {
+	request := &proto.StatWeightsRequest{
+		Player:          settings.Player,
+		RaidBuffs:       settings.RaidBuffs,
+		PartyBuffs:      settings.PartyBuffs,
+		Debuffs:         settings.Debuffs,
+		Encounter:       settings.Encounter,
+		Tanks:           settings.Tanks,
+		EpReferenceStat: settings.DpsRefStat,
+		SimOptions: &proto.SimOptions{
+			Iterations: settings.GetSettings().GetIterations(),
+			RandomSeed: settings.GetSettings().GetFixedRngSeed(),
+		},
+	}
+
+	// Weigh the stats the player has EP weights for.
+	if epWeights := settings.EpWeightsStats; epWeights != nil {
+		for i, weight := range epWeights.Stats {
+			if weight != 0 {
+				request.StatsToWeigh = append(request.StatsToWeigh, proto.Stat(i))
+			}
+		}
+		for i, weight := range epWeights.PseudoStats {
+			if weight != 0 {
+				request.PseudoStatsToWeigh = append(request.PseudoStatsToWeigh, proto.PseudoStat(i))
+			}
+		}
+	}
+	return request
+}
+
+// Parses stat names like "strength", "StatStrength" or "mainhanddps".
+func parseUnitStat(name string) (*proto.Stat, *proto.PseudoStat, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for enumName, value := range proto.Stat_value {
+		if strings.ToLower(enumName) == name || strings.ToLower(strings.TrimPrefix(enumName, "Stat")) == name {
+			stat := proto.Stat(value)
+			return &stat, nil, nil
+		}
+	}
+	for enumName, value := range proto.PseudoStat_value {
+		if strings.ToLower(enumName) == name || strings.ToLower(strings.TrimPrefix(enumName, "PseudoStat")) == name {
+			pseudoStat := proto.PseudoStat(value)
+			return nil, &pseudoStat, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("unknown stat %q", name)
+}
+
+func statWeightValuesForMetric(result *proto.StatWeightsResult, metric string) (*proto.StatWeightValues, error) {
+	switch metric {
+	case "dps":
+		return result.Dps, nil
+	case "hps":
+		return result.Hps, nil
+	case "tps":
+		return result.Tps, nil
+	case "dtps":
+		return result.Dtps, nil
+	}
+	return nil, fmt.Errorf("unknown metric %q", metric)
+}
+
+// Weighed stats and the reference stat in result order, stats first and then
+// pseudo stats.
+func weighedUnitStats(request *proto.StatWeightsRequest) []stats.UnitStat {
+	var unitStats []stats.UnitStat
+	for stat := stats.Stat(0); stat < stats.Len; stat++ {
+		if proto.Stat(stat) == request.EpReferenceStat || slices.Contains(request.StatsToWeigh, proto.Stat(stat)) {
+			unitStats = append(unitStats, stats.UnitStatFromStat(stat))
+		}
+	}
+	for _, pseudoStat := range request.PseudoStatsToWeigh {
+		unitStats = append(unitStats, stats.UnitStatFromPseudoStat(pseudoStat))
+	}
+	return unitStats
+}
+
+func unitStatName(stat stats.UnitStat) string {
+	if stat.IsStat() {
+		return stats.Stat(stat.StatIdx()).StatName()
+	}
+	return strings.TrimPrefix(proto.PseudoStat(stat.PseudoStatIdx()).String(), "PseudoStat")
+}
+
+func unitStatValue(values *proto.UnitStats, stat stats.UnitStat) float64 {
+	if stat.IsStat() {
+		if idx := stat.StatIdx(); idx < len(values.GetStats()) {
+			return values.Stats[idx]
+		}
+	} else if idx := stat.PseudoStatIdx(); idx < len(values.GetPseudoStats()) {
+		return values.PseudoStats[idx]
+	}
+	return 0
+}
+
+func printStatWeights(values *proto.StatWeightValues, unitStats []stats.UnitStat) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-20s %10s %10s %10s %10s\n", "Stat", "Weight", "Stdev", "EP", "EP Stdev")
+	for _, stat := range unitStats {
+		fmt.Fprintf(&sb, "%-20s %10.3f %10.3f %10.3f %10.3f\n", unitStatName(stat),
+			unitStatValue(values.Weights, stat), unitStatValue(values.WeightsStdev, stat),
+			unitStatValue(values.EpValues, stat), unitStatValue(values.EpValuesStdev, stat))
+	}
+	return sb.String()
+}
+
+func statWeightsCSV(values *proto.StatWeightValues, unitStats []stats.UnitStat) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"stat", "weight", "weight_stdev", "ep", "ep_stdev"})
+	for _, stat := range unitStats {
+		w.Write([]string{
+			unitStatName(stat),
+			fmt.Sprintf("%.4f", unitStatValue(values.Weights, stat)),
+			fmt.Sprintf("%.4f", unitStatValue(values.WeightsStdev, stat)),
+			fmt.Sprintf("%.4f", unitStatValue(values.EpValues, stat)),
+			fmt.Sprintf("%.4f", unitStatValue(values.EpValuesStdev, stat)),
+		})
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// Same names as the Pawn EP exporter of the UI.
+var pawnStatNames = map[proto.Stat]string{
+	proto.Stat_StatStrength:          "Strength",
+	proto.Stat_StatAgility:           "Agility",
+	proto.Stat_StatStamina:           "Stamina",
+	proto.Stat_StatIntellect:         "Intellect",
+	proto.Stat_StatSpirit:            "Spirit",
+	proto.Stat_StatSpellPower:        "SpellDamage",
+	proto.Stat_StatSpellDamage:       "SpellDamage",
+	proto.Stat_StatArcanePower:       "ArcaneSpellDamage",
+	proto.Stat_StatFirePower:         "FireSpellDamage",
+	proto.Stat_StatFrostPower:        "FrostSpellDamage",
+	proto.Stat_StatHolyPower:         "HolySpellDamage",
+	proto.Stat_StatNaturePower:       "NatureSpellDamage",
+	proto.Stat_StatShadowPower:       "ShadowSpellDamage",
+	proto.Stat_StatMP5:               "Mp5",
+	proto.Stat_StatSpellHit:          "SpellHitRating",
+	proto.Stat_StatSpellCrit:         "SpellCritRating",
+	proto.Stat_StatSpellHaste:        "SpellHasteRating",
+	proto.Stat_StatSpellPenetration:  "SpellPen",
+	proto.Stat_StatAttackPower:       "Ap",
+	proto.Stat_StatMeleeHit:          "HitRating",
+	proto.Stat_StatMeleeCrit:         "CritRating",
+	proto.Stat_StatMeleeHaste:        "HasteRating",
+	proto.Stat_StatArmorPenetration:  "ArmorPenetration",
+	proto.Stat_StatExpertise:         "ExpertiseRating",
+	proto.Stat_StatMana:              "Mana",
+	proto.Stat_StatEnergy:            "Energy",
+	proto.Stat_StatRage:              "Rage",
+	proto.Stat_StatArmor:             "Armor",
+	proto.Stat_StatRangedAttackPower: "Ap",
+	proto.Stat_StatDefense:           "DefenseRating",
+	proto.Stat_StatBlock:             "BlockRating",
+	proto.Stat_StatBlockValue:        "BlockValue",
+	proto.Stat_StatDodge:             "DodgeRating",
+	proto.Stat_StatParry:             "ParryRating",
+	proto.Stat_StatResilience:        "ResilienceRating",
+	proto.Stat_StatHealth:            "Health",
+	proto.Stat_StatArcaneResistance:  "ArcaneResistance",
+	proto.Stat_StatFireResistance:    "FireResistance",
+	proto.Stat_StatFrostResistance:   "FrostResistance",
+	proto.Stat_StatNatureResistance:  "NatureResistance",
+	proto.Stat_StatShadowResistance:  "ShadowResistance",
+	proto.Stat_StatBonusArmor:        "Armor2",
+	proto.Stat_StatHealingPower:      "Healing",
+	proto.Stat_StatFeralAttackPower:  "FeralAttackPower",
+}
+
+var pawnPseudoStatNames = map[proto.PseudoStat]string{
+	proto.PseudoStat_PseudoStatMainHandDps: "MeleeDps",
+	proto.PseudoStat_PseudoStatRangedDps:   "RangedDps",
+}
+
+// Pawn import string of the EP values, in the same format as the UI exporter.
+func pawnString(player *proto.Player, values *proto.StatWeightValues, unitStats []stats.UnitStat) string {
+	var names []string
+	weights := map[string]float64{}
+	for _, stat := range unitStats {
+		var name string
+		if stat.IsStat() {
+			name = pawnStatNames[proto.Stat(stat.StatIdx())]
+		} else {
+			name = pawnPseudoStatNames[proto.PseudoStat(stat.PseudoStatIdx())]
+		}
+		weight := unitStatValue(values.EpValues, stat)
+		if name == "" || weight == 0 {
+			continue
+		}
+
+		// Stats with the same Pawn name are added together.
+		if _, ok := weights[name]; !ok {
+			names = append(names, name)
+		}
+		weights[name] += weight
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%.3f", name, weights[name])
+	}
+	return fmt.Sprintf(`( Pawn: v1: "%s WoWSims Weights": Class=%s,%s )`, specName(player), strings.TrimPrefix(player.Class.String(), "Class"), strings.Join(pairs, ","))
+}
+
+// E.g. "Elemental Shaman" for a player with the elemental_shaman spec.
+func specName(player *proto.Player) string {
+	msg := player.ProtoReflect()
+	field := msg.WhichOneof(msg.Descriptor().Oneofs().ByName("spec"))
+	if field == nil {
+		return strings.TrimPrefix(player.Class.String(), "Class")
+	}
+
+	words := strings.Split(string(field.Name()), "_")
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func printProgressBar(status *proto.ProgressMetrics) {
+	const width = 40
+	if status.TotalIterations == 0 {
+		return
+	}
+	done := float64(status.CompletedIterations) / float64(status.TotalIterations)
+	filled := min(int(done*width), width)
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %3.0f%% (%d/%d iterations)", strings.Repeat("#", filled), strings.Repeat(".", width-filled), done*100, status.CompletedIterations, status.TotalIterations)
+}
@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	goproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	sweepParams []string
+	sweepMetric string
+	sweepFormat string
+)
+
+var sweepCmd = &cobra.Command{
+	Use:   "sweep",
+	Short: "sim a grid of setting variations",
+	Long: `sim every combination of the given request field values and output the mean and 95% CI of each point.
+
+Params are a field path of the RaidSimRequest and the values to sweep, e.g.
+  --param 'encounter.duration=60..300 step 30'
+  --param 'raid.parties[0].players[0].distance_from_target=5,10,25'
+  --param 'encounter.targets[0].mob_type=MobTypeUndead,MobTypeDemon'`,
+	Run: sweepMain,
+}
+
+func init() {
+	addRaidSimInputFlags(sweepCmd)
+	sweepCmd.Flags().StringArrayVar(&sweepParams, "param", nil, "field path and values to sweep, can be repeated to sweep a grid")
+	sweepCmd.Flags().StringVar(&sweepMetric, "metric", "dps", "metric to report: dps or hps of the raid, or tps or dtps of the first player")
+	sweepCmd.Flags().StringVar(&sweepFormat, "format", "csv", "output format: csv or json")
+	sweepCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	sweepCmd.Flags().BoolVar(&verbose, "verbose", false, "print information during runtime")
+	sweepCmd.MarkFlagRequired("param")
+}
+
+// A field to sweep and the values it takes.
+type sweepParam struct {
+	path   string
+	values []string
+}
+
+type sweepPoint struct {
+	Params     map[string]string `json:"params"`
+	Mean       float64           `json:"mean"`
+	Stdev      float64           `json:"stdev"`
+	CI95       float64           `json:"ci95"`
+	Iterations int32             `json:"iterations"`
+}
+
+func sweepMain(cmd *cobra.Command, args []string) {
+	base, err := readRaidSimRequest()
+	if err != nil {
+		log.Fatalf("failed to load input: %s", err)
+	}
+
+	params := make([]sweepParam, len(sweepParams))
+	for i, arg := range sweepParams {
+		if params[i], err = parseSweepParam(arg); err != nil {
+			log.Fatalf("invalid param %q: %s", arg, err)
+		}
+	}
+
+	var points []sweepPoint
+	total := 1
+	for _, param := range params {
+		total *= len(param.values)
+	}
+	for i := 0; i < total; i++ {
+		request := goproto.Clone(base).(*proto.RaidSimRequest)
+		point := sweepPoint{Params: map[string]string{}}
+
+		// Mixed radix over the params, the last one changes fastest.
+		idx := i
+		for p := len(params) - 1; p >= 0; p-- {
+			value := params[p].values[idx%len(params[p].values)]
+			idx /= len(params[p].values)
+			if err := setFieldPath(request.ProtoReflect(), params[p].path, value); err != nil {
+				log.Fatalf("failed to set %s: %s", params[p].path, err)
+			}
+			point.Params[params[p].path] = value
+		}
+
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Point %d/%d: %v\n", i+1, total, point.Params)
+		}
+		result := runSweepPoint(request, fmt.Sprintf("cmd-sweep-%d", i))
+		if result.Error != nil {
+			log.Fatalf("sim failed at %v: %s", point.Params, result.Error.Message)
+		}
+
+		dist, err := sweepDistribution(result, sweepMetric)
+		if err != nil {
+			log.Fatal(err)
+		}
+		point.Mean = dist.Avg
+		point.Stdev = dist.Stdev
+		point.Iterations = result.IterationsDone
+		if result.IterationsDone > 0 {
+			point.CI95 = 1.96 * dist.Stdev / math.Sqrt(float64(result.IterationsDone))
+		}
+		points = append(points, point)
+	}
+
+	var output string
+	switch sweepFormat {
+	case "csv":
+		output = sweepCSV(params, points)
+	case "json":
+		data, err := json.MarshalIndent(points, "", "  ")
+		if err != nil {
+			log.Fatalf("failed to marshal results: %s", err)
+		}
+		output = string(data) + "\n"
+	default:
+		log.Fatalf("unknown output format %q", sweepFormat)
+	}
+
+	if outfile == "" {
+		fmt.Print(output)
+	} else if err := os.WriteFile(outfile, []byte(output), 0666); err != nil {
+		log.Fatalf("failed to write output file:: %s", err)
+	}
+}
+
+func runSweepPoint(request *proto.RaidSimRequest, requestId string) *proto.RaidSimResult {
+	progress := make(chan *proto.ProgressMetrics, 100)
+	core.RunRaidSimConcurrentAsync(request, progress, requestId)
+	for status := range progress {
+		if status.FinalRaidResult != nil {
+			return status.FinalRaidResult
+		}
+	}
+	return &proto.RaidSimResult{Error: &proto.ErrorOutcome{Message: "sim finished without a result"}}
+}
+
+func sweepDistribution(result *proto.RaidSimResult, metric string) (*proto.DistributionMetrics, error) {
+	switch metric {
+	case "dps":
+		return result.RaidMetrics.Dps, nil
+	case "hps":
+		return result.RaidMetrics.Hps, nil
+	case "tps", "dtps":
+		if len(result.RaidMetrics.Parties) == 0 || len(result.RaidMetrics.Parties[0].Players) == 0 {
+			return nil, errors.New("result has no players")
+		}
+		player := result.RaidMetrics.Parties[0].Players[0]
+		if metric == "tps" {
+			return player.Threat, nil
+		}
+		return player.Dtps, nil
+	}
+	return nil, fmt.Errorf("unknown metric %q", metric)
+}
+
+func sweepCSV(params []sweepParam, points []sweepPoint) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+
+	header := make([]string, 0, len(params)+4)
+	for _, param := range params {
+		header = append(header, param.path)
+	}
+	w.Write(append(header, "mean", "stdev", "ci95", "iterations"))
+
+	for _, point := range points {
+		row := make([]string, 0, len(header)+4)
+		for _, param := range params {
+			row = append(row, point.Params[param.path])
+		}
+		w.Write(append(row,
+			strconv.FormatFloat(point.Mean, 'f', 3, 64),
+			strconv.FormatFloat(point.Stdev, 'f', 3, 64),
+			strconv.FormatFloat(point.CI95, 'f', 3, 64),
+			strconv.Itoa(int(point.Iterations)),
+		))
+	}
+	w.Flush()
+	return sb.String()
+}
+
+var sweepRangeRegexp = regexp.MustCompile(`^\s*(-?[\d.]+)\s*\.\.\s*(-?[\d.]+)(?:\s*(?:step|:)\s*([\d.]+))?\s*$`)
+
+// Parses "path=from..to step n" or "path=a,b,c". Ranges default to a step of 1.
+func parseSweepParam(arg string) (sweepParam, error) {
+	path, spec, ok := strings.Cut(arg, "=")
+	if !ok {
+		return sweepParam{}, errors.New("expected path=values")
+	}
+	param := sweepParam{path: strings.TrimSpace(path)}
+
+	if match := sweepRangeRegexp.FindStringSubmatch(spec); match != nil {
+		from, err1 := strconv.ParseFloat(match[1], 64)
+		to, err2 := strconv.ParseFloat(match[2], 64)
+		step := 1.0
+		var err3 error
+		if match[3] != "" {
+			step, err3 = strconv.ParseFloat(match[3], 64)
+		}
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return sweepParam{}, err
+		}
+		if step <= 0 || to < from {
+			return sweepParam{}, errors.New("range must be increasing with a positive step")
+		}
+		// Index based, so float steps don't drift past the end.
+		for i := 0; from+float64(i)*step <= to+step*1e-9; i++ {
+			param.values = append(param.values, strconv.FormatFloat(from+float64(i)*step, 'f', -1, 64))
+		}
+	} else {
+		for _, value := range strings.Split(spec, ",") {
+			param.values = append(param.values, strings.TrimSpace(value))
+		}
+	}
+
+	if len(param.values) == 0 {
+		return sweepParam{}, errors.New("no values")
+	}
+	return param, nil
+}
+
+var fieldPathSegmentRegexp = regexp.MustCompile(`^(\w+)(?:\[(\d+)\])?$`)
+
+// Sets the field at a path like "raid.parties[0].players[0].distance_from_target"
+// to the value, parsed for the field's type. Fields can be given by their proto
+// or JSON name. Missing messages along the path are created, list elements
+// must already exist.
+func setFieldPath(msg protoreflect.Message, path string, value string) error {
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		match := fieldPathSegmentRegexp.FindStringSubmatch(segment)
+		if match == nil {
+			return fmt.Errorf("invalid path segment %q", segment)
+		}
+
+		fields := msg.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(match[1]))
+		if fd == nil {
+			fd = fields.ByJSONName(match[1])
+		}
+		if fd == nil {
+			return fmt.Errorf("%s has no field %q", msg.Descriptor().FullName(), match[1])
+		}
+		last := i == len(segments)-1
+
+		if match[2] != "" {
+			if !fd.IsList() {
+				return fmt.Errorf("%s is not a list", fd.Name())
+			}
+			idx, _ := strconv.Atoi(match[2])
+			list := msg.Mutable(fd).List()
+			if idx >= list.Len() {
+				return fmt.Errorf("%s[%d] is out of range, the list has %d elements", fd.Name(), idx, list.Len())
+			}
+
+			if last {
+				v, err := parseFieldValue(fd, value)
+				if err != nil {
+					return err
+				}
+				list.Set(idx, v)
+				return nil
+			}
+			if fd.Kind() != protoreflect.MessageKind {
+				return fmt.Errorf("%s is not a message list", fd.Name())
+			}
+			msg = list.Get(idx).Message()
+			continue
+		}
+
+		if fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("%s needs an index", fd.Name())
+		}
+		if last {
+			v, err := parseFieldValue(fd, value)
+			if err != nil {
+				return err
+			}
+			msg.Set(fd, v)
+			return nil
+		}
+		if fd.Kind() != protoreflect.MessageKind {
+			return fmt.Errorf("%s is not a message", fd.Name())
+		}
+		msg = msg.Mutable(fd).Message()
+	}
+	return nil
+}
+
+func parseFieldValue(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a value of %s", value, fd.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("can't sweep %s fields", fd.Kind())
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	goproto "google.golang.org/protobuf/proto"
+)
+
+func TestParseSweepParam(t *testing.T) {
+	cases := []struct {
+		arg      string
+		path     string
+		values   []string
+		expected string // Error substring, if the param is invalid.
+	}{
+		{"encounter.duration=60..180 step 60", "encounter.duration", []string{"60", "120", "180"}, ""},
+		{"encounter.duration=1..3", "encounter.duration", []string{"1", "2", "3"}, ""},
+		{"encounter.duration_variation=0..0.5:0.25", "encounter.duration_variation", []string{"0", "0.25", "0.5"}, ""},
+		{"encounter.duration=-2..0 step 1", "encounter.duration", []string{"-2", "-1", "0"}, ""},
+		{" encounter.targets[0].mob_type = MobTypeUndead, MobTypeDemon", "encounter.targets[0].mob_type", []string{"MobTypeUndead", "MobTypeDemon"}, ""},
+		{"encounter.duration=60", "encounter.duration", []string{"60"}, ""},
+		{"encounter.duration", "", nil, "expected path=values"},
+		{"encounter.duration=180..60", "", nil, "range must be increasing"},
+		{"encounter.duration=60..180 step 0", "", nil, "range must be increasing"},
+		{"encounter.duration=1.2.3..5", "", nil, "invalid syntax"},
+	}
+	for _, c := range cases {
+		param, err := parseSweepParam(c.arg)
+		if c.expected != "" {
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Errorf("%q: expected an error containing %q, got %v", c.arg, c.expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", c.arg, err)
+			continue
+		}
+		if param.path != c.path || !reflect.DeepEqual(param.values, c.values) {
+			t.Errorf("%q: expected %s = %v, got %s = %v", c.arg, c.path, c.values, param.path, param.values)
+		}
+	}
+}
+
+func TestSetFieldPath(t *testing.T) {
+	newRequest := func() *proto.RaidSimRequest {
+		return &proto.RaidSimRequest{
+			Raid: &proto.Raid{Parties: []*proto.Party{{Players: []*proto.Player{{Name: "Player"}}}}},
+			Encounter: &proto.Encounter{Targets: []*proto.Target{
+				{Name: "Target", Stats: []float64{1, 2, 3}},
+			}},
+		}
+	}
+
+	cases := []struct {
+		path     string
+		value    string
+		check    func(request *proto.RaidSimRequest) bool
+		expected string // Error substring, if the path or value is invalid.
+	}{
+		{"encounter.duration", "120", func(r *proto.RaidSimRequest) bool { return r.Encounter.Duration == 120 }, ""},
+		{"encounter.durationVariation", "5", func(r *proto.RaidSimRequest) bool { return r.Encounter.DurationVariation == 5 }, ""},
+		{"raid.parties[0].players[0].distance_from_target", "25", func(r *proto.RaidSimRequest) bool {
+			return r.Raid.Parties[0].Players[0].DistanceFromTarget == 25
+		}, ""},
+		{"encounter.targets[0].stats[1]", "10", func(r *proto.RaidSimRequest) bool {
+			return reflect.DeepEqual(r.Encounter.Targets[0].Stats, []float64{1, 10, 3})
+		}, ""},
+		{"encounter.targets[0].mob_type", "MobTypeUndead", func(r *proto.RaidSimRequest) bool {
+			return r.Encounter.Targets[0].MobType == proto.MobType_MobTypeUndead
+		}, ""},
+		{"encounter.targets[0].mob_type", "8", func(r *proto.RaidSimRequest) bool {
+			return r.Encounter.Targets[0].MobType == proto.MobType_MobTypeUndead
+		}, ""},
+		{"sim_options.iterations", "100", func(r *proto.RaidSimRequest) bool { return r.SimOptions.GetIterations() == 100 }, ""},
+		{"encounter.targets[0].mob_type", "MobTypeDragon", nil, "is not a value of"},
+		{"encounter.duration", "long", nil, "invalid syntax"},
+		{"encounter.length", "120", nil, "has no field"},
+		{"encounter.duration[0]", "120", nil, "is not a list"},
+		{"encounter.targets[1].level", "63", nil, "is out of range"},
+		{"encounter.duration.value", "120", nil, "is not a message"},
+		{"encounter.targets.level", "63", nil, "needs an index"},
+		{"encounter..duration", "120", nil, "invalid path segment"},
+	}
+	for _, c := range cases {
+		request := newRequest()
+		err := setFieldPath(request.ProtoReflect(), c.path, c.value)
+		if c.expected != "" {
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Errorf("%s=%s: expected an error containing %q, got %v", c.path, c.value, c.expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s=%s: unexpected error: %s", c.path, c.value, err)
+		} else if !c.check(request) {
+			t.Errorf("%s=%s: field was not set, got %v", c.path, c.value, request)
+		}
+	}
+
+	// Only the swept field changes.
+	request := newRequest()
+	if err := setFieldPath(request.ProtoReflect(), "encounter.duration", "60"); err != nil {
+		t.Fatal(err)
+	}
+	expected := newRequest()
+	expected.Encounter.Duration = 60
+	if !goproto.Equal(request, expected) {
+		t.Errorf("Expected only the duration to change, got %v", request)
+	}
+}
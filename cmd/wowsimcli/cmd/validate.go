@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/combatlog"
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	combatLogFile  string
+	logPlayerName  string
+	playerIndex    int
+	encounterIndex int
+	zThreshold     float64
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "compare a combat log against the sim",
+	Long:  "compare a WoWCombatLog.txt against a sim of the same character, flagging statistically significant differences",
+	Run:   validateMain,
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (RaidSimRequest in protojson format)")
+	validateCmd.Flags().StringVar(&combatLogFile, "log", "WoWCombatLog.txt", "location of the combat log")
+	validateCmd.Flags().StringVar(&logPlayerName, "player", "", "name of the character in the combat log")
+	validateCmd.Flags().IntVar(&playerIndex, "player-index", 0, "index of the character in the raid, counting across parties")
+	validateCmd.Flags().IntVar(&encounterIndex, "encounter", 0, "which ENCOUNTER_START/END pair in the log to use, if any")
+	validateCmd.Flags().Float64Var(&zThreshold, "zthreshold", combatlog.DefaultZThreshold, "|z| above which a difference is flagged")
+	validateCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	validateCmd.Flags().BoolVar(&verbose, "verbose", false, "print information during runtime")
+	validateCmd.MarkFlagRequired("infile")
+	validateCmd.MarkFlagRequired("log")
+	validateCmd.MarkFlagRequired("player")
+}
+
+func validateMain(cmd *cobra.Command, args []string) {
+	data, err := os.ReadFile(infile)
+	if err != nil {
+		log.Fatalf("failed to load input json file %q: %v", infile, err)
+	}
+	input := &proto.RaidSimRequest{}
+
+	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, input)
+	if err != nil {
+		log.Fatalf("failed to load input json file: %s", err)
+	}
+
+	logFile, err := os.Open(combatLogFile)
+	if err != nil {
+		log.Fatalf("failed to open combat log %q: %v", combatLogFile, err)
+	}
+	defer logFile.Close()
+
+	events, skipped, err := combatlog.Parse(logFile)
+	if err != nil {
+		log.Fatalf("failed to read combat log: %s", err)
+	}
+	if verbose {
+		fmt.Printf("Parsed %d combat log events, skipped %d lines.\n", len(events), skipped)
+	}
+
+	summary, err := combatlog.Summarize(events, logPlayerName, encounterIndex)
+	if err != nil {
+		log.Fatalf("failed to summarize combat log: %s", err)
+	}
+
+	reporter := make(chan *proto.ProgressMetrics, 10)
+	core.RunRaidSimConcurrentAsync(input, reporter, "cmd-validate")
+
+	var finalResult *proto.RaidSimResult
+	for v := range reporter {
+		if v.FinalRaidResult != nil {
+			finalResult = v.FinalRaidResult
+			break
+		}
+		if verbose {
+			fmt.Printf("Sim Progress: %d / %d\n", v.CompletedIterations, v.TotalIterations)
+		}
+	}
+	if finalResult.Error != nil {
+		log.Fatalf("sim failed: %s", finalResult.Error.Message)
+	}
+
+	var players []*proto.UnitMetrics
+	for _, party := range finalResult.RaidMetrics.Parties {
+		players = append(players, party.Players...)
+	}
+	if playerIndex < 0 || playerIndex >= len(players) {
+		log.Fatalf("player index %d out of range, raid has %d players", playerIndex, len(players))
+	}
+
+	report := combatlog.Compare(summary, players[playerIndex], finalResult.IterationsDone, finalResult.AvgIterationDuration, zThreshold)
+	output := report.String()
+
+	if outfile == "" {
+		fmt.Print(output)
+	} else {
+		err = os.WriteFile(outfile, []byte(output), 0666)
+		if err != nil {
+			log.Fatalf("failed to write output file:: %s", err)
+		}
+		if verbose {
+			fmt.Printf("Wrote output file: `%s` successfully.\n", outfile)
+		}
+	}
+}
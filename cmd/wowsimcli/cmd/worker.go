@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+var (
+	coordinatorURL string
+	workerName     string
+	workerThreads  int
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "run sims handed out by a coordinator",
+	Long:  "run sims handed out by a web server started with --coordinator, to split large sims across machines",
+	Run:   workerMain,
+}
+
+func init() {
+	hostname, _ := os.Hostname()
+	workerCmd.Flags().StringVar(&coordinatorURL, "coordinator", "http://localhost:3333", "address of the coordinator")
+	workerCmd.Flags().StringVar(&workerName, "name", hostname, "name shown on the coordinator")
+	workerCmd.Flags().IntVar(&workerThreads, "threads", runtime.NumCPU(), "number of sims to run at once")
+	workerCmd.Flags().BoolVar(&verbose, "verbose", false, "print information during runtime")
+}
+
+// The coordinator forgot about this worker, e.g. because it restarted.
+var errWorkerUnknown = errors.New("worker is not registered")
+
+// Message of the coordinator's 404 for unknown workers, see errUnknownWorker in sim/web.
+const unknownWorkerMessage = "unknown worker, register again"
+
+// Any other 404, most likely because --coordinator doesn't point at a coordinator.
+var errRouteNotFound = errors.New("route not found")
+
+type worker struct {
+	url      string
+	workerId string
+}
+
+func workerMain(cmd *cobra.Command, args []string) {
+	w := &worker{url: strings.TrimSuffix(coordinatorURL, "/")}
+	for {
+		w.register()
+		w.run()
+		log.Printf("Coordinator lost track of this worker, registering again.")
+	}
+}
+
+// Registers with the coordinator, retrying until it is reachable. Exits if the
+// URL is reachable but isn't a coordinator.
+func (w *worker) register() {
+	for {
+		resp := &proto.WorkerRegisterResponse{}
+		err := w.post("/worker/register", &proto.WorkerRegisterRequest{Name: workerName, Threads: int32(workerThreads)}, resp)
+		if err == nil {
+			w.workerId = resp.WorkerId
+			log.Printf("Registered with %s as %s, running %d sims at once.", w.url, w.workerId, workerThreads)
+			return
+		}
+		if errors.Is(err, errRouteNotFound) {
+			log.Fatalf("Failed to register with %s, is it a web server started with --coordinator? %s", w.url, err)
+		}
+		log.Printf("Failed to register with %s: %s", w.url, err)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// Runs tasks on all threads until the coordinator no longer knows this worker.
+func (w *worker) run() {
+	// Goroutines stop once the coordinator rejects this ID, so they can't carry
+	// on under a later registration.
+	workerId := w.workerId
+	lost := make(chan struct{}, workerThreads+1)
+
+	go func() {
+		for {
+			time.Sleep(10 * time.Second)
+			if err := w.post("/worker/heartbeat", &proto.WorkerTaskRequest{WorkerId: workerId}, &proto.WorkerRegisterResponse{}); errors.Is(err, errWorkerUnknown) {
+				lost <- struct{}{}
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < workerThreads; i++ {
+		go func() {
+			task := &proto.WorkerTask{}
+			for {
+				if task.TaskId == "" {
+					if err := w.post("/worker/task", &proto.WorkerTaskRequest{WorkerId: workerId}, task); err != nil {
+						if errors.Is(err, errWorkerUnknown) {
+							lost <- struct{}{}
+							return
+						}
+						log.Printf("Failed to fetch task: %s", err)
+						time.Sleep(5 * time.Second)
+					}
+					continue
+				}
+
+				if verbose {
+					fmt.Printf("Running task %s (%d iterations)\n", task.TaskId, task.Request.GetSimOptions().GetIterations())
+				}
+				result := core.RunSim(task.Request, nil, simsignals.CreateSignals())
+
+				taskResult := &proto.WorkerTaskResult{WorkerId: workerId, TaskId: task.TaskId, Result: result}
+				task = &proto.WorkerTask{}
+				for {
+					err := w.post("/worker/result", taskResult, task)
+					if err == nil {
+						break
+					}
+					if errors.Is(err, errWorkerUnknown) {
+						lost <- struct{}{}
+						return
+					}
+					log.Printf("Failed to send result of task %s: %s", taskResult.TaskId, err)
+					time.Sleep(5 * time.Second)
+				}
+			}
+		}()
+	}
+
+	<-lost
+}
+
+func (w *worker) post(route string, msg googleProto.Message, result googleProto.Message) error {
+	body, err := googleProto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(w.url+route, "application/x-protobuf", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		errorOutcome := &proto.ErrorOutcome{}
+		googleProto.Unmarshal(respBody, errorOutcome)
+		if resp.StatusCode == http.StatusNotFound {
+			if errorOutcome.Message == unknownWorkerMessage {
+				return errWorkerUnknown
+			}
+			return fmt.Errorf("%s: %w", route, errRouteNotFound)
+		}
+		return fmt.Errorf("%s returned %d: %s", route, resp.StatusCode, errorOutcome.Message)
+	}
+
+	return googleProto.Unmarshal(respBody, result)
+}
@@ -131,6 +131,84 @@ func RunBulkSimAsync(request *proto.BulkSimRequest, progress chan *proto.Progres
 	}()
 }
 
+func RunGearOptimize(request *proto.GearOptimizeRequest) *proto.GearOptimizeResult {
+	return GearOptimize(simsignals.CreateSignals(), request, nil)
+}
+
+func RunGearOptimizeAsync(request *proto.GearOptimizeRequest, progress chan *proto.ProgressMetrics, requestId string) {
+	signals, err := simsignals.RegisterWithId(requestId)
+	if err != nil {
+		progress <- &proto.ProgressMetrics{
+			FinalGearOptimizeResult: &proto.GearOptimizeResult{
+				Error: &proto.ErrorOutcome{
+					Message: "Couldn't register for signal API: " + err.Error(),
+				},
+			},
+		}
+		return
+	}
+	go func() {
+		defer simsignals.UnregisterId(requestId)
+		GearOptimize(signals, request, progress)
+	}()
+}
+
+/**
+ * Sims each request with the same seeds and compares them against the first, iteration by iteration.
+ */
+func RunCompare(request *proto.CompareRequest) *proto.CompareResult {
+	return runCompare(request, nil, simsignals.CreateSignals())
+}
+
+func RunCompareAsync(request *proto.CompareRequest, progress chan *proto.ProgressMetrics, requestId string) {
+	signals, err := simsignals.RegisterWithId(requestId)
+	if err != nil {
+		progress <- &proto.ProgressMetrics{
+			FinalCompareResult: &proto.CompareResult{
+				Error: &proto.ErrorOutcome{
+					Message: "Couldn't register for signal API: " + err.Error(),
+				},
+			},
+		}
+		return
+	}
+	go func() {
+		defer simsignals.UnregisterId(requestId)
+		result := runCompare(request, progress, signals)
+		progress <- &proto.ProgressMetrics{
+			FinalCompareResult: result,
+		}
+	}()
+}
+
+/**
+ * Searches the tunable consts of the first player's APL rotation for the values with the highest DPS.
+ */
+func RunAPLTune(request *proto.APLTuneRequest) *proto.APLTuneResult {
+	return runAPLTune(request, nil, simsignals.CreateSignals())
+}
+
+func RunAPLTuneAsync(request *proto.APLTuneRequest, progress chan *proto.ProgressMetrics, requestId string) {
+	signals, err := simsignals.RegisterWithId(requestId)
+	if err != nil {
+		progress <- &proto.ProgressMetrics{
+			FinalAplTuneResult: &proto.APLTuneResult{
+				Error: &proto.ErrorOutcome{
+					Message: "Couldn't register for signal API: " + err.Error(),
+				},
+			},
+		}
+		return
+	}
+	go func() {
+		defer simsignals.UnregisterId(requestId)
+		result := runAPLTune(request, progress, signals)
+		progress <- &proto.ProgressMetrics{
+			FinalAplTuneResult: result,
+		}
+	}()
+}
+
 var runningInWasm = false
 
 func SetRunningInWasm() {
@@ -13,6 +13,13 @@ type APLRotation struct {
 	unit           *Unit
 	prepullActions []*APLAction
 	priorityList   []*APLAction
+	actionLists    []*APLActionList
+
+	// User variables by name, set by Variable actions.
+	variables map[string]*aplVariable
+	// Changes made by Variable actions during the current search, which are
+	// only kept if the action it finds is executed.
+	variableChanges []aplVariableChange
 
 	// Action currently controlling this rotation (only used for certain actions, such as StrictSequence).
 	controllingActions []APLActionImpl
@@ -34,9 +41,33 @@ type APLRotation struct {
 
 	// Validation warnings that occur during proto parsing.
 	// We return these back to the user for display in the UI.
-	curWarnings          []string
-	prepullWarnings      [][]string
-	priorityListWarnings [][]string
+	curWarnings            []string
+	prepullWarnings        [][]string
+	priorityListWarnings   [][]string
+	actionListWarnings     [][]string
+	actionListItemWarnings [][][]string
+
+	// Findings of the static analysis in lint(), indexed like the warnings.
+	prepullLints        [][]string
+	priorityListLints   [][]string
+	actionListItemLints [][][]string
+
+	// Index of each prepull and priority list action in the config.
+	prepullIdxs      []int
+	priorityListIdxs []int
+
+	// Only set when profiling, see enableProfiling.
+	profile *aplProfile
+}
+
+// A named list of actions, searched by Call Action List and Run Action List.
+type APLActionList struct {
+	name    string
+	actions []*APLAction
+
+	// Index of the list and of each of its actions in the config, for warnings.
+	configIdx  int
+	configIdxs []int
 }
 
 func (rot *APLRotation) ValidationWarning(message string, vals ...interface{}) {
@@ -75,9 +106,29 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 	}
 
 	rotation := &APLRotation{
-		unit:                 unit,
-		prepullWarnings:      make([][]string, len(config.PrepullActions)),
-		priorityListWarnings: make([][]string, len(config.PriorityList)),
+		unit:                   unit,
+		variables:              make(map[string]*aplVariable),
+		prepullWarnings:        make([][]string, len(config.PrepullActions)),
+		priorityListWarnings:   make([][]string, len(config.PriorityList)),
+		actionListWarnings:     make([][]string, len(config.ActionLists)),
+		actionListItemWarnings: make([][][]string, len(config.ActionLists)),
+	}
+
+	// Declare action lists before parsing any actions, so calls can refer to
+	// lists defined after them.
+	lists := make([]*APLActionList, len(config.ActionLists))
+	for i, listConfig := range config.ActionLists {
+		rotation.actionListItemWarnings[i] = make([][]string, len(listConfig.Items))
+		rotation.doAndRecordWarnings(&rotation.actionListWarnings[i], false, func() {
+			if listConfig.Name == "" {
+				rotation.ValidationWarning("Action list must have a name")
+			} else if rotation.getActionList(listConfig.Name) != nil {
+				rotation.ValidationWarning("Duplicate action list name: '%s'", listConfig.Name)
+			} else {
+				lists[i] = &APLActionList{name: listConfig.Name, configIdx: i}
+				rotation.actionLists = append(rotation.actionLists, lists[i])
+			}
+		})
 	}
 
 	// Parse prepull actions
@@ -94,6 +145,7 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 						action := rotation.newAPLAction(prepullItem.Action)
 						if action != nil {
 							rotation.prepullActions = append(rotation.prepullActions, action)
+							rotation.prepullIdxs = append(rotation.prepullIdxs, prepullIdx)
 							unit.RegisterPrepullAction(doAt, func(sim *Simulation) {
 								// Warnings for prepull cast failure are detected by running a fake prepull,
 								// so this action.Execute needs to record warnings.
@@ -108,20 +160,39 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 		})
 	}
 
+	// Parse action lists
+	for i, listConfig := range config.ActionLists {
+		if lists[i] == nil {
+			continue
+		}
+		for j, aplItem := range listConfig.Items {
+			rotation.doAndRecordWarnings(&rotation.actionListItemWarnings[i][j], false, func() {
+				if !aplItem.Hide {
+					action := rotation.newAPLAction(aplItem.Action)
+					if action != nil {
+						lists[i].actions = append(lists[i].actions, action)
+						lists[i].configIdxs = append(lists[i].configIdxs, j)
+					}
+				}
+			})
+		}
+	}
+
 	// Parse priority list
-	var configIdxs []int
 	for i, aplItem := range config.PriorityList {
 		rotation.doAndRecordWarnings(&rotation.priorityListWarnings[i], false, func() {
 			if !aplItem.Hide {
 				action := rotation.newAPLAction(aplItem.Action)
 				if action != nil {
 					rotation.priorityList = append(rotation.priorityList, action)
-					configIdxs = append(configIdxs, i)
+					rotation.priorityListIdxs = append(rotation.priorityListIdxs, i)
 				}
 			}
 		})
 	}
 
+	rotation.removeActionListCycles()
+
 	// Finalize
 	for i, action := range rotation.prepullActions {
 		rotation.doAndRecordWarnings(&rotation.prepullWarnings[i], true, func() {
@@ -133,6 +204,16 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 			action.Finalize(rotation)
 		})
 	}
+	for i, list := range lists {
+		if list == nil {
+			continue
+		}
+		for j, action := range list.actions {
+			rotation.doAndRecordWarnings(&rotation.actionListItemWarnings[i][list.configIdxs[j]], false, func() {
+				action.Finalize(rotation)
+			})
+		}
+	}
 
 	// Remove MCDs that are referenced by APL actions, so that the Autocast Other Cooldowns
 	// action does not include them.
@@ -149,15 +230,46 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 	return rotation
 }
 func (rot *APLRotation) getStats() *proto.APLStats {
-	return &proto.APLStats{
+	stats := &proto.APLStats{
 		PrepullActions: MapSlice(rot.prepullWarnings, func(warnings []string) *proto.APLActionStats { return &proto.APLActionStats{Warnings: warnings} }),
 		PriorityList:   MapSlice(rot.priorityListWarnings, func(warnings []string) *proto.APLActionStats { return &proto.APLActionStats{Warnings: warnings} }),
+		ActionLists: MapSlice(rot.actionListWarnings, func(warnings []string) *proto.APLActionListStats {
+			return &proto.APLActionListStats{Warnings: warnings}
+		}),
 	}
+	for i, itemWarnings := range rot.actionListItemWarnings {
+		stats.ActionLists[i].Items = MapSlice(itemWarnings, func(warnings []string) *proto.APLActionStats { return &proto.APLActionStats{Warnings: warnings} })
+	}
+	for i, itemLints := range rot.actionListItemLints {
+		for j, lints := range itemLints {
+			stats.ActionLists[i].Items[j].Lints = lints
+		}
+	}
+	for i, lints := range rot.prepullLints {
+		stats.PrepullActions[i].Lints = lints
+	}
+	for i, lints := range rot.priorityListLints {
+		stats.PriorityList[i].Lints = lints
+	}
+	return stats
 }
 
 // Returns all action objects as an unstructured list. Used for easily finding specific actions.
 func (rot *APLRotation) allAPLActions() []*APLAction {
-	return Flatten(MapSlice(rot.priorityList, func(action *APLAction) []*APLAction { return action.GetAllActions() }))
+	actions := Flatten(MapSlice(rot.priorityList, func(action *APLAction) []*APLAction { return action.GetAllActions() }))
+	for _, list := range rot.actionLists {
+		actions = append(actions, Flatten(MapSlice(list.actions, func(action *APLAction) []*APLAction { return action.GetAllActions() }))...)
+	}
+	return actions
+}
+
+func (rot *APLRotation) getActionList(name string) *APLActionList {
+	for _, list := range rot.actionLists {
+		if list.name == name {
+			return list
+		}
+	}
+	return nil
 }
 
 // Returns all action objects from the prepull as an unstructured list. Used for easily finding specific actions.
@@ -176,6 +288,13 @@ func (rot *APLRotation) reset(sim *Simulation) {
 	for _, action := range rot.allAPLActions() {
 		action.impl.Reset(sim)
 	}
+	for _, variable := range rot.variables {
+		variable.value = 0
+	}
+	rot.variableChanges = rot.variableChanges[:0]
+	if rot.profile != nil {
+		rot.profile.iterations++
+	}
 }
 
 // We intentionally try to mimic the behavior of simc APL to avoid confusion
@@ -206,8 +325,14 @@ func (apl *APLRotation) DoNextAction(sim *Simulation) {
 			panic(fmt.Sprintf("[USER_ERROR] Infinite loop detected, current action:\n%s", nextAction))
 		}
 
+		apl.keepVariableChanges()
 		nextAction.Execute(sim)
+		if nextAction.profile != nil {
+			nextAction.profile.executions++
+		}
 	}
+	// The last search found nothing to execute.
+	apl.undoVariableChanges()
 	apl.inLoop = false
 
 	if sim.Log != nil && i == 0 {
@@ -225,13 +350,43 @@ func (apl *APLRotation) getNextAction(sim *Simulation) *APLAction {
 		return apl.controllingActions[len(apl.controllingActions)-1].GetNextAction(sim)
 	}
 
-	for _, action := range apl.priorityList {
-		if action.IsReady(sim) {
-			return action
+	nextAction, _ := apl.nextActionInList(sim, apl.priorityList)
+	return nextAction
+}
+
+// Returns the first ready action in the list. Like simc, Variable actions are
+// applied as they are passed and called lists are searched in place. Callers
+// keep or undo the variable changes, depending on whether they execute the
+// action found. The bool is true once a Run Action List was reached, which
+// also ends the search of the lists calling this one. Lists can't call each
+// other in a loop, see removeActionListCycles.
+func (apl *APLRotation) nextActionInList(sim *Simulation, actions []*APLAction) (*APLAction, bool) {
+	for _, action := range actions {
+		if action.profile != nil {
+			if !action.profile.isReady(sim, action) {
+				continue
+			}
+		} else if !action.IsReady(sim) {
+			continue
+		}
+
+		switch impl := action.impl.(type) {
+		case *APLActionVariable:
+			apl.variableChanges = append(apl.variableChanges, aplVariableChange{action: action, variable: impl.variable, value: impl.variable.value})
+			impl.Execute(sim)
+		case *APLActionCallActionList:
+			if nextAction, ran := apl.nextActionInList(sim, impl.list.actions); nextAction != nil || ran {
+				return nextAction, ran
+			}
+		case *APLActionRunActionList:
+			nextAction, _ := apl.nextActionInList(sim, impl.list.actions)
+			return nextAction, true
+		default:
+			return action, false
 		}
 	}
 
-	return nil
+	return nil, false
 }
 
 func (apl *APLRotation) pushControllingAction(ca APLActionImpl) {
@@ -263,7 +418,9 @@ func (apl *APLRotation) shouldInterruptChannel(sim *Simulation) bool {
 	}
 
 	// Allow next action to interrupt the channel, but if the action is the same action then it still needs to continue.
+	// This only looks ahead, the next action is found again when it executes.
 	nextAction := apl.getNextAction(sim)
+	apl.undoVariableChanges()
 	if nextAction == nil {
 		return false
 	}
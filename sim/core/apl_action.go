@@ -9,6 +9,12 @@ import (
 type APLAction struct {
 	condition APLValue
 	impl      APLActionImpl
+
+	// Only set for list items while profiling.
+	profile *aplActionProfile
+
+	// The config this action was parsed from, for printing.
+	config *proto.APLAction
 }
 
 func (action *APLAction) Finalize(rot *APLRotation) {
@@ -69,6 +75,9 @@ func (action *APLAction) GetAllSpells() []*Spell {
 }
 
 func (action *APLAction) String() string {
+	if action.config != nil {
+		return APLActionToText(action.config)
+	}
 	if action.condition == nil {
 		return fmt.Sprintf("ACTION = %s", action.impl)
 	} else {
@@ -124,6 +133,7 @@ func (rot *APLRotation) newAPLAction(config *proto.APLAction) *APLAction {
 	action := &APLAction{
 		condition: rot.coerceTo(rot.newAPLValue(config.Condition), proto.APLValueType_ValueTypeBool),
 		impl:      rot.newAPLActionImpl(config),
+		config:    config,
 	}
 
 	if action.impl == nil {
@@ -172,6 +182,14 @@ func (rot *APLRotation) newAPLActionImpl(config *proto.APLAction) APLActionImpl
 	case *proto.APLAction_StrictSequence:
 		return rot.newActionStrictSequence(config.GetStrictSequence())
 
+	// Variables and action lists
+	case *proto.APLAction_Variable:
+		return rot.newActionVariable(config.GetVariable())
+	case *proto.APLAction_CallActionList:
+		return rot.newActionCallActionList(config.GetCallActionList())
+	case *proto.APLAction_RunActionList:
+		return rot.newActionRunActionList(config.GetRunActionList())
+
 	// Misc
 	case *proto.APLAction_ChangeTarget:
 		return rot.newActionChangeTarget(config.GetChangeTarget())
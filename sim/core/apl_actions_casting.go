@@ -156,8 +156,9 @@ func (action *APLActionMultidot) IsReady(sim *Simulation) bool {
 			}
 		}
 	} else {
-		for i := int32(0); i < action.maxDots; i++ {
-			target := sim.Encounter.TargetUnits[i]
+		activeTargets := sim.Encounter.ActiveTargetUnits
+		for i := int32(0); i < min(action.maxDots, int32(len(activeTargets))); i++ {
+			target := activeTargets[i]
 			dot := action.spell.Dot(target)
 			if (!dot.IsActive() || dot.RemainingDuration(sim) < maxOverlap) && action.spell.CanCast(sim, target) {
 				action.nextTarget = target
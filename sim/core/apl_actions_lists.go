@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+type APLActionCallActionList struct {
+	defaultAPLActionImpl
+	rot  *APLRotation
+	list *APLActionList
+}
+
+func (rot *APLRotation) newActionCallActionList(config *proto.APLActionCallActionList) APLActionImpl {
+	list := rot.getAPLActionList(config.Name)
+	if list == nil {
+		return nil
+	}
+	return &APLActionCallActionList{
+		rot:  rot,
+		list: list,
+	}
+}
+func (action *APLActionCallActionList) IsReady(sim *Simulation) bool {
+	return true
+}
+
+// The priority list searches called lists in place, this is only used when
+// the call is executed by other actions, e.g. in a sequence.
+func (action *APLActionCallActionList) Execute(sim *Simulation) {
+	if nextAction, _ := action.rot.nextActionInList(sim, action.list.actions); nextAction != nil {
+		action.rot.keepVariableChanges()
+		nextAction.Execute(sim)
+	} else {
+		action.rot.undoVariableChanges()
+	}
+}
+func (action *APLActionCallActionList) String() string {
+	return fmt.Sprintf("Call Action List(%s)", action.list.name)
+}
+
+type APLActionRunActionList struct {
+	defaultAPLActionImpl
+	rot  *APLRotation
+	list *APLActionList
+}
+
+func (rot *APLRotation) newActionRunActionList(config *proto.APLActionRunActionList) APLActionImpl {
+	list := rot.getAPLActionList(config.Name)
+	if list == nil {
+		return nil
+	}
+	return &APLActionRunActionList{
+		rot:  rot,
+		list: list,
+	}
+}
+func (action *APLActionRunActionList) IsReady(sim *Simulation) bool {
+	return true
+}
+
+// Like Call Action List, except that the priority list never continues past it.
+func (action *APLActionRunActionList) Execute(sim *Simulation) {
+	if nextAction, _ := action.rot.nextActionInList(sim, action.list.actions); nextAction != nil {
+		action.rot.keepVariableChanges()
+		nextAction.Execute(sim)
+	} else {
+		action.rot.undoVariableChanges()
+	}
+}
+func (action *APLActionRunActionList) String() string {
+	return fmt.Sprintf("Run Action List(%s)", action.list.name)
+}
+
+func (rot *APLRotation) getAPLActionList(name string) *APLActionList {
+	if name == "" {
+		rot.ValidationWarning("Must provide an action list name")
+		return nil
+	}
+	list := rot.getActionList(name)
+	if list == nil {
+		rot.ValidationWarning("No action list with name: '%s'", name)
+	}
+	return list
+}
+
+// Disables actions which call or run action lists in a loop, including calls
+// nested in other actions such as sequences, so the priority list search always
+// ends.
+func (rot *APLRotation) removeActionListCycles() {
+	for _, list := range rot.actionLists {
+		for j := 0; j < len(list.actions); {
+			calledLists := calledActionLists(list.actions[j])
+			idx := slices.IndexFunc(calledLists, func(called *APLActionList) bool {
+				return called.calls(list, make(map[*APLActionList]bool))
+			})
+			if idx == -1 {
+				j++
+				continue
+			}
+
+			called := calledLists[idx]
+			rot.doAndRecordWarnings(&rot.actionListItemWarnings[list.configIdx][list.configIdxs[j]], false, func() {
+				if called == list {
+					rot.ValidationWarning("Action list '%s' calls itself, ignoring this action", list.name)
+				} else {
+					rot.ValidationWarning("Action list '%s' calls itself through '%s', ignoring this action", list.name, called.name)
+				}
+			})
+			list.actions = slices.Delete(list.actions, j, j+1)
+			list.configIdxs = slices.Delete(list.configIdxs, j, j+1)
+		}
+	}
+}
+
+// Returns the lists called or run by the action or any of its inner actions.
+func calledActionLists(action *APLAction) []*APLActionList {
+	var lists []*APLActionList
+	for _, a := range action.GetAllActions() {
+		switch impl := a.impl.(type) {
+		case *APLActionCallActionList:
+			lists = append(lists, impl.list)
+		case *APLActionRunActionList:
+			lists = append(lists, impl.list)
+		}
+	}
+	return lists
+}
+
+// Whether searching list can reach target, directly or through other lists.
+func (list *APLActionList) calls(target *APLActionList, visited map[*APLActionList]bool) bool {
+	if list == target {
+		return true
+	}
+	if visited[list] {
+		return false
+	}
+	visited[list] = true
+
+	for _, action := range list.actions {
+		for _, called := range calledActionLists(action) {
+			if called.calls(target, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -2,13 +2,13 @@ package core
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/wowsims/sod/sim/core/proto"
 )
 
 type APLActionSequence struct {
 	defaultAPLActionImpl
+	config     *proto.APLActionSequence
 	unit       *Unit
 	name       string
 	subactions []*APLAction
@@ -25,6 +25,7 @@ func (rot *APLRotation) newActionSequence(config *proto.APLActionSequence) APLAc
 	}
 
 	return &APLActionSequence{
+		config:     config,
 		unit:       rot.unit,
 		name:       config.Name,
 		subactions: subactions,
@@ -49,7 +50,7 @@ func (action *APLActionSequence) Execute(sim *Simulation) {
 	action.curIdx++
 }
 func (action *APLActionSequence) String() string {
-	return "Sequence(" + strings.Join(MapSlice(action.subactions, func(subaction *APLAction) string { return fmt.Sprintf("(%s)", subaction) }), "+") + ")"
+	return APLActionToText(&proto.APLAction{Action: &proto.APLAction_Sequence{Sequence: action.config}})
 }
 
 type APLActionResetSequence struct {
@@ -88,9 +89,10 @@ func (action *APLActionResetSequence) String() string {
 
 type APLActionStrictSequence struct {
 	defaultAPLActionImpl
-	unit            *Unit
-	subactions      []*APLAction
-	curIdx          int
+	config           *proto.APLActionStrictSequence
+	unit             *Unit
+	subactions       []*APLAction
+	curIdx           int
 	requiresGCDCheck bool
 
 	subactionSpells []*Spell
@@ -106,8 +108,9 @@ func (rot *APLRotation) newActionStrictSequence(config *proto.APLActionStrictSeq
 	}
 
 	return &APLActionStrictSequence{
-		unit:            rot.unit,
-		subactions:      subactions,
+		config:           config,
+		unit:             rot.unit,
+		subactions:       subactions,
 		requiresGCDCheck: false,
 	}
 }
@@ -170,5 +173,5 @@ func (action *APLActionStrictSequence) GetNextAction(sim *Simulation) *APLAction
 	}
 }
 func (action *APLActionStrictSequence) String() string {
-	return "Strict Sequence(" + strings.Join(MapSlice(action.subactions, func(subaction *APLAction) string { return fmt.Sprintf("(%s)", subaction) }), "+") + ")"
+	return APLActionToText(&proto.APLAction{Action: &proto.APLAction_StrictSequence{StrictSequence: action.config}})
 }
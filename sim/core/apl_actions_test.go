@@ -0,0 +1,193 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+type testAPLAction struct {
+	defaultAPLActionImpl
+	name string
+}
+
+func (action *testAPLAction) IsReady(sim *Simulation) bool { return true }
+func (action *testAPLAction) Execute(sim *Simulation)      {}
+func (action *testAPLAction) String() string               { return action.name }
+
+func constAPLValue(val string) *proto.APLValue {
+	return &proto.APLValue{Value: &proto.APLValue_Const{Const: &proto.APLValueConst{Val: val}}}
+}
+
+func TestActionVariable(t *testing.T) {
+	sim := &Simulation{}
+	rot := &APLRotation{
+		unit:      &Unit{},
+		variables: make(map[string]*aplVariable),
+	}
+
+	value := rot.newValueVariable(&proto.APLValueVariable{Name: "x"})
+	newOp := func(op proto.APLActionVariable_Operation, val string) APLActionImpl {
+		return rot.newActionVariable(&proto.APLActionVariable{Name: "x", Op: op, Value: constAPLValue(val)})
+	}
+
+	for _, step := range []struct {
+		action   APLActionImpl
+		expected float64
+	}{
+		{newOp(proto.APLActionVariable_OpSet, "1.5s"), 1.5},
+		{newOp(proto.APLActionVariable_OpAdd, "2"), 3.5},
+		{newOp(proto.APLActionVariable_OpMul, "2"), 7},
+		{newOp(proto.APLActionVariable_OpDiv, "0"), 7},
+		{newOp(proto.APLActionVariable_OpMin, "5"), 5},
+		{newOp(proto.APLActionVariable_OpReset, ""), 0},
+	} {
+		step.action.Execute(sim)
+		if value.GetFloat(sim) != step.expected {
+			t.Fatalf("After %s expected %f, got %f", step.action, step.expected, value.GetFloat(sim))
+		}
+	}
+
+	if rot.newActionVariable(&proto.APLActionVariable{Name: "x", Op: proto.APLActionVariable_OpSet}) != nil {
+		t.Fatalf("Expected no action for a variable without a value")
+	}
+}
+
+func TestActionLists(t *testing.T) {
+	sim := &Simulation{}
+	rot := &APLRotation{
+		unit:      &Unit{},
+		variables: make(map[string]*aplVariable),
+	}
+	aoe := &APLActionList{name: "aoe"}
+	single := &APLActionList{name: "single"}
+	rot.actionLists = []*APLActionList{aoe, single}
+
+	skipped := rot.newValueConst(&proto.APLValueConst{Val: "false"})
+	aoe.actions = []*APLAction{{condition: skipped, impl: &testAPLAction{name: "aoe spell"}}}
+	single.actions = []*APLAction{{impl: &testAPLAction{name: "single spell"}}}
+
+	rot.priorityList = []*APLAction{
+		{impl: rot.newActionVariable(&proto.APLActionVariable{Name: "targets", Value: constAPLValue("3")})},
+		{impl: rot.newActionCallActionList(&proto.APLActionCallActionList{Name: "aoe"})},
+		{impl: rot.newActionRunActionList(&proto.APLActionRunActionList{Name: "single"})},
+		{impl: &testAPLAction{name: "after run"}},
+	}
+
+	// The called list has nothing ready so the search continues, and the run list decides.
+	if next := rot.getNextAction(sim); next == nil || next.impl.String() != "single spell" {
+		t.Fatalf("Expected the run list's action, got %v", next)
+	}
+	if targets := rot.getVariable("targets").value; targets != 3 {
+		t.Fatalf("Expected the variable to be set on the way, got %f", targets)
+	}
+
+	// Nothing ready in a run list means nothing to do, not the actions after it.
+	single.actions[0].condition = skipped
+	if next := rot.getNextAction(sim); next != nil {
+		t.Fatalf("Expected no action, got %s", next)
+	}
+
+	if rot.newActionCallActionList(&proto.APLActionCallActionList{Name: "missing"}) != nil {
+		t.Fatalf("Expected no action for an unknown list")
+	}
+}
+
+func TestActionVariableLookahead(t *testing.T) {
+	sim := &Simulation{}
+	rot := &APLRotation{
+		unit:      &Unit{},
+		variables: make(map[string]*aplVariable),
+	}
+	counter := rot.getVariable("counter")
+	rot.priorityList = []*APLAction{
+		{impl: rot.newActionVariable(&proto.APLActionVariable{Name: "counter", Op: proto.APLActionVariable_OpAdd, Value: constAPLValue("1")})},
+		{condition: rot.newValueConst(&proto.APLValueConst{Val: "false"}), impl: &testAPLAction{name: "skipped"}},
+	}
+
+	// Searches which find nothing to execute don't change variables.
+	for i := 0; i < 3; i++ {
+		if next := rot.getNextAction(sim); next != nil {
+			t.Fatalf("Expected no action, got %s", next)
+		}
+		if counter.value != 1 {
+			t.Fatalf("Expected the search to see the added value, got %f", counter.value)
+		}
+		rot.undoVariableChanges()
+		if counter.value != 0 {
+			t.Fatalf("Expected the change to be undone, got %f", counter.value)
+		}
+	}
+
+	rot.priorityList = append(rot.priorityList, &APLAction{impl: &testAPLAction{name: "spell"}})
+	rot.getNextAction(sim)
+	rot.keepVariableChanges()
+	rot.undoVariableChanges()
+	if counter.value != 1 {
+		t.Fatalf("Expected the change to be kept once the action executes, got %f", counter.value)
+	}
+}
+
+func TestActionListCycles(t *testing.T) {
+	rot := &APLRotation{
+		unit:                   &Unit{},
+		variables:              make(map[string]*aplVariable),
+		actionListItemWarnings: [][][]string{make([][]string, 2), make([][]string, 2)},
+	}
+	aoe := &APLActionList{name: "aoe", configIdx: 0, configIdxs: []int{0, 1}}
+	single := &APLActionList{name: "single", configIdx: 1, configIdxs: []int{0, 1}}
+	rot.actionLists = []*APLActionList{aoe, single}
+
+	aoe.actions = []*APLAction{
+		{impl: rot.newActionCallActionList(&proto.APLActionCallActionList{Name: "aoe"})},
+		{impl: rot.newActionRunActionList(&proto.APLActionRunActionList{Name: "single"})},
+	}
+	single.actions = []*APLAction{
+		{impl: rot.newActionCallActionList(&proto.APLActionCallActionList{Name: "aoe"})},
+		{impl: &testAPLAction{name: "single spell"}},
+	}
+	rot.priorityList = []*APLAction{{impl: rot.newActionCallActionList(&proto.APLActionCallActionList{Name: "aoe"})}}
+
+	rot.removeActionListCycles()
+	if len(aoe.actions) != 1 || len(rot.actionListItemWarnings[0][0]) != 1 || !strings.Contains(rot.actionListItemWarnings[0][0][0], "calls itself") {
+		t.Fatalf("Expected the list calling itself to be disabled with a warning, got %v", rot.actionListItemWarnings[0])
+	}
+	if len(single.actions) != 1 || len(rot.actionListItemWarnings[1][0]) != 1 || !strings.Contains(rot.actionListItemWarnings[1][0][0], "through 'aoe'") {
+		t.Fatalf("Expected the call back to 'aoe' to be disabled with a warning, got %v", rot.actionListItemWarnings[1])
+	}
+	if len(rot.actionListItemWarnings[0][1]) != 0 || len(rot.actionListItemWarnings[1][1]) != 0 {
+		t.Fatalf("Expected no warnings for the remaining actions")
+	}
+
+	if next := rot.getNextAction(&Simulation{}); next == nil || next.impl.String() != "single spell" {
+		t.Fatalf("Expected the search to end in the run list, got %v", next)
+	}
+}
+
+func TestActionListNestedCycles(t *testing.T) {
+	rot := &APLRotation{
+		unit:                   &Unit{},
+		variables:              make(map[string]*aplVariable),
+		actionListItemWarnings: [][][]string{make([][]string, 2)},
+	}
+	aoe := &APLActionList{name: "aoe", configIdx: 0, configIdxs: []int{0, 1}}
+	rot.actionLists = []*APLActionList{aoe}
+
+	// The sequence would execute the call, which searches the list and finds the sequence again.
+	callAoe := &APLAction{impl: rot.newActionCallActionList(&proto.APLActionCallActionList{Name: "aoe"})}
+	aoe.actions = []*APLAction{
+		{impl: &APLActionSequence{unit: rot.unit, subactions: []*APLAction{callAoe}}},
+		{impl: &testAPLAction{name: "aoe spell"}},
+	}
+	rot.priorityList = []*APLAction{{impl: rot.newActionCallActionList(&proto.APLActionCallActionList{Name: "aoe"})}}
+
+	rot.removeActionListCycles()
+	if len(aoe.actions) != 1 || len(rot.actionListItemWarnings[0][0]) != 1 || !strings.Contains(rot.actionListItemWarnings[0][0][0], "calls itself") {
+		t.Fatalf("Expected the sequence calling its own list to be disabled with a warning, got %v", rot.actionListItemWarnings[0])
+	}
+
+	if next := rot.getNextAction(&Simulation{}); next == nil || next.impl.String() != "aoe spell" {
+		t.Fatalf("Expected the search to skip the disabled sequence, got %v", next)
+	}
+}
@@ -104,6 +104,7 @@ func (action *APLActionWaitUntil) String() string {
 
 type APLActionSchedule struct {
 	defaultAPLActionImpl
+	config      *proto.APLActionSchedule
 	innerAction *APLAction
 
 	timings       []time.Duration
@@ -136,6 +137,7 @@ func (rot *APLRotation) newActionSchedule(config *proto.APLActionSchedule) APLAc
 	}
 
 	return &APLActionSchedule{
+		config:      config,
 		innerAction: innerAction,
 		timings:     timings,
 	}
@@ -158,5 +160,5 @@ func (action *APLActionSchedule) Execute(sim *Simulation) {
 }
 
 func (action *APLActionSchedule) String() string {
-	return fmt.Sprintf("Schedule(%s, %s)", action.timings, action.innerAction)
+	return APLActionToText(&proto.APLAction{Action: &proto.APLAction_Schedule{Schedule: action.config}})
 }
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// A user variable. All variables start at 0 in each iteration.
+type aplVariable struct {
+	name  string
+	value float64
+
+	// Whether any Variable action changes it.
+	assigned bool
+}
+
+func (rot *APLRotation) getVariable(name string) *aplVariable {
+	variable, ok := rot.variables[name]
+	if !ok {
+		variable = &aplVariable{name: name}
+		rot.variables[name] = variable
+	}
+	return variable
+}
+
+// The value of a variable before a Variable action changed it.
+type aplVariableChange struct {
+	action   *APLAction
+	variable *aplVariable
+	value    float64
+}
+
+// Keeps the changes of the Variable actions passed by the last search, since
+// the action it found is about to be executed.
+func (rot *APLRotation) keepVariableChanges() {
+	for _, change := range rot.variableChanges {
+		if change.action.profile != nil {
+			change.action.profile.executions++
+		}
+	}
+	rot.variableChanges = rot.variableChanges[:0]
+}
+
+// Reverts the changes of the Variable actions passed by the last search, e.g.
+// when it only looked ahead or found nothing to do.
+func (rot *APLRotation) undoVariableChanges() {
+	for i := len(rot.variableChanges) - 1; i >= 0; i-- {
+		change := rot.variableChanges[i]
+		change.variable.value = change.value
+	}
+	rot.variableChanges = rot.variableChanges[:0]
+}
+
+type APLActionVariable struct {
+	defaultAPLActionImpl
+	variable *aplVariable
+	op       proto.APLActionVariable_Operation
+	value    APLValue
+}
+
+func (rot *APLRotation) newActionVariable(config *proto.APLActionVariable) APLActionImpl {
+	if config.Name == "" {
+		rot.ValidationWarning("Variable must have a name")
+		return nil
+	}
+
+	var value APLValue
+	if config.Op != proto.APLActionVariable_OpReset {
+		value = rot.coerceTo(rot.newAPLValue(config.Value), proto.APLValueType_ValueTypeFloat)
+		if value == nil {
+			rot.ValidationWarning("Variable '%s' has no value", config.Name)
+			return nil
+		}
+	}
+
+	variable := rot.getVariable(config.Name)
+	variable.assigned = true
+	return &APLActionVariable{
+		variable: variable,
+		op:       config.Op,
+		value:    value,
+	}
+}
+func (action *APLActionVariable) GetAPLValues() []APLValue {
+	if action.value == nil {
+		return nil
+	}
+	return []APLValue{action.value}
+}
+func (action *APLActionVariable) IsReady(sim *Simulation) bool {
+	return true
+}
+
+// Variable actions don't take any time, so the priority list applies them as
+// it passes them instead of stopping there, see keepVariableChanges.
+func (action *APLActionVariable) Execute(sim *Simulation) {
+	variable := action.variable
+	switch action.op {
+	case proto.APLActionVariable_OpReset:
+		variable.value = 0
+	case proto.APLActionVariable_OpAdd:
+		variable.value += action.value.GetFloat(sim)
+	case proto.APLActionVariable_OpSub:
+		variable.value -= action.value.GetFloat(sim)
+	case proto.APLActionVariable_OpMul:
+		variable.value *= action.value.GetFloat(sim)
+	case proto.APLActionVariable_OpDiv:
+		// Dividing by 0 leaves the variable as it was.
+		if divisor := action.value.GetFloat(sim); divisor != 0 {
+			variable.value /= divisor
+		}
+	case proto.APLActionVariable_OpMin:
+		variable.value = min(variable.value, action.value.GetFloat(sim))
+	case proto.APLActionVariable_OpMax:
+		variable.value = max(variable.value, action.value.GetFloat(sim))
+	default:
+		variable.value = action.value.GetFloat(sim)
+	}
+}
+func (action *APLActionVariable) String() string {
+	if action.value == nil {
+		return fmt.Sprintf("Variable(%s %s)", action.variable.name, action.op)
+	}
+	return fmt.Sprintf("Variable(%s %s %s)", action.variable.name, action.op, action.value)
+}
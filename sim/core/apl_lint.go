@@ -0,0 +1,210 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// Static analysis of a parsed rotation. Unlike validation warnings, these
+// don't change how the rotation runs, they point out items which can be
+// removed or simplified. Results are stored like the warnings, by config index.
+// Only run for stats requests, where they are shown.
+func (rot *APLRotation) lint(config *proto.APLRotation) {
+	rot.prepullLints = make([][]string, len(config.PrepullActions))
+	rot.priorityListLints = make([][]string, len(config.PriorityList))
+	rot.actionListItemLints = make([][][]string, len(config.ActionLists))
+
+	// Re-parsing values below may warn again, those warnings are already recorded.
+	rot.doAndRecordWarnings(nil, false, func() {
+		rot.lintPrepull(config.PrepullActions)
+		rot.lintList(config.PriorityList, rot.priorityList, rot.priorityListIdxs, rot.priorityListLints)
+		for i, listConfig := range config.ActionLists {
+			rot.actionListItemLints[i] = make([][]string, len(listConfig.Items))
+		}
+		for _, list := range rot.actionLists {
+			rot.lintList(config.ActionLists[list.configIdx].Items, list.actions, list.configIdxs, rot.actionListItemLints[list.configIdx])
+		}
+	})
+}
+
+func (rot *APLRotation) lintPrepull(items []*proto.APLPrepullAction) {
+	for i, action := range rot.prepullActions {
+		idx := rot.prepullIdxs[i]
+		doAtVal := rot.newAPLValue(items[idx].DoAtValue)
+		if doAtVal == nil {
+			continue
+		}
+		doAt := doAtVal.GetDuration(nil)
+
+		var spell *Spell
+		switch impl := action.impl.(type) {
+		case *APLActionCastSpell:
+			spell = impl.spell
+		case *APLActionChannelSpell:
+			spell = impl.spell
+		default:
+			continue
+		}
+
+		if castTime := spell.Unit.ApplyCastSpeedForSpell(spell.DefaultCast.CastTime, spell); doAt+castTime > 0 {
+			rot.prepullLints[idx] = append(rot.prepullLints[idx], fmt.Sprintf("%s takes %s to cast, so starting at %s it finishes after the pull", spell.ActionID, castTime, doAt))
+		}
+	}
+}
+
+type aplCastKey struct {
+	spell  *Spell
+	target UnitReference
+}
+
+func (rot *APLRotation) lintList(items []*proto.APLListItem, actions []*APLAction, configIdxs []int, lints [][]string) {
+	actionsByIdx := make(map[int]*APLAction, len(actions))
+	for i, action := range actions {
+		actionsByIdx[configIdxs[i]] = action
+	}
+
+	// The first item which always casts a spell when it is ready, or always
+	// ends the search by running another list.
+	castBy := make(map[aplCastKey]int)
+	ranBy := -1
+
+	for i, item := range items {
+		if item.Hide {
+			continue
+		}
+		action := actionsByIdx[i]
+		if action == nil {
+			// Other actions such as potions are looked up differently.
+			if castSpell := item.Action.GetCastSpell(); castSpell != nil {
+				if actionID := ProtoToActionID(castSpell.SpellId); actionID.OtherID == proto.OtherAction_OtherActionNone && rot.unit.GetSpell(actionID) == nil {
+					lints[i] = append(lints[i], fmt.Sprintf("Never runs, the character doesn't know %s", actionID))
+				}
+			}
+			continue
+		}
+
+		if ranBy != -1 {
+			lints[i] = append(lints[i], fmt.Sprintf("Never runs, item #%d always runs another action list first", ranBy+1))
+		}
+
+		castSpell, isCastSpell := action.impl.(*APLActionCastSpell)
+		if isCastSpell {
+			if first, ok := castBy[aplCastKey{castSpell.spell, castSpell.target}]; ok {
+				lints[i] = append(lints[i], fmt.Sprintf("Never runs, item #%d casts %s whenever it is ready", first+1, castSpell.spell.ActionID))
+			}
+		}
+
+		unconditional := action.condition == nil
+		if action.condition != nil {
+			lints[i] = append(lints[i], lintCondition(action.condition)...)
+			if isTrue, ok := aplConstBool(action.condition); ok {
+				unconditional = isTrue
+			}
+		}
+
+		if unconditional {
+			switch impl := action.impl.(type) {
+			case *APLActionCastSpell:
+				key := aplCastKey{impl.spell, impl.target}
+				if _, ok := castBy[key]; !ok {
+					castBy[key] = i
+				}
+			case *APLActionRunActionList:
+				if ranBy == -1 {
+					ranBy = i
+				}
+			}
+		}
+	}
+}
+
+func lintCondition(condition APLValue) []string {
+	if isTrue, ok := aplConstBool(condition); ok {
+		if isTrue {
+			return []string{"Condition is always true, so it can be removed"}
+		}
+		return []string{"Condition is always false, so this never runs"}
+	}
+
+	var lints []string
+	unprocessed := []APLValue{condition}
+	for len(unprocessed) > 0 {
+		value := unprocessed[len(unprocessed)-1]
+		unprocessed = append(unprocessed[:len(unprocessed)-1], value.GetInnerValues()...)
+
+		var operands []APLValue
+		var redundant bool
+		var op string
+		switch value := value.(type) {
+		case *APLValueAnd:
+			operands, redundant, op = value.vals, true, "AND"
+		case *APLValueOr:
+			operands, redundant, op = value.vals, false, "OR"
+		default:
+			continue
+		}
+		for _, operand := range operands {
+			if constVal, ok := aplConstBool(operand); ok && constVal == redundant {
+				lints = append(lints, fmt.Sprintf("(%s) is always %t, so it can be removed from the %s", operand, constVal, op))
+			}
+		}
+	}
+	return lints
+}
+
+// Returns the value of a bool APLValue if it is the same in every sim, by
+// folding constant operands.
+func aplConstBool(value APLValue) (result bool, ok bool) {
+	switch value := value.(type) {
+	case *APLValueAnd:
+		return aplConstJunction(value.vals, false)
+	case *APLValueOr:
+		return aplConstJunction(value.vals, true)
+	case *APLValueNot:
+		result, ok = aplConstBool(value.val)
+		return !result, ok
+	}
+
+	if !isConstAPLValue(value) {
+		return false, false
+	}
+	// Constant operands can still fail, e.g. integer division by 0.
+	defer func() {
+		if recover() != nil {
+			result, ok = false, false
+		}
+	}()
+	return value.GetBool(nil), true
+}
+
+// And (stopAt false) or Or (stopAt true) of the values. One constant operand
+// equal to stopAt decides the result on its own.
+func aplConstJunction(vals []APLValue, stopAt bool) (bool, bool) {
+	allConst := true
+	for _, val := range vals {
+		result, ok := aplConstBool(val)
+		if ok && result == stopAt {
+			return stopAt, true
+		}
+		allConst = allConst && ok
+	}
+	return !stopAt, allConst
+}
+
+// Whether the value only depends on constants and other values which can't
+// change during the sim.
+func isConstAPLValue(value APLValue) bool {
+	switch value.(type) {
+	case *APLValueConst, *APLValueSpellIsKnown, *APLValueAuraIsKnown:
+		return true
+	case *APLValueCoerced, *APLValueCompare, *APLValueMath, *APLValueMax, *APLValueMin, *APLValueAnd, *APLValueOr, *APLValueNot:
+		for _, inner := range value.GetInnerValues() {
+			if inner == nil || !isConstAPLValue(inner) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func TestAPLConstBool(t *testing.T) {
+	rot := &APLRotation{
+		unit:      &Unit{},
+		variables: make(map[string]*aplVariable),
+	}
+	notConst := rot.newValueVariable(&proto.APLValueVariable{Name: "x"})
+	newValue := func(val string) APLValue { return rot.newValueConst(&proto.APLValueConst{Val: val}) }
+
+	cases := []struct {
+		value  APLValue
+		result bool
+		ok     bool
+	}{
+		{newValue("true"), true, true},
+		{&APLValueNot{val: newValue("true")}, false, true},
+		{&APLValueAnd{vals: []APLValue{notConst, newValue("false")}}, false, true},
+		{&APLValueOr{vals: []APLValue{notConst, newValue("true")}}, true, true},
+		{&APLValueAnd{vals: []APLValue{notConst, newValue("true")}}, false, false},
+		{&APLValueCompare{op: proto.APLValueCompare_OpLt, lhs: newValue("1s"), rhs: newValue("2s")}, true, true},
+		{&APLValueCompare{op: proto.APLValueCompare_OpLt, lhs: notConst, rhs: newValue("2")}, false, false},
+	}
+	for _, c := range cases {
+		if result, ok := aplConstBool(c.value); result != c.result || ok != c.ok {
+			t.Errorf("Expected %s to fold to (%t, %t), got (%t, %t)", c.value, c.result, c.ok, result, ok)
+		}
+	}
+}
+
+func TestAPLLintList(t *testing.T) {
+	spell := &Spell{ActionID: ActionID{SpellID: 1}}
+	rot := &APLRotation{unit: &Unit{Spellbook: []*Spell{spell}}}
+	alwaysTrue := rot.newValueConst(&proto.APLValueConst{Val: "true"})
+
+	castItem := func(spellID int32) *proto.APLListItem {
+		return &proto.APLListItem{Action: &proto.APLAction{Action: &proto.APLAction_CastSpell{CastSpell: &proto.APLActionCastSpell{
+			SpellId: &proto.ActionID{RawId: &proto.ActionID_SpellId{SpellId: spellID}},
+		}}}}
+	}
+	items := []*proto.APLListItem{castItem(1), castItem(1), castItem(2)}
+	actions := []*APLAction{
+		{condition: alwaysTrue, impl: &APLActionCastSpell{spell: spell}},
+		{impl: &APLActionCastSpell{spell: spell}},
+	}
+
+	lints := make([][]string, len(items))
+	rot.lintList(items, actions, []int{0, 1}, lints)
+
+	expected := []string{"always true", "item #1 casts", "doesn't know"}
+	for i, substr := range expected {
+		if len(lints[i]) != 1 || !strings.Contains(lints[i][0], substr) {
+			t.Errorf("Expected item %d to have a lint containing %q, got %v", i, substr, lints[i])
+		}
+	}
+}
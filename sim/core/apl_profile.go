@@ -0,0 +1,129 @@
+package core
+
+import (
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// Counts for a single APL item, totalled over all iterations.
+type aplActionProfile struct {
+	evaluations   int64
+	conditionTrue int64
+	notReady      []int64 // Indexed by proto.APLNotReadyReason.
+	executions    int64
+	evaluateTime  time.Duration
+}
+
+// Opt-in profiling of how a rotation's items are evaluated, enabled by
+// SimOptions.ProfileApl. Items are indexed like the rotation config.
+type aplProfile struct {
+	iterations   int32
+	priorityList []*aplActionProfile
+	actionLists  [][]*aplActionProfile
+}
+
+func (rot *APLRotation) enableProfiling() {
+	if rot.profile != nil {
+		return
+	}
+
+	newActionProfile := func(action *APLAction) *aplActionProfile {
+		action.profile = &aplActionProfile{notReady: make([]int64, len(proto.APLNotReadyReason_name))}
+		return action.profile
+	}
+
+	rot.profile = &aplProfile{
+		priorityList: make([]*aplActionProfile, len(rot.priorityListWarnings)),
+		actionLists:  make([][]*aplActionProfile, len(rot.actionListItemWarnings)),
+	}
+	for i, action := range rot.priorityList {
+		rot.profile.priorityList[rot.priorityListIdxs[i]] = newActionProfile(action)
+	}
+	for i, itemWarnings := range rot.actionListItemWarnings {
+		rot.profile.actionLists[i] = make([]*aplActionProfile, len(itemWarnings))
+	}
+	for _, list := range rot.actionLists {
+		for j, action := range list.actions {
+			rot.profile.actionLists[list.configIdx][list.configIdxs[j]] = newActionProfile(action)
+		}
+	}
+}
+
+// Same as action.IsReady, but records the result and why the action wasn't ready.
+func (profile *aplActionProfile) isReady(sim *Simulation, action *APLAction) bool {
+	start := time.Now()
+	profile.evaluations++
+
+	isReady := false
+	if action.condition == nil || action.condition.GetBool(sim) {
+		profile.conditionTrue++
+		if isReady = action.impl.IsReady(sim); !isReady {
+			profile.notReady[aplNotReadyReason(sim, action.impl)]++
+		}
+	}
+
+	profile.evaluateTime += time.Since(start)
+	return isReady
+}
+
+func aplNotReadyReason(sim *Simulation, impl APLActionImpl) proto.APLNotReadyReason {
+	var spell *Spell
+	var target *Unit
+	switch impl := impl.(type) {
+	case *APLActionCastSpell:
+		spell, target = impl.spell, impl.target.Get()
+		if impl.spell.CanCast(sim, target) {
+			// Only the extra GCD check for MCDs is left.
+			return proto.APLNotReadyReason_APLNotReadyGcd
+		}
+	case *APLActionChannelSpell:
+		spell, target = impl.spell, impl.target.Get()
+	case *APLActionMultidot:
+		spell = impl.spell
+	case *APLActionMultishield:
+		spell = impl.spell
+	default:
+		spell = impl.GetSpellFromAction()
+	}
+
+	if spell == nil {
+		return proto.APLNotReadyReason_APLNotReadyOther
+	}
+	if target == nil {
+		target = spell.Unit.CurrentTarget
+	}
+	return spell.cantCastReason(sim, target)
+}
+
+func (profile *aplActionProfile) toProto() *proto.APLActionProfile {
+	if profile == nil {
+		return &proto.APLActionProfile{}
+	}
+
+	result := &proto.APLActionProfile{
+		Evaluations:       profile.evaluations,
+		ConditionTrue:     profile.conditionTrue,
+		Executions:        profile.executions,
+		EvaluationSeconds: profile.evaluateTime.Seconds(),
+	}
+	for reason, count := range profile.notReady {
+		if count > 0 {
+			result.NotReady = append(result.NotReady, &proto.APLNotReadyCount{
+				Reason: proto.APLNotReadyReason(reason),
+				Count:  count,
+			})
+		}
+	}
+	return result
+}
+
+func (profile *aplProfile) toProto() *proto.APLProfile {
+	return &proto.APLProfile{
+		PriorityList: MapSlice(profile.priorityList, (*aplActionProfile).toProto),
+		ActionLists: MapSlice(profile.actionLists, func(items []*aplActionProfile) *proto.APLActionListProfile {
+			return &proto.APLActionListProfile{Items: MapSlice(items, (*aplActionProfile).toProto)}
+		}),
+		Iterations: profile.iterations,
+	}
+}
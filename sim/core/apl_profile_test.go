@@ -0,0 +1,54 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+type notReadyAPLAction struct {
+	testAPLAction
+}
+
+func (action *notReadyAPLAction) IsReady(sim *Simulation) bool { return false }
+
+func TestAPLProfile(t *testing.T) {
+	sim := &Simulation{}
+	rot := &APLRotation{
+		unit:      &Unit{},
+		variables: make(map[string]*aplVariable),
+	}
+	skipped := rot.newValueConst(&proto.APLValueConst{Val: "false"})
+
+	// The second config item is hidden, so it has no action.
+	rot.priorityListWarnings = make([][]string, 4)
+	rot.priorityList = []*APLAction{
+		{condition: skipped, impl: &testAPLAction{name: "skipped"}},
+		{impl: &notReadyAPLAction{}},
+		{impl: &testAPLAction{name: "ready"}},
+	}
+	rot.priorityListIdxs = []int{0, 2, 3}
+	rot.enableProfiling()
+
+	for i := 0; i < 3; i++ {
+		if next := rot.getNextAction(sim); next == nil || next.impl.String() != "ready" {
+			t.Fatalf("Expected the ready action, got %v", next)
+		}
+	}
+
+	profile := rot.profile.toProto()
+	if len(profile.PriorityList) != 4 || profile.PriorityList[1].Evaluations != 0 {
+		t.Fatalf("Expected an empty profile for the hidden item, got %v", profile.PriorityList)
+	}
+	if item := profile.PriorityList[0]; item.Evaluations != 3 || item.ConditionTrue != 0 || len(item.NotReady) != 0 {
+		t.Fatalf("Expected the condition to fail 3 times, got %v", item)
+	}
+	if item := profile.PriorityList[2]; item.ConditionTrue != 3 || len(item.NotReady) != 1 || item.NotReady[0].Count != 3 || item.NotReady[0].Reason != proto.APLNotReadyReason_APLNotReadyOther {
+		t.Fatalf("Expected the action to not be ready 3 times, got %v", item)
+	}
+
+	addAPLProfile(profile, rot.profile.toProto())
+	if item := profile.PriorityList[2]; item.Evaluations != 6 || item.NotReady[0].Count != 6 {
+		t.Fatalf("Expected combined profiles to be summed, got %v", item)
+	}
+}
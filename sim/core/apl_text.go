@@ -0,0 +1,834 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// A compact text syntax for APL rotations, in the style of SimulationCraft
+// action lists. Each line adds one item to a list:
+//
+//	actions.precombat+=/cast_spell,spell_id=25292,do_at=-1.5s
+//	actions=cast_spell,spell_id=25292,if=aura_remaining_time(aura_id=123)<3s&current_mana_percent>20%
+//	actions+=/call_action_list,name=aoe,if=number_targets>=3
+//	actions.aoe+=/cast_spell,spell_id=2,hide=true,notes="Too expensive"
+//
+// Actions and values are named after their proto fields, and their options
+// are the fields of the action or value message. Values can be combined with
+// ! & | = != < <= > >= + - * /, and other messages are written as (field=value,...).
+// "_" is an action or value with nothing selected. Lines starting with # are
+// comments.
+//
+// The text round-trips with the prepull actions, priority list and action
+// lists of an APLRotation. Other rotation types aren't represented.
+
+const (
+	aplTextPrepull = "precombat"
+)
+
+var (
+	aplValueDesc  = (&proto.APLValue{}).ProtoReflect().Descriptor()
+	aplActionDesc = (&proto.APLAction{}).ProtoReflect().Descriptor()
+	actionIDDesc  = (&proto.ActionID{}).ProtoReflect().Descriptor()
+
+	aplListItemDesc = (&proto.APLListItem{}).ProtoReflect().Descriptor()
+
+	aplTextIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// Consts which read back as a single number token, such as 3, 1.5s or 20%.
+	aplTextNumberRe = regexp.MustCompile(`^-?[0-9][0-9A-Za-z.%]*$`)
+
+	aplTextCompareOps = map[proto.APLValueCompare_ComparisonOperator]string{
+		proto.APLValueCompare_OpEq: "=",
+		proto.APLValueCompare_OpNe: "!=",
+		proto.APLValueCompare_OpLt: "<",
+		proto.APLValueCompare_OpLe: "<=",
+		proto.APLValueCompare_OpGt: ">",
+		proto.APLValueCompare_OpGe: ">=",
+	}
+	aplTextMathOps = map[proto.APLValueMath_MathOperator]string{
+		proto.APLValueMath_OpAdd: "+",
+		proto.APLValueMath_OpSub: "-",
+		proto.APLValueMath_OpMul: "*",
+		proto.APLValueMath_OpDiv: "/",
+	}
+)
+
+// Operator precedence, lowest first. Operands which bind less tightly than
+// their position allows are put in parentheses.
+const (
+	aplLevelOr = iota + 1
+	aplLevelAnd
+	aplLevelNot
+	aplLevelCompare
+	aplLevelSum
+	aplLevelProduct
+	aplLevelPrimary
+)
+
+// Returns the text syntax for the rotation's prepull actions, priority list and action lists.
+func APLRotationToText(rotation *proto.APLRotation) (string, error) {
+	var lines []string
+	// Lists are separated by a blank line.
+	addItems := func(list string, items []string) {
+		if len(items) > 0 && len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		for i, item := range items {
+			if i == 0 && item != "" {
+				lines = append(lines, list+"="+item)
+			} else {
+				lines = append(lines, list+"+=/"+item)
+			}
+		}
+	}
+
+	addItems("actions."+aplTextPrepull, MapSlice(rotation.PrepullActions, func(item *proto.APLPrepullAction) string {
+		return aplItemText(item.Action, item.DoAtValue, item.Hide, "")
+	}))
+	addItems("actions", MapSlice(rotation.PriorityList, func(item *proto.APLListItem) string {
+		return aplItemText(item.Action, nil, item.Hide, item.Notes)
+	}))
+
+	names := make(map[string]bool)
+	for _, list := range rotation.ActionLists {
+		if names[list.Name] {
+			return "", fmt.Errorf("duplicate action list name: '%s'", list.Name)
+		}
+		names[list.Name] = true
+
+		name := list.Name
+		if !aplTextIdentRe.MatchString(name) || name == aplTextPrepull {
+			name = strconv.Quote(name)
+		}
+		if len(list.Items) == 0 {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, "actions."+name+"=")
+		}
+		addItems("actions."+name, MapSlice(list.Items, func(item *proto.APLListItem) string {
+			return aplItemText(item.Action, nil, item.Hide, item.Notes)
+		}))
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func aplItemText(action *proto.APLAction, doAt *proto.APLValue, hide bool, notes string) string {
+	text := ""
+	if action != nil {
+		text = APLActionToText(action)
+	}
+	if doAt != nil {
+		text += ",do_at=" + APLValueToText(doAt)
+	}
+	if hide {
+		text += ",hide=true"
+	}
+	if notes != "" {
+		text += ",notes=" + strconv.Quote(notes)
+	}
+	return text
+}
+
+// Returns the text syntax for a single action, e.g. cast_spell,spell_id=25292,if=current_mana_percent>20%.
+func APLActionToText(action *proto.APLAction) string {
+	msg := action.ProtoReflect()
+	text := "_"
+	if fd := msg.WhichOneof(aplActionDesc.Oneofs().ByName("action")); fd != nil {
+		text = string(fd.Name())
+		if fields := aplFieldsText(msg.Get(fd).Message()); fields != "" {
+			text += "," + fields
+		}
+	}
+	if action.Condition != nil {
+		text += ",if=" + APLValueToText(action.Condition)
+	}
+	return text
+}
+
+// Returns the text syntax for a value, e.g. aura_is_active(aura_id=123)&current_mana_percent>20%.
+func APLValueToText(value *proto.APLValue) string {
+	return aplValueText(value, aplLevelOr)
+}
+
+func aplValueText(value *proto.APLValue, minLevel int) string {
+	text, level := aplValueTextLevel(value)
+	if level < minLevel {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func aplValueTextLevel(value *proto.APLValue) (string, int) {
+	joinValues := func(vals []*proto.APLValue, op string, minLevel int) string {
+		return strings.Join(MapSlice(vals, func(val *proto.APLValue) string { return aplValueText(val, minLevel) }), op)
+	}
+
+	// Operators which can't be written with symbols, such as an And with one
+	// value, fall through to the generic syntax.
+	switch v := value.Value.(type) {
+	case *proto.APLValue_Const:
+		// Consts marked tunable need the generic syntax for the tuning range.
+		if v.Const != nil && v.Const.Tuning == nil {
+			if val := v.Const.Val; val == "true" || val == "false" || aplTextNumberRe.MatchString(val) {
+				return val, aplLevelPrimary
+			}
+			return strconv.Quote(v.Const.Val), aplLevelPrimary
+		}
+	case *proto.APLValue_And:
+		if v.And != nil && len(v.And.Vals) >= 2 {
+			return joinValues(v.And.Vals, "&", aplLevelNot), aplLevelAnd
+		}
+	case *proto.APLValue_Or:
+		if v.Or != nil && len(v.Or.Vals) >= 2 {
+			return joinValues(v.Or.Vals, "|", aplLevelAnd), aplLevelOr
+		}
+	case *proto.APLValue_Not:
+		if v.Not != nil && v.Not.Val != nil {
+			return "!" + aplValueText(v.Not.Val, aplLevelNot), aplLevelNot
+		}
+	case *proto.APLValue_Cmp:
+		if op, ok := aplTextCompareOps[v.Cmp.GetOp()]; ok && v.Cmp.Lhs != nil && v.Cmp.Rhs != nil {
+			return aplValueText(v.Cmp.Lhs, aplLevelSum) + op + aplValueText(v.Cmp.Rhs, aplLevelSum), aplLevelCompare
+		}
+	case *proto.APLValue_Math:
+		if op, ok := aplTextMathOps[v.Math.GetOp()]; ok && v.Math.Lhs != nil && v.Math.Rhs != nil {
+			level := aplLevelSum
+			if v.Math.Op == proto.APLValueMath_OpMul || v.Math.Op == proto.APLValueMath_OpDiv {
+				level = aplLevelProduct
+			}
+			// Operators are left associative, so only the right side needs
+			// parentheses at the same level.
+			return aplValueText(v.Math.Lhs, level) + op + aplValueText(v.Math.Rhs, level+1), level
+		}
+	}
+
+	msg := value.ProtoReflect()
+	fd := msg.WhichOneof(aplValueDesc.Oneofs().ByName("value"))
+	if fd == nil {
+		return "_", aplLevelPrimary
+	}
+	if fields := aplFieldsText(msg.Get(fd).Message()); fields != "" {
+		return string(fd.Name()) + "(" + fields + ")", aplLevelPrimary
+	}
+	return string(fd.Name()), aplLevelPrimary
+}
+
+// Returns the set fields of the message as field=value pairs. Repeated fields
+// repeat the name for each element.
+func aplFieldsText(msg protoreflect.Message) string {
+	var parts []string
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !msg.Has(fd) {
+			continue
+		}
+		if fd.IsList() {
+			list := msg.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				parts = append(parts, string(fd.Name())+"="+aplFieldValueText(fd, list.Get(j)))
+			}
+		} else {
+			parts = append(parts, string(fd.Name())+"="+aplFieldValueText(fd, msg.Get(fd)))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func aplFieldValueText(fd protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return aplMessageText(value.Message())
+	case protoreflect.EnumKind:
+		if enumValue := fd.Enum().Values().ByNumber(value.Enum()); enumValue != nil {
+			return string(enumValue.Name())
+		}
+		return strconv.Itoa(int(value.Enum()))
+	case protoreflect.StringKind:
+		if aplTextIdentRe.MatchString(value.String()) {
+			return value.String()
+		}
+		return strconv.Quote(value.String())
+	case protoreflect.BytesKind:
+		return strconv.Quote(string(value.Bytes()))
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(value.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(value.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(value.Uint(), 10)
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64)
+	}
+	panic(fmt.Sprintf("Unsupported APL field kind %s", fd.Kind()))
+}
+
+func aplMessageText(msg protoreflect.Message) string {
+	switch msg.Descriptor().FullName() {
+	case aplValueDesc.FullName():
+		return APLValueToText(msg.Interface().(*proto.APLValue))
+	case aplActionDesc.FullName():
+		return "(" + APLActionToText(msg.Interface().(*proto.APLAction)) + ")"
+	case actionIDDesc.FullName():
+		// Spell IDs are by far the most common, so they can be written as just the number.
+		id := msg.Interface().(*proto.ActionID)
+		if spellID, ok := id.RawId.(*proto.ActionID_SpellId); ok && id.Tag == 0 && id.Rank == 0 {
+			return strconv.Itoa(int(spellID.SpellId))
+		}
+	}
+	return "(" + aplFieldsText(msg) + ")"
+}
+
+// Parses rotation text written by APLRotationToText, or by hand.
+func APLRotationFromText(text string) (*proto.APLRotation, error) {
+	rotation := &proto.APLRotation{Type: proto.APLRotation_TypeAPL}
+	lists := make(map[string]*proto.APLActionList)
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parseAPLTextLine(rotation, lists, line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return rotation, nil
+}
+
+// Parses a single action, e.g. cast_spell,spell_id=25292,if=current_mana_percent>20%.
+func APLActionFromText(text string) (action *proto.APLAction, err error) {
+	p := &aplTextParser{src: text}
+	defer p.recover(&err)
+	action = p.parseAction(nil)
+	p.expectEnd()
+	return action, nil
+}
+
+// Parses a single value, e.g. aura_is_active(aura_id=123)&current_mana_percent>20%.
+func APLValueFromText(text string) (value *proto.APLValue, err error) {
+	p := &aplTextParser{src: text}
+	defer p.recover(&err)
+	value = p.parseValue()
+	p.expectEnd()
+	return value, nil
+}
+
+func parseAPLTextLine(rotation *proto.APLRotation, lists map[string]*proto.APLActionList, line string) (err error) {
+	p := &aplTextParser{src: line}
+	defer p.recover(&err)
+
+	if tok := p.next(); tok.kind != aplTokenIdent || tok.text != "actions" {
+		p.fail(tok.pos, "expected 'actions', got %s", tok)
+	}
+
+	prepull := false
+	var list *proto.APLActionList
+	if p.peek().is(".") {
+		p.next()
+		switch tok := p.next(); {
+		case tok.kind == aplTokenIdent && tok.text == aplTextPrepull:
+			prepull = true
+		case tok.kind == aplTokenIdent || tok.kind == aplTokenString:
+			list = lists[tok.text]
+			if list == nil {
+				list = &proto.APLActionList{Name: tok.text}
+				lists[tok.text] = list
+				rotation.ActionLists = append(rotation.ActionLists, list)
+			}
+		default:
+			p.fail(tok.pos, "expected a list name, got %s", tok)
+		}
+	}
+
+	// Like simc, = starts the list over and +=/ adds to it.
+	if p.peek().is("=") {
+		p.next()
+		switch {
+		case prepull:
+			rotation.PrepullActions = nil
+		case list != nil:
+			list.Items = nil
+		default:
+			rotation.PriorityList = nil
+		}
+		if p.peek().kind == aplTokenEnd {
+			return nil
+		}
+	} else {
+		p.expect("+")
+		p.expect("=")
+		p.expect("/")
+	}
+
+	var doAt *proto.APLValue
+	var hide bool
+	var notes string
+	itemOption := func(key string) bool {
+		switch {
+		case key == "do_at" && prepull:
+			doAt = p.parseValue()
+		case key == "hide":
+			hide = p.parseScalar(aplListItemDesc.Fields().ByName("hide")).Bool()
+		case key == "notes" && !prepull:
+			notes = p.parseScalar(aplListItemDesc.Fields().ByName("notes")).String()
+		default:
+			return false
+		}
+		return true
+	}
+
+	var action *proto.APLAction
+	if next := p.peek(); next.kind == aplTokenEnd || next.is(",") {
+		p.parseOptions(nil, itemOption)
+	} else {
+		action = p.parseAction(itemOption)
+	}
+	p.expectEnd()
+
+	switch {
+	case prepull:
+		rotation.PrepullActions = append(rotation.PrepullActions, &proto.APLPrepullAction{Action: action, DoAtValue: doAt, Hide: hide})
+	case list != nil:
+		list.Items = append(list.Items, &proto.APLListItem{Action: action, Hide: hide, Notes: notes})
+	default:
+		rotation.PriorityList = append(rotation.PriorityList, &proto.APLListItem{Action: action, Hide: hide, Notes: notes})
+	}
+	return nil
+}
+
+type aplTokenKind int
+
+const (
+	aplTokenEnd aplTokenKind = iota
+	aplTokenIdent
+	aplTokenNumber
+	aplTokenString
+	aplTokenPunct
+)
+
+type aplToken struct {
+	kind aplTokenKind
+	text string // Unquoted, for strings.
+	pos  int
+}
+
+func (tok aplToken) is(punct string) bool {
+	return tok.kind == aplTokenPunct && tok.text == punct
+}
+
+func (tok aplToken) String() string {
+	if tok.kind == aplTokenEnd {
+		return "end of line"
+	}
+	return strconv.Quote(tok.text)
+}
+
+type aplTextError struct {
+	err error
+}
+
+// Recursive descent parser for the text syntax. Errors panic with an
+// aplTextError, which recover() turns back into an error.
+type aplTextParser struct {
+	src string
+	pos int
+}
+
+func (p *aplTextParser) fail(pos int, format string, vals ...interface{}) {
+	panic(aplTextError{fmt.Errorf("column %d: %s", pos+1, fmt.Sprintf(format, vals...))})
+}
+
+func (p *aplTextParser) recover(err *error) {
+	if r := recover(); r != nil {
+		textErr, ok := r.(aplTextError)
+		if !ok {
+			panic(r)
+		}
+		*err = textErr.err
+	}
+}
+
+func (p *aplTextParser) scan() aplToken {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	start := p.pos
+	if start >= len(p.src) {
+		return aplToken{kind: aplTokenEnd, pos: start}
+	}
+
+	consume := func(kind aplTokenKind, valid func(c byte) bool) aplToken {
+		for p.pos < len(p.src) && valid(p.src[p.pos]) {
+			p.pos++
+		}
+		return aplToken{kind: kind, text: p.src[start:p.pos], pos: start}
+	}
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	isIdent := func(c byte) bool { return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+	c := p.src[start]
+	switch {
+	case isIdent(c) && !isDigit(c):
+		return consume(aplTokenIdent, isIdent)
+	case isDigit(c):
+		return consume(aplTokenNumber, func(c byte) bool { return isIdent(c) || c == '.' || c == '%' })
+	case c == '"':
+		quoted, err := strconv.QuotedPrefix(p.src[start:])
+		if err != nil {
+			p.fail(start, "unterminated string")
+		}
+		p.pos += len(quoted)
+		text, _ := strconv.Unquote(quoted)
+		return aplToken{kind: aplTokenString, text: text, pos: start}
+	}
+
+	for _, punct := range []string{"!=", "<=", ">=", "==", "(", ")", ",", ".", "=", "<", ">", "&", "|", "!", "+", "-", "*", "/"} {
+		if strings.HasPrefix(p.src[start:], punct) {
+			p.pos += len(punct)
+			return aplToken{kind: aplTokenPunct, text: punct, pos: start}
+		}
+	}
+	p.fail(start, "unexpected character %q", c)
+	return aplToken{}
+}
+
+func (p *aplTextParser) peek() aplToken {
+	pos := p.pos
+	tok := p.scan()
+	p.pos = pos
+	return tok
+}
+
+func (p *aplTextParser) next() aplToken {
+	return p.scan()
+}
+
+func (p *aplTextParser) expect(punct string) {
+	if tok := p.next(); !tok.is(punct) {
+		p.fail(tok.pos, "expected %q, got %s", punct, tok)
+	}
+}
+
+func (p *aplTextParser) expectEnd() {
+	if tok := p.next(); tok.kind != aplTokenEnd {
+		p.fail(tok.pos, "unexpected %s", tok)
+	}
+}
+
+func (p *aplTextParser) expectIdent() aplToken {
+	tok := p.next()
+	if tok.kind != aplTokenIdent {
+		p.fail(tok.pos, "expected a name, got %s", tok)
+	}
+	return tok
+}
+
+// Parses an action kind followed by its options. itemOption handles options
+// of the list item rather than the action, and returns false for other keys.
+func (p *aplTextParser) parseAction(itemOption func(key string) bool) *proto.APLAction {
+	action := &proto.APLAction{}
+	tok := p.expectIdent()
+	var impl protoreflect.Message
+	if tok.text != "_" {
+		fd := aplActionDesc.Oneofs().ByName("action").Fields().ByName(protoreflect.Name(tok.text))
+		if fd == nil {
+			p.fail(tok.pos, "unknown action %s", tok)
+		}
+		impl = action.ProtoReflect().Mutable(fd).Message()
+	}
+
+	p.parseOptions(func(key aplToken) {
+		switch {
+		case key.text == "if":
+			if action.Condition != nil {
+				p.fail(key.pos, "condition is set twice")
+			}
+			action.Condition = p.parseValue()
+		case impl == nil:
+			p.fail(key.pos, "unknown option %s", key)
+		default:
+			p.parseField(impl, key)
+		}
+	}, itemOption)
+	return action
+}
+
+// Parses ,key=value options until there are no more.
+func (p *aplTextParser) parseOptions(parseOption func(key aplToken), itemOption func(key string) bool) {
+	for p.peek().is(",") {
+		p.next()
+		key := p.expectIdent()
+		p.expect("=")
+		if itemOption != nil && itemOption(key.text) {
+			continue
+		}
+		if parseOption == nil {
+			p.fail(key.pos, "unknown option %s", key)
+		}
+		parseOption(key)
+	}
+}
+
+// Parses the value of the field named by key into msg.
+func (p *aplTextParser) parseField(msg protoreflect.Message, key aplToken) {
+	fd := msg.Descriptor().Fields().ByName(protoreflect.Name(key.text))
+	if fd == nil {
+		p.fail(key.pos, "unknown option %s for %s", key, msg.Descriptor().Name())
+	}
+	if fd.IsMap() {
+		p.fail(key.pos, "map fields are not supported")
+	}
+	if !fd.IsList() && msg.Has(fd) {
+		p.fail(key.pos, "%s is set twice", key)
+	}
+
+	if fd.IsList() {
+		list := msg.Mutable(fd).List()
+		if fd.Kind() == protoreflect.MessageKind {
+			list.Append(protoreflect.ValueOfMessage(p.parseMessage(list.NewElement().Message())))
+		} else {
+			list.Append(p.parseScalar(fd))
+		}
+	} else if fd.Kind() == protoreflect.MessageKind {
+		msg.Set(fd, protoreflect.ValueOfMessage(p.parseMessage(msg.NewField(fd).Message())))
+	} else {
+		msg.Set(fd, p.parseScalar(fd))
+	}
+}
+
+// Parses a message field's value. Generic messages are parsed into empty.
+func (p *aplTextParser) parseMessage(empty protoreflect.Message) protoreflect.Message {
+	switch empty.Descriptor().FullName() {
+	case aplValueDesc.FullName():
+		return p.parseValue().ProtoReflect()
+	case aplActionDesc.FullName():
+		p.expect("(")
+		action := p.parseAction(nil)
+		p.expect(")")
+		return action.ProtoReflect()
+	case actionIDDesc.FullName():
+		if p.peek().kind == aplTokenNumber {
+			tok := p.next()
+			spellID, err := strconv.ParseInt(tok.text, 10, 32)
+			if err != nil {
+				p.fail(tok.pos, "invalid spell ID %s", tok)
+			}
+			return (&proto.ActionID{RawId: &proto.ActionID_SpellId{SpellId: int32(spellID)}}).ProtoReflect()
+		}
+	}
+	p.parseFields(empty)
+	return empty
+}
+
+// Parses (key=value,...) into msg.
+func (p *aplTextParser) parseFields(msg protoreflect.Message) {
+	p.expect("(")
+	if p.peek().is(")") {
+		p.next()
+		return
+	}
+	for {
+		key := p.expectIdent()
+		p.expect("=")
+		p.parseField(msg, key)
+		if tok := p.next(); tok.is(")") {
+			return
+		} else if !tok.is(",") {
+			p.fail(tok.pos, "expected \",\" or \")\", got %s", tok)
+		}
+	}
+}
+
+func (p *aplTextParser) parseScalar(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	tok := p.next()
+	text := tok.text
+	if tok.is("-") {
+		number := p.next()
+		if number.kind != aplTokenNumber {
+			p.fail(number.pos, "expected a number, got %s", number)
+		}
+		text = "-" + number.text
+	} else if tok.kind == aplTokenPunct || tok.kind == aplTokenEnd {
+		p.fail(tok.pos, "expected a %s, got %s", fd.Kind(), tok)
+	}
+
+	var value protoreflect.Value
+	var err error
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(text)
+		value = protoreflect.ValueOfBool(b)
+	case protoreflect.EnumKind:
+		if enumValue := fd.Enum().Values().ByName(protoreflect.Name(text)); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number())
+		}
+		var n int64
+		n, err = strconv.ParseInt(text, 10, 32)
+		value = protoreflect.ValueOfEnum(protoreflect.EnumNumber(n))
+	case protoreflect.StringKind:
+		// Unquoted numbers are fine too, e.g. const(val=3s).
+		value = protoreflect.ValueOfString(text)
+	case protoreflect.BytesKind:
+		value = protoreflect.ValueOfBytes([]byte(text))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(text, 10, 32)
+		value = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var n int64
+		n, err = strconv.ParseInt(text, 10, 64)
+		value = protoreflect.ValueOfInt64(n)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var n uint64
+		n, err = strconv.ParseUint(text, 10, 32)
+		value = protoreflect.ValueOfUint32(uint32(n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var n uint64
+		n, err = strconv.ParseUint(text, 10, 64)
+		value = protoreflect.ValueOfUint64(n)
+	case protoreflect.FloatKind:
+		var f float64
+		f, err = strconv.ParseFloat(text, 32)
+		value = protoreflect.ValueOfFloat32(float32(f))
+	case protoreflect.DoubleKind:
+		var f float64
+		f, err = strconv.ParseFloat(text, 64)
+		value = protoreflect.ValueOfFloat64(f)
+	default:
+		p.fail(tok.pos, "unsupported field kind %s", fd.Kind())
+	}
+	if err != nil {
+		p.fail(tok.pos, "invalid %s %s", fd.Kind(), tok)
+	}
+	return value
+}
+
+func (p *aplTextParser) parseValue() *proto.APLValue {
+	return p.parseOr()
+}
+
+func (p *aplTextParser) parseOr() *proto.APLValue {
+	vals := []*proto.APLValue{p.parseAnd()}
+	for p.peek().is("|") {
+		p.next()
+		vals = append(vals, p.parseAnd())
+	}
+	if len(vals) == 1 {
+		return vals[0]
+	}
+	return &proto.APLValue{Value: &proto.APLValue_Or{Or: &proto.APLValueOr{Vals: vals}}}
+}
+
+func (p *aplTextParser) parseAnd() *proto.APLValue {
+	vals := []*proto.APLValue{p.parseNot()}
+	for p.peek().is("&") {
+		p.next()
+		vals = append(vals, p.parseNot())
+	}
+	if len(vals) == 1 {
+		return vals[0]
+	}
+	return &proto.APLValue{Value: &proto.APLValue_And{And: &proto.APLValueAnd{Vals: vals}}}
+}
+
+func (p *aplTextParser) parseNot() *proto.APLValue {
+	if p.peek().is("!") {
+		p.next()
+		return &proto.APLValue{Value: &proto.APLValue_Not{Not: &proto.APLValueNot{Val: p.parseNot()}}}
+	}
+	return p.parseCompare()
+}
+
+func (p *aplTextParser) parseCompare() *proto.APLValue {
+	lhs := p.parseSum()
+	next := p.peek()
+	if next.kind != aplTokenPunct {
+		return lhs
+	}
+	for op, text := range aplTextCompareOps {
+		if next.text == text || (next.text == "==" && op == proto.APLValueCompare_OpEq) {
+			p.next()
+			return &proto.APLValue{Value: &proto.APLValue_Cmp{Cmp: &proto.APLValueCompare{Op: op, Lhs: lhs, Rhs: p.parseSum()}}}
+		}
+	}
+	return lhs
+}
+
+func (p *aplTextParser) parseSum() *proto.APLValue {
+	return p.parseMath(p.parseProduct, proto.APLValueMath_OpAdd, proto.APLValueMath_OpSub)
+}
+
+func (p *aplTextParser) parseProduct() *proto.APLValue {
+	return p.parseMath(p.parsePrimary, proto.APLValueMath_OpMul, proto.APLValueMath_OpDiv)
+}
+
+// Parses a left associative chain of the given math operators.
+func (p *aplTextParser) parseMath(parseOperand func() *proto.APLValue, ops ...proto.APLValueMath_MathOperator) *proto.APLValue {
+	lhs := parseOperand()
+	for {
+		next := p.peek()
+		op := proto.APLValueMath_OpUnknown
+		for _, candidate := range ops {
+			if next.is(aplTextMathOps[candidate]) {
+				op = candidate
+			}
+		}
+		if op == proto.APLValueMath_OpUnknown {
+			return lhs
+		}
+		p.next()
+		lhs = &proto.APLValue{Value: &proto.APLValue_Math{Math: &proto.APLValueMath{Op: op, Lhs: lhs, Rhs: parseOperand()}}}
+	}
+}
+
+func (p *aplTextParser) parsePrimary() *proto.APLValue {
+	newConst := func(val string) *proto.APLValue {
+		return &proto.APLValue{Value: &proto.APLValue_Const{Const: &proto.APLValueConst{Val: val}}}
+	}
+
+	tok := p.next()
+	switch {
+	case tok.is("("):
+		value := p.parseValue()
+		p.expect(")")
+		return value
+	case tok.is("-"):
+		number := p.next()
+		if number.kind != aplTokenNumber {
+			p.fail(number.pos, "expected a number, got %s", number)
+		}
+		return newConst("-" + number.text)
+	case tok.kind == aplTokenNumber || tok.kind == aplTokenString:
+		return newConst(tok.text)
+	case tok.kind == aplTokenIdent:
+		if tok.text == "true" || tok.text == "false" {
+			return newConst(tok.text)
+		}
+		value := &proto.APLValue{}
+		if tok.text == "_" {
+			return value
+		}
+		fd := aplValueDesc.Oneofs().ByName("value").Fields().ByName(protoreflect.Name(tok.text))
+		if fd == nil {
+			p.fail(tok.pos, "unknown value %s", tok)
+		}
+		impl := value.ProtoReflect().Mutable(fd).Message()
+		if p.peek().is("(") {
+			p.parseFields(impl)
+		}
+		return value
+	}
+	p.fail(tok.pos, "expected a value, got %s", tok)
+	return nil
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+const testAPLText = `actions.precombat=cast_spell,spell_id=25292,do_at=-1.5s
+
+actions=cast_spell,spell_id=25292,target=(type=Target,index=1),if=aura_remaining_time(aura_id=10060)<3s&current_mana_percent>20%
+actions+=/sequence,name=opener,actions=(cast_spell,spell_id=1),actions=(cast_spell,spell_id=(item_id=5),if=!(current_time>1s|current_time<2s)),hide=true
+actions+=/call_action_list,name=aoe,if=(current_time-1s)*2>=-3s,notes="Cleave\nlist"
+actions+=/,hide=true
+actions+=/_,if=_
+
+actions.aoe=
+
+actions."precombat"=run_action_list,name="two words",if=and(vals=true)
+`
+
+func TestAPLTextRoundTrip(t *testing.T) {
+	rotation, err := APLRotationFromText(testAPLText)
+	if err != nil {
+		t.Fatalf("Failed to parse rotation text: %s", err)
+	}
+	if len(rotation.PrepullActions) != 1 || len(rotation.PriorityList) != 5 || len(rotation.ActionLists) != 2 {
+		t.Fatalf("Expected 1 prepull action, 5 items and 2 lists, got %v", rotation)
+	}
+
+	text, err := APLRotationToText(rotation)
+	if err != nil {
+		t.Fatalf("Failed to print rotation: %s", err)
+	}
+	if text != testAPLText {
+		t.Fatalf("Expected printed rotation to match the parsed text, got:\n%s", text)
+	}
+
+	reparsed, err := APLRotationFromText(text)
+	if err != nil {
+		t.Fatalf("Failed to parse printed rotation: %s", err)
+	}
+	if !googleProto.Equal(rotation, reparsed) {
+		t.Fatalf("Expected round trip to be lossless, got %v", reparsed)
+	}
+}
+
+func TestAPLTextFloatRoundTrip(t *testing.T) {
+	rotation := &proto.APLRotation{PriorityList: []*proto.APLListItem{{
+		Action: &proto.APLAction{Action: &proto.APLAction_Wait{Wait: &proto.APLActionWait{
+			Duration: &proto.APLValue{Value: &proto.APLValue_Const{Const: &proto.APLValueConst{
+				Val:    "1",
+				Tuning: &proto.APLValueConstTuning{Min: 0.0000001, Max: 1000000, Step: 2.5},
+			}}},
+		}}},
+	}}}
+
+	text, err := APLRotationToText(rotation)
+	if err != nil {
+		t.Fatalf("Failed to print rotation: %s", err)
+	}
+	if !strings.Contains(text, "min=0.0000001,max=1000000,step=2.5") {
+		t.Fatalf("Expected floats without exponents, got:\n%s", text)
+	}
+
+	reparsed, err := APLRotationFromText(text)
+	if err != nil {
+		t.Fatalf("Failed to parse printed rotation: %s", err)
+	}
+	if !googleProto.Equal(rotation, reparsed) {
+		t.Fatalf("Expected round trip to be lossless, got %v", reparsed)
+	}
+}
+
+func TestAPLTextExpressions(t *testing.T) {
+	constValue := func(val string) *proto.APLValue {
+		return &proto.APLValue{Value: &proto.APLValue_Const{Const: &proto.APLValueConst{Val: val}}}
+	}
+	mathValue := func(op proto.APLValueMath_MathOperator, lhs, rhs *proto.APLValue) *proto.APLValue {
+		return &proto.APLValue{Value: &proto.APLValue_Math{Math: &proto.APLValueMath{Op: op, Lhs: lhs, Rhs: rhs}}}
+	}
+	one, two := constValue("1"), constValue("two words")
+
+	cases := []struct {
+		value *proto.APLValue
+		text  string
+	}{
+		{mathValue(proto.APLValueMath_OpSub, mathValue(proto.APLValueMath_OpSub, one, one), one), "1-1-1"},
+		{mathValue(proto.APLValueMath_OpSub, one, mathValue(proto.APLValueMath_OpSub, one, one)), "1-(1-1)"},
+		{mathValue(proto.APLValueMath_OpMul, mathValue(proto.APLValueMath_OpAdd, one, one), constValue("-1")), "(1+1)*-1"},
+		{mathValue(proto.APLValueMath_OpUnknown, two, nil), `math(lhs="two words")`},
+		{&proto.APLValue{Value: &proto.APLValue_Or{Or: &proto.APLValueOr{Vals: []*proto.APLValue{
+			{Value: &proto.APLValue_And{And: &proto.APLValueAnd{Vals: []*proto.APLValue{one, two}}}},
+			{Value: &proto.APLValue_Not{Not: &proto.APLValueNot{Val: one}}},
+		}}}}, `1&"two words"|!1`},
+	}
+	for _, c := range cases {
+		if text := APLValueToText(c.value); text != c.text {
+			t.Errorf("Expected %v to print as %s, got %s", c.value, c.text, text)
+		}
+		if value, err := APLValueFromText(c.text); err != nil || !googleProto.Equal(value, c.value) {
+			t.Errorf("Expected %s to parse as %v, got %v (%v)", c.text, c.value, value, err)
+		}
+	}
+}
+
+func TestAPLTextErrors(t *testing.T) {
+	cases := []struct {
+		text  string
+		error string
+	}{
+		{"actions+=/cast_spell,spell_id=1\nactions+=/cast_spel", "line 2: column 11: unknown action"},
+		{"actions=wait,duration=current_time<1<2", "column 37: unexpected \"<\""},
+		{"actions=cast_spell,spell_id=1,spell_id=2", "is set twice"},
+		{"actions=wait,if=\"unterminated", "unterminated string"},
+		{"actions.precombat=cast_spell,notes=x", "unknown option \"notes\""},
+	}
+	for _, c := range cases {
+		if _, err := APLRotationFromText(c.text); err == nil || !strings.Contains(err.Error(), c.error) {
+			t.Errorf("Expected parsing %q to fail with %q, got %v", c.text, c.error, err)
+		}
+	}
+}
+
+func TestAPLActionImplStrings(t *testing.T) {
+	cases := []struct {
+		text string
+		impl func(action *proto.APLAction) APLActionImpl
+	}{
+		{
+			"sequence,name=opener,actions=(cast_spell,spell_id=1),actions=(cast_spell,spell_id=2,if=current_time>1s)",
+			func(action *proto.APLAction) APLActionImpl { return &APLActionSequence{config: action.GetSequence()} },
+		},
+		{
+			"strict_sequence,actions=(cast_spell,spell_id=1),actions=(cast_spell,spell_id=2)",
+			func(action *proto.APLAction) APLActionImpl {
+				return &APLActionStrictSequence{config: action.GetStrictSequence()}
+			},
+		},
+		{
+			`schedule,schedule="0s, 30s",inner_action=(cast_spell,spell_id=1)`,
+			func(action *proto.APLAction) APLActionImpl { return &APLActionSchedule{config: action.GetSchedule()} },
+		},
+	}
+	for _, c := range cases {
+		action, err := APLActionFromText(c.text)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %s", c.text, err)
+		}
+		if actual := c.impl(action).String(); actual != c.text {
+			t.Errorf("Expected the action to print as %q, got %q", c.text, actual)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	googleProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	defaultAPLTuneMaxSims   = 200
+	defaultAPLTuneMaxPasses = 3
+	// Searched values for tunables without a step.
+	defaultAPLTuneSteps = 10
+	// Tunables with more values than this are rejected, the step is most likely a typo.
+	maxAPLTuneValues = 1000
+)
+
+// A const marked tunable in the rotation being tuned.
+type aplTunable struct {
+	config   *proto.APLValueConst
+	name     string
+	original string
+	// Searched values, in the unit of the original.
+	values []string
+}
+
+// Returns the consts marked tunable, depth first in field order so that the
+// order matches the rotation.
+func findAPLTunables(msg protoreflect.Message, consts []*proto.APLValueConst) []*proto.APLValueConst {
+	if config, ok := msg.Interface().(*proto.APLValueConst); ok {
+		if config.Tuning != nil {
+			consts = append(consts, config)
+		}
+		return consts
+	}
+
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() != protoreflect.MessageKind || fd.IsMap() || !msg.Has(fd) {
+			continue
+		}
+		if fd.IsList() {
+			list := msg.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				consts = findAPLTunables(list.Get(j).Message(), consts)
+			}
+		} else {
+			consts = findAPLTunables(msg.Get(fd).Message(), consts)
+		}
+	}
+	return consts
+}
+
+func newAPLTunable(config *proto.APLValueConst, idx int) (*aplTunable, error) {
+	tuning := config.Tuning
+	tunable := &aplTunable{
+		config:   config,
+		name:     tuning.Name,
+		original: config.Val,
+	}
+	if tunable.name == "" {
+		tunable.name = fmt.Sprintf("Tunable #%d", idx+1)
+	}
+
+	// Searched values keep the unit of the original, so the const parses the same way.
+	suffix := ""
+	if strings.HasSuffix(config.Val, "%") {
+		suffix = "%"
+	} else if _, err := strconv.ParseFloat(config.Val, 64); err != nil {
+		if _, err := time.ParseDuration(config.Val); err != nil {
+			return nil, fmt.Errorf("APL tuner: %s has value '%s', which isn't a number", tunable.name, config.Val)
+		}
+		suffix = "s"
+	}
+
+	if tuning.Min > tuning.Max {
+		return nil, fmt.Errorf("APL tuner: %s has min %g above max %g", tunable.name, tuning.Min, tuning.Max)
+	}
+	step := tuning.Step
+	if step < 0 {
+		return nil, fmt.Errorf("APL tuner: %s has negative step %g", tunable.name, step)
+	} else if step == 0 {
+		step = (tuning.Max - tuning.Min) / defaultAPLTuneSteps
+	}
+
+	numSteps := 0
+	if step > 0 {
+		// Allow for rounding, so the max is included when the range is a multiple of the step.
+		numSteps = int(math.Floor((tuning.Max-tuning.Min)/step + 1e-9))
+	}
+	if numSteps+1 > maxAPLTuneValues {
+		return nil, fmt.Errorf("APL tuner: %s has more than %d values, use a bigger step", tunable.name, maxAPLTuneValues)
+	}
+	for i := 0; i <= numSteps; i++ {
+		value := math.Round((tuning.Min+float64(i)*step)*1e6) / 1e6
+		tunable.values = append(tunable.values, strconv.FormatFloat(value, 'f', -1, 64)+suffix)
+	}
+	return tunable, nil
+}
+
+// Searches the tunable consts of the first player's rotation by coordinate
+// descent: each tunable in turn is set to the value with the highest DPS,
+// until a pass over all of them changes nothing.
+func runAPLTune(request *proto.APLTuneRequest, progress chan *proto.ProgressMetrics, signals simsignals.Signals) *proto.APLTuneResult {
+	errorResult := func(format string, vals ...interface{}) *proto.APLTuneResult {
+		return &proto.APLTuneResult{Error: &proto.ErrorOutcome{Message: fmt.Sprintf(format, vals...)}}
+	}
+
+	base := request.GetBaseSettings()
+	if base.GetSimOptions() == nil {
+		return errorResult("No sim options set!")
+	}
+	if len(base.GetRaid().GetParties()) == 0 || len(base.Raid.Parties[0].Players) == 0 {
+		return errorResult("APL tuner: no player to tune")
+	}
+	rotation := request.Rotation
+	if rotation == nil {
+		rotation = base.Raid.Parties[0].Players[0].Rotation
+	}
+	if rotation.GetType() != proto.APLRotation_TypeAPL {
+		return errorResult("APL tuner: the first player has no APL rotation")
+	}
+	originalRotation := rotation
+	rotation = googleProto.Clone(rotation).(*proto.APLRotation)
+
+	var tunables []*aplTunable
+	for i, config := range findAPLTunables(rotation.ProtoReflect(), nil) {
+		tunable, err := newAPLTunable(config, i)
+		if err != nil {
+			return errorResult("%s", err)
+		}
+		tunables = append(tunables, tunable)
+	}
+	if len(tunables) == 0 {
+		return errorResult("APL tuner: no consts in the rotation are marked tunable")
+	}
+
+	settings := request.GetTuneSettings()
+	maxSims := settings.GetMaxSims()
+	if maxSims <= 0 {
+		maxSims = defaultAPLTuneMaxSims
+	}
+	maxPasses := int(settings.GetMaxPasses())
+	if maxPasses <= 0 {
+		maxPasses = defaultAPLTuneMaxPasses
+	}
+	// Leave room in the budget for the starting values and the final comparison.
+	searchBudget := maxSims - 2
+	if searchBudget < 2 {
+		return errorResult("APL tuner: budget of %d sims is too small", maxSims)
+	}
+
+	// Every candidate sees the same rolls, so noise mostly cancels when comparing them.
+	options := pairedSimOptions(base.SimOptions)
+	if settings.GetIterationsPerSim() > 0 {
+		options.Iterations = settings.IterationsPerSim
+	}
+	// The final comparison uses a new seed, so the reported gain isn't fit to the rolls of the search.
+	finalOptions := googleProto.Clone(options).(*proto.SimOptions)
+	finalOptions.RandomSeed++
+	finalOptions.Iterations = max(base.SimOptions.Iterations, options.Iterations)
+
+	newRequest := func(rotation *proto.APLRotation, options *proto.SimOptions) *proto.RaidSimRequest {
+		request := googleProto.Clone(base).(*proto.RaidSimRequest)
+		request.SimOptions = googleProto.Clone(options).(*proto.SimOptions)
+		request.Raid.Parties[0].Players[0].Rotation = googleProto.Clone(rotation).(*proto.APLRotation)
+		return request
+	}
+
+	runner := &sequentialSimRunner{
+		progress:        progress,
+		signals:         signals,
+		simsTotal:       maxSims,
+		iterationsTotal: searchBudget*options.Iterations + 2*finalOptions.Iterations,
+	}
+
+	best := runner.run(newRequest(rotation, options))
+	if best.Error != nil {
+		return &proto.APLTuneResult{Error: best.Error}
+	}
+	runner.dps = best.RaidMetrics.Dps.Avg
+
+search:
+	for pass := 0; pass < maxPasses; pass++ {
+		changed := false
+		for _, tunable := range tunables {
+			current := tunable.config.Val
+			bestValue := current
+			for _, value := range tunable.values {
+				if value == current {
+					continue
+				}
+				if runner.simsCompleted >= searchBudget {
+					tunable.config.Val = bestValue
+					break search
+				}
+
+				tunable.config.Val = value
+				result := runner.run(newRequest(rotation, options))
+				if result.Error != nil {
+					return &proto.APLTuneResult{Error: result.Error}
+				}
+				// With the same iterations, the mean paired difference is the difference of the means.
+				if result.RaidMetrics.Dps.Avg > best.RaidMetrics.Dps.Avg {
+					best, bestValue = result, value
+					runner.dps = best.RaidMetrics.Dps.Avg
+				}
+			}
+			tunable.config.Val = bestValue
+			changed = changed || bestValue != current
+		}
+		if !changed {
+			break
+		}
+	}
+
+	result := &proto.APLTuneResult{
+		Rotation: rotation,
+	}
+	for _, tunable := range tunables {
+		result.Values = append(result.Values, &proto.APLTunedValue{
+			Name:     tunable.name,
+			Original: tunable.original,
+			Tuned:    tunable.config.Val,
+		})
+	}
+
+	if googleProto.Equal(rotation, originalRotation) {
+		// The original values were best, so there is nothing to compare.
+		result.Dps = pairedDelta(best.RaidMetrics.Dps, best.RaidMetrics.Dps)
+	} else {
+		original := runner.run(newRequest(originalRotation, finalOptions))
+		if original.Error != nil {
+			return &proto.APLTuneResult{Error: original.Error}
+		}
+		tuned := runner.run(newRequest(rotation, finalOptions))
+		if tuned.Error != nil {
+			return &proto.APLTuneResult{Error: tuned.Error}
+		}
+		result.Dps = pairedDelta(original.RaidMetrics.Dps, tuned.RaidMetrics.Dps)
+	}
+	result.SimsRun = runner.simsCompleted
+	return result
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+)
+
+func TestAPLTunables(t *testing.T) {
+	rotation, err := APLRotationFromText(`
+actions=wait,duration=const(val=3s,tuning=(min=1,max=2,name=Wait)),if=current_mana_percent>const(val="20%",tuning=(min=10,max=30,step=10))
+actions+=/wait,duration=const(val=abc,tuning=(min=0,max=1))
+actions+=/wait,duration=const(val=5,tuning=(min=2,max=1))
+actions+=/wait,duration=1s
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse rotation text: %s", err)
+	}
+
+	consts := findAPLTunables(rotation.ProtoReflect(), nil)
+	if len(consts) != 4 {
+		t.Fatalf("Expected 4 tunable consts, got %d", len(consts))
+	}
+
+	wait, err := newAPLTunable(consts[0], 0)
+	if err != nil {
+		t.Fatalf("Failed to create tunable: %s", err)
+	}
+	// The default step is a tenth of the range.
+	if wait.name != "Wait" || len(wait.values) != 11 || wait.values[0] != "1s" || wait.values[2] != "1.2s" || wait.values[10] != "2s" {
+		t.Fatalf("Expected 11 durations from 1s to 2s, got %s: %v", wait.name, wait.values)
+	}
+
+	mana, err := newAPLTunable(consts[1], 1)
+	if err != nil {
+		t.Fatalf("Failed to create tunable: %s", err)
+	}
+	if mana.name != "Tunable #2" || !slices.Equal(mana.values, []string{"10%", "20%", "30%"}) {
+		t.Fatalf("Expected percentages from 10%% to 30%%, got %s: %v", mana.name, mana.values)
+	}
+
+	if _, err := newAPLTunable(consts[2], 2); err == nil || !strings.Contains(err.Error(), "isn't a number") {
+		t.Errorf("Expected a non-number tunable to fail, got %v", err)
+	}
+	if _, err := newAPLTunable(consts[3], 3); err == nil || !strings.Contains(err.Error(), "above max") {
+		t.Errorf("Expected a tunable with min above max to fail, got %v", err)
+	}
+}
+
+func TestAPLTune(t *testing.T) {
+	// Starting the fake DoT later loses ticks, so the earliest start is best.
+	rotation, err := APLRotationFromText(`
+actions=cast_spell,spell_id=42,if=!dot_is_active(spell_id=42)&current_time>const(val=10s,tuning=(min=0,max=20,step=10,name=Start))
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse rotation text: %s", err)
+	}
+
+	baseSettings := fakeRaidSimRequest(rotation, []*proto.Target{{Name: "target", Level: 63, MobType: proto.MobType_MobTypeDemon}}, 30)
+	baseSettings.SimOptions = DefaultSimTestOptions
+	request := &proto.APLTuneRequest{
+		BaseSettings: baseSettings,
+		TuneSettings: &proto.APLTuneSettings{MaxSims: 6},
+	}
+
+	result := runAPLTune(request, nil, simsignals.CreateSignals())
+	if result.Error != nil {
+		t.Fatalf("Tuning failed: %s", result.Error.Message)
+	}
+	if result.SimsRun > request.TuneSettings.MaxSims {
+		t.Fatalf("Expected at most %d sims, ran %d", request.TuneSettings.MaxSims, result.SimsRun)
+	}
+	if len(result.Values) != 1 || result.Values[0].Name != "Start" || result.Values[0].Original != "10s" || result.Values[0].Tuned != "0s" {
+		t.Fatalf("Expected Start to be tuned from 10s to 0s, got %v", result.Values)
+	}
+	if result.Dps.Mean <= 0 {
+		t.Fatalf("Expected the tuned rotation to gain DPS, got %f", result.Dps.Mean)
+	}
+	if consts := findAPLTunables(rotation.ProtoReflect(), nil); consts[0].Val != "10s" {
+		t.Fatalf("Expected the request's rotation to be left unchanged, got %s", consts[0].Val)
+	}
+
+	request.TuneSettings.MaxSims = 3
+	if result := runAPLTune(request, nil, simsignals.CreateSignals()); result.Error == nil || !strings.Contains(result.Error.Message, "too small") {
+		t.Fatalf("Expected a budget of 3 sims to fail, got %v", result.Error)
+	}
+}
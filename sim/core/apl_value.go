@@ -79,6 +79,8 @@ func (rot *APLRotation) newAPLValue(config *proto.APLValue) APLValue {
 		return rot.newValueMax(config.GetMax())
 	case *proto.APLValue_Min:
 		return rot.newValueMin(config.GetMin())
+	case *proto.APLValue_Variable:
+		return rot.newValueVariable(config.GetVariable())
 
 	// Encounter
 	case *proto.APLValue_CurrentTime:
@@ -93,6 +95,14 @@ func (rot *APLRotation) newAPLValue(config *proto.APLValue) APLValue {
 		return rot.newValueIsExecutePhase(config.GetIsExecutePhase())
 	case *proto.APLValue_NumberTargets:
 		return rot.newValueNumberTargets(config.GetNumberTargets())
+	case *proto.APLValue_ThreatPercentOfTank:
+		return rot.newValueThreatPercentOfTank(config.GetThreatPercentOfTank())
+	case *proto.APLValue_TargetHealthPercent:
+		return rot.newValueTargetHealthPercent(config.GetTargetHealthPercent())
+	case *proto.APLValue_TargetTimeToDie:
+		return rot.newValueTargetTimeToDie(config.GetTargetTimeToDie())
+	case *proto.APLValue_TargetTimeToPercent:
+		return rot.newValueTargetTimeToPercent(config.GetTargetTimeToPercent())
 
 	// Resources
 	case *proto.APLValue_CurrentHealth:
@@ -86,12 +86,142 @@ func (value *APLValueNumberTargets) Type() proto.APLValueType {
 	return proto.APLValueType_ValueTypeInt
 }
 func (value *APLValueNumberTargets) GetInt(sim *Simulation) int32 {
-	return sim.GetNumTargets()
+	return int32(len(sim.Encounter.ActiveTargetUnits))
 }
 func (value *APLValueNumberTargets) String() string {
 	return "Num Targets"
 }
 
+type APLValueThreatPercentOfTank struct {
+	DefaultAPLValueImpl
+	unit   *Unit
+	target UnitReference
+}
+
+func (rot *APLRotation) newValueThreatPercentOfTank(config *proto.APLValueThreatPercentOfTank) APLValue {
+	target := rot.GetTargetUnit(config.TargetUnit)
+	if target.Get() == nil {
+		return nil
+	}
+	if target.Get().Type != EnemyUnit {
+		rot.ValidationWarning("%s does not have a threat table", target.Get().Label)
+		return nil
+	}
+	if !rot.unit.Env.Encounter.UseThreatTable {
+		rot.ValidationWarning("Threat %% of Tank requires the encounter to use threat tables")
+		return nil
+	}
+	return &APLValueThreatPercentOfTank{
+		unit:   rot.unit,
+		target: target,
+	}
+}
+func (value *APLValueThreatPercentOfTank) Type() proto.APLValueType {
+	return proto.APLValueType_ValueTypeFloat
+}
+func (value *APLValueThreatPercentOfTank) GetFloat(_ *Simulation) float64 {
+	return value.target.Get().ThreatTable.ThreatPercentOfHolder(value.unit)
+}
+func (value *APLValueThreatPercentOfTank) String() string {
+	return "Threat % of Tank"
+}
+
+// Returns the Target behind a target reference, or nil with a warning.
+func (rot *APLRotation) getAPLTarget(ref *proto.UnitReference) UnitReference {
+	target := rot.GetTargetUnit(ref)
+	if target.Get() == nil {
+		return UnitReference{}
+	}
+	if target.Get().Type != EnemyUnit {
+		rot.ValidationWarning("%s is not a target", target.Get().Label)
+		return UnitReference{}
+	}
+	return target
+}
+
+func getTarget(ref UnitReference) *Target {
+	unit := ref.Get()
+	return unit.Env.GetTarget(unit.Index)
+}
+
+type APLValueTargetHealthPercent struct {
+	DefaultAPLValueImpl
+	target UnitReference
+}
+
+func (rot *APLRotation) newValueTargetHealthPercent(config *proto.APLValueTargetHealthPercent) APLValue {
+	target := rot.getAPLTarget(config.TargetUnit)
+	if target.Get() == nil {
+		return nil
+	}
+	return &APLValueTargetHealthPercent{
+		target: target,
+	}
+}
+func (value *APLValueTargetHealthPercent) Type() proto.APLValueType {
+	return proto.APLValueType_ValueTypeFloat
+}
+func (value *APLValueTargetHealthPercent) GetFloat(sim *Simulation) float64 {
+	return getTarget(value.target).HealthPercent(sim)
+}
+func (value *APLValueTargetHealthPercent) String() string {
+	return fmt.Sprintf("Target Health %%(%s)", value.target.String())
+}
+
+type APLValueTargetTimeToDie struct {
+	DefaultAPLValueImpl
+	target UnitReference
+}
+
+func (rot *APLRotation) newValueTargetTimeToDie(config *proto.APLValueTargetTimeToDie) APLValue {
+	target := rot.getAPLTarget(config.TargetUnit)
+	if target.Get() == nil {
+		return nil
+	}
+	return &APLValueTargetTimeToDie{
+		target: target,
+	}
+}
+func (value *APLValueTargetTimeToDie) Type() proto.APLValueType {
+	return proto.APLValueType_ValueTypeDuration
+}
+func (value *APLValueTargetTimeToDie) GetDuration(sim *Simulation) time.Duration {
+	return getTarget(value.target).TimeToDie(sim)
+}
+func (value *APLValueTargetTimeToDie) String() string {
+	return fmt.Sprintf("Target Time To Die(%s)", value.target.String())
+}
+
+type APLValueTargetTimeToPercent struct {
+	DefaultAPLValueImpl
+	target  UnitReference
+	percent float64
+}
+
+func (rot *APLRotation) newValueTargetTimeToPercent(config *proto.APLValueTargetTimeToPercent) APLValue {
+	if config.Percent < 0 || config.Percent > 100 {
+		rot.ValidationWarning("Health percent must be between 0 and 100")
+		return nil
+	}
+	target := rot.getAPLTarget(config.TargetUnit)
+	if target.Get() == nil {
+		return nil
+	}
+	return &APLValueTargetTimeToPercent{
+		target:  target,
+		percent: config.Percent / 100,
+	}
+}
+func (value *APLValueTargetTimeToPercent) Type() proto.APLValueType {
+	return proto.APLValueType_ValueTypeDuration
+}
+func (value *APLValueTargetTimeToPercent) GetDuration(sim *Simulation) time.Duration {
+	return getTarget(value.target).TimeToPercent(sim, value.percent)
+}
+func (value *APLValueTargetTimeToPercent) String() string {
+	return fmt.Sprintf("Target Time To %.0f%%(%s)", value.percent*100, value.target.String())
+}
+
 type APLValueIsExecutePhase struct {
 	DefaultAPLValueImpl
 	threshold proto.APLValueIsExecutePhase_ExecutePhaseThreshold
@@ -9,13 +9,15 @@ import (
 
 type APLValueSpellIsKnown struct {
 	DefaultAPLValueImpl
-	spell *Spell
+	spell    *Spell
+	actionID ActionID // Kept for String(), since spell is nil when it isn't known.
 }
 
 func (rot *APLRotation) newValueSpellIsKnown(config *proto.APLValueSpellIsKnown) APLValue {
 	spell := rot.GetAPLSpell(config.SpellId)
 	return &APLValueSpellIsKnown{
-		spell: spell,
+		spell:    spell,
+		actionID: ProtoToActionID(config.SpellId),
 	}
 }
 func (value *APLValueSpellIsKnown) Type() proto.APLValueType {
@@ -25,7 +27,7 @@ func (value *APLValueSpellIsKnown) GetBool(sim *Simulation) bool {
 	return value.spell != nil
 }
 func (value *APLValueSpellIsKnown) String() string {
-	return fmt.Sprintf("Is Known(%s)", value.spell.ActionID)
+	return fmt.Sprintf("Is Known(%s)", value.actionID)
 }
 
 type APLValueSpellCanCast struct {
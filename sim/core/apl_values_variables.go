@@ -0,0 +1,36 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+type APLValueVariable struct {
+	DefaultAPLValueImpl
+	variable *aplVariable
+}
+
+func (rot *APLRotation) newValueVariable(config *proto.APLValueVariable) APLValue {
+	if config.Name == "" {
+		rot.ValidationWarning("Variable must have a name")
+		return nil
+	}
+	return &APLValueVariable{
+		variable: rot.getVariable(config.Name),
+	}
+}
+func (value *APLValueVariable) Finalize(rot *APLRotation) {
+	if !value.variable.assigned {
+		rot.ValidationWarning("Variable '%s' is never set, so it is always 0", value.variable.name)
+	}
+}
+func (value *APLValueVariable) Type() proto.APLValueType {
+	return proto.APLValueType_ValueTypeFloat
+}
+func (value *APLValueVariable) GetFloat(_ *Simulation) float64 {
+	return value.variable.value
+}
+func (value *APLValueVariable) String() string {
+	return fmt.Sprintf("Variable(%s)", value.variable.name)
+}
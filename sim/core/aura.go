@@ -179,7 +179,7 @@ func (aura *Aura) Refresh(sim *Simulation) {
 	}
 
 	if sim.Log != nil && aura.IsActive() && !aura.ActionID.IsEmptyAction() {
-		aura.Unit.Log(sim, "Aura refreshed: %s", aura.ActionID)
+		aura.Unit.LogEvent(sim, &LogEvent{Type: proto.LogEventType_LogEventAuraRefreshed, ActionID: aura.ActionID})
 	}
 
 	if aura.OnRefresh != nil {
@@ -212,7 +212,7 @@ func (aura *Aura) SetStacks(sim *Simulation, newStacks int32) {
 	}
 
 	if sim.Log != nil {
-		aura.Unit.Log(sim, "%s stacks: %d --> %d", aura.ActionID, oldStacks, newStacks)
+		aura.Unit.LogEvent(sim, &LogEvent{Type: proto.LogEventType_LogEventAuraStacks, ActionID: aura.ActionID, OldStacks: oldStacks, NewStacks: newStacks})
 	}
 	aura.stacks = newStacks
 	if aura.OnStacksChange != nil {
@@ -664,7 +664,7 @@ func (aura *Aura) Activate(sim *Simulation) {
 	}
 
 	if sim.Log != nil && !aura.ActionID.IsEmptyAction() {
-		aura.Unit.Log(sim, "Aura gained: %s", aura.ActionID)
+		aura.Unit.LogEvent(sim, &LogEvent{Type: proto.LogEventType_LogEventAuraGained, ActionID: aura.ActionID})
 	}
 
 	// don't invoke possible callbacks until the internal state is consistent
@@ -686,7 +686,7 @@ func (aura *Aura) Deactivate(sim *Simulation) {
 		sim.CurrentTime = min(sim.CurrentTime, aura.expires)
 		aura.metrics.Uptime += sim.CurrentTime - max(aura.startTime, 0)
 		if sim.Log != nil {
-			aura.Unit.Log(sim, "Aura faded: %s", aura.ActionID)
+			aura.Unit.LogEvent(sim, &LogEvent{Type: proto.LogEventType_LogEventAuraFaded, ActionID: aura.ActionID})
 		}
 		sim.CurrentTime = oldTime
 	}
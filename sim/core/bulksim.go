@@ -5,6 +5,7 @@ import (
 	"math"
 	"runtime"
 	"runtime/debug"
+	"slices"
 	"sort"
 	"strings"
 	"sync/atomic"
@@ -20,21 +21,32 @@ const (
 	defaultIterationsPerCombo = 1000
 )
 
-// raidSimRunner runs a standard raid simulation.
-type raidSimRunner func(*proto.RaidSimRequest, chan *proto.ProgressMetrics, bool, simsignals.Signals) *proto.RaidSimResult
+// RaidSimRunner runs a standard raid simulation. It takes the request, a
+// progress channel which it closes when done, whether to skip the presim and
+// the signals of the bulk sim.
+type RaidSimRunner func(*proto.RaidSimRequest, chan *proto.ProgressMetrics, bool, simsignals.Signals) *proto.RaidSimResult
 
 // bulkSimRunner runs a bulk simulation.
 type bulkSimRunner struct {
 	// SingleRaidSimRunner used to run one simulation of the bulk.
-	SingleRaidSimRunner raidSimRunner
+	SingleRaidSimRunner RaidSimRunner
 	// Request used for this bulk simulation.
 	Request *proto.BulkSimRequest
+	// Max number of sims to run at once. Defaults to the number of CPUs.
+	Concurrency int
 }
 
 func BulkSim(signals simsignals.Signals, request *proto.BulkSimRequest, progress chan *proto.ProgressMetrics) *proto.BulkSimResult {
+	return BulkSimWithRunner(signals, request, progress, runSim, 0)
+}
+
+// Runs a bulk sim with each combination simmed by runner, e.g. to run them on
+// other machines. Up to concurrency combinations are simmed at once.
+func BulkSimWithRunner(signals simsignals.Signals, request *proto.BulkSimRequest, progress chan *proto.ProgressMetrics, runner RaidSimRunner, concurrency int) *proto.BulkSimResult {
 	bulk := &bulkSimRunner{
-		SingleRaidSimRunner: runSim,
+		SingleRaidSimRunner: runner,
 		Request:             request,
+		Concurrency:         concurrency,
 	}
 
 	result := bulk.Run(signals, progress)
@@ -131,18 +143,32 @@ func (b *bulkSimRunner) Run(signals simsignals.Signals, progress chan *proto.Pro
 	}
 	baseItems := player.Equipment.Items
 
+	runeCombos, err := b.runeCombinations(player)
+	if err != nil {
+		return &proto.BulkSimResult{
+			Error: &proto.ErrorOutcome{Message: err.Error()},
+		}
+	}
+	talentLoadouts := b.talentLoadouts(player)
+
 	allCombos := generateAllEquipmentSubstitutions(signals, baseItems, b.Request.BulkSettings.Combinations, distinctItemSlotCombos)
 
 	var validCombos []singleBulkSim
 	count := 0
-	for sub := range allCombos {
-		count++
-		if count > 1000000 {
-			panic("over 1 million combos, abandoning attempt")
-		}
-		substitutedRequest, changeLog := createNewRequestWithSubstitution(b.Request.BaseSettings, sub, b.Request.BulkSettings.AutoEnchant)
-		if isValidEquipment(substitutedRequest.Raid.Parties[0].Players[0].Equipment) {
-			validCombos = append(validCombos, singleBulkSim{req: substitutedRequest, cl: changeLog, eq: sub})
+	for itemSub := range allCombos {
+		for _, runes := range runeCombos {
+			for _, talents := range talentLoadouts {
+				count++
+				if count > 1000000 {
+					panic("over 1 million combos, abandoning attempt")
+				}
+				sub := &equipmentSubstitution{Items: itemSub.Items, Runes: runes, Talents: talents}
+				substitutedRequest, changeLog := createNewRequestWithSubstitution(b.Request.BaseSettings, sub, b.Request.BulkSettings.AutoEnchant)
+				substitutedPlayer := substitutedRequest.Raid.Parties[0].Players[0]
+				if isValidEquipment(substitutedPlayer.Equipment) && hasValidRunes(substitutedPlayer.Equipment, substitutedPlayer.TalentsString) {
+					validCombos = append(validCombos, singleBulkSim{req: substitutedRequest, cl: changeLog, eq: sub})
+				}
+			}
 		}
 	}
 
@@ -241,8 +267,10 @@ func (b *bulkSimRunner) Run(signals simsignals.Signals, progress chan *proto.Pro
 		um.Pets = nil
 
 		result.Results = append(result.Results, &proto.BulkComboResult{
-			ItemsAdded:  r.ChangeLog.AddedItems,
-			UnitMetrics: um,
+			ItemsAdded:    r.ChangeLog.AddedItems,
+			RunesChanged:  r.ChangeLog.ChangedRunes,
+			TalentLoadout: r.ChangeLog.TalentLoadout,
+			UnitMetrics:   um,
 		})
 	}
 
@@ -260,6 +288,12 @@ func (b *bulkSimRunner) getRankedResults(signals simsignals.Signals, validCombos
 	if concurrency <= 0 {
 		concurrency = 2
 	}
+	if maxThreads := b.Request.GetBaseSettings().GetSimOptions().GetMaxThreads(); maxThreads > 0 {
+		concurrency = min(concurrency, int(maxThreads))
+	}
+	if b.Concurrency > 0 {
+		concurrency = b.Concurrency
+	}
 
 	tickets := make(chan struct{}, concurrency)
 	for i := 0; i < concurrency; i++ {
@@ -319,6 +353,7 @@ func (b *bulkSimRunner) getRankedResults(signals simsignals.Signals, validCombos
 				// overwrite the requests iterations with the input for this function.
 				sub.req.SimOptions.Iterations = int32(iterations)
 				results <- &itemSubstitutionSimResult{
+					Metric:       b.Request.BulkSettings.RankMetric,
 					Request:      sub.req,
 					Result:       b.SingleRaidSimRunner(sub.req, singleSimProgress, false, signals),
 					Substitution: sub.eq,
@@ -339,7 +374,7 @@ func (b *bulkSimRunner) getRankedResults(signals simsignals.Signals, validCombos
 			reporterSignal.Abort.Trigger() // cancel reporter
 			return nil, nil, result.Result.Error
 		}
-		if !result.Substitution.HasItemReplacements() {
+		if !result.Substitution.HasChanges() {
 			baseResult = result
 		}
 		rankedResults[i] = result
@@ -356,6 +391,7 @@ func (b *bulkSimRunner) getRankedResults(signals simsignals.Signals, validCombos
 // equipment susbstitution and a changelog of which items were added and removed from the base
 // equipment set.
 type itemSubstitutionSimResult struct {
+	Metric       proto.PrecisionMetric
 	Request      *proto.RaidSimRequest
 	Result       *proto.RaidSimResult
 	Substitution *equipmentSubstitution
@@ -367,19 +403,30 @@ func (r *itemSubstitutionSimResult) Score() float64 {
 	if r.Result == nil || r.Result.Error != nil {
 		return 0
 	}
-	return r.Result.RaidMetrics.Dps.Avg
+	if dist := metricDistribution(r.Result, r.Metric); dist != nil {
+		return dist.Avg
+	}
+	return 0
 }
 
-// equipmentSubstitution specifies all items to be used as replacements for the equipped gear.
+// equipmentSubstitution specifies all items to be used as replacements for the equipped gear,
+// along with any rune and talent changes.
 type equipmentSubstitution struct {
-	Items []*itemWithSlot
+	Items   []*itemWithSlot
+	Runes   []*proto.RuneWithSlot
+	Talents *proto.TalentLoadout
 }
 
-// HasChanges returns true if the equipment substitution has any item replacmenets.
+// HasItemReplacements returns true if the equipment substitution has any item replacmenets.
 func (es *equipmentSubstitution) HasItemReplacements() bool {
 	return len(es.Items) > 0
 }
 
+// HasChanges returns true if the substitution changes anything about the player.
+func (es *equipmentSubstitution) HasChanges() bool {
+	return es.HasItemReplacements() || len(es.Runes) > 0 || es.Talents != nil
+}
+
 func (es *equipmentSubstitution) CanonicalHash() string {
 	slotToID := map[proto.ItemSlot]int32{}
 	for _, repl := range es.Items {
@@ -585,7 +632,9 @@ type itemWithSlot struct {
 // raidSimRequestChangeLog stores a change log of which items were added and removed from the base
 // equipment set.
 type raidSimRequestChangeLog struct {
-	AddedItems []*proto.ItemSpecWithSlot
+	AddedItems    []*proto.ItemSpecWithSlot
+	ChangedRunes  []*proto.RuneWithSlot
+	TalentLoadout *proto.TalentLoadout
 }
 
 // createNewRequestWithSubstitution creates a copy of the input RaidSimRequest and applis the given
@@ -617,6 +666,17 @@ func createNewRequestWithSubstitution(readonlyInputRequest *proto.RaidSimRequest
 			})
 		}
 	}
+	for _, rs := range substitution.Runes {
+		// Clone since the item spec may be shared with the substitution.
+		itemSpec := goproto.Clone(equipment.Items[rs.Slot]).(*proto.ItemSpec)
+		itemSpec.Rune = rs.Rune
+		equipment.Items[rs.Slot] = itemSpec
+		changeLog.ChangedRunes = append(changeLog.ChangedRunes, rs)
+	}
+	if substitution.Talents != nil {
+		player.TalentsString = substitution.Talents.TalentsString
+		changeLog.TalentLoadout = substitution.Talents
+	}
 	return request, changeLog
 }
 
@@ -657,3 +717,97 @@ func (ic *SubstitutionComboChecker) HasCombo(replacements equipmentSubstitution)
 	(*ic)[key] = struct{}{}
 	return false
 }
+
+// runeCombinations returns every rune assignment to simulate, each as a list of changes from the
+// equipped runes. The first combination is always empty, i.e. the equipped runes. Talent-gated
+// runes are covered by also setting sim_talents, since every rune combination is crossed with
+// every talent loadout, and combos without the talent are dropped by hasValidRunes.
+func (b *bulkSimRunner) runeCombinations(player *proto.Player) ([][]*proto.RuneWithSlot, error) {
+	combos := [][]*proto.RuneWithSlot{nil}
+	if !b.Request.BulkSettings.SimRunes {
+		return combos, nil
+	}
+
+	equipped := player.GetEquipment().GetItems()
+	for _, runeSlot := range b.Request.BulkSettings.RunesToSim {
+		slot := runeSlot.Slot
+		if int(slot) >= len(equipped) || equipped[slot].GetId() == 0 {
+			return nil, fmt.Errorf("cannot sim runes for %s, no item is equipped", slot)
+		}
+
+		options := slices.Clone(runeSlot.Runes)
+		if runeSlot.AllRunes {
+			options = append(options, runesForSlot(player.Class, slot)...)
+		}
+
+		var next [][]*proto.RuneWithSlot
+		for _, combo := range combos {
+			// Keeping the equipped rune is always an option.
+			next = append(next, combo)
+			seen := map[int32]bool{equipped[slot].GetRune(): true}
+			for _, runeID := range options {
+				if seen[runeID] {
+					continue
+				}
+				seen[runeID] = true
+				next = append(next, append(slices.Clone(combo), &proto.RuneWithSlot{Rune: runeID, Slot: slot}))
+			}
+		}
+		combos = next
+
+		if len(combos) > 1000000 {
+			return nil, fmt.Errorf("over 1 million rune combos, abandoning attempt")
+		}
+	}
+	return combos, nil
+}
+
+// runesForSlot returns all known runes for the class which can be engraved on the slot.
+func runesForSlot(class proto.Class, slot proto.ItemSlot) []int32 {
+	var runes []int32
+	for id, runeData := range RunesByID {
+		if len(runeData.ClassAllowlist) > 0 && !slices.Contains(runeData.ClassAllowlist, class) {
+			continue
+		}
+		if slices.Contains(itemTypeToSlotsMap[runeData.Type], slot) {
+			runes = append(runes, id)
+		}
+	}
+	// Sort so combos are always generated in the same order.
+	slices.Sort(runes)
+	return runes
+}
+
+// talentLoadouts returns every talent loadout to simulate. The first entry is always nil, i.e.
+// the player's current talents.
+func (b *bulkSimRunner) talentLoadouts(player *proto.Player) []*proto.TalentLoadout {
+	loadouts := []*proto.TalentLoadout{nil}
+	if !b.Request.BulkSettings.SimTalents {
+		return loadouts
+	}
+	for _, loadout := range b.Request.BulkSettings.TalentsToSim {
+		if loadout.TalentsString != player.TalentsString {
+			loadouts = append(loadouts, loadout)
+		}
+	}
+	return loadouts
+}
+
+// hasValidRunes returns true if runes are only engraved on equipped items, no rune is engraved
+// twice, e.g. the same ring rune on both rings, and the talents needed by runes are taken.
+func hasValidRunes(equipment *proto.EquipmentSpec, talentsString string) bool {
+	seen := make(map[int32]bool)
+	for _, item := range equipment.GetItems() {
+		if item.GetRune() == 0 {
+			continue
+		}
+		if item.GetId() == 0 || seen[item.GetRune()] {
+			return false
+		}
+		if hasTalent, ok := runeTalentRequirements[item.GetRune()]; ok && !hasTalent(talentsString) {
+			return false
+		}
+		seen[item.GetRune()] = true
+	}
+	return true
+}
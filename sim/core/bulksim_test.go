@@ -380,3 +380,91 @@ func TestGenerateAllEquipmentSubstitutions(t *testing.T) {
 		})
 	}
 }
+
+func TestHasValidRunes(t *testing.T) {
+	swordAndBoard := int32(proto.WarriorRune_RuneSwordAndBoard)
+	RegisterRuneTalentRequirement(swordAndBoard, func(talentsString string) bool { return talentsString == "shield slam" })
+	defer delete(runeTalentRequirements, swordAndBoard)
+
+	for _, tc := range []struct {
+		comment string
+		spec    *proto.EquipmentSpec
+		talents string
+		want    bool
+	}{
+		{
+			comment: "different ring runes are valid",
+			spec: createEquipmentFromItems(
+				&itemWithSlot{Item: &proto.ItemSpec{Id: 1, Rune: int32(proto.RingRune_RuneRingAxeSpecialization)}, Slot: proto.ItemSlot_ItemSlotFinger1},
+				&itemWithSlot{Item: &proto.ItemSpec{Id: 2, Rune: int32(proto.RingRune_RuneRingSwordSpecialization)}, Slot: proto.ItemSlot_ItemSlotFinger2},
+			),
+			want: true,
+		},
+		{
+			comment: "cannot engrave the same ring rune twice",
+			spec: createEquipmentFromItems(
+				&itemWithSlot{Item: &proto.ItemSpec{Id: 1, Rune: int32(proto.RingRune_RuneRingAxeSpecialization)}, Slot: proto.ItemSlot_ItemSlotFinger1},
+				&itemWithSlot{Item: &proto.ItemSpec{Id: 2, Rune: int32(proto.RingRune_RuneRingAxeSpecialization)}, Slot: proto.ItemSlot_ItemSlotFinger2},
+			),
+			want: false,
+		},
+		{
+			comment: "cannot engrave an empty slot",
+			spec: createEquipmentFromItems(
+				&itemWithSlot{Item: &proto.ItemSpec{Rune: int32(proto.RingRune_RuneRingAxeSpecialization)}, Slot: proto.ItemSlot_ItemSlotFinger1},
+			),
+			want: false,
+		},
+		{
+			comment: "talent-gated rune with the talent",
+			spec: createEquipmentFromItems(
+				&itemWithSlot{Item: &proto.ItemSpec{Id: 1, Rune: swordAndBoard}, Slot: proto.ItemSlot_ItemSlotWrist},
+			),
+			talents: "shield slam",
+			want:    true,
+		},
+		{
+			comment: "talent-gated rune without the talent",
+			spec: createEquipmentFromItems(
+				&itemWithSlot{Item: &proto.ItemSpec{Id: 1, Rune: swordAndBoard}, Slot: proto.ItemSlot_ItemSlotWrist},
+			),
+			want: false,
+		},
+	} {
+		if got := hasValidRunes(tc.spec, tc.talents); got != tc.want {
+			t.Fatalf("%s: hasValidRunes(%v, %q) = %v, want %v", tc.comment, tc.spec, tc.talents, got, tc.want)
+		}
+	}
+}
+
+func TestRuneCombinations(t *testing.T) {
+	player := &proto.Player{
+		Equipment: createEquipmentFromItems(
+			&itemWithSlot{Item: &proto.ItemSpec{Id: 1, Rune: 10}, Slot: proto.ItemSlot_ItemSlotHead},
+			&itemWithSlot{Item: &proto.ItemSpec{Id: 2, Rune: 20}, Slot: proto.ItemSlot_ItemSlotChest},
+		),
+	}
+	bulk := &bulkSimRunner{
+		Request: &proto.BulkSimRequest{
+			BulkSettings: &proto.BulkSettings{
+				SimRunes: true,
+				RunesToSim: []*proto.BulkRuneSlot{
+					{Slot: proto.ItemSlot_ItemSlotHead, Runes: []int32{10, 11, 12}},
+					{Slot: proto.ItemSlot_ItemSlotChest, Runes: []int32{21}},
+				},
+			},
+		},
+	}
+
+	combos, err := bulk.runeCombinations(player)
+	if err != nil {
+		t.Fatalf("runeCombinations() returned error: %v", err)
+	}
+	// 3 head options (including the equipped rune) * 2 chest options.
+	if len(combos) != 6 {
+		t.Fatalf("runeCombinations() returned %d combos, want 6", len(combos))
+	}
+	if len(combos[0]) != 0 {
+		t.Fatalf("runeCombinations() first combo should be the equipped runes, got %v", combos[0])
+	}
+}
@@ -239,9 +239,8 @@ func (spell *Spell) makeCastFunc(config CastConfig) CastSuccessFunc {
 					spell.LastCastAt = sim.CurrentTime
 
 					if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
-						spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
-							spell.ActionID, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
-						spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+						spell.logCast(sim, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
+						spell.logCastComplete(sim)
 					}
 
 					if spell.Cost != nil {
@@ -263,8 +262,7 @@ func (spell *Spell) makeCastFunc(config CastConfig) CastSuccessFunc {
 		// Hardcasts
 		if spell.CurCast.CastTime > 0 {
 			if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
-				spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
-					spell.ActionID, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
+				spell.logCast(sim, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
 			}
 
 			spell.Unit.Hardcast = Hardcast{
@@ -274,7 +272,7 @@ func (spell *Spell) makeCastFunc(config CastConfig) CastSuccessFunc {
 					spell.LastCastAt = sim.CurrentTime
 
 					if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
-						spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+						spell.logCastComplete(sim)
 					}
 
 					if spell.Cost != nil {
@@ -308,9 +306,8 @@ func (spell *Spell) makeCastFunc(config CastConfig) CastSuccessFunc {
 		spell.LastCastAt = sim.CurrentTime
 
 		if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
-			spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
-				spell.ActionID, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
-			spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+			spell.logCast(sim, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
+			spell.logCastComplete(sim)
 		}
 
 		if spell.Cost != nil {
@@ -354,9 +351,8 @@ func (spell *Spell) makeCastFuncSimple() CastSuccessFunc {
 		}
 
 		if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
-			spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
-				spell.ActionID, 0.0, "0s", "0s")
-			spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+			spell.logCast(sim, 0, 0, 0)
+			spell.logCastComplete(sim)
 		}
 
 		spell.applyEffects(sim, target)
@@ -372,9 +368,8 @@ func (spell *Spell) makeCastFuncSimple() CastSuccessFunc {
 func (spell *Spell) makeCastFuncAutosOrProcs() CastSuccessFunc {
 	return func(sim *Simulation, target *Unit) bool {
 		if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
-			spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
-				spell.ActionID, 0.0, "0s", "0s")
-			spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+			spell.logCast(sim, 0, 0, 0)
+			spell.logCastComplete(sim)
 		}
 
 		spell.applyEffects(sim, target)
@@ -93,6 +93,8 @@ type Character struct {
 	Pets []*Pet // cached in AddPet, for advance()
 
 	ActiveShapeShift *Aura // Some things can't be used in shapeshift forms
+
+	deathTracker
 }
 
 func NewCharacter(party *Party, partyIndex int, player *proto.Player) Character {
@@ -122,6 +124,10 @@ func NewCharacter(party *Party, partyIndex int, player *proto.Player) Character
 		Class: player.Class,
 		Spec:  PlayerProtoToSpec(player),
 
+		deathTracker: deathTracker{
+			resurrections: player.Resurrections,
+		},
+
 		Equipment: ProtoToEquipment(player.Equipment),
 
 		professions: [2]proto.Profession{
@@ -516,6 +522,7 @@ func (character *Character) reset(sim *Simulation, agent Agent) {
 	character.majorCooldownManager.reset(sim)
 	character.ItemSwap.reset(sim)
 	character.CurrentTarget = character.defaultTarget
+	character.resetDeathTracker()
 
 	agent.Reset(sim)
 
@@ -614,6 +621,8 @@ func (character *Character) getProcMaskFor(pred func(weapon *Item) bool) ProcMas
 }
 
 func (character *Character) doneIteration(sim *Simulation) {
+	character.doneIterationDeathTracker(sim)
+
 	// Need to do pets first, so we can add their results to the owners.
 	for _, pet := range character.Pets {
 		pet.doneIteration(sim)
@@ -670,6 +679,9 @@ func (character *Character) GetMetricsProto() *proto.UnitMetrics {
 	metrics.Name = character.Name
 	metrics.UnitIndex = character.UnitIndex
 	metrics.Auras = character.auraTracker.GetMetricsProto()
+	if character.Rotation != nil && character.Rotation.profile != nil {
+		metrics.AplProfile = character.Rotation.profile.toProto()
+	}
 
 	metrics.Pets = make([]*proto.UnitMetrics, len(character.Pets))
 	for i, pet := range character.Pets {
@@ -0,0 +1,245 @@
+package combatlog
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// Default |z| above which a difference is flagged. Deliberately stricter than
+// 1.96 since a report makes many comparisons at once.
+const DefaultZThreshold = 3.0
+
+// A single log vs sim comparison.
+type Finding struct {
+	ActionID core.ActionID
+	Name     string
+	Metric   string
+
+	LogValue float64
+	SimValue float64
+	ZScore   float64
+
+	// Whether |ZScore| is above the report threshold.
+	Significant bool
+}
+
+type Report struct {
+	Actor         string
+	FightDuration float64 // Seconds, from the log.
+	SimDuration   float64 // Average seconds per sim iteration.
+	Iterations    int32
+	ZThreshold    float64
+
+	Findings []*Finding
+}
+
+// Sim totals for an action summed over all targets and tags.
+type simActionTotals struct {
+	casts, hits, crits, glances, crushes, misses, dodges, parries, blocks, ticks, critTicks int32
+	damage                                                                                  float64
+}
+
+func (totals *simActionTotals) landed() int32 {
+	return totals.hits + totals.crits + totals.glances + totals.crushes
+}
+func (totals *simActionTotals) attempts() int32 {
+	return totals.landed() + totals.misses + totals.dodges + totals.parries + totals.blocks
+}
+func (totals *simActionTotals) damageEvents() int32 {
+	return totals.landed() + totals.ticks + totals.critTicks
+}
+
+func collectSimActions(unit *proto.UnitMetrics) map[core.ActionID]*simActionTotals {
+	actions := make(map[core.ActionID]*simActionTotals)
+	for _, action := range unit.Actions {
+		actionID := core.ProtoToActionID(action.Id)
+		actionID.Tag = 0
+
+		totals, ok := actions[actionID]
+		if !ok {
+			totals = &simActionTotals{}
+			actions[actionID] = totals
+		}
+		for _, tam := range action.Targets {
+			totals.casts += tam.Casts
+			totals.hits += tam.Hits
+			totals.crits += tam.Crits + tam.BlockedCrits
+			totals.glances += tam.Glances
+			totals.crushes += tam.Crushes
+			totals.misses += tam.Misses
+			totals.dodges += tam.Dodges
+			totals.parries += tam.Parries
+			totals.blocks += tam.Blocks
+			totals.ticks += tam.Ticks
+			totals.critTicks += tam.CritTicks
+			totals.damage += tam.Damage
+		}
+	}
+	return actions
+}
+
+// Two-proportion z-test between the log (k1/n1) and the sim (k2/n2).
+func proportionZ(k1, n1, k2, n2 int32) float64 {
+	p1 := float64(k1) / float64(n1)
+	p2 := float64(k2) / float64(n2)
+	pooled := float64(k1+k2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 0
+	}
+	return (p1 - p2) / se
+}
+
+func (report *Report) add(finding *Finding) {
+	finding.Significant = math.Abs(finding.ZScore) >= report.ZThreshold
+	report.Findings = append(report.Findings, finding)
+}
+
+func (report *Report) addRate(ss *SpellSummary, metric string, logK, logN, simK, simN int32) {
+	if logN == 0 || simN == 0 {
+		return
+	}
+	report.add(&Finding{
+		ActionID: ss.ActionID,
+		Name:     ss.Name,
+		Metric:   metric,
+		LogValue: float64(logK) / float64(logN),
+		SimValue: float64(simK) / float64(simN),
+		ZScore:   proportionZ(logK, logN, simK, simN),
+	})
+}
+
+// Compares a combat log summary against the sim metrics for the same character.
+// iterations and avgDuration come from the RaidSimResult the metrics were taken from.
+func Compare(summary *Summary, unit *proto.UnitMetrics, iterations int32, avgDuration float64, zThreshold float64) *Report {
+	report := &Report{
+		Actor:         summary.Actor,
+		FightDuration: summary.Duration.Seconds(),
+		SimDuration:   avgDuration,
+		Iterations:    iterations,
+		ZThreshold:    zThreshold,
+	}
+
+	simSeconds := float64(iterations) * avgDuration
+	logSeconds := summary.Duration.Seconds()
+	simActions := collectSimActions(unit)
+
+	for actionID, ss := range summary.Spells {
+		sa, ok := simActions[actionID]
+		if !ok {
+			report.add(&Finding{
+				ActionID: actionID,
+				Name:     ss.Name,
+				Metric:   "casts/min",
+				LogValue: float64(ss.Casts) / logSeconds * 60,
+				ZScore:   math.Inf(1),
+			})
+			continue
+		}
+
+		// Casts are compared as Poisson rates, scaled to the log's fight length.
+		simRate := float64(sa.casts) / simSeconds
+		expectedCasts := simRate * logSeconds
+		castsZ := 0.0
+		if expectedCasts > 0 {
+			castsZ = (float64(ss.Casts) - expectedCasts) / math.Sqrt(expectedCasts)
+		} else if ss.Casts > 0 {
+			castsZ = math.Inf(1)
+		}
+		report.add(&Finding{
+			ActionID: actionID,
+			Name:     ss.Name,
+			Metric:   "casts/min",
+			LogValue: float64(ss.Casts) / logSeconds * 60,
+			SimValue: simRate * 60,
+			ZScore:   castsZ,
+		})
+
+		report.addRate(ss, "miss rate", ss.Misses, ss.Attempts(), sa.misses, sa.attempts())
+		report.addRate(ss, "dodge rate", ss.Dodges, ss.Attempts(), sa.dodges, sa.attempts())
+		report.addRate(ss, "parry rate", ss.Parries, ss.Attempts(), sa.parries, sa.attempts())
+		report.addRate(ss, "glancing rate", ss.Glances, ss.Attempts(), sa.glances, sa.attempts())
+		report.addRate(ss, "crit rate", ss.Crits, ss.Landed(), sa.crits, sa.landed())
+		report.addRate(ss, "tick crit rate", ss.CritTicks, ss.Ticks+ss.CritTicks, sa.critTicks, sa.ticks+sa.critTicks)
+
+		// Average damage uses the log's own spread, the sim's mean is far more precise.
+		if n := ss.DamageEvents(); n >= 2 && sa.damageEvents() > 0 {
+			simAvg := sa.damage / float64(sa.damageEvents())
+			z := 0.0
+			if stdev := ss.DamageStdev(); stdev > 0 {
+				z = (ss.AvgDamage() - simAvg) / (stdev / math.Sqrt(float64(n)))
+			}
+			report.add(&Finding{
+				ActionID: actionID,
+				Name:     ss.Name,
+				Metric:   "avg damage",
+				LogValue: ss.AvgDamage(),
+				SimValue: simAvg,
+				ZScore:   z,
+			})
+		}
+	}
+
+	for _, auraMetrics := range unit.Auras {
+		actionID := core.ProtoToActionID(auraMetrics.Id)
+		actionID.Tag = 0
+		as, ok := summary.Auras[actionID]
+		if !ok || avgDuration <= 0 {
+			continue
+		}
+
+		logUptime := as.Uptime.Seconds() / logSeconds
+		simUptime := auraMetrics.UptimeSecondsAvg / avgDuration
+		simStdev := auraMetrics.UptimeSecondsStdev / avgDuration
+		z := 0.0
+		if simStdev > 0 {
+			z = (logUptime - simUptime) / simStdev
+		} else if math.Abs(logUptime-simUptime) > 0.01 {
+			z = math.Copysign(math.Inf(1), logUptime-simUptime)
+		}
+		report.add(&Finding{
+			ActionID: actionID,
+			Name:     as.Name,
+			Metric:   "uptime",
+			LogValue: logUptime,
+			SimValue: simUptime,
+			ZScore:   z,
+		})
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return math.Abs(report.Findings[i].ZScore) > math.Abs(report.Findings[j].ZScore)
+	})
+
+	return report
+}
+
+func (report *Report) NumSignificant() int {
+	count := 0
+	for _, finding := range report.Findings {
+		if finding.Significant {
+			count++
+		}
+	}
+	return count
+}
+
+func (report *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Validation of %s: log fight %0.1fs, sim avg %0.1fs over %d iterations\n", report.Actor, report.FightDuration, report.SimDuration, report.Iterations)
+	fmt.Fprintf(&sb, "%d of %d comparisons differ significantly (|z| >= %0.2f)\n\n", report.NumSignificant(), len(report.Findings), report.ZThreshold)
+	fmt.Fprintf(&sb, "%-3s %-30s %-28s %-14s %12s %12s %8s\n", "", "Action", "ID", "Metric", "Log", "Sim", "z")
+	for _, finding := range report.Findings {
+		flag := ""
+		if finding.Significant {
+			flag = "!!"
+		}
+		fmt.Fprintf(&sb, "%-3s %-30s %-28s %-14s %12.3f %12.3f %8.2f\n", flag, finding.Name, finding.ActionID, finding.Metric, finding.LogValue, finding.SimValue, finding.ZScore)
+	}
+	return sb.String()
+}
@@ -0,0 +1,114 @@
+package combatlog
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func TestCompare(t *testing.T) {
+	melee := core.ActionID{OtherID: proto.OtherAction_OtherActionAttack}
+	heroicStrike := core.ActionID{SpellID: 11567}
+	bloodthirst := core.ActionID{SpellID: 23894}
+	hamstring := core.ActionID{SpellID: 1715}
+	battleStance := core.ActionID{SpellID: 2457}
+	battleShout := core.ActionID{SpellID: 6673}
+
+	// A 100s fight in the log against 10 sim iterations of 100s.
+	summary := &Summary{
+		Actor:    "Tester",
+		Duration: time.Second * 100,
+		Spells: map[core.ActionID]*SpellSummary{
+			melee:        {ActionID: melee, Name: "Melee", Casts: 50, Hits: 40, Crits: 10},
+			heroicStrike: {ActionID: heroicStrike, Name: "Heroic Strike", Casts: 20, Hits: 10, Crits: 10},
+			bloodthirst:  {ActionID: bloodthirst, Name: "Bloodthirst", Casts: 30},
+			hamstring:    {ActionID: hamstring, Name: "Hamstring", Casts: 2},
+		},
+		Auras: map[core.ActionID]*AuraSummary{
+			battleStance: {ActionID: battleStance, Name: "Battle Stance", Uptime: time.Second * 100},
+			battleShout:  {ActionID: battleShout, Name: "Battle Shout", Uptime: time.Second * 50},
+		},
+	}
+
+	action := func(actionID core.ActionID, casts, hits, crits int32) *proto.ActionMetrics {
+		return &proto.ActionMetrics{
+			Id:      actionID.ToProto(),
+			Targets: []*proto.TargetedActionMetrics{{Casts: casts, Hits: hits, Crits: crits}},
+		}
+	}
+	unit := &proto.UnitMetrics{
+		Actions: []*proto.ActionMetrics{
+			action(melee, 500, 400, 100),
+			action(heroicStrike, 200, 180, 20),
+			action(bloodthirst, 100, 0, 0),
+		},
+		Auras: []*proto.AuraMetrics{
+			{Id: battleStance.ToProto(), UptimeSecondsAvg: 100},
+			{Id: battleShout.ToProto(), UptimeSecondsAvg: 90},
+		},
+	}
+
+	report := Compare(summary, unit, 10, 100, DefaultZThreshold)
+	find := func(actionID core.ActionID, metric string) *Finding {
+		t.Helper()
+		for _, finding := range report.Findings {
+			if finding.ActionID == actionID && finding.Metric == metric {
+				return finding
+			}
+		}
+		t.Fatalf("Expected a %s finding for %s", metric, actionID)
+		return nil
+	}
+
+	cases := []struct {
+		name        string
+		actionID    core.ActionID
+		metric      string
+		significant bool
+	}{
+		{"matching melee crit rate", melee, "crit rate", false},
+		{"matching melee casts", melee, "casts/min", false},
+		{"crit rate gap", heroicStrike, "crit rate", true},
+		{"matching Heroic Strike casts", heroicStrike, "casts/min", false},
+		{"cast count gap", bloodthirst, "casts/min", true},
+		{"spell missing from the sim", hamstring, "casts/min", true},
+		{"matching uptime without spread", battleStance, "uptime", false},
+		{"uptime gap without spread", battleShout, "uptime", true},
+	}
+	for _, c := range cases {
+		if finding := find(c.actionID, c.metric); finding.Significant != c.significant {
+			t.Errorf("%s: expected significant = %t, got z = %f", c.name, c.significant, finding.ZScore)
+		}
+	}
+
+	// A sim without spread can't explain any gap in uptime.
+	if z := find(battleShout, "uptime").ZScore; !math.IsInf(z, -1) {
+		t.Errorf("Expected an uptime below the sim's to have z = -Inf, got %f", z)
+	}
+	if z := find(battleStance, "uptime").ZScore; z != 0 {
+		t.Errorf("Expected a matching uptime to have z = 0, got %f", z)
+	}
+
+	if report.NumSignificant() != 4 {
+		t.Errorf("Expected 4 significant findings, got %d", report.NumSignificant())
+	}
+	if !math.IsInf(report.Findings[0].ZScore, 0) {
+		t.Errorf("Expected findings sorted by |z|, got %f first", report.Findings[0].ZScore)
+	}
+}
+
+func TestProportionZ(t *testing.T) {
+	if z := proportionZ(10, 100, 100, 1000); z != 0 {
+		t.Errorf("Expected equal proportions to have z = 0, got %f", z)
+	}
+	if z := proportionZ(0, 100, 0, 1000); z != 0 {
+		t.Errorf("Expected proportions without spread to have z = 0, got %f", z)
+	}
+	// 50% in the log against 10% in the sim.
+	if z := proportionZ(10, 20, 20, 200); math.Abs(z-4.97) > 0.01 {
+		t.Errorf("Expected z = 4.97, got %f", z)
+	}
+}
@@ -0,0 +1,260 @@
+// Package combatlog reads in-game WoWCombatLog.txt files and compares them
+// against sim results, so a spec's sim can be validated against real logs.
+package combatlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of base parameters (source and dest GUID/name/flags/raidFlags) on every event.
+const numBaseParams = 8
+
+// Number of trailing parameters on a _DAMAGE suffix in classic era logs:
+// amount, overkill, school, resisted, blocked, absorbed, critical, glancing, crushing, isOffHand.
+const numDamageSuffixParams = 10
+
+// Number of trailing parameters on a _HEAL suffix: amount, overhealing, absorbed, critical.
+const numHealSuffixParams = 4
+
+type Unit struct {
+	GUID string
+	Name string
+}
+
+// A single parsed combat log line. Only the fields needed for validation are kept.
+type Event struct {
+	Timestamp time.Duration // Relative to the first line of the log.
+	Type      string        // e.g. SPELL_DAMAGE, SWING_MISSED, ENCOUNTER_START.
+
+	Source Unit
+	Dest   Unit
+
+	// 0 for swing events.
+	SpellID   int32
+	SpellName string
+
+	// Damage and heal events.
+	Amount   float64
+	Critical bool
+	Glancing bool
+	Crushing bool
+
+	// Missed events, e.g. MISS, DODGE, PARRY, RESIST.
+	MissType string
+
+	// Raw parameters, for events not otherwise understood.
+	Params []string
+}
+
+func (event *Event) IsSwing() bool {
+	return strings.HasPrefix(event.Type, "SWING_")
+}
+func (event *Event) IsRange() bool {
+	return strings.HasPrefix(event.Type, "RANGE_")
+}
+func (event *Event) IsPeriodic() bool {
+	return strings.HasPrefix(event.Type, "SPELL_PERIODIC_")
+}
+func (event *Event) IsDamage() bool {
+	return strings.HasSuffix(event.Type, "_DAMAGE")
+}
+func (event *Event) IsMissed() bool {
+	return strings.HasSuffix(event.Type, "_MISSED")
+}
+func (event *Event) IsHeal() bool {
+	return strings.HasSuffix(event.Type, "_HEAL")
+}
+
+// Splits a combat log line into its comma separated parameters, respecting quoted names.
+func splitParams(line string) []string {
+	var params []string
+	var sb strings.Builder
+	inQuotes := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ',' && !inQuotes:
+			params = append(params, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return append(params, sb.String())
+}
+
+// Year of dates which don't have one. A leap year, so 2/29 parses.
+const defaultLogYear = 2000
+
+// Parsed timestamps are durations since this time, see parseTimestamp.
+var logEpoch = time.Date(defaultLogYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Parses the timestamp prefix, which looks like "4/23 20:15:31.123" or "4/23/2024 20:15:31.123-4".
+// The full date is used, so logs can run past midnight and into the next month. Dates without a
+// year can't run past New Year's Eve.
+func parseTimestamp(str string) (time.Duration, error) {
+	fields := strings.Fields(str)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("invalid timestamp %q", str)
+	}
+
+	dateParts := strings.Split(fields[0], "/")
+	if len(dateParts) != 2 && len(dateParts) != 3 {
+		return 0, fmt.Errorf("invalid date %q", fields[0])
+	}
+	month, err1 := strconv.Atoi(dateParts[0])
+	day, err2 := strconv.Atoi(dateParts[1])
+	year := defaultLogYear
+	var err3 error
+	if len(dateParts) == 3 {
+		year, err3 = strconv.Atoi(dateParts[2])
+	}
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, fmt.Errorf("invalid date %q", fields[0])
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	// time.Date normalizes out of range values, e.g. 4/31 to 5/1.
+	if date.Month() != time.Month(month) || date.Day() != day {
+		return 0, fmt.Errorf("invalid date %q", fields[0])
+	}
+
+	clock := fields[1]
+	if idx := strings.IndexAny(clock, "+-"); idx != -1 {
+		clock = clock[:idx]
+	}
+	clockParts := strings.Split(clock, ":")
+	if len(clockParts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", fields[1])
+	}
+	hours, err1 := strconv.Atoi(clockParts[0])
+	minutes, err2 := strconv.Atoi(clockParts[1])
+	seconds, err3 := strconv.ParseFloat(clockParts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, fmt.Errorf("invalid time %q", fields[1])
+	}
+
+	return date.Sub(logEpoch) +
+		time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)), nil
+}
+
+// Parses a single combat log line. The returned timestamp is absolute, see Parse.
+func ParseLine(line string) (*Event, error) {
+	sepIdx := strings.Index(line, "  ")
+	if sepIdx == -1 {
+		return nil, fmt.Errorf("missing timestamp separator")
+	}
+
+	timestamp, err := parseTimestamp(line[:sepIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	params := splitParams(strings.TrimSpace(line[sepIdx+2:]))
+	event := &Event{
+		Timestamp: timestamp,
+		Type:      params[0],
+		Params:    params[1:],
+	}
+
+	args := params[1:]
+	if len(args) < numBaseParams {
+		// Not a unit event, e.g. COMBAT_LOG_VERSION or ENCOUNTER_START.
+		return event, nil
+	}
+
+	event.Source = Unit{GUID: args[0], Name: args[1]}
+	event.Dest = Unit{GUID: args[4], Name: args[5]}
+	args = args[numBaseParams:]
+
+	if !event.IsSwing() {
+		if len(args) < 3 {
+			return event, nil
+		}
+		spellID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid spell ID %q", args[0])
+		}
+		event.SpellID = int32(spellID)
+		event.SpellName = args[1]
+		args = args[3:]
+	}
+
+	switch {
+	case event.IsDamage():
+		if len(args) < numDamageSuffixParams {
+			return nil, fmt.Errorf("too few damage parameters for %s", event.Type)
+		}
+		// Advanced logging parameters come first, so read from the end.
+		suffix := args[len(args)-numDamageSuffixParams:]
+		event.Amount, err = strconv.ParseFloat(suffix[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid damage amount %q", suffix[0])
+		}
+		event.Critical = isLogTrue(suffix[6])
+		event.Glancing = isLogTrue(suffix[7])
+		event.Crushing = isLogTrue(suffix[8])
+	case event.IsHeal():
+		if len(args) < numHealSuffixParams {
+			return nil, fmt.Errorf("too few heal parameters for %s", event.Type)
+		}
+		suffix := args[len(args)-numHealSuffixParams:]
+		event.Amount, err = strconv.ParseFloat(suffix[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid heal amount %q", suffix[0])
+		}
+		event.Critical = isLogTrue(suffix[3])
+	case event.IsMissed():
+		if len(args) < 1 {
+			return nil, fmt.Errorf("missing miss type for %s", event.Type)
+		}
+		event.MissType = args[0]
+	}
+
+	return event, nil
+}
+
+func isLogTrue(str string) bool {
+	return str == "1"
+}
+
+// Parses a whole combat log. Timestamps are made relative to the first event.
+// Lines which fail to parse are skipped and counted in the returned skip count.
+func Parse(r io.Reader) ([]*Event, int, error) {
+	var events []*Event
+	skipped := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		event, err := ParseLine(line)
+		if err != nil {
+			skipped++
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, skipped, err
+	}
+
+	if len(events) > 0 {
+		start := events[0].Timestamp
+		for _, event := range events {
+			event.Timestamp -= start
+		}
+	}
+
+	return events, skipped, nil
+}
@@ -0,0 +1,90 @@
+package combatlog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+const testLog = `4/23 20:15:30.000  ENCOUNTER_START,610,"Razorgore the Untamed",9,40,469
+4/23 20:15:30.500  SPELL_AURA_APPLIED,Player-1-0001,"Tester-Realm",0x511,0x0,Player-1-0001,"Tester-Realm",0x511,0x0,2458,"Berserker Stance",0x1,BUFF
+4/23 20:15:31.000  SWING_DAMAGE,Player-1-0001,"Tester-Realm",0x511,0x0,Creature-0-1-1-1-1-1,"Boss, the Big",0x10a48,0x0,Player-1-0001,0000000000000000,100,100,0,0,0,0,1,0,0,0,0,0,0,0,0,60,250,-1,1,0,0,0,1,nil,nil,nil
+4/23 20:15:32.000  SWING_MISSED,Player-1-0001,"Tester-Realm",0x511,0x0,Creature-0-1-1-1-1-1,"Boss, the Big",0x10a48,0x0,DODGE,nil
+4/23 20:15:33.000  SPELL_CAST_SUCCESS,Player-1-0001,"Tester-Realm",0x511,0x0,Creature-0-1-1-1-1-1,"Boss, the Big",0x10a48,0x0,11567,"Heroic Strike",0x1
+4/23 20:15:33.000  SPELL_DAMAGE,Player-1-0001,"Tester-Realm",0x511,0x0,Creature-0-1-1-1-1-1,"Boss, the Big",0x10a48,0x0,11567,"Heroic Strike",0x1,400,-1,1,0,0,0,nil,nil,nil,nil
+4/23 20:15:40.000  ENCOUNTER_END,610,"Razorgore the Untamed",9,40,1
+`
+
+func TestParseLine(t *testing.T) {
+	event, err := ParseLine(`4/23 20:15:31.250  SPELL_MISSED,Player-1-0001,"Tester-Realm",0x511,0x0,Creature-0-1-1-1-1-1,"Boss",0x10a48,0x0,11567,"Heroic Strike",0x1,PARRY,nil`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if event.Type != "SPELL_MISSED" || event.SpellID != 11567 || event.MissType != "PARRY" {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+	if event.Source.Name != "Tester-Realm" || event.Dest.Name != "Boss" {
+		t.Fatalf("Unexpected units: %+v / %+v", event.Source, event.Dest)
+	}
+
+	expectedTime := time.Date(defaultLogYear, time.April, 23, 20, 15, 31, 250*int(time.Millisecond), time.UTC).Sub(logEpoch)
+	if event.Timestamp != expectedTime {
+		t.Fatalf("Expected timestamp %s, got %s", expectedTime, event.Timestamp)
+	}
+}
+
+func TestParseAcrossMonths(t *testing.T) {
+	events, skipped, err := Parse(strings.NewReader(`4/30/2024 23:59:55.000-4  ENCOUNTER_START,610,"Razorgore the Untamed",9,40,469
+5/1/2024 00:00:05.000-4  ENCOUNTER_END,610,"Razorgore the Untamed",9,40,1
+2/29 12:00:00.000  ENCOUNTER_END,610,"Razorgore the Untamed",9,40,1
+4/31/2024 00:00:00.000-4  ENCOUNTER_END,610,"Razorgore the Untamed",9,40,1
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// 4/31 doesn't exist.
+	if len(events) != 3 || skipped != 1 {
+		t.Fatalf("Expected 3 events and 1 skipped line, got %d and %d", len(events), skipped)
+	}
+	if events[1].Timestamp != time.Second*10 {
+		t.Fatalf("Expected 10s across the month boundary, got %s", events[1].Timestamp)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	events, skipped, err := Parse(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if skipped != 0 {
+		t.Fatalf("Expected no skipped lines, got %d", skipped)
+	}
+
+	summary, err := Summarize(events, "Tester", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if summary.Duration != time.Second*10 {
+		t.Fatalf("Expected 10s fight, got %s", summary.Duration)
+	}
+
+	melee := summary.Spells[core.ActionID{OtherID: proto.OtherAction_OtherActionAttack}]
+	if melee == nil || melee.Casts != 2 || melee.Crits != 1 || melee.Dodges != 1 || melee.Damage != 250 {
+		t.Fatalf("Unexpected melee summary: %+v", melee)
+	}
+
+	heroicStrike := summary.Spells[core.ActionID{SpellID: 11567}]
+	if heroicStrike == nil || heroicStrike.Casts != 1 || heroicStrike.Hits != 1 || heroicStrike.Damage != 400 {
+		t.Fatalf("Unexpected Heroic Strike summary: %+v", heroicStrike)
+	}
+
+	stance := summary.Auras[core.ActionID{SpellID: 2458}]
+	if stance == nil || stance.Uptime != time.Millisecond*9500 {
+		t.Fatalf("Unexpected stance summary: %+v", stance)
+	}
+}
@@ -0,0 +1,276 @@
+package combatlog
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// Spell ID of the hunter Auto Shot, which the sim tracks as the generic Shoot action.
+const autoShotSpellID = 75
+
+// Per-action totals for a single actor in a combat log, mirroring the sim's TargetedActionMetrics.
+type SpellSummary struct {
+	ActionID core.ActionID
+	Name     string
+
+	Casts     int32
+	Hits      int32
+	Crits     int32
+	Glances   int32
+	Crushes   int32
+	Misses    int32
+	Dodges    int32
+	Parries   int32
+	Blocks    int32
+	Ticks     int32
+	CritTicks int32
+
+	Damage float64
+
+	// Used to compute the per-event damage standard deviation.
+	damageSumSq float64
+}
+
+// Landed direct (non-periodic) hits.
+func (ss *SpellSummary) Landed() int32 {
+	return ss.Hits + ss.Crits + ss.Glances + ss.Crushes
+}
+
+// All direct hit attempts, landed or not.
+func (ss *SpellSummary) Attempts() int32 {
+	return ss.Landed() + ss.Misses + ss.Dodges + ss.Parries + ss.Blocks
+}
+
+// Number of events which dealt damage, including periodic ticks.
+func (ss *SpellSummary) DamageEvents() int32 {
+	return ss.Landed() + ss.Ticks + ss.CritTicks
+}
+
+func (ss *SpellSummary) AvgDamage() float64 {
+	if n := ss.DamageEvents(); n > 0 {
+		return ss.Damage / float64(n)
+	}
+	return 0
+}
+
+func (ss *SpellSummary) DamageStdev() float64 {
+	n := float64(ss.DamageEvents())
+	if n < 2 {
+		return 0
+	}
+	mean := ss.Damage / n
+	return math.Sqrt(max(0, (ss.damageSumSq-n*mean*mean)/(n-1)))
+}
+
+type AuraSummary struct {
+	ActionID core.ActionID
+	Name     string
+
+	Applications int32
+	Uptime       time.Duration
+
+	appliedAt time.Duration
+	active    bool
+}
+
+// Everything the validator needs to know about a single actor over a single fight.
+type Summary struct {
+	Actor    string
+	Start    time.Duration
+	Duration time.Duration
+
+	Spells map[core.ActionID]*SpellSummary
+	Auras  map[core.ActionID]*AuraSummary
+}
+
+func (summary *Summary) getSpell(event *Event) *SpellSummary {
+	actionID := actionIDForEvent(event)
+	ss, ok := summary.Spells[actionID]
+	if !ok {
+		ss = &SpellSummary{ActionID: actionID, Name: event.SpellName}
+		if event.IsSwing() {
+			ss.Name = "Melee"
+		}
+		summary.Spells[actionID] = ss
+	}
+	return ss
+}
+
+func (summary *Summary) getAura(event *Event) *AuraSummary {
+	actionID := core.ActionID{SpellID: event.SpellID}
+	as, ok := summary.Auras[actionID]
+	if !ok {
+		as = &AuraSummary{ActionID: actionID, Name: event.SpellName}
+		summary.Auras[actionID] = as
+	}
+	return as
+}
+
+// Maps a combat log event onto the ActionID the sim uses for the same action, ignoring tags.
+func actionIDForEvent(event *Event) core.ActionID {
+	switch {
+	case event.IsSwing():
+		return core.ActionID{OtherID: proto.OtherAction_OtherActionAttack}
+	case event.IsRange() || event.SpellID == autoShotSpellID:
+		return core.ActionID{OtherID: proto.OtherAction_OtherActionShoot}
+	default:
+		return core.ActionID{SpellID: event.SpellID}
+	}
+}
+
+func matchesActor(unit Unit, actor string) bool {
+	if unit.Name == actor {
+		return true
+	}
+	// Names in logs include the realm, e.g. "Name-Realm".
+	name, _, _ := strings.Cut(unit.Name, "-")
+	return name == actor
+}
+
+// Finds the [start, end] window to summarize. If the log has ENCOUNTER_START/ENCOUNTER_END
+// events, encounterIndex picks which one to use. Otherwise the window spans all events
+// caused by the actor.
+func findWindow(events []*Event, actor string, encounterIndex int) (time.Duration, time.Duration, error) {
+	type window struct{ start, end time.Duration }
+	var encounters []window
+	for _, event := range events {
+		switch event.Type {
+		case "ENCOUNTER_START":
+			encounters = append(encounters, window{start: event.Timestamp, end: -1})
+		case "ENCOUNTER_END":
+			if n := len(encounters); n > 0 && encounters[n-1].end == -1 {
+				encounters[n-1].end = event.Timestamp
+			}
+		}
+	}
+
+	if len(encounters) > 0 {
+		if encounterIndex < 0 || encounterIndex >= len(encounters) {
+			return 0, 0, fmt.Errorf("encounter index %d out of range, log has %d encounters", encounterIndex, len(encounters))
+		}
+		found := encounters[encounterIndex]
+		if found.end == -1 {
+			found.end = events[len(events)-1].Timestamp
+		}
+		return found.start, found.end, nil
+	}
+
+	start, end := time.Duration(-1), time.Duration(-1)
+	for _, event := range events {
+		if matchesActor(event.Source, actor) {
+			if start == -1 {
+				start = event.Timestamp
+			}
+			end = event.Timestamp
+		}
+	}
+	if start == -1 {
+		return 0, 0, fmt.Errorf("no events found for %q", actor)
+	}
+	return start, end, nil
+}
+
+// Summarizes everything the named actor did during the chosen fight window.
+func Summarize(events []*Event, actor string, encounterIndex int) (*Summary, error) {
+	start, end, err := findWindow(events, actor, encounterIndex)
+	if err != nil {
+		return nil, err
+	}
+	if end <= start {
+		return nil, fmt.Errorf("fight window for %q is empty", actor)
+	}
+
+	summary := &Summary{
+		Actor:    actor,
+		Start:    start,
+		Duration: end - start,
+		Spells:   make(map[core.ActionID]*SpellSummary),
+		Auras:    make(map[core.ActionID]*AuraSummary),
+	}
+
+	for _, event := range events {
+		if event.Timestamp < start || event.Timestamp > end {
+			continue
+		}
+		ts := event.Timestamp - start
+
+		// Buffs on the actor, for uptime comparisons.
+		if matchesActor(event.Dest, actor) {
+			switch event.Type {
+			case "SPELL_AURA_APPLIED":
+				as := summary.getAura(event)
+				if !as.active {
+					as.active = true
+					as.appliedAt = ts
+					as.Applications++
+				}
+			case "SPELL_AURA_REMOVED":
+				as := summary.getAura(event)
+				if as.active {
+					as.active = false
+					as.Uptime += ts - as.appliedAt
+				}
+			}
+		}
+
+		if !matchesActor(event.Source, actor) {
+			continue
+		}
+
+		switch {
+		case event.Type == "SPELL_CAST_SUCCESS":
+			summary.getSpell(event).Casts++
+		case event.IsDamage() && !matchesActor(event.Dest, actor):
+			ss := summary.getSpell(event)
+			if event.IsSwing() {
+				ss.Casts++
+			}
+			switch {
+			case event.IsPeriodic() && event.Critical:
+				ss.CritTicks++
+			case event.IsPeriodic():
+				ss.Ticks++
+			case event.Critical:
+				ss.Crits++
+			case event.Glancing:
+				ss.Glances++
+			case event.Crushing:
+				ss.Crushes++
+			default:
+				ss.Hits++
+			}
+			ss.Damage += event.Amount
+			ss.damageSumSq += event.Amount * event.Amount
+		case event.IsMissed():
+			ss := summary.getSpell(event)
+			if event.IsSwing() {
+				ss.Casts++
+			}
+			switch event.MissType {
+			case "MISS", "RESIST":
+				ss.Misses++
+			case "DODGE":
+				ss.Dodges++
+			case "PARRY":
+				ss.Parries++
+			case "BLOCK":
+				ss.Blocks++
+			}
+		}
+	}
+
+	// Auras still active at the end of the window.
+	for _, as := range summary.Auras {
+		if as.active {
+			as.Uptime += summary.Duration - as.appliedAt
+			as.active = false
+		}
+	}
+
+	return summary, nil
+}
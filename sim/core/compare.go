@@ -0,0 +1,298 @@
+package core
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+// Options for sims whose iterations pair up, with labeled RNG so that changes
+// in one part of a setup don't shift the rolls everywhere else.
+func pairedSimOptions(options *proto.SimOptions) *proto.SimOptions {
+	options = googleProto.Clone(options).(*proto.SimOptions)
+
+	// Same as stat weights, a random seed keeps run-run differences.
+	if options.RandomSeed == 0 {
+		options.RandomSeed = time.Now().UnixNano()
+	}
+	options.SaveAllValues = true
+	options.UseLabeledRands = true
+	// Every request needs the same iterations for values to pair up.
+	options.TargetPrecision = 0
+	return options
+}
+
+// Gives all requests the same iterations and seed.
+func buildCompareRequests(request *proto.CompareRequest) []*proto.RaidSimRequest {
+	options := request.SimOptions
+	if options == nil {
+		options = request.Requests[0].SimOptions
+	}
+	options = pairedSimOptions(options)
+
+	requests := make([]*proto.RaidSimRequest, len(request.Requests))
+	for i, req := range request.Requests {
+		requests[i] = googleProto.Clone(req).(*proto.RaidSimRequest)
+		requests[i].SimOptions = googleProto.Clone(options).(*proto.SimOptions)
+	}
+	return requests
+}
+
+// Computes the paired difference of variant - baseline. Both need all values
+// saved, from sims with the same seed.
+func pairedDelta(baseline *proto.DistributionMetrics, variant *proto.DistributionMetrics) *proto.CompareDelta {
+	delta := &proto.CompareDelta{
+		Baseline: baseline.Avg,
+		Variant:  variant.Avg,
+		PValue:   1,
+	}
+
+	var diffs aggregator
+	for i := 0; i < min(len(baseline.AllValues), len(variant.AllValues)); i++ {
+		diffs.add(variant.AllValues[i] - baseline.AllValues[i])
+	}
+	if diffs.n == 0 {
+		return delta
+	}
+
+	mean, stdev := diffs.meanAndStdDev()
+	// Rounding can take the variance slightly below 0 when all differences are equal.
+	if math.IsNaN(stdev) {
+		stdev = 0
+	}
+	delta.Mean = mean
+	delta.Stdev = stdev
+
+	stdErr := stdev / math.Sqrt(float64(diffs.n))
+	delta.Ci95 = 1.96 * stdErr
+	if stdErr > 0 {
+		// Normal approximation, iteration counts are far past where a t-distribution matters.
+		delta.PValue = math.Erfc(math.Abs(mean/stdErr) / math.Sqrt2)
+	} else if mean != 0 {
+		delta.PValue = 0
+	}
+	return delta
+}
+
+type compareActionTotals struct {
+	id     *proto.ActionID
+	damage float64
+	casts  float64
+	hits   float64
+	crits  float64
+}
+
+func addCompareActionTotals(totals map[string]*compareActionTotals, order *[]string, unit *proto.UnitMetrics) {
+	for _, action := range unit.Actions {
+		key := action.Id.String()
+		at, ok := totals[key]
+		if !ok {
+			at = &compareActionTotals{id: action.Id}
+			totals[key] = at
+			*order = append(*order, key)
+		}
+		for _, tgt := range action.Targets {
+			at.damage += tgt.Damage
+			at.casts += float64(tgt.Casts)
+			at.hits += float64(tgt.Hits)
+			at.crits += float64(tgt.Crits + tgt.CritTicks)
+		}
+	}
+}
+
+// Differences of the first player's actions, biggest DPS change first.
+func compareActions(baseline *proto.RaidSimResult, variant *proto.RaidSimResult) []*proto.CompareActionDelta {
+	firstPlayer := func(result *proto.RaidSimResult) *proto.UnitMetrics {
+		if len(result.RaidMetrics.Parties) == 0 || len(result.RaidMetrics.Parties[0].Players) == 0 {
+			return &proto.UnitMetrics{}
+		}
+		return result.RaidMetrics.Parties[0].Players[0]
+	}
+
+	var order []string
+	baseTotals := map[string]*compareActionTotals{}
+	variantTotals := map[string]*compareActionTotals{}
+	addCompareActionTotals(baseTotals, &order, firstPlayer(baseline))
+	addCompareActionTotals(variantTotals, &order, firstPlayer(variant))
+
+	perIteration := func(result *proto.RaidSimResult, totals *compareActionTotals) (dps, casts, hits, crits float64) {
+		if totals == nil || result.IterationsDone == 0 {
+			return
+		}
+		iterations := float64(result.IterationsDone)
+		if result.AvgIterationDuration > 0 {
+			dps = totals.damage / iterations / result.AvgIterationDuration
+		}
+		return dps, totals.casts / iterations, totals.hits / iterations, totals.crits / iterations
+	}
+
+	deltas := make([]*proto.CompareActionDelta, 0, len(order))
+	seen := map[string]bool{}
+	for _, key := range order {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		baseDps, baseCasts, baseHits, baseCrits := perIteration(baseline, baseTotals[key])
+		varDps, varCasts, varHits, varCrits := perIteration(variant, variantTotals[key])
+		id := variantTotals[key]
+		if id == nil {
+			id = baseTotals[key]
+		}
+		deltas = append(deltas, &proto.CompareActionDelta{
+			Id:    id.id,
+			Dps:   varDps - baseDps,
+			Casts: varCasts - baseCasts,
+			Hits:  varHits - baseHits,
+			Crits: varCrits - baseCrits,
+		})
+	}
+
+	slices.SortStableFunc(deltas, func(a, b *proto.CompareActionDelta) int {
+		if math.Abs(a.Dps) > math.Abs(b.Dps) {
+			return -1
+		} else if math.Abs(a.Dps) < math.Abs(b.Dps) {
+			return 1
+		}
+		return 0
+	})
+	return deltas
+}
+
+func compareVariant(baseline *proto.RaidSimResult, variant *proto.RaidSimResult) *proto.CompareVariantResult {
+	result := &proto.CompareVariantResult{
+		Dps:     pairedDelta(baseline.RaidMetrics.Dps, variant.RaidMetrics.Dps),
+		Hps:     pairedDelta(baseline.RaidMetrics.Hps, variant.RaidMetrics.Hps),
+		Actions: compareActions(baseline, variant),
+	}
+
+	if len(baseline.RaidMetrics.Parties) > 0 && len(baseline.RaidMetrics.Parties[0].Players) > 0 &&
+		len(variant.RaidMetrics.Parties) > 0 && len(variant.RaidMetrics.Parties[0].Players) > 0 {
+		basePlayer := baseline.RaidMetrics.Parties[0].Players[0]
+		variantPlayer := variant.RaidMetrics.Parties[0].Players[0]
+		result.Tps = pairedDelta(basePlayer.Threat, variantPlayer.Threat)
+		result.Dtps = pairedDelta(basePlayer.Dtps, variantPlayer.Dtps)
+	}
+	return result
+}
+
+// Per-iteration values are only needed for the deltas, and make results huge.
+func clearAllValues(result *proto.RaidSimResult) {
+	var clearUnit func(unit *proto.UnitMetrics)
+	clearUnit = func(unit *proto.UnitMetrics) {
+		for _, dist := range []*proto.DistributionMetrics{unit.Dps, unit.Dpasp, unit.Threat, unit.Dtps, unit.Tmi, unit.Hps, unit.Tto} {
+			if dist != nil {
+				dist.AllValues = nil
+			}
+		}
+		for _, pet := range unit.Pets {
+			clearUnit(pet)
+		}
+	}
+
+	result.RaidMetrics.Dps.AllValues = nil
+	result.RaidMetrics.Hps.AllValues = nil
+	for _, party := range result.RaidMetrics.Parties {
+		party.Dps.AllValues = nil
+		party.Hps.AllValues = nil
+		for _, player := range party.Players {
+			clearUnit(player)
+		}
+	}
+	for _, target := range result.EncounterMetrics.Targets {
+		clearUnit(target)
+	}
+}
+
+// Sims all requests one after another with paired RNG and compares them against the first.
+func runCompare(request *proto.CompareRequest, progress chan *proto.ProgressMetrics, signals simsignals.Signals) *proto.CompareResult {
+	if len(request.Requests) < 2 {
+		return &proto.CompareResult{Error: &proto.ErrorOutcome{Message: "Compare needs at least 2 requests!"}}
+	}
+	if request.SimOptions == nil && request.Requests[0].SimOptions == nil {
+		return &proto.CompareResult{Error: &proto.ErrorOutcome{Message: "No sim options set!"}}
+	}
+
+	requests := buildCompareRequests(request)
+
+	runner := &sequentialSimRunner{
+		progress:  progress,
+		signals:   signals,
+		simsTotal: int32(len(requests)),
+	}
+	for _, req := range requests {
+		runner.iterationsTotal += req.SimOptions.Iterations
+	}
+
+	results := make([]*proto.RaidSimResult, len(requests))
+	for i, req := range requests {
+		results[i] = runner.run(req)
+		if results[i].Error != nil {
+			return &proto.CompareResult{Error: results[i].Error}
+		}
+	}
+
+	compareResult := &proto.CompareResult{
+		Results:    results,
+		Iterations: requests[0].SimOptions.Iterations,
+	}
+	for _, result := range results[1:] {
+		compareResult.Variants = append(compareResult.Variants, compareVariant(results[0], result))
+	}
+	for _, result := range results {
+		clearAllValues(result)
+	}
+	return compareResult
+}
+
+// Runs sims one after another with all threads, reporting their progress as a
+// single job of simsTotal sims.
+type sequentialSimRunner struct {
+	progress chan *proto.ProgressMetrics
+	signals  simsignals.Signals
+
+	iterationsTotal int32
+	iterationsDone  int32
+	simsTotal       int32
+	simsCompleted   int32
+	// Reported as the partial result.
+	dps float64
+}
+
+func (runner *sequentialSimRunner) run(request *proto.RaidSimRequest) *proto.RaidSimResult {
+	simFunc := runSimConcurrent
+	// Don't use go threads in wasm, it just adds more overhead and makes the worker more unresponsive.
+	if IsRunningInWasm() || request.SimOptions.IsTest {
+		simFunc = RunSim
+	}
+
+	simProgress := make(chan *proto.ProgressMetrics, 100)
+	go simFunc(request, simProgress, runner.signals)
+
+	var lastCompleted int32 = 0
+	for metrics := range simProgress {
+		runner.iterationsDone += metrics.CompletedIterations - lastCompleted
+		lastCompleted = metrics.CompletedIterations
+
+		if runner.progress != nil {
+			runner.progress <- &proto.ProgressMetrics{
+				TotalIterations:     runner.iterationsTotal,
+				CompletedIterations: runner.iterationsDone,
+				CompletedSims:       runner.simsCompleted,
+				TotalSims:           runner.simsTotal,
+				Dps:                 runner.dps,
+			}
+		}
+
+		if metrics.FinalRaidResult != nil {
+			runner.simsCompleted++
+			return metrics.FinalRaidResult
+		}
+	}
+	return &proto.RaidSimResult{Error: &proto.ErrorOutcome{Type: proto.ErrorOutcomeType_ErrorOutcomeAborted}}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func TestPairedDelta(t *testing.T) {
+	// Noisy values with a small constant improvement: the paired difference has
+	// no spread, although the values themselves vary a lot.
+	baseline := &proto.DistributionMetrics{Avg: 1500, AllValues: []float64{1000, 2000, 1200, 1800}}
+	variant := &proto.DistributionMetrics{Avg: 1510, AllValues: []float64{1010, 2010, 1210, 1810}}
+
+	delta := pairedDelta(baseline, variant)
+	if math.Abs(delta.Mean-10) > 1e-9 || delta.Stdev > 1e-6 {
+		t.Fatalf("mean = %f, stdev = %f, want 10 and 0", delta.Mean, delta.Stdev)
+	}
+	if delta.PValue != 0 {
+		t.Fatalf("p = %f for a difference without noise, want 0", delta.PValue)
+	}
+
+	variant.AllValues = []float64{1020, 1990, 1215, 1795}
+	delta = pairedDelta(baseline, variant)
+	if math.Abs(delta.Mean-5) > 1e-9 {
+		t.Fatalf("mean = %f, want 5", delta.Mean)
+	}
+	if delta.PValue <= 0.05 || delta.PValue >= 1 {
+		t.Fatalf("p = %f for a difference smaller than its noise, want above 0.05", delta.PValue)
+	}
+
+	delta = pairedDelta(baseline, baseline)
+	if delta.Mean != 0 || delta.PValue != 1 {
+		t.Fatalf("mean = %f, p = %f comparing with itself, want 0 and 1", delta.Mean, delta.PValue)
+	}
+}
+
+func TestCompareActions(t *testing.T) {
+	spell := func(id int32, damage float64, casts int32) *proto.ActionMetrics {
+		return &proto.ActionMetrics{
+			Id:      &proto.ActionID{RawId: &proto.ActionID_SpellId{SpellId: id}},
+			Targets: []*proto.TargetedActionMetrics{{Damage: damage, Casts: casts}},
+		}
+	}
+	result := func(actions ...*proto.ActionMetrics) *proto.RaidSimResult {
+		return &proto.RaidSimResult{
+			IterationsDone:       10,
+			AvgIterationDuration: 100,
+			RaidMetrics: &proto.RaidMetrics{
+				Parties: []*proto.PartyMetrics{{Players: []*proto.UnitMetrics{{Actions: actions}}}},
+			},
+		}
+	}
+
+	deltas := compareActions(
+		result(spell(1, 10000, 100), spell(2, 50000, 200)),
+		result(spell(1, 10000, 100), spell(2, 40000, 180), spell(3, 30000, 50)),
+	)
+	if len(deltas) != 3 {
+		t.Fatalf("got %d action deltas, want 3", len(deltas))
+	}
+
+	// 30000 damage over 10 iterations of 100s is 30 DPS.
+	if deltas[0].Id.GetSpellId() != 3 || deltas[0].Dps != 30 || deltas[0].Casts != 5 {
+		t.Fatalf("first delta = %v, want the new spell 3 with +30 DPS and +5 casts", deltas[0])
+	}
+	if deltas[1].Id.GetSpellId() != 2 || deltas[1].Dps != -10 || deltas[1].Casts != -2 {
+		t.Fatalf("second delta = %v, want spell 2 with -10 DPS and -2 casts", deltas[1])
+	}
+	if deltas[2].Dps != 0 {
+		t.Fatalf("unchanged spell has %f DPS delta", deltas[2].Dps)
+	}
+}
@@ -16,6 +16,7 @@ var rwMutex = sync.RWMutex{}
 var ItemsByID = map[int32]Item{}
 var RandomSuffixesByID = map[int32]RandomSuffix{}
 var EnchantsByEffectID = map[int32]Enchant{}
+var RunesByID = map[int32]Rune{}
 
 func addToDatabase(newDB *proto.SimDatabase) {
 	for _, v := range newDB.Items {
@@ -41,6 +42,14 @@ func addToDatabase(newDB *proto.SimDatabase) {
 		}
 		rwMutex.Unlock()
 	}
+
+	for _, v := range newDB.Runes {
+		rwMutex.Lock()
+		if _, ok := RunesByID[v.Id]; !ok {
+			RunesByID[v.Id] = RuneFromProto(v)
+		}
+		rwMutex.Unlock()
+	}
 }
 
 type Item struct {
@@ -69,6 +78,15 @@ type Item struct {
 	Timeworn   bool
 	Sanctified bool
 
+	// Availability, used by the gear optimizer.
+	ILvl                int32
+	Phase               int32
+	Unique              bool
+	RequiredProfession  proto.Profession
+	FactionRestriction  proto.Faction
+	Sources             []proto.ItemSourceType
+	RandomSuffixOptions []int32
+
 	// Modified for each instance of the item.
 	RandomSuffix RandomSuffix
 	Enchant      Enchant
@@ -99,6 +117,13 @@ func ItemFromProto(pData *proto.SimItem) Item {
 		WeaponSkills:        stats.WeaponSkillsFloatArray(pData.WeaponSkills),
 		Timeworn:            pData.Timeworn,
 		Sanctified:          pData.Sanctified,
+		ILvl:                pData.Ilvl,
+		Phase:               pData.Phase,
+		Unique:              pData.Unique,
+		RequiredProfession:  pData.RequiredProfession,
+		FactionRestriction:  pData.FactionRestriction,
+		Sources:             pData.Sources,
+		RandomSuffixOptions: pData.RandomSuffixOptions,
 	}
 }
 
@@ -143,15 +168,29 @@ func EnchantFromProto(pData *proto.SimEnchant) Enchant {
 }
 
 type Rune struct {
-	ID int32
+	ID             int32
+	Type           proto.ItemType
+	ClassAllowlist []proto.Class
 }
 
 func RuneFromProto(pData *proto.SimRune) Rune {
 	return Rune{
-		ID: pData.Id,
+		ID:             pData.Id,
+		Type:           pData.Type,
+		ClassAllowlist: pData.ClassAllowlist,
 	}
 }
 
+// Runes which do nothing without a talent, by rune ID. Given the player's talents string,
+// returns whether the talent is taken.
+var runeTalentRequirements = make(map[int32]func(talentsString string) bool)
+
+// Registers a rune which needs a talent, e.g. Sword and Board needs Shield Slam. Rune searches
+// skip the rune for talents without it.
+func RegisterRuneTalentRequirement(runeID int32, hasTalent func(talentsString string) bool) {
+	runeTalentRequirements[runeID] = hasTalent
+}
+
 type ItemSpec struct {
 	ID           int32
 	RandomSuffix int32
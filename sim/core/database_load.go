@@ -17,6 +17,7 @@ func init() {
 		Items:          make([]*proto.SimItem, len(db.Items)),
 		Enchants:       make([]*proto.SimEnchant, len(db.Enchants)),
 		RandomSuffixes: make([]*proto.ItemRandomSuffix, len(db.RandomSuffixes)),
+		Runes:          make([]*proto.SimRune, len(db.Runes)),
 	}
 
 	for i, item := range db.Items {
@@ -39,6 +40,14 @@ func init() {
 			SetId:               item.SetId,
 			WeaponSkills:        item.WeaponSkills,
 			Timeworn:            item.Timeworn,
+			Sanctified:          item.Sanctified,
+			Ilvl:                item.Ilvl,
+			Phase:               item.Phase,
+			Unique:              item.Unique,
+			RequiredProfession:  item.RequiredProfession,
+			FactionRestriction:  factionRestrictionToFaction(item.FactionRestriction),
+			Sources:             itemSourceTypes(item),
+			RandomSuffixOptions: item.RandomSuffixOptions,
 		}
 	}
 
@@ -57,5 +66,50 @@ func init() {
 		}
 	}
 
+	for i, runeData := range db.Runes {
+		simDB.Runes[i] = &proto.SimRune{
+			Id:             runeData.Id,
+			Type:           runeData.Type,
+			ClassAllowlist: runeData.ClassAllowlist,
+		}
+	}
+
 	addToDatabase(simDB)
 }
+
+func factionRestrictionToFaction(restriction proto.UIItem_FactionRestriction) proto.Faction {
+	switch restriction {
+	case proto.UIItem_FACTION_RESTRICTION_ALLIANCE_ONLY:
+		return proto.Faction_Alliance
+	case proto.UIItem_FACTION_RESTRICTION_HORDE_ONLY:
+		return proto.Faction_Horde
+	}
+	return proto.Faction_Unknown
+}
+
+// Same categories as the item picker's source filters, see filterItemData in player.ts.
+func itemSourceTypes(item *proto.UIItem) []proto.ItemSourceType {
+	var sources []proto.ItemSourceType
+	for _, source := range item.Sources {
+		switch src := source.Source.(type) {
+		case *proto.UIItemSource_Crafted:
+			sources = append(sources, proto.ItemSourceType_ItemSourceCrafted)
+		case *proto.UIItemSource_Quest:
+			sources = append(sources, proto.ItemSourceType_ItemSourceQuest)
+		case *proto.UIItemSource_Rep:
+			sources = append(sources, proto.ItemSourceType_ItemSourceReputation)
+		case *proto.UIItemSource_SoldBy:
+			sources = append(sources, proto.ItemSourceType_ItemSourceVendor)
+		case *proto.UIItemSource_Drop:
+			if _, ok := proto.RaidFilterOption_name[src.Drop.ZoneId]; ok {
+				sources = append(sources, proto.ItemSourceType_ItemSourceRaid)
+			} else if _, ok := proto.DungeonFilterOption_name[src.Drop.ZoneId]; ok {
+				sources = append(sources, proto.ItemSourceType_ItemSourceDungeon)
+			}
+		}
+	}
+	if len(item.RandomSuffixOptions) > 0 {
+		sources = append(sources, proto.ItemSourceType_ItemSourceWorldDrop)
+	}
+	return sources
+}
@@ -0,0 +1,330 @@
+package core
+
+import (
+	"slices"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+	googleProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Tracks player deaths when the raid models them, see Raid.ModelPlayerDeaths.
+// Otherwise deaths only count towards chance of death and the player keeps acting.
+type deathTracker struct {
+	resurrections    []*proto.Resurrection
+	numResurrections int
+
+	isDead bool
+	diedAt time.Duration
+
+	// Permanent auras which were active on death, restored on resurrection.
+	permanentAuras []*Aura
+
+	// Buffs this player provides to the raid and its party, which are lost
+	// while the player is dead unless someone else also provides them.
+	providedRaidBuffs  *proto.RaidBuffs
+	providedPartyBuffs *proto.PartyBuffs
+}
+
+func (character *Character) IsDead() bool {
+	return character.isDead
+}
+
+// Called when the character's health reaches 0.
+func (character *Character) die(sim *Simulation) {
+	character.Metrics.Died = true
+	if sim.Log != nil {
+		character.Log(sim, "Dead")
+	}
+
+	if !character.Env.Raid.ModelPlayerDeaths {
+		return
+	}
+
+	character.isDead = true
+	character.diedAt = sim.CurrentTime
+	character.enabled = false
+
+	character.CancelGCDTimer(sim)
+	if character.hardcastAction != nil && !character.hardcastAction.consumed {
+		character.hardcastAction.Cancel(sim)
+	}
+	character.Hardcast = Hardcast{}
+	character.AutoAttacks.CancelAutoSwing(sim)
+
+	for _, pet := range character.Pets {
+		if pet.IsEnabled() {
+			pet.Disable(sim)
+		}
+	}
+
+	character.permanentAuras = character.permanentAuras[:0]
+	for _, aura := range character.activeAuras {
+		if aura.Duration == NeverExpires {
+			character.permanentAuras = append(character.permanentAuras, aura)
+		}
+	}
+	character.auraTracker.expireAll(sim)
+
+	character.toggleProvidedBuffs(sim, false)
+	character.dropAggro(sim)
+
+	if character.numResurrections < len(character.resurrections) {
+		resurrection := character.resurrections[character.numResurrections]
+		character.numResurrections++
+
+		StartDelayedAction(sim, DelayedActionOptions{
+			DoAt: sim.CurrentTime + DurationFromSeconds(resurrection.Delay),
+			OnAction: func(sim *Simulation) {
+				character.resurrect(sim, resurrection.HealthPercent)
+			},
+		})
+	}
+}
+
+func (character *Character) resurrect(sim *Simulation, healthPercent float64) {
+	if !character.isDead {
+		return
+	}
+
+	character.isDead = false
+	character.enabled = true
+	character.Metrics.DeadTime += sim.CurrentTime - character.diedAt
+
+	if healthPercent <= 0 {
+		healthPercent = 1
+	}
+	character.currentHealth = character.MaxHealth() * min(healthPercent, 1)
+
+	for _, aura := range character.permanentAuras {
+		aura.Activate(sim)
+	}
+	character.toggleProvidedBuffs(sim, true)
+
+	for _, petAgent := range character.PetAgents {
+		if pet := petAgent.GetPet(); pet.enabledOnStart {
+			pet.Enable(sim, petAgent)
+		}
+	}
+
+	character.SetGCDTimer(sim, sim.CurrentTime)
+	character.AutoAttacks.EnableAutoSwing(sim)
+
+	if sim.Log != nil {
+		character.Log(sim, "Resurrected with %0.0f health", character.CurrentHealth())
+	}
+}
+
+// Removes or restores the buffs this character provides to everyone else.
+// Buffs which the config or another living player also provide are kept, e.g.
+// Arcane Brilliance is only lost when the last living mage dies.
+func (character *Character) toggleProvidedBuffs(sim *Simulation, enable bool) {
+	otherRaidBuffs := character.Env.Raid.livingRaidBuffs(character)
+	allRaidBuffs := googleProto.Clone(otherRaidBuffs).(*proto.RaidBuffs)
+	mergeBuffFields(allRaidBuffs, character.providedRaidBuffs)
+
+	for _, party := range character.Env.Raid.Parties {
+		var otherPartyBuffs, allPartyBuffs *proto.PartyBuffs
+		if party == character.Party {
+			otherPartyBuffs = party.livingPartyBuffs(character)
+			allPartyBuffs = googleProto.Clone(otherPartyBuffs).(*proto.PartyBuffs)
+			mergeBuffFields(allPartyBuffs, character.providedPartyBuffs)
+		}
+
+		for _, player := range party.Players {
+			recipient := player.GetCharacter()
+			if recipient == character {
+				continue
+			}
+
+			bonusStats := providedBuffStats(allRaidBuffs, allPartyBuffs, recipient.Level).
+				Subtract(providedBuffStats(otherRaidBuffs, otherPartyBuffs, recipient.Level))
+			if !enable {
+				bonusStats = bonusStats.Invert()
+			}
+			recipient.AddStatsDynamic(sim, bonusStats)
+
+			otherAuras := providedBuffAuras(recipient, otherRaidBuffs)
+			for _, aura := range providedBuffAuras(recipient, allRaidBuffs) {
+				if slices.Contains(otherAuras, aura) {
+					continue
+				}
+				if enable {
+					aura.Activate(sim)
+				} else {
+					aura.Deactivate(sim)
+				}
+			}
+		}
+	}
+}
+
+// Raid buffs from the config and every living player except excluded.
+func (raid *Raid) livingRaidBuffs(excluded *Character) *proto.RaidBuffs {
+	raidBuffs := googleProto.Clone(raid.baseBuffs).(*proto.RaidBuffs)
+	for _, party := range raid.Parties {
+		for _, player := range party.Players {
+			if character := player.GetCharacter(); character != excluded && !character.isDead {
+				mergeBuffFields(raidBuffs, character.providedRaidBuffs)
+			}
+		}
+	}
+	return raidBuffs
+}
+
+// Party buffs from the config and every living party member except excluded.
+func (party *Party) livingPartyBuffs(excluded *Character) *proto.PartyBuffs {
+	partyBuffs := googleProto.Clone(party.baseBuffs).(*proto.PartyBuffs)
+	for _, player := range party.Players {
+		if character := player.GetCharacter(); character != excluded && !character.isDead {
+			mergeBuffFields(partyBuffs, character.providedPartyBuffs)
+		}
+	}
+	return partyBuffs
+}
+
+// Stat bonuses from the raid and party buffs in the given protos. This only
+// covers buffs which players add themselves via AddRaidBuffs/AddPartyBuffs,
+// see applyBuffEffects for how these are applied at the start of the fight.
+func providedBuffStats(raidBuffs *proto.RaidBuffs, partyBuffs *proto.PartyBuffs, level int32) stats.Stats {
+	bonusStats := stats.Stats{}
+
+	if raidBuffs != nil {
+		if raidBuffs.ArcaneBrilliance {
+			bonusStats = bonusStats.Add(BuffSpellByLevel[ArcaneIntellect][level])
+		}
+		if raidBuffs.GiftOfTheWild > 0 {
+			updateStats := BuffSpellByLevel[MarkOfTheWild][level]
+			if raidBuffs.GiftOfTheWild == proto.TristateEffect_TristateEffectImproved {
+				updateStats = updateStats.Multiply(1.35).Floor()
+			}
+			bonusStats = bonusStats.Add(updateStats)
+		}
+		if raidBuffs.PowerWordFortitude > 0 {
+			updateStats := BuffSpellByLevel[PowerWordFortitude][level]
+			if raidBuffs.PowerWordFortitude == proto.TristateEffect_TristateEffectImproved {
+				updateStats = updateStats.Multiply(1.3).Floor()
+			}
+			bonusStats = bonusStats.Add(updateStats)
+		}
+		if raidBuffs.BloodPact > 0 {
+			updateStats := BuffSpellByLevel[BloodPact][level]
+			if raidBuffs.BloodPact == proto.TristateEffect_TristateEffectImproved {
+				updateStats = updateStats.Multiply(1.3).Floor()
+			}
+			bonusStats = bonusStats.Add(updateStats)
+		}
+		if raidBuffs.ShadowProtection {
+			bonusStats = bonusStats.Add(BuffSpellByLevel[ShadowProtection][level])
+		}
+		if raidBuffs.DivineSpirit {
+			bonusStats = bonusStats.Add(BuffSpellByLevel[DivineSpirit][level])
+		}
+		if raidBuffs.MoonkinAura {
+			bonusStats[stats.SpellCrit] += 3 * SpellCritRatingPerCritChance
+		}
+		if raidBuffs.LeaderOfThePack {
+			bonusStats[stats.MeleeCrit] += 3 * CritRatingPerCritChance
+		}
+	}
+
+	if partyBuffs != nil {
+		bonusStats[stats.SpellCrit] += 2 * SpellCritRatingPerCritChance * float64(partyBuffs.AtieshMage)
+		bonusStats[stats.SpellPower] += 33 * float64(partyBuffs.AtieshWarlock)
+	}
+
+	return bonusStats
+}
+
+// Buff auras registered on recipient for the given raid buffs.
+func providedBuffAuras(recipient *Character, raidBuffs *proto.RaidBuffs) []*Aura {
+	if raidBuffs == nil {
+		return nil
+	}
+
+	var auras []*Aura
+	if raidBuffs.AspectOfTheLion {
+		if aura := recipient.GetAura("Heart of the Lion"); aura != nil {
+			auras = append(auras, aura)
+		}
+	}
+	if raidBuffs.TrueshotAura {
+		if aura := recipient.GetAura("Trueshot Aura"); aura != nil {
+			auras = append(auras, aura)
+		}
+	}
+	return auras
+}
+
+// Enemies attacking a dead player move on to someone else.
+func (character *Character) dropAggro(sim *Simulation) {
+	for _, target := range character.Env.Encounter.TargetUnits {
+		if target.ThreatTable != nil {
+			target.ThreatTable.dropUnit(sim, &character.Unit)
+			continue
+		}
+
+		if target.CurrentTarget != &character.Unit {
+			continue
+		}
+		for _, player := range character.Env.Raid.AllPlayerUnits {
+			if player.enabled {
+				target.CurrentTarget = player
+				break
+			}
+		}
+	}
+}
+
+// Returns the fields of after which differ from before, i.e. the buffs a
+// single player added in AddRaidBuffs/AddPartyBuffs. Counts such as
+// PartyBuffs.AtieshMage are returned as the amount added.
+func changedBuffFields[T googleProto.Message](before T, after T) T {
+	changed := after.ProtoReflect().New()
+	beforeMsg := before.ProtoReflect()
+	after.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if beforeMsg.Has(fd) && beforeMsg.Get(fd).Equal(v) {
+			return true
+		}
+		if fd.Kind() == protoreflect.Int32Kind {
+			v = protoreflect.ValueOfInt32(int32(v.Int() - beforeMsg.Get(fd).Int()))
+		}
+		changed.Set(fd, v)
+		return true
+	})
+	return changed.Interface().(T)
+}
+
+// Adds the buffs from changedBuffFields to buffs. Counts stack, tristates
+// keep the better value and everything else is overwritten.
+func mergeBuffFields[T googleProto.Message](buffs T, provided T) {
+	buffsMsg := buffs.ProtoReflect()
+	provided.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch fd.Kind() {
+		case protoreflect.Int32Kind:
+			v = protoreflect.ValueOfInt32(int32(buffsMsg.Get(fd).Int() + v.Int()))
+		case protoreflect.EnumKind:
+			v = protoreflect.ValueOfEnum(max(buffsMsg.Get(fd).Enum(), v.Enum()))
+		}
+		buffsMsg.Set(fd, v)
+		return true
+	})
+}
+
+func (character *Character) resetDeathTracker() {
+	character.isDead = false
+	character.numResurrections = 0
+}
+
+// Credits time spent dead. Buff stats and auras lost to the death come back
+// when the next iteration resets every unit.
+func (character *Character) doneIterationDeathTracker(sim *Simulation) {
+	if !character.isDead {
+		return
+	}
+
+	character.Metrics.DeadTime += sim.CurrentTime - character.diedAt
+	character.isDead = false
+}
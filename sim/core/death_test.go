@@ -0,0 +1,161 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	"github.com/wowsims/sod/sim/core/stats"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+func init() {
+	RegisterAgentFactory(
+		proto.Player_Mage{},
+		proto.Spec_SpecMage,
+		func(char *Character, _ *proto.Player) Agent {
+			return &fakeMage{FakeAgent: FakeAgent{Character: *char}}
+		},
+		func(player *proto.Player, spec interface{}) {
+			playerSpec, ok := spec.(*proto.Player_Mage)
+			if !ok {
+				panic("Invalid spec value for Mage!")
+			}
+			player.Spec = playerSpec
+		},
+	)
+}
+
+type fakeMage struct {
+	FakeAgent
+}
+
+func (fm *fakeMage) AddRaidBuffs(raidBuffs *proto.RaidBuffs) {
+	raidBuffs.ArcaneBrilliance = true
+}
+
+// Two mages providing Arcane Brilliance and a shaman receiving it.
+func setupDeathSim() *Simulation {
+	newPlayer := func(name string, class proto.Class, spec interface{}) *proto.Player {
+		return WithSpec(&proto.Player{
+			Name:      name,
+			Race:      proto.Race_RaceTroll,
+			Class:     class,
+			Level:     60,
+			Consumes:  &proto.Consumes{},
+			Buffs:     &proto.IndividualBuffs{},
+			Equipment: &proto.EquipmentSpec{},
+		}, spec)
+	}
+
+	sim := NewSim(&proto.RaidSimRequest{
+		SimOptions: &proto.SimOptions{
+			RandomSeed: 100,
+		},
+		Raid: &proto.Raid{
+			Parties: []*proto.Party{
+				{
+					Players: []*proto.Player{
+						newPlayer("Mage 1", proto.Class_ClassMage, &proto.Player_Mage{Mage: &proto.Mage{}}),
+						newPlayer("Mage 2", proto.Class_ClassMage, &proto.Player_Mage{Mage: &proto.Mage{}}),
+						newPlayer("Recipient", proto.Class_ClassShaman, &proto.Player_ElementalShaman{}),
+					},
+					Buffs: &proto.PartyBuffs{},
+				},
+			},
+			ModelPlayerDeaths: true,
+		},
+		Encounter: &proto.Encounter{
+			Targets: []*proto.Target{
+				{Name: "target", Level: 63, MobType: proto.MobType_MobTypeDemon},
+			},
+			Duration: 180,
+		},
+	}, simsignals.CreateSignals())
+	sim.Reset()
+
+	return sim
+}
+
+func TestDeathSharedBuffs(t *testing.T) {
+	sim := setupDeathSim()
+	players := sim.Raid.Parties[0].Players
+	mage1, mage2, recipient := players[0].GetCharacter(), players[1].GetCharacter(), players[2].GetCharacter()
+
+	buffedIntellect := recipient.GetStat(stats.Intellect)
+	arcaneIntellect := BuffSpellByLevel[ArcaneIntellect][60][stats.Intellect]
+
+	expectIntellect := func(action string, expected float64) {
+		t.Helper()
+		if actual := recipient.GetStat(stats.Intellect); actual != expected {
+			t.Fatalf("Expected %0.0f intellect after %s, got %0.0f", expected, action, actual)
+		}
+	}
+
+	mage1.die(sim)
+	expectIntellect("the first mage dies", buffedIntellect)
+
+	mage2.die(sim)
+	expectIntellect("both mages die", buffedIntellect-arcaneIntellect)
+
+	mage1.resurrect(sim, 1)
+	expectIntellect("the first mage is resurrected", buffedIntellect)
+
+	mage1.die(sim)
+	expectIntellect("the first mage dies again", buffedIntellect-arcaneIntellect)
+
+	// Stats lost to deaths come back with the next iteration.
+	sim.Reset()
+	expectIntellect("a reset", buffedIntellect)
+	if mage1.IsDead() || mage2.IsDead() {
+		t.Fatalf("Expected the mages to be alive after a reset")
+	}
+}
+
+func TestDeathStatsOnResurrect(t *testing.T) {
+	sim := setupDeathSim()
+	mage := sim.Raid.Parties[0].Players[0].GetCharacter()
+
+	initialStats := mage.GetStats()
+
+	mage.die(sim)
+	if !mage.IsDead() || mage.IsEnabled() {
+		t.Fatalf("Expected the mage to be dead and disabled")
+	}
+
+	mage.resurrect(sim, 0.5)
+	if mage.IsDead() || !mage.IsEnabled() {
+		t.Fatalf("Expected the mage to be alive and enabled")
+	}
+	if !mage.GetStats().Equals(initialStats) {
+		t.Fatalf("Expected stats %s after resurrection, got %s", initialStats, mage.GetStats())
+	}
+	if expected := mage.MaxHealth() * 0.5; mage.CurrentHealth() != expected {
+		t.Fatalf("Expected %0.0f health after resurrection, got %0.0f", expected, mage.CurrentHealth())
+	}
+}
+
+func TestMergeBuffFields(t *testing.T) {
+	base := &proto.PartyBuffs{AtieshMage: 1}
+	provider := googleProto.Clone(base).(*proto.PartyBuffs)
+	provider.AtieshMage += 1
+	provider.AtieshWarlock += 1
+
+	provided := changedBuffFields(base, provider)
+	if provided.AtieshMage != 1 || provided.AtieshWarlock != 1 {
+		t.Fatalf("Expected counts to be returned as the amount added, got %v", provided)
+	}
+
+	partyBuffs := googleProto.Clone(base).(*proto.PartyBuffs)
+	mergeBuffFields(partyBuffs, provided)
+	mergeBuffFields(partyBuffs, provided)
+	if partyBuffs.AtieshMage != 3 || partyBuffs.AtieshWarlock != 2 {
+		t.Fatalf("Expected counts from every provider to stack, got %v", partyBuffs)
+	}
+
+	raidBuffs := &proto.RaidBuffs{PowerWordFortitude: proto.TristateEffect_TristateEffectImproved}
+	mergeBuffFields(raidBuffs, &proto.RaidBuffs{PowerWordFortitude: proto.TristateEffect_TristateEffectRegular, ArcaneBrilliance: true})
+	if raidBuffs.PowerWordFortitude != proto.TristateEffect_TristateEffectImproved || !raidBuffs.ArcaneBrilliance {
+		t.Fatalf("Expected tristates to keep the better value, got %v", raidBuffs)
+	}
+}
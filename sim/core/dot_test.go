@@ -127,6 +127,39 @@ func SetupFakeSim() *Simulation {
 	return sim
 }
 
+// A request for a single fake elemental shaman, see NewFakeElementalShaman.
+// Tests change the sim options and add any buffs or debuffs they need.
+func fakeRaidSimRequest(rotation *proto.APLRotation, targets []*proto.Target, duration float64) *proto.RaidSimRequest {
+	return &proto.RaidSimRequest{
+		SimOptions: &proto.SimOptions{
+			RandomSeed: 100,
+		},
+		Raid: &proto.Raid{
+			Parties: []*proto.Party{
+				{
+					Players: []*proto.Player{
+						{
+							Name:      "Caster",
+							Class:     proto.Class_ClassShaman,
+							Level:     60,
+							Consumes:  &proto.Consumes{},
+							Buffs:     &proto.IndividualBuffs{},
+							Spec:      &proto.Player_ElementalShaman{},
+							Equipment: &proto.EquipmentSpec{},
+							Rotation:  rotation,
+						},
+					},
+					Buffs: &proto.PartyBuffs{},
+				},
+			},
+		},
+		Encounter: &proto.Encounter{
+			Targets:  targets,
+			Duration: duration,
+		},
+	}
+}
+
 func expectDotTickDamage(t *testing.T, sim *Simulation, dot *Dot, expectedDamage float64) {
 	damageBefore := dot.Spell.SpellMetrics[0].TotalDamage
 	dot.TickOnce(sim)
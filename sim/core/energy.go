@@ -161,7 +161,7 @@ func (eb *energyBar) addEnergyInternal(sim *Simulation, amount float64, metrics
 	metrics.AddEvent(amount, newEnergy-eb.currentEnergy)
 
 	if sim.Log != nil {
-		eb.unit.Log(sim, "Gained %0.3f energy from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, eb.currentEnergy, newEnergy)
+		eb.unit.logResourceChange(sim, proto.LogEventType_LogEventResourceGain, metrics, amount, eb.currentEnergy, newEnergy)
 	}
 
 	crossedThreshold := eb.cumulativeEnergyDecisionThresholds == nil || eb.cumulativeEnergyDecisionThresholds[int(eb.currentEnergy)] != eb.cumulativeEnergyDecisionThresholds[int(newEnergy)]
@@ -183,7 +183,7 @@ func (eb *energyBar) SpendEnergy(sim *Simulation, amount float64, metrics *Resou
 	metrics.AddEvent(-amount, -amount)
 
 	if sim.Log != nil {
-		eb.unit.Log(sim, "Spent %0.3f energy from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, eb.currentEnergy, newEnergy)
+		eb.unit.logResourceChange(sim, proto.LogEventType_LogEventResourceSpend, metrics, amount, eb.currentEnergy, newEnergy)
 	}
 
 	eb.currentEnergy = newEnergy
@@ -47,14 +47,14 @@ type Environment struct {
 	prepullActions []PrepullAction
 }
 
-func NewEnvironment(raidProto *proto.Raid, encounterProto *proto.Encounter, runFakePrepull bool) (*Environment, *proto.RaidStats, *proto.EncounterStats) {
+func NewEnvironment(raidProto *proto.Raid, encounterProto *proto.Encounter, computeStats bool) (*Environment, *proto.RaidStats, *proto.EncounterStats) {
 	env := &Environment{
 		State: Created,
 	}
 
 	env.construct(raidProto, encounterProto)
 	raidStats := env.initialize(raidProto, encounterProto)
-	env.finalize(raidProto, encounterProto, raidStats, runFakePrepull)
+	env.finalize(raidProto, encounterProto, raidStats, computeStats)
 
 	encounterStats := &proto.EncounterStats{}
 	for _, target := range env.Encounter.Targets {
@@ -109,6 +109,18 @@ func (env *Environment) construct(raidProto *proto.Raid, encounterProto *proto.E
 		}
 	}
 
+	if encounterProto.UseThreatTable {
+		env.Encounter.UseThreatTable = true
+		for _, target := range env.Encounter.Targets {
+			// Without an assigned tank, open on the first player. Their threat
+			// starts at 0, so whoever generates threat first takes over.
+			if target.CurrentTarget == nil && len(env.Raid.AllPlayerUnits) > 0 {
+				target.CurrentTarget = env.Raid.AllPlayerUnits[0]
+			}
+			target.ThreatTable = newThreatTable(&target.Unit, len(env.AllUnits))
+		}
+	}
+
 	env.State = Constructed
 }
 
@@ -141,7 +153,7 @@ func (env *Environment) initialize(raidProto *proto.Raid, encounterProto *proto.
 }
 
 // The finalization phase.
-func (env *Environment) finalize(raidProto *proto.Raid, _ *proto.Encounter, raidStats *proto.RaidStats, runFakePrepull bool) {
+func (env *Environment) finalize(raidProto *proto.Raid, _ *proto.Encounter, raidStats *proto.RaidStats, computeStats bool) {
 	for _, finalizeEffect := range env.preFinalizeEffects {
 		finalizeEffect()
 	}
@@ -175,6 +187,10 @@ func (env *Environment) finalize(raidProto *proto.Raid, _ *proto.Encounter, raid
 			playerProto := partyProto.Players[playerIdx]
 			char := player.GetCharacter()
 			char.Rotation = char.newAPLRotation(playerProto.Rotation)
+			// Linting re-parses parts of the rotation, so it's only done when the results are shown.
+			if computeStats && char.Rotation != nil {
+				char.Rotation.lint(playerProto.Rotation)
+			}
 		}
 	}
 
@@ -191,7 +207,7 @@ func (env *Environment) finalize(raidProto *proto.Raid, _ *proto.Encounter, raid
 		return int(a1.DoAt - a2.DoAt)
 	})
 
-	if runFakePrepull {
+	if computeStats {
 		// Runs prepull only, for a single iteration. This lets us detect misconfigured
 		// prepull spells (e.g. GCD not available) in APL.
 		sim := newSimWithEnv(env, &proto.SimOptions{
@@ -264,6 +280,7 @@ func (env *Environment) reset(sim *Simulation) {
 	for _, target := range env.Encounter.Targets {
 		target.Reset(sim)
 	}
+	env.Encounter.updateActiveTargets()
 
 	env.Raid.reset(sim)
 }
@@ -0,0 +1,817 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	goproto "google.golang.org/protobuf/proto"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	"github.com/wowsims/sod/sim/core/stats"
+)
+
+const (
+	defaultGearOptimizeMaxSims           = 300
+	defaultGearOptimizeIterations        = 1000
+	defaultGearOptimizeCandidatesPerSlot = 6
+	defaultGearOptimizeNumResults        = 5
+
+	// Chance for a proposed move to equip several pieces of a set at once, rather than a single item.
+	gearOptimizeSetMoveChance = 0.2
+	// Starting annealing temperature, as a fraction of the starting score.
+	gearOptimizeStartTemperature = 0.005
+)
+
+// Highest armor type each class can wear at max level.
+var maxArmorTypeByClass = map[proto.Class]proto.ArmorType{
+	proto.Class_ClassDruid:   proto.ArmorType_ArmorTypeLeather,
+	proto.Class_ClassHunter:  proto.ArmorType_ArmorTypeMail,
+	proto.Class_ClassMage:    proto.ArmorType_ArmorTypeCloth,
+	proto.Class_ClassPaladin: proto.ArmorType_ArmorTypePlate,
+	proto.Class_ClassPriest:  proto.ArmorType_ArmorTypeCloth,
+	proto.Class_ClassRogue:   proto.ArmorType_ArmorTypeLeather,
+	proto.Class_ClassShaman:  proto.ArmorType_ArmorTypeMail,
+	proto.Class_ClassWarlock: proto.ArmorType_ArmorTypeCloth,
+	proto.Class_ClassWarrior: proto.ArmorType_ArmorTypePlate,
+}
+
+// Classes which can always dual wield. Others only get off hand weapons if they already have one equipped.
+var dualWieldClasses = []proto.Class{proto.Class_ClassHunter, proto.Class_ClassRogue, proto.Class_ClassWarrior}
+
+const numGearSlots = int(proto.ItemSlot_ItemSlotRanged) + 1
+
+// An item which survived filtering and pruning, along with the EP used to rank it.
+type gearCandidate struct {
+	item Item
+	spec *proto.ItemSpec
+	ep   float64
+}
+
+// A full set of equipped items, indexed by slot.
+type gearSet []*proto.ItemSpec
+
+func (gs gearSet) clone() gearSet {
+	return slices.Clone(gs)
+}
+
+// Canonical key for a gear set, so rings and trinkets in swapped slots are only simmed once.
+func (gs gearSet) hash() string {
+	ids := make([]string, numGearSlots)
+	for i, spec := range gs {
+		ids[i] = fmt.Sprintf("%d/%d/%d", spec.Id, spec.RandomSuffix, spec.Rune)
+	}
+	for _, pair := range [][2]proto.ItemSlot{
+		{proto.ItemSlot_ItemSlotFinger1, proto.ItemSlot_ItemSlotFinger2},
+		{proto.ItemSlot_ItemSlotTrinket1, proto.ItemSlot_ItemSlotTrinket2},
+	} {
+		if ids[pair[0]] > ids[pair[1]] {
+			ids[pair[0]], ids[pair[1]] = ids[pair[1]], ids[pair[0]]
+		}
+	}
+	return strings.Join(ids, ":")
+}
+
+type gearOptimizeSimResult struct {
+	gear   gearSet
+	result *proto.RaidSimResult
+}
+
+// Score used to rank results.
+func (r *gearOptimizeSimResult) Score() float64 {
+	if r.result == nil || r.result.Error != nil {
+		return 0
+	}
+	return r.result.RaidMetrics.Dps.Avg
+}
+
+type gearOptimizer struct {
+	request  *proto.GearOptimizeRequest
+	settings *proto.GearOptimizeSettings
+	player   *proto.Player
+	weights  UnitStats
+
+	equipped gearSet
+	locked   [numGearSlots]bool
+
+	// EP-pruned candidates for each slot, best first.
+	pools [numGearSlots][]*gearCandidate
+	// Filtered set pieces, grouped by set name. Only sets with registered bonuses are included.
+	setPieces map[string][]*gearCandidate
+	setNames  []string
+	// Runes the player can engrave on each unlocked slot, by ID.
+	runes [numGearSlots][]int32
+
+	maxSims    int32
+	iterations int32
+	simsRun    int32
+
+	results map[string]*gearOptimizeSimResult
+	rand    *rand.Rand
+}
+
+func GearOptimize(signals simsignals.Signals, request *proto.GearOptimizeRequest, progress chan *proto.ProgressMetrics) *proto.GearOptimizeResult {
+	opt := &gearOptimizer{
+		request:  request,
+		settings: request.GetGearOptimizeSettings(),
+	}
+
+	result := opt.Run(signals, progress)
+
+	if progress != nil {
+		progress <- &proto.ProgressMetrics{
+			FinalGearOptimizeResult: result,
+		}
+		close(progress)
+	}
+
+	return result
+}
+
+func (opt *gearOptimizer) Run(signals simsignals.Signals, progress chan *proto.ProgressMetrics) (result *proto.GearOptimizeResult) {
+	defer func() {
+		if err := recover(); err != nil {
+			result = &proto.GearOptimizeResult{
+				Error: &proto.ErrorOutcome{Message: fmt.Sprintf("%v\nStack Trace:\n%s", err, string(debug.Stack()))},
+			}
+		}
+		signals.Abort.Trigger()
+	}()
+
+	if err := opt.setup(); err != nil {
+		return &proto.GearOptimizeResult{Error: &proto.ErrorOutcome{Message: err.Error()}}
+	}
+	opt.buildPools()
+
+	numResults := int(opt.settings.GetNumResults())
+	if numResults <= 0 {
+		numResults = defaultGearOptimizeNumResults
+	}
+	// Leave room in the budget to re-sim the best results and the equipped gear.
+	searchBudget := opt.maxSims - int32(numResults) - 1
+	if searchBudget < 2 {
+		return &proto.GearOptimizeResult{
+			Error: &proto.ErrorOutcome{Message: fmt.Sprintf("gear optimizer: budget of %d sims is too small for %d results", opt.maxSims, numResults)},
+		}
+	}
+
+	// Start from whichever is better, the equipped gear or the best gear by EP alone.
+	starts := []gearSet{opt.equipped}
+	if greedy := opt.greedyGear(); greedy != nil {
+		starts = append(starts, greedy)
+	}
+	evaluated, errorOutcome := opt.simGearSets(signals, starts, opt.iterations)
+	if errorOutcome != nil {
+		return &proto.GearOptimizeResult{Error: errorOutcome}
+	}
+	current := evaluated[0]
+	for _, r := range evaluated[1:] {
+		if r.Score() > current.Score() {
+			current = r
+		}
+	}
+	best := current
+	opt.reportProgress(progress, best)
+
+	startTemperature := math.Max(current.Score()*gearOptimizeStartTemperature, 1)
+	batchSize := simThreads(opt.request.BaseSettings.GetSimOptions())
+	for opt.simsRun < searchBudget && !signals.Abort.IsTriggered() {
+		batch := opt.proposeBatch(current.gear, min(batchSize, int(searchBudget-opt.simsRun)))
+		if len(batch) == 0 {
+			// Every neighbour within reach has been simmed already.
+			break
+		}
+
+		evaluated, errorOutcome := opt.simGearSets(signals, batch, opt.iterations)
+		if errorOutcome != nil {
+			return &proto.GearOptimizeResult{Error: errorOutcome}
+		}
+
+		temperature := startTemperature * (1 - float64(opt.simsRun)/float64(searchBudget))
+		for _, candidate := range evaluated {
+			delta := candidate.Score() - current.Score()
+			if delta > 0 || (temperature > 0 && opt.rand.Float64() < math.Exp(delta/temperature)) {
+				current = candidate
+			}
+			if candidate.Score() > best.Score() {
+				best = candidate
+			}
+		}
+		opt.reportProgress(progress, best)
+	}
+
+	// Re-sim the best results with full iterations, since the search used fewer and noisier ones.
+	ranked := make([]*gearOptimizeSimResult, 0, len(opt.results))
+	for _, r := range opt.results {
+		ranked = append(ranked, r)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].Score() > ranked[j].Score()
+	})
+	if len(ranked) > numResults {
+		ranked = ranked[:numResults]
+	}
+
+	finalGear := []gearSet{opt.equipped}
+	for _, r := range ranked {
+		if r.gear.hash() != opt.equipped.hash() {
+			finalGear = append(finalGear, r.gear)
+		}
+	}
+	finalIterations := max(opt.request.GetBaseSettings().GetSimOptions().GetIterations(), opt.iterations)
+	final, errorOutcome := opt.simGearSets(signals, finalGear, finalIterations)
+	if errorOutcome != nil {
+		return &proto.GearOptimizeResult{Error: errorOutcome}
+	}
+
+	result = &proto.GearOptimizeResult{
+		EquippedGearResult: opt.toCandidateProto(final[0]),
+	}
+	sort.Slice(final, func(i, j int) bool {
+		return final[i].Score() > final[j].Score()
+	})
+	for _, r := range final[:min(len(final), numResults)] {
+		result.Results = append(result.Results, opt.toCandidateProto(r))
+	}
+	result.SimsRun = opt.simsRun
+
+	return result
+}
+
+func (opt *gearOptimizer) setup() error {
+	// Like bulk sims, the optimizer only supports a single player.
+	var playerCount int
+	for _, p := range opt.request.GetBaseSettings().GetRaid().GetParties() {
+		for _, pl := range p.GetPlayers() {
+			if pl.Name != "" {
+				opt.player = pl
+				playerCount++
+			}
+		}
+	}
+	if playerCount != 1 || opt.player == nil {
+		return fmt.Errorf("gear optimizer: expected exactly 1 player, found %d", playerCount)
+	}
+	if opt.player.GetDatabase() != nil {
+		addToDatabase(opt.player.GetDatabase())
+	}
+	// reduce to just base party.
+	opt.request.BaseSettings.Raid.Parties = []*proto.Party{opt.request.BaseSettings.Raid.Parties[0]}
+	// clean to reduce memory
+	opt.player.Database = nil
+
+	epWeights := opt.settings.GetEpWeights()
+	if epWeights == nil || (!slices.ContainsFunc(epWeights.Stats, isNonZero) && !slices.ContainsFunc(epWeights.PseudoStats, isNonZero)) {
+		return fmt.Errorf("gear optimizer: EP weights are required")
+	}
+	opt.weights = NewUnitStats()
+	opt.weights.Stats = stats.FromFloatArray(epWeights.Stats)
+	copy(opt.weights.PseudoStats, epWeights.PseudoStats)
+
+	if opt.player.Equipment == nil {
+		opt.player.Equipment = &proto.EquipmentSpec{}
+	}
+	opt.equipped = make(gearSet, numGearSlots)
+	for i := range opt.equipped {
+		if i < len(opt.player.Equipment.Items) && opt.player.Equipment.Items[i] != nil {
+			opt.equipped[i] = opt.player.Equipment.Items[i]
+		} else {
+			opt.equipped[i] = &proto.ItemSpec{}
+		}
+	}
+	for _, slot := range opt.settings.GetLockedSlots() {
+		opt.locked[slot] = true
+	}
+
+	opt.maxSims = opt.settings.GetMaxSims()
+	if opt.maxSims <= 0 {
+		opt.maxSims = defaultGearOptimizeMaxSims
+	}
+	opt.iterations = opt.settings.GetIterationsPerSim()
+	if opt.iterations <= 0 {
+		opt.iterations = defaultGearOptimizeIterations
+	}
+
+	opt.results = make(map[string]*gearOptimizeSimResult)
+	opt.rand = rand.New(rand.NewSource(opt.request.GetBaseSettings().GetSimOptions().GetRandomSeed()))
+	return nil
+}
+
+func isNonZero(value float64) bool {
+	return value != 0
+}
+
+// Returns the best random suffix for the item by EP, and that EP.
+func (opt *gearOptimizer) bestRandomSuffix(item Item) (int32, float64) {
+	var bestID int32
+	bestEP := 0.0
+	for _, id := range item.RandomSuffixOptions {
+		suffix, ok := RandomSuffixesByID[id]
+		if !ok {
+			continue
+		}
+		if ep := opt.statsEP(suffix.Stats); bestID == 0 || ep > bestEP {
+			bestID, bestEP = id, ep
+		}
+	}
+	return bestID, bestEP
+}
+
+func (opt *gearOptimizer) statsEP(itemStats stats.Stats) float64 {
+	ep := 0.0
+	for i, value := range itemStats {
+		ep += value * opt.weights.Stats[i]
+	}
+	return ep
+}
+
+func (opt *gearOptimizer) pseudoStatEP(stat proto.PseudoStat, value float64) float64 {
+	return opt.weights.Get(stats.UnitStatFromPseudoStat(stat)) * value
+}
+
+// Same as computeItemEP in player.ts, so pruning agrees with the item picker.
+func (opt *gearOptimizer) itemEP(item Item, slot proto.ItemSlot) float64 {
+	ep := opt.statsEP(item.Stats)
+
+	if item.SwingSpeed > 0 {
+		weaponDps := (item.WeaponDamageMin + item.WeaponDamageMax) / 2 / item.SwingSpeed
+		switch slot {
+		case proto.ItemSlot_ItemSlotMainHand:
+			ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatMainHandDps, weaponDps)
+		case proto.ItemSlot_ItemSlotOffHand:
+			ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatOffHandDps, weaponDps)
+		case proto.ItemSlot_ItemSlotRanged:
+			ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatRangedDps, weaponDps)
+		}
+	}
+
+	ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatBonusPhysicalDamage, item.BonusPhysicalDamage)
+	ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatMeleeSpeedMultiplier, item.Stats[stats.MeleeHaste])
+	ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatRangedSpeedMultiplier, item.Stats[stats.MeleeHaste])
+	ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatCastSpeedMultiplier, item.Stats[stats.SpellHaste])
+	if item.Timeworn {
+		ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatTimewornBonus, 1)
+	}
+	if item.Sanctified {
+		ep += opt.pseudoStatEP(proto.PseudoStat_PseudoStatSanctifiedBonus, 1)
+	}
+
+	_, suffixEP := opt.bestRandomSuffix(item)
+	ep += suffixEP
+
+	// unique items are slightly worse than non-unique because you can have only one.
+	if item.Unique {
+		ep -= 0.01
+	}
+
+	return ep
+}
+
+func (opt *gearOptimizer) gearEP(gear gearSet) float64 {
+	ep := 0.0
+	for slot, spec := range gear {
+		if item, ok := ItemsByID[spec.Id]; ok {
+			ep += opt.itemEP(item, proto.ItemSlot(slot))
+		}
+	}
+	return ep
+}
+
+// Returns whether the player could obtain and use the item in the given slot, according to the filters.
+func (opt *gearOptimizer) isAllowed(item Item, slot proto.ItemSlot) bool {
+	filters := opt.settings.GetFilters()
+	player := opt.player
+
+	if phase := opt.settings.GetPhase(); phase > 0 && item.Phase > phase {
+		return false
+	}
+	if player.Level > 0 && item.RequiresLevel > player.Level {
+		return false
+	}
+	if len(item.ClassAllowlist) > 0 && !slices.Contains(item.ClassAllowlist, player.Class) {
+		return false
+	}
+	if filters.GetMinIlvl() > 0 && item.ILvl < filters.GetMinIlvl() {
+		return false
+	}
+	if filters.GetMaxIlvl() > 0 && item.ILvl > filters.GetMaxIlvl() {
+		return false
+	}
+	if filters.GetFaction() != proto.Faction_Unknown && item.FactionRestriction != proto.Faction_Unknown && item.FactionRestriction != filters.GetFaction() {
+		return false
+	}
+	if item.RequiredProfession != proto.Profession_ProfessionUnknown &&
+		item.RequiredProfession != player.Profession1 && item.RequiredProfession != player.Profession2 &&
+		!slices.Contains(filters.GetProfessions(), item.RequiredProfession) {
+		return false
+	}
+	if len(filters.GetSources()) > 0 {
+		for _, source := range item.Sources {
+			if !slices.Contains(filters.GetSources(), source) {
+				return false
+			}
+		}
+	}
+	if slices.Contains(filters.GetExcludedItems(), item.ID) {
+		return false
+	}
+
+	switch slot {
+	case proto.ItemSlot_ItemSlotMainHand, proto.ItemSlot_ItemSlotOffHand:
+		return opt.isWeaponAllowed(item, slot)
+	case proto.ItemSlot_ItemSlotRanged:
+		if len(filters.GetRangedWeaponTypes()) > 0 {
+			return slices.Contains(filters.GetRangedWeaponTypes(), item.RangedWeaponType)
+		}
+		equipped, ok := ItemsByID[opt.equipped[slot].Id]
+		return ok && equipped.RangedWeaponType == item.RangedWeaponType
+	}
+
+	if len(filters.GetArmorTypes()) > 0 {
+		return item.ArmorType == proto.ArmorType_ArmorTypeUnknown || slices.Contains(filters.GetArmorTypes(), item.ArmorType)
+	}
+	return item.ArmorType <= maxArmorTypeByClass[player.Class]
+}
+
+func (opt *gearOptimizer) isWeaponAllowed(item Item, slot proto.ItemSlot) bool {
+	filters := opt.settings.GetFilters()
+
+	if item.HandType == proto.HandType_HandTypeTwoHand && filters.GetExcludeTwoHandedWeapons() {
+		return false
+	}
+	if item.HandType != proto.HandType_HandTypeTwoHand && filters.GetExcludeOneHandedWeapons() {
+		return false
+	}
+
+	if len(filters.GetWeaponTypes()) > 0 {
+		if !slices.Contains(filters.GetWeaponTypes(), item.WeaponType) {
+			return false
+		}
+	} else {
+		// Default to the weapon types the player already uses.
+		mainHand, mhOk := ItemsByID[opt.equipped[proto.ItemSlot_ItemSlotMainHand].Id]
+		offHand, ohOk := ItemsByID[opt.equipped[proto.ItemSlot_ItemSlotOffHand].Id]
+		if !(mhOk && mainHand.WeaponType == item.WeaponType) && !(ohOk && offHand.WeaponType == item.WeaponType) {
+			return false
+		}
+	}
+
+	if slot == proto.ItemSlot_ItemSlotOffHand && item.IsWeapon() {
+		offHand, ok := ItemsByID[opt.equipped[proto.ItemSlot_ItemSlotOffHand].Id]
+		if !slices.Contains(dualWieldClasses, opt.player.Class) && !(ok && offHand.IsWeapon()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (opt *gearOptimizer) newCandidate(item Item, slot proto.ItemSlot) *gearCandidate {
+	candidate := &gearCandidate{
+		item: item,
+		spec: &proto.ItemSpec{Id: item.ID},
+		ep:   opt.itemEP(item, slot),
+	}
+	candidate.spec.RandomSuffix, _ = opt.bestRandomSuffix(item)
+	return candidate
+}
+
+// Filters the whole item database and keeps the best candidates for each slot by EP, plus any
+// pieces of item sets with bonuses, since EP can't see set bonuses.
+func (opt *gearOptimizer) buildPools() {
+	perSlot := int(opt.settings.GetCandidatesPerSlot())
+	if perSlot <= 0 {
+		perSlot = defaultGearOptimizeCandidatesPerSlot
+	}
+
+	var all [numGearSlots][]*gearCandidate
+	opt.setPieces = make(map[string][]*gearCandidate)
+	// Sorted so that items with equal EP always rank in the same order.
+	ids := make([]int32, 0, len(ItemsByID))
+	for id := range ItemsByID {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		item := ItemsByID[id]
+		for _, slot := range eligibleSlotsForItem(item) {
+			if opt.locked[slot] || !opt.isAllowed(item, slot) {
+				continue
+			}
+			candidate := opt.newCandidate(item, slot)
+			all[slot] = append(all[slot], candidate)
+			if item.SetName != "" && hasSetBonuses(item.SetName) && slot == eligibleSlotsForItem(item)[0] {
+				opt.setPieces[item.SetName] = append(opt.setPieces[item.SetName], candidate)
+			}
+		}
+	}
+
+	for slot := range all {
+		candidates := all[slot]
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].ep > candidates[j].ep
+		})
+
+		var pool []*gearCandidate
+		if slot == int(proto.ItemSlot_ItemSlotMainHand) {
+			// Keep both one and two handers, otherwise two handers always win on raw EP.
+			twoHanders := slices.DeleteFunc(slices.Clone(candidates), func(c *gearCandidate) bool { return c.item.HandType != proto.HandType_HandTypeTwoHand })
+			oneHanders := slices.DeleteFunc(slices.Clone(candidates), func(c *gearCandidate) bool { return c.item.HandType == proto.HandType_HandTypeTwoHand })
+			pool = append(twoHanders[:min(perSlot, len(twoHanders))], oneHanders[:min(perSlot, len(oneHanders))]...)
+		} else {
+			pool = candidates[:min(perSlot, len(candidates))]
+		}
+
+		for _, pieces := range opt.setPieces {
+			for _, piece := range pieces {
+				if slices.Contains(eligibleSlotsForItem(piece.item), proto.ItemSlot(slot)) && !slices.ContainsFunc(pool, func(c *gearCandidate) bool { return c.item.ID == piece.item.ID }) {
+					pool = append(pool, opt.newCandidate(piece.item, proto.ItemSlot(slot)))
+				}
+			}
+		}
+
+		// The equipped item is always a candidate, so the search can move back to it.
+		if equipped, ok := ItemsByID[opt.equipped[slot].Id]; ok && !opt.locked[slot] && !slices.ContainsFunc(pool, func(c *gearCandidate) bool { return c.item.ID == equipped.ID }) {
+			pool = append(pool, &gearCandidate{item: equipped, spec: opt.equipped[slot], ep: opt.itemEP(equipped, proto.ItemSlot(slot))})
+		}
+
+		sort.SliceStable(pool, func(i, j int) bool {
+			return pool[i].ep > pool[j].ep
+		})
+		opt.pools[slot] = pool
+	}
+
+	for name := range opt.setPieces {
+		opt.setNames = append(opt.setNames, name)
+	}
+	slices.Sort(opt.setNames)
+
+	for slot := range opt.runes {
+		if !opt.locked[slot] {
+			opt.runes[slot] = runesForSlot(opt.player.Class, proto.ItemSlot(slot))
+		}
+	}
+}
+
+func hasSetBonuses(setName string) bool {
+	return slices.ContainsFunc(sets, func(set *ItemSet) bool {
+		return len(set.Bonuses) > 0 && (set.Name == setName || set.AlternativeName == setName)
+	})
+}
+
+func setBonusThresholds(setName string) []int32 {
+	for _, set := range sets {
+		if set.Name == setName || set.AlternativeName == setName {
+			thresholds := make([]int32, 0, len(set.Bonuses))
+			for numPieces := range set.Bonuses {
+				thresholds = append(thresholds, numPieces)
+			}
+			slices.Sort(thresholds)
+			return thresholds
+		}
+	}
+	return nil
+}
+
+// Puts the candidate in the slot, keeping the equipped enchant and the slot's current rune.
+// Returns false if the change isn't possible, e.g. it would need a locked slot to change.
+func (opt *gearOptimizer) equip(gear gearSet, slot proto.ItemSlot, candidate *gearCandidate) bool {
+	if opt.locked[slot] {
+		return false
+	}
+
+	spec := goproto.Clone(candidate.spec).(*proto.ItemSpec)
+	if spec.Enchant == 0 {
+		spec.Enchant = opt.equipped[slot].Enchant
+	}
+	if spec.Rune == 0 {
+		spec.Rune = gear[slot].Rune
+	}
+	gear[slot] = spec
+
+	switch slot {
+	case proto.ItemSlot_ItemSlotMainHand:
+		if candidate.item.HandType == proto.HandType_HandTypeTwoHand && gear[proto.ItemSlot_ItemSlotOffHand].Id != 0 {
+			if opt.locked[proto.ItemSlot_ItemSlotOffHand] {
+				return false
+			}
+			gear[proto.ItemSlot_ItemSlotOffHand] = &proto.ItemSpec{}
+		}
+	case proto.ItemSlot_ItemSlotOffHand:
+		if mainHand, ok := ItemsByID[gear[proto.ItemSlot_ItemSlotMainHand].Id]; ok && mainHand.HandType == proto.HandType_HandTypeTwoHand {
+			// Swap the two hander for the best one hander.
+			idx := slices.IndexFunc(opt.pools[proto.ItemSlot_ItemSlotMainHand], func(c *gearCandidate) bool {
+				return c.item.HandType != proto.HandType_HandTypeTwoHand
+			})
+			if idx == -1 {
+				return false
+			}
+			return opt.equip(gear, proto.ItemSlot_ItemSlotMainHand, opt.pools[proto.ItemSlot_ItemSlotMainHand][idx])
+		}
+	}
+	return true
+}
+
+func (opt *gearOptimizer) isValidGear(gear gearSet) bool {
+	equipment := &proto.EquipmentSpec{Items: gear}
+	if !isValidEquipment(equipment) || !hasValidRunes(equipment, opt.player.GetTalentsString()) {
+		return false
+	}
+	// isValidEquipment already covers rings and trinkets.
+	mainHand, ok := ItemsByID[gear[proto.ItemSlot_ItemSlotMainHand].Id]
+	if ok && mainHand.Unique && mainHand.ID == gear[proto.ItemSlot_ItemSlotOffHand].Id {
+		return false
+	}
+	return true
+}
+
+// Builds the best gear by EP alone, or nil if there's nothing to change.
+func (opt *gearOptimizer) greedyGear() gearSet {
+	gear := opt.equipped.clone()
+	for slot := range gear {
+		if slot == int(proto.ItemSlot_ItemSlotOffHand) {
+			continue
+		}
+		for _, candidate := range opt.pools[slot] {
+			next := gear.clone()
+			if opt.equip(next, proto.ItemSlot(slot), candidate) && opt.isValidGear(next) {
+				gear = next
+				break
+			}
+		}
+	}
+
+	// Only take an off hand if it beats the main hand picked above, which may be a two hander.
+	if len(opt.pools[proto.ItemSlot_ItemSlotOffHand]) > 0 {
+		for _, candidate := range opt.pools[proto.ItemSlot_ItemSlotOffHand] {
+			next := gear.clone()
+			if opt.equip(next, proto.ItemSlot_ItemSlotOffHand, candidate) && opt.isValidGear(next) {
+				if opt.gearEP(next) > opt.gearEP(gear) {
+					gear = next
+				}
+				break
+			}
+		}
+	}
+
+	if gear.hash() == opt.equipped.hash() {
+		return nil
+	}
+	return gear
+}
+
+// Applies a random move to the gear: either a single item, favouring those with higher EP,
+// a different rune on a single item, or enough pieces of a set to reach one of its bonuses.
+func (opt *gearOptimizer) propose(current gearSet) gearSet {
+	next := current.clone()
+
+	if len(opt.setNames) > 0 && opt.rand.Float64() < gearOptimizeSetMoveChance {
+		setName := opt.setNames[opt.rand.Intn(len(opt.setNames))]
+		thresholds := setBonusThresholds(setName)
+		if len(thresholds) == 0 {
+			return nil
+		}
+		numPieces := int(thresholds[opt.rand.Intn(len(thresholds))])
+		pieces := slices.Clone(opt.setPieces[setName])
+		opt.rand.Shuffle(len(pieces), func(i, j int) { pieces[i], pieces[j] = pieces[j], pieces[i] })
+		equippedPieces := 0
+		for _, piece := range pieces {
+			if equippedPieces >= numPieces {
+				break
+			}
+			slot := eligibleSlotsForItem(piece.item)[0]
+			if opt.equip(next, slot, piece) {
+				equippedPieces++
+			}
+		}
+	} else {
+		var slots []proto.ItemSlot
+		for slot, pool := range opt.pools {
+			if len(pool) > 0 || len(opt.runes[slot]) > 0 {
+				slots = append(slots, proto.ItemSlot(slot))
+			}
+		}
+		if len(slots) == 0 {
+			return nil
+		}
+		slot := slots[opt.rand.Intn(len(slots))]
+		pool := opt.pools[slot]
+		if runes := opt.runes[slot]; len(runes) > 0 && (len(pool) == 0 || opt.rand.Intn(2) == 0) {
+			// EP can't value runes, so every rune is equally likely.
+			spec := goproto.Clone(next[slot]).(*proto.ItemSpec)
+			spec.Rune = runes[opt.rand.Intn(len(runes))]
+			next[slot] = spec
+		} else {
+			idx := int(float64(len(pool)) * math.Pow(opt.rand.Float64(), 2))
+			if !opt.equip(next, slot, pool[idx]) {
+				return nil
+			}
+		}
+	}
+
+	if !opt.isValidGear(next) {
+		return nil
+	}
+	return next
+}
+
+// Proposes up to n distinct gear sets which haven't been simmed yet.
+func (opt *gearOptimizer) proposeBatch(current gearSet, n int) []gearSet {
+	var batch []gearSet
+	seen := make(map[string]bool)
+	for attempts := 0; len(batch) < n && attempts < n*50; attempts++ {
+		next := opt.propose(current)
+		if next == nil {
+			continue
+		}
+		hash := next.hash()
+		if _, ok := opt.results[hash]; ok || seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		batch = append(batch, next)
+	}
+	return batch
+}
+
+// Sims each gear set concurrently, returning results in the same order.
+func (opt *gearOptimizer) simGearSets(signals simsignals.Signals, gearSets []gearSet, iterations int32) ([]*gearOptimizeSimResult, *proto.ErrorOutcome) {
+	results := make([]*gearOptimizeSimResult, len(gearSets))
+
+	concurrency := simThreads(opt.request.BaseSettings.GetSimOptions())
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	tickets := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, gear := range gearSets {
+		wg.Add(1)
+		tickets <- struct{}{}
+		go func(i int, gear gearSet) {
+			defer wg.Done()
+			request := goproto.Clone(opt.request.BaseSettings).(*proto.RaidSimRequest)
+			request.Raid.Parties[0].Players[0].Equipment = &proto.EquipmentSpec{Items: gear}
+			request.SimOptions.Iterations = iterations
+			results[i] = &gearOptimizeSimResult{
+				gear:   gear,
+				result: runSim(request, nil, false, signals),
+			}
+			<-tickets
+		}(i, gear)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		if r.result == nil {
+			return nil, &proto.ErrorOutcome{Message: "gear optimizer: sim returned no result"}
+		}
+		if r.result.Error != nil {
+			return nil, r.result.Error
+		}
+		opt.results[r.gear.hash()] = r
+	}
+	opt.simsRun += int32(len(gearSets))
+	return results, nil
+}
+
+func (opt *gearOptimizer) reportProgress(progress chan *proto.ProgressMetrics, best *gearOptimizeSimResult) {
+	if progress == nil {
+		return
+	}
+	progress <- &proto.ProgressMetrics{
+		TotalSims:           opt.maxSims,
+		CompletedSims:       opt.simsRun,
+		TotalIterations:     opt.maxSims * opt.iterations,
+		CompletedIterations: opt.simsRun * opt.iterations,
+		Dps:                 best.Score(),
+	}
+}
+
+func (opt *gearOptimizer) toCandidateProto(r *gearOptimizeSimResult) *proto.GearOptimizeCandidate {
+	um := r.result.GetRaidMetrics().GetParties()[0].GetPlayers()[0]
+	um.Actions = nil
+	um.Auras = nil
+	um.Resources = nil
+	um.Pets = nil
+
+	candidate := &proto.GearOptimizeCandidate{
+		Equipment:   &proto.EquipmentSpec{Items: r.gear},
+		UnitMetrics: um,
+		Ep:          opt.gearEP(r.gear),
+	}
+	for slot, spec := range r.gear {
+		if spec.Id != opt.equipped[slot].Id || spec.RandomSuffix != opt.equipped[slot].RandomSuffix {
+			candidate.ItemsChanged = append(candidate.ItemsChanged, &proto.ItemSpecWithSlot{
+				Item: spec,
+				Slot: proto.ItemSlot(slot),
+			})
+		}
+	}
+	return candidate
+}
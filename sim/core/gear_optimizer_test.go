@@ -0,0 +1,96 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+)
+
+func newTestGearSet() gearSet {
+	gear := make(gearSet, numGearSlots)
+	for i := range gear {
+		gear[i] = &proto.ItemSpec{}
+	}
+	return gear
+}
+
+func TestGearSetHashIgnoresRingOrder(t *testing.T) {
+	gearA := newTestGearSet()
+	gearA[proto.ItemSlot_ItemSlotFinger1] = &proto.ItemSpec{Id: 1}
+	gearA[proto.ItemSlot_ItemSlotFinger2] = &proto.ItemSpec{Id: 2}
+
+	gearB := newTestGearSet()
+	gearB[proto.ItemSlot_ItemSlotFinger1] = &proto.ItemSpec{Id: 2}
+	gearB[proto.ItemSlot_ItemSlotFinger2] = &proto.ItemSpec{Id: 1}
+
+	if gearA.hash() != gearB.hash() {
+		t.Fatalf("Expected equal hashes, got %s and %s", gearA.hash(), gearB.hash())
+	}
+
+	gearB[proto.ItemSlot_ItemSlotFinger2].RandomSuffix = 5
+	if gearA.hash() == gearB.hash() {
+		t.Fatalf("Expected different hashes for different random suffixes")
+	}
+
+	gearB[proto.ItemSlot_ItemSlotFinger2].RandomSuffix = 0
+	gearB[proto.ItemSlot_ItemSlotFinger2].Rune = 7
+	if gearA.hash() == gearB.hash() {
+		t.Fatalf("Expected different hashes for different runes")
+	}
+}
+
+func TestGearOptimizerProposesRunes(t *testing.T) {
+	opt := &gearOptimizer{rand: rand.New(rand.NewSource(1))}
+	opt.runes[proto.ItemSlot_ItemSlotChest] = []int32{10, 11, 12}
+	opt.runes[proto.ItemSlot_ItemSlotFinger1] = []int32{20, 21}
+	opt.runes[proto.ItemSlot_ItemSlotFinger2] = []int32{20, 21}
+
+	current := newTestGearSet()
+	current[proto.ItemSlot_ItemSlotChest] = &proto.ItemSpec{Id: 1, Rune: 10}
+	current[proto.ItemSlot_ItemSlotFinger1] = &proto.ItemSpec{Id: 2, Rune: 20}
+	current[proto.ItemSlot_ItemSlotFinger2] = &proto.ItemSpec{Id: 3}
+
+	seen := map[int32]bool{}
+	for i := 0; i < 200; i++ {
+		next := opt.propose(current)
+		if next == nil {
+			continue
+		}
+		if next[proto.ItemSlot_ItemSlotFinger1].Rune != 0 && next[proto.ItemSlot_ItemSlotFinger1].Rune == next[proto.ItemSlot_ItemSlotFinger2].Rune {
+			t.Fatalf("Expected the same rune to never be proposed on both rings")
+		}
+		seen[next[proto.ItemSlot_ItemSlotChest].Rune] = true
+		seen[next[proto.ItemSlot_ItemSlotFinger2].Rune] = true
+	}
+	for _, runeID := range []int32{10, 11, 12, 21} {
+		if !seen[runeID] {
+			t.Errorf("Expected rune %d to be proposed", runeID)
+		}
+	}
+	if current[proto.ItemSlot_ItemSlotChest].Rune != 10 {
+		t.Fatalf("Expected proposals to leave the current gear unchanged")
+	}
+}
+
+func TestGearOptimizerItemEP(t *testing.T) {
+	opt := &gearOptimizer{weights: NewUnitStats()}
+	opt.weights.Stats[stats.Strength] = 2
+	opt.weights.PseudoStats[proto.PseudoStat_PseudoStatMainHandDps] = 10
+
+	weapon := Item{
+		Stats:           stats.Stats{stats.Strength: 10},
+		WeaponDamageMin: 100,
+		WeaponDamageMax: 200,
+		SwingSpeed:      3,
+	}
+
+	// 10 Strength * 2 + 50 DPS * 10
+	if ep := opt.itemEP(weapon, proto.ItemSlot_ItemSlotMainHand); ep != 520 {
+		t.Fatalf("Expected main hand EP of 520, got %f", ep)
+	}
+	if ep := opt.itemEP(weapon, proto.ItemSlot_ItemSlotOffHand); ep != 20 {
+		t.Fatalf("Expected off hand EP of 20, got %f", ep)
+	}
+}
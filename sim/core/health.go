@@ -55,7 +55,7 @@ func (hb *healthBar) GainHealth(sim *Simulation, amount float64, metrics *Resour
 	metrics.AddEvent(amount, newHealth-oldHealth)
 
 	if sim.Log != nil {
-		hb.unit.Log(sim, "Gained %0.3f health from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, oldHealth, newHealth)
+		hb.unit.logResourceChange(sim, proto.LogEventType_LogEventResourceGain, metrics, amount, oldHealth, newHealth)
 	}
 
 	hb.currentHealth = newHealth
@@ -81,7 +81,7 @@ func (hb *healthBar) RemoveHealth(sim *Simulation, amount float64) {
 	}
 
 	if sim.Log != nil {
-		hb.unit.Log(sim, "Spent %0.3f health from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, oldHealth, newHealth)
+		hb.unit.logResourceChange(sim, proto.LogEventType_LogEventResourceSpend, metrics, amount, oldHealth, newHealth)
 	}
 
 	hb.currentHealth = newHealth
@@ -96,7 +96,8 @@ func (character *Character) trackChanceOfDeath(healingModel *proto.HealingModel)
 			character.Unit.Metrics.isTanking = true
 		}
 	}
-	if !character.Unit.Metrics.isTanking {
+	// When deaths are modelled, anyone with a healing model can die.
+	if !character.Unit.Metrics.isTanking && !character.Env.Raid.ModelPlayerDeaths {
 		return
 	}
 
@@ -113,28 +114,10 @@ func (character *Character) trackChanceOfDeath(healingModel *proto.HealingModel)
 			aura.Activate(sim)
 		},
 		OnSpellHitTaken: func(aura *Aura, sim *Simulation, spell *Spell, result *SpellResult) {
-			if result.Damage > 0 {
-				aura.Unit.RemoveHealth(sim, result.Damage)
-
-				if aura.Unit.CurrentHealth() <= 0 && !aura.Unit.Metrics.Died {
-					aura.Unit.Metrics.Died = true
-					if sim.Log != nil {
-						character.Log(sim, "Dead")
-					}
-				}
-			}
+			character.takeDamage(sim, result.Damage)
 		},
 		OnPeriodicDamageTaken: func(aura *Aura, sim *Simulation, spell *Spell, result *SpellResult) {
-			if result.Damage > 0 {
-				aura.Unit.RemoveHealth(sim, result.Damage)
-
-				if aura.Unit.CurrentHealth() <= 0 && !aura.Unit.Metrics.Died {
-					aura.Unit.Metrics.Died = true
-					if sim.Log != nil {
-						character.Log(sim, "Dead")
-					}
-				}
-			}
+			character.takeDamage(sim, result.Damage)
 		},
 	})
 
@@ -143,6 +126,23 @@ func (character *Character) trackChanceOfDeath(healingModel *proto.HealingModel)
 	}
 }
 
+func (character *Character) takeDamage(sim *Simulation, damage float64) {
+	if damage <= 0 {
+		return
+	}
+
+	character.RemoveHealth(sim, damage)
+
+	if character.CurrentHealth() > 0 || character.isDead {
+		return
+	}
+	// Without death modelling the character keeps acting, so only the first death counts.
+	if character.Metrics.Died && !character.Env.Raid.ModelPlayerDeaths {
+		return
+	}
+	character.die(sim)
+}
+
 func (character *Character) applyHealingModel(healingModel *proto.HealingModel) {
 	// Store variance parameters for healing cadence. Note that low rolls on
 	// cadence are special cased here so that the model is still well-behaved
@@ -174,14 +174,17 @@ func (character *Character) applyHealingModel(healingModel *proto.HealingModel)
 			// Use modeled HPS to scale heal per tick based on random cadence
 			healPerTick = healingModel.Hps * (float64(timeToNextHeal) / float64(time.Second))
 			totalHeal := healPerTick * character.PseudoStats.HealingTakenMultiplier
-			// Execute the heal
-			character.GainHealth(sim, totalHeal, healthMetrics)
-
-			// Callback that can be used by tank specs
-			result := healingModelSpell.NewResult(&character.Unit)
-			result.Damage = totalHeal
-			character.OnHealTaken(sim, healingModelSpell, result)
-			healingModelSpell.DisposeResult(result)
+			// Dead players can't be healed, but the heal cadence keeps going.
+			if !character.isDead {
+				// Execute the heal
+				character.GainHealth(sim, totalHeal, healthMetrics)
+
+				// Callback that can be used by tank specs
+				result := healingModelSpell.NewResult(&character.Unit)
+				result.Damage = totalHeal
+				character.OnHealTaken(sim, healingModelSpell, result)
+				healingModelSpell.DisposeResult(result)
+			}
 
 			// Random roll for time to next heal. In the case where CadenceVariation exceeds CadenceSeconds, then
 			// CadenceSeconds is treated as the median, with two separate uniform distributions to the left and right
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// A single structured combat log event. Events are collected into
+// RaidSimResult.LogEvents, and the text logs are rendered from them so both
+// views always agree.
+type LogEvent struct {
+	Type      proto.LogEventType
+	Timestamp time.Duration
+
+	Unit     *Unit
+	Target   *Unit
+	ActionID ActionID
+
+	// Damage and healing events.
+	IsPeriodic  bool
+	SpellSchool SpellSchool
+	Outcome     HitOutcome
+	Amount      float64
+	Threat      float64
+
+	// Cast events.
+	Cost          float64
+	CastTime      time.Duration
+	EffectiveTime time.Duration
+
+	// Resource events, Amount is also used.
+	ResourceType   proto.ResourceType
+	ResourceBefore float64
+	ResourceAfter  float64
+
+	// Aura stack events.
+	OldStacks int32
+	NewStacks int32
+}
+
+var resourceTypeLogNames = map[proto.ResourceType]string{
+	proto.ResourceType_ResourceTypeMana:        "mana",
+	proto.ResourceType_ResourceTypeEnergy:      "energy",
+	proto.ResourceType_ResourceTypeRage:        "rage",
+	proto.ResourceType_ResourceTypeComboPoints: "combo points",
+	proto.ResourceType_ResourceTypeFocus:       "focus",
+	proto.ResourceType_ResourceTypeHealth:      "health",
+}
+
+// Renders the event as a text log line, without the timestamp and unit label prefix.
+func (event *LogEvent) String() string {
+	switch event.Type {
+	case proto.LogEventType_LogEventCastStart:
+		return fmt.Sprintf("Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)", event.ActionID, event.Cost, event.CastTime, event.EffectiveTime)
+	case proto.LogEventType_LogEventCastComplete:
+		return fmt.Sprintf("Completed cast %s", event.ActionID)
+	case proto.LogEventType_LogEventDamage:
+		result := SpellResult{Outcome: event.Outcome, Damage: event.Amount}
+		if event.IsPeriodic {
+			return fmt.Sprintf("%s %s tick %s (SpellSchool: %d). (Threat: %0.3f)", event.Target.LogLabel(), event.ActionID, result.DamageString(), event.SpellSchool, event.Threat)
+		}
+		return fmt.Sprintf("%s %s %s (SpellSchool: %d). (Threat: %0.3f)", event.Target.LogLabel(), event.ActionID, result.DamageString(), event.SpellSchool, event.Threat)
+	case proto.LogEventType_LogEventHealing:
+		result := SpellResult{Outcome: event.Outcome, Damage: event.Amount}
+		if event.IsPeriodic {
+			return fmt.Sprintf("%s %s tick %s. (Threat: %0.3f)", event.Target.LogLabel(), event.ActionID, result.HealingString(), event.Threat)
+		}
+		return fmt.Sprintf("%s %s %s. (Threat: %0.3f)", event.Target.LogLabel(), event.ActionID, result.HealingString(), event.Threat)
+	case proto.LogEventType_LogEventAuraGained:
+		return fmt.Sprintf("Aura gained: %s", event.ActionID)
+	case proto.LogEventType_LogEventAuraFaded:
+		return fmt.Sprintf("Aura faded: %s", event.ActionID)
+	case proto.LogEventType_LogEventAuraRefreshed:
+		return fmt.Sprintf("Aura refreshed: %s", event.ActionID)
+	case proto.LogEventType_LogEventAuraStacks:
+		return fmt.Sprintf("%s stacks: %d --> %d", event.ActionID, event.OldStacks, event.NewStacks)
+	case proto.LogEventType_LogEventResourceGain:
+		return fmt.Sprintf("Gained %0.3f %s from %s (%0.3f --> %0.3f).", event.Amount, resourceTypeLogNames[event.ResourceType], event.ActionID, event.ResourceBefore, event.ResourceAfter)
+	case proto.LogEventType_LogEventResourceSpend:
+		return fmt.Sprintf("Spent %0.3f %s from %s (%0.3f --> %0.3f).", event.Amount, resourceTypeLogNames[event.ResourceType], event.ActionID, event.ResourceBefore, event.ResourceAfter)
+	case proto.LogEventType_LogEventPetEnabled:
+		return "Pet summoned"
+	case proto.LogEventType_LogEventPetDisabled:
+		return "Pet dismissed"
+	case proto.LogEventType_LogEventTargetChanged:
+		return fmt.Sprintf("Changed target to %s", event.Target.LogLabel())
+	default:
+		return fmt.Sprintf("Unknown log event %s", event.Type)
+	}
+}
+
+func (event *LogEvent) ToProto() *proto.LogEvent {
+	protoEvent := &proto.LogEvent{
+		Type:      event.Type,
+		Timestamp: event.Timestamp.Seconds(),
+
+		UnitIndex: event.Unit.UnitIndex,
+		UnitLabel: event.Unit.Label,
+
+		IsPeriodic:      event.IsPeriodic,
+		SpellSchool:     int32(event.SpellSchool),
+		Outcome:         int32(event.Outcome),
+		Amount:          event.Amount,
+		Threat:          event.Threat,
+		Cost:            event.Cost,
+		CastTimeMs:      float64(event.CastTime.Milliseconds()),
+		EffectiveTimeMs: float64(event.EffectiveTime.Milliseconds()),
+
+		ResourceType:   event.ResourceType,
+		ResourceBefore: event.ResourceBefore,
+		ResourceAfter:  event.ResourceAfter,
+
+		OldStacks: event.OldStacks,
+		NewStacks: event.NewStacks,
+	}
+
+	if !event.ActionID.IsEmptyAction() {
+		protoEvent.ActionId = event.ActionID.ToProto()
+	}
+	if event.Target != nil {
+		protoEvent.TargetIndex = event.Target.UnitIndex
+		protoEvent.TargetLabel = event.Target.Label
+	}
+	if event.Type == proto.LogEventType_LogEventDamage || event.Type == proto.LogEventType_LogEventHealing {
+		protoEvent.OutcomeString = event.Outcome.String()
+	}
+
+	return protoEvent
+}
+
+// Records the event and writes its text rendering to the logs. Callers should
+// check sim.Log != nil first, same as for Unit.Log.
+func (unit *Unit) LogEvent(sim *Simulation, event *LogEvent) {
+	event.Unit = unit
+	event.Timestamp = sim.CurrentTime
+
+	if sim.recordLogEvents {
+		sim.logEvents = append(sim.logEvents, event.ToProto())
+	}
+
+	unit.Log(sim, "%s", event.String())
+}
+
+func (spell *Spell) logCast(sim *Simulation, cost float64, castTime time.Duration, effectiveTime time.Duration) {
+	spell.Unit.LogEvent(sim, &LogEvent{
+		Type:          proto.LogEventType_LogEventCastStart,
+		ActionID:      spell.ActionID,
+		Cost:          cost,
+		CastTime:      castTime,
+		EffectiveTime: effectiveTime,
+	})
+}
+
+func (spell *Spell) logCastComplete(sim *Simulation) {
+	spell.Unit.LogEvent(sim, &LogEvent{
+		Type:     proto.LogEventType_LogEventCastComplete,
+		ActionID: spell.ActionID,
+	})
+}
+
+func (unit *Unit) logResourceChange(sim *Simulation, eventType proto.LogEventType, metrics *ResourceMetrics, amount float64, before float64, after float64) {
+	unit.LogEvent(sim, &LogEvent{
+		Type:           eventType,
+		ActionID:       metrics.ActionID,
+		Amount:         amount,
+		ResourceType:   metrics.Type,
+		ResourceBefore: before,
+		ResourceAfter:  after,
+	})
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func TestLogEventString(t *testing.T) {
+	target := &Unit{Label: "Target 1"}
+	spellID := ActionID{SpellID: 42}
+
+	cases := []struct {
+		event    *LogEvent
+		expected string
+	}{
+		{
+			&LogEvent{Type: proto.LogEventType_LogEventDamage, Target: target, ActionID: spellID, IsPeriodic: true, Outcome: OutcomeHit, Amount: 100, Threat: 150},
+			"[Target 1] {SpellID: 42} tick Hit for 100.000 damage (SpellSchool: 0). (Threat: 150.000)",
+		},
+		{
+			&LogEvent{Type: proto.LogEventType_LogEventDamage, Target: target, ActionID: spellID, Outcome: OutcomeMiss},
+			"[Target 1] {SpellID: 42} Miss (SpellSchool: 0). (Threat: 0.000)",
+		},
+		{
+			&LogEvent{Type: proto.LogEventType_LogEventResourceGain, ActionID: spellID, ResourceType: proto.ResourceType_ResourceTypeMana, Amount: 10, ResourceBefore: 90, ResourceAfter: 100},
+			"Gained 10.000 mana from {SpellID: 42} (90.000 --> 100.000).",
+		},
+		{
+			&LogEvent{Type: proto.LogEventType_LogEventAuraStacks, ActionID: spellID, OldStacks: 1, NewStacks: 2},
+			"{SpellID: 42} stacks: 1 --> 2",
+		},
+		{
+			&LogEvent{Type: proto.LogEventType_LogEventTargetChanged, Target: target},
+			"Changed target to [Target 1]",
+		},
+	}
+	for _, c := range cases {
+		if actual := c.event.String(); actual != c.expected {
+			t.Errorf("Expected %s event to render as %q, got %q", c.event.Type, c.expected, actual)
+		}
+	}
+}
+
+func TestLogEventToProto(t *testing.T) {
+	unit := &Unit{Label: "Caster", UnitIndex: 1}
+	target := &Unit{Label: "Target 1", UnitIndex: 2}
+
+	damage := (&LogEvent{
+		Type:     proto.LogEventType_LogEventDamage,
+		Unit:     unit,
+		Target:   target,
+		ActionID: ActionID{SpellID: 42},
+		Outcome:  OutcomeCrit,
+		Amount:   200,
+	}).ToProto()
+	if damage.UnitLabel != "Caster" || damage.TargetIndex != 2 || damage.TargetLabel != "Target 1" {
+		t.Errorf("Expected the units to be set, got %v", damage)
+	}
+	if damage.ActionId.GetSpellId() != 42 || damage.OutcomeString != "Crit" || damage.Amount != 200 {
+		t.Errorf("Expected the damage fields to be set, got %v", damage)
+	}
+
+	pet := (&LogEvent{Type: proto.LogEventType_LogEventPetEnabled, Unit: unit}).ToProto()
+	if pet.ActionId != nil || pet.TargetLabel != "" || pet.OutcomeString != "" {
+		t.Errorf("Expected only the unit to be set on a pet event, got %v", pet)
+	}
+}
+
+func TestLogEventsInResult(t *testing.T) {
+	rotation, err := APLRotationFromText("actions=cast_spell,spell_id=42,if=!dot_is_active(spell_id=42)")
+	if err != nil {
+		t.Fatalf("Failed to parse rotation text: %s", err)
+	}
+
+	request := fakeRaidSimRequest(rotation, []*proto.Target{{Name: "target", Level: 63, MobType: proto.MobType_MobTypeDemon}}, 30)
+	request.SimOptions = &proto.SimOptions{
+		Iterations: 1,
+		IsTest:     true,
+		Debug:      true,
+		RandomSeed: 101,
+	}
+	result := RunRaidSim(request)
+	if result.Error != nil {
+		t.Fatalf("Sim failed: %s", result.Error.Message)
+	}
+
+	casts, ticks := 0, 0
+	for i, event := range result.LogEvents {
+		if i > 0 && event.Timestamp < result.LogEvents[i-1].Timestamp {
+			t.Fatalf("Expected events in time order, got %f after %f", event.Timestamp, result.LogEvents[i-1].Timestamp)
+		}
+		if event.ActionId.GetSpellId() != 42 {
+			continue
+		}
+		switch event.Type {
+		case proto.LogEventType_LogEventCastStart:
+			casts++
+		case proto.LogEventType_LogEventDamage:
+			if event.IsPeriodic {
+				ticks++
+				if event.UnitLabel != "Caster" || event.TargetLabel == "" {
+					t.Fatalf("Expected a tick from the caster on the target, got %v", event)
+				}
+			}
+		}
+	}
+	if casts == 0 || ticks == 0 {
+		t.Fatalf("Expected casts and ticks of the fake spell, got %d casts and %d ticks", casts, ticks)
+	}
+
+	// Every event is also rendered to the text logs.
+	if textCasts := strings.Count(result.Logs, "Casting {SpellID: 42}"); textCasts != casts {
+		t.Errorf("Expected %d casts in the text logs, got %d", casts, textCasts)
+	}
+	if textTicks := strings.Count(result.Logs, "{SpellID: 42} tick"); textTicks != ticks {
+		t.Errorf("Expected %d ticks in the text logs, got %d", ticks, textTicks)
+	}
+}
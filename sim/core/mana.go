@@ -85,7 +85,7 @@ func (unit *Unit) AddMana(sim *Simulation, amount float64, metrics *ResourceMetr
 	metrics.AddEvent(amount, newMana-oldMana)
 
 	if sim.Log != nil {
-		unit.Log(sim, "Gained %0.3f mana from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, oldMana, newMana)
+		unit.logResourceChange(sim, proto.LogEventType_LogEventResourceGain, metrics, amount, oldMana, newMana)
 	}
 
 	unit.currentMana = newMana
@@ -101,7 +101,7 @@ func (unit *Unit) SpendMana(sim *Simulation, amount float64, metrics *ResourceMe
 	metrics.AddEvent(-amount, -amount)
 
 	if sim.Log != nil {
-		unit.Log(sim, "Spent %0.3f mana from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, unit.CurrentMana(), newMana)
+		unit.logResourceChange(sim, proto.LogEventType_LogEventResourceSpend, metrics, amount, unit.CurrentMana(), newMana)
 	}
 
 	unit.currentMana = newMana
@@ -100,8 +100,14 @@ type UnitMetrics struct {
 	// Aggregate values. These are updated after each iteration.
 	numItersDead int32
 	oomTimeSum   float64
-	actions      map[ActionID]*ActionMetrics
-	resources    []*ResourceMetrics
+	aggroTimeSum float64
+	// Only tracked for enemies, for per-add metrics in fights where adds come and go.
+	damageTakenSum float64
+	activeTimeSum  float64
+	deadTimeSum    float64
+	dpsLostSum     float64
+	actions        map[ActionID]*ActionMetrics
+	resources      []*ResourceMetrics
 }
 
 // Metrics for the current iteration, for 1 agent. Keep this as a separate
@@ -116,6 +122,12 @@ type CharacterIterationMetrics struct {
 	OOMTime time.Duration // time spent not casting and waiting for regen.
 
 	FirstOOMTimestamp time.Duration // Timestamp at which unit first went OOM.
+
+	AggroTime time.Duration // time spent holding aggro, summed over all targets with a threat table.
+
+	ActiveTime time.Duration // time an enemy spent in the fight, see Target.Spawn().
+
+	DeadTime time.Duration // time spent dead, only when the raid models deaths.
 }
 
 type ActionMetrics struct {
@@ -366,6 +378,32 @@ func empty(spellMetrics []SpellMetrics) bool {
 	return true
 }
 
+// Returns the damage, threat and healing (including shielding) done by the unit
+// and its pets so far in the current iteration, counted the same way as the
+// iteration totals of the metrics.
+func (unit *Unit) CurrentIterationTotals() (damage float64, threat float64, healing float64) {
+	for _, spell := range unit.Spellbook {
+		for _, spellMetrics := range spell.splitSpellMetrics {
+			for i, spellTargetMetrics := range spellMetrics {
+				if unit.IsOpponent(unit.Env.AllUnits[i]) {
+					damage += spellTargetMetrics.TotalDamage
+					threat += spellTargetMetrics.TotalThreat
+				} else {
+					healing += spellTargetMetrics.TotalHealing + spellTargetMetrics.TotalShielding
+				}
+			}
+		}
+	}
+
+	for _, petAgent := range unit.PetAgents {
+		petDamage, petThreat, petHealing := petAgent.GetPet().CurrentIterationTotals()
+		damage += petDamage
+		threat += petThreat
+		healing += petHealing
+	}
+	return damage, threat, healing
+}
+
 // Adds the results of a spell to the character metrics.
 func (unitMetrics *UnitMetrics) addSpellMetrics(spell *Spell, actionID ActionID, spellMetrics []SpellMetrics) {
 	if empty(spellMetrics) {
@@ -506,6 +544,19 @@ func (unitMetrics *UnitMetrics) doneIteration(unit *Unit, sim *Simulation) {
 		unitMetrics.tmi.Total *= sim.Duration.Seconds()
 	}
 
+	if unit.Type == EnemyUnit {
+		unitMetrics.damageTakenSum += unitMetrics.dtps.Total
+		unitMetrics.activeTimeSum += unitMetrics.ActiveTime.Seconds()
+	}
+
+	if unitMetrics.DeadTime > 0 {
+		// Assume the unit would have kept doing its DPS while alive.
+		unitMetrics.deadTimeSum += unitMetrics.DeadTime.Seconds()
+		if aliveTime := sim.CurrentTime - unitMetrics.DeadTime; aliveTime > 0 {
+			unitMetrics.dpsLostSum += unitMetrics.dps.Total / aliveTime.Seconds() * unitMetrics.DeadTime.Seconds() / sim.CurrentTime.Seconds()
+		}
+	}
+
 	unitMetrics.dps.doneIteration(sim)
 	unitMetrics.dpasp.doneIteration(sim)
 	unitMetrics.threat.doneIteration(sim)
@@ -515,6 +566,7 @@ func (unitMetrics *UnitMetrics) doneIteration(unit *Unit, sim *Simulation) {
 	unitMetrics.tto.doneIteration(sim)
 
 	unitMetrics.oomTimeSum += unitMetrics.OOMTime.Seconds()
+	unitMetrics.aggroTimeSum += unitMetrics.AggroTime.Seconds()
 	if unitMetrics.Died {
 		unitMetrics.numItersDead++
 	}
@@ -595,15 +647,20 @@ func (unitMetrics *UnitMetrics) calculateTMI(unit *Unit, sim *Simulation) float6
 func (unitMetrics *UnitMetrics) ToProto() *proto.UnitMetrics {
 	n := float64(unitMetrics.dps.n)
 	protoMetrics := &proto.UnitMetrics{
-		Dps:           unitMetrics.dps.ToProto(),
-		Dpasp:         unitMetrics.dpasp.ToProto(),
-		Threat:        unitMetrics.threat.ToProto(),
-		Dtps:          unitMetrics.dtps.ToProto(),
-		Tmi:           unitMetrics.tmi.ToProto(),
-		Hps:           unitMetrics.hps.ToProto(),
-		Tto:           unitMetrics.tto.ToProto(),
-		SecondsOomAvg: unitMetrics.oomTimeSum / n,
-		ChanceOfDeath: float64(unitMetrics.numItersDead) / n,
+		Dps:                unitMetrics.dps.ToProto(),
+		Dpasp:              unitMetrics.dpasp.ToProto(),
+		Threat:             unitMetrics.threat.ToProto(),
+		Dtps:               unitMetrics.dtps.ToProto(),
+		Tmi:                unitMetrics.tmi.ToProto(),
+		Hps:                unitMetrics.hps.ToProto(),
+		Tto:                unitMetrics.tto.ToProto(),
+		SecondsOomAvg:      unitMetrics.oomTimeSum / n,
+		ChanceOfDeath:      float64(unitMetrics.numItersDead) / n,
+		SecondsOnAggroAvg:  unitMetrics.aggroTimeSum / n,
+		DamageTakenAvg:     unitMetrics.damageTakenSum / n,
+		SecondsActiveAvg:   unitMetrics.activeTimeSum / n,
+		SecondsDeadAvg:     unitMetrics.deadTimeSum / n,
+		DpsLostToDeathsAvg: unitMetrics.dpsLostSum / n,
 	}
 
 	protoMetrics.Actions = make([]*proto.ActionMetrics, 0, len(unitMetrics.actions))
@@ -169,7 +169,7 @@ func (pet *Pet) Enable(sim *Simulation, petAgent PetAgent) {
 	if sim.Log != nil {
 		pet.Log(sim, "Pet stats: %s", pet.GetStats().FlatString())
 		pet.Log(sim, "Pet inherited stats: %s", pet.ApplyStatDependencies(pet.inheritedStats).FlatString())
-		pet.Log(sim, "Pet summoned")
+		pet.LogEvent(sim, &LogEvent{Type: proto.LogEventType_LogEventPetEnabled})
 	}
 
 	sim.addTracker(&pet.auraTracker)
@@ -267,7 +267,7 @@ func (pet *Pet) Disable(sim *Simulation) {
 	sim.removeTracker(&pet.auraTracker)
 
 	if sim.Log != nil {
-		pet.Log(sim, "Pet dismissed")
+		pet.LogEvent(sim, &LogEvent{Type: proto.LogEventType_LogEventPetDisabled})
 		pet.Log(sim, pet.GetStats().FlatString())
 	}
 }
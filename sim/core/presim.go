@@ -69,6 +69,7 @@ func (sim *Simulation) runPresims(request *proto.RaidSimRequest) *proto.RaidSimR
 	presimRequest.SimOptions.RandomSeed = 1
 	presimRequest.SimOptions.Debug = false
 	presimRequest.SimOptions.DebugFirstIteration = false
+	presimRequest.SimOptions.ProfileApl = false
 	presimRequest.SimOptions.Iterations = numPresimIterations
 	duration := DurationFromSeconds(presimRequest.Encounter.Duration)
 
@@ -19,6 +19,9 @@ type Party struct {
 
 	dpsMetrics DistributionMetrics
 	hpsMetrics DistributionMetrics
+
+	// Party buffs from the config, which no player provides.
+	baseBuffs *proto.PartyBuffs
 }
 
 func NewParty(raid *Raid, index int, partyConfig *proto.Party) *Party {
@@ -52,9 +55,16 @@ func (party *Party) GetPartyBuffs(basePartyBuffs *proto.PartyBuffs) *proto.Party
 	if basePartyBuffs != nil {
 		partyBuffs = googleProto.Clone(basePartyBuffs).(*proto.PartyBuffs)
 	}
+	// Each player's buffs are computed from the base, so a buff provided by
+	// several players is credited to all of them.
+	party.baseBuffs = googleProto.Clone(partyBuffs).(*proto.PartyBuffs)
 	for _, player := range party.Players {
-		player.AddPartyBuffs(partyBuffs)
-		player.GetCharacter().AddPartyBuffs(partyBuffs)
+		playerBuffs := googleProto.Clone(party.baseBuffs).(*proto.PartyBuffs)
+		player.AddPartyBuffs(playerBuffs)
+		player.GetCharacter().AddPartyBuffs(playerBuffs)
+		provided := changedBuffFields(party.baseBuffs, playerBuffs)
+		player.GetCharacter().providedPartyBuffs = provided
+		mergeBuffFields(partyBuffs, provided)
 	}
 	return partyBuffs
 }
@@ -124,6 +134,12 @@ type Raid struct {
 
 	nextPetIndex int32
 
+	// Whether dead players stop acting, see Character.die().
+	ModelPlayerDeaths bool
+
+	// Raid buffs from the config, which no player provides.
+	baseBuffs *proto.RaidBuffs
+
 	replenishmentUnits         []*Unit   // All units who can receive replenishment.
 	curReplenishmentUnits      [][]*Unit // Units that currently have replenishment active, separated by source.
 	leftoverReplenishmentUnits []*Unit   // Units without replenishment currently active.
@@ -150,6 +166,8 @@ func NewRaid(raidConfig *proto.Raid) *Raid {
 		dpsMetrics:   NewDistributionMetrics(),
 		hpsMetrics:   NewDistributionMetrics(),
 		nextPetIndex: int32(numParties) * 5,
+
+		ModelPlayerDeaths: raidConfig.ModelPlayerDeaths,
 	}
 
 	for partyIndex, partyConfig := range raidConfig.Parties {
@@ -223,10 +241,16 @@ func (raid *Raid) GetRaidBuffs(baseRaidBuffs *proto.RaidBuffs) *proto.RaidBuffs
 	if baseRaidBuffs != nil {
 		raidBuffs = baseRaidBuffs
 	}
+	// See GetPartyBuffs.
+	raid.baseBuffs = googleProto.Clone(raidBuffs).(*proto.RaidBuffs)
 	for _, party := range raid.Parties {
 		for _, player := range party.Players {
-			player.AddRaidBuffs(raidBuffs)
-			player.GetCharacter().AddRaidBuffs(raidBuffs)
+			playerBuffs := googleProto.Clone(raid.baseBuffs).(*proto.RaidBuffs)
+			player.AddRaidBuffs(playerBuffs)
+			player.GetCharacter().AddRaidBuffs(playerBuffs)
+			provided := changedBuffFields(raid.baseBuffs, playerBuffs)
+			player.GetCharacter().providedRaidBuffs = provided
+			mergeBuffFields(raidBuffs, provided)
 		}
 	}
 	return raidBuffs
@@ -385,7 +409,11 @@ func (raid *Raid) GetMetrics() *proto.RaidMetrics {
 		Hps: raid.hpsMetrics.ToProto(),
 	}
 	for _, party := range raid.Parties {
-		metrics.Parties = append(metrics.Parties, party.GetMetrics())
+		partyMetrics := party.GetMetrics()
+		for _, player := range partyMetrics.Players {
+			metrics.DpsLostToDeathsAvg += player.DpsLostToDeathsAvg
+		}
+		metrics.Parties = append(metrics.Parties, partyMetrics)
 	}
 	return metrics
 }
@@ -43,6 +43,10 @@ type Simulation struct {
 
 	Log func(string, ...interface{})
 
+	// Structured events backing the text logs, see LogEvent.
+	recordLogEvents bool
+	logEvents       []*proto.LogEvent
+
 	executePhase int32 // 20, 25, or 35 for the respective execute range, 100 otherwise
 
 	executePhaseCallbacks []func(*Simulation, int32) // 2nd parameter is 35 for 35%, 25 for 25% and 20 for 20%
@@ -194,6 +198,14 @@ func newSimWithEnv(env *Environment, simOptions *proto.SimOptions, signals simsi
 		rseed = time.Now().UnixNano()
 	}
 
+	if simOptions.ProfileApl {
+		for _, unit := range env.Raid.AllPlayerUnits {
+			if unit.Rotation != nil {
+				unit.Rotation.enableProfiling()
+			}
+		}
+	}
+
 	return &Simulation{
 		Environment: env,
 		Options:     simOptions,
@@ -289,6 +301,7 @@ func (sim *Simulation) run() *proto.RaidSimResult {
 		sim.Log = func(message string, vals ...interface{}) {
 			logsBuffer.WriteString(fmt.Sprintf("[%0.2f] "+message+"\n", append([]interface{}{sim.CurrentTime.Seconds()}, vals...)...))
 		}
+		sim.recordLogEvents = true
 	}
 
 	// Uncomment this to print logs directly to console.
@@ -345,6 +358,7 @@ func (sim *Simulation) run() *proto.RaidSimResult {
 		EncounterMetrics: sim.Encounter.GetMetricsProto(),
 
 		Logs:                   logsBuffer.String(),
+		LogEvents:              sim.logEvents,
 		FirstIterationDuration: firstIterationDuration.Seconds(),
 		AvgIterationDuration:   totalDuration.Seconds() / float64(sim.Options.Iterations),
 		IterationsDone: sim.Options.Iterations,
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"runtime"
 	"runtime/debug"
+	"slices"
 
 	"github.com/wowsims/sod/sim/core/proto"
 	"github.com/wowsims/sod/sim/core/simsignals"
@@ -256,6 +257,11 @@ func (rsrc *raidSimResultCombiner) combineUnitMetrics(base *proto.UnitMetrics, a
 
 	base.SecondsOomAvg += add.SecondsOomAvg * weight
 	base.ChanceOfDeath += add.ChanceOfDeath * weight
+	base.SecondsOnAggroAvg += add.SecondsOnAggroAvg * weight
+	base.DamageTakenAvg += add.DamageTakenAvg * weight
+	base.SecondsActiveAvg += add.SecondsActiveAvg * weight
+	base.SecondsDeadAvg += add.SecondsDeadAvg * weight
+	base.DpsLostToDeathsAvg += add.DpsLostToDeathsAvg * weight
 
 	for _, addAction := range add.Actions {
 		rsrc.addActionMetrics(base, addAction)
@@ -269,14 +275,51 @@ func (rsrc *raidSimResultCombiner) combineUnitMetrics(base *proto.UnitMetrics, a
 		rsrc.addResourceMetrics(base, addResource)
 	}
 
+	if add.AplProfile != nil {
+		if base.AplProfile == nil {
+			base.AplProfile = googleProto.Clone(add.AplProfile).(*proto.APLProfile)
+		} else {
+			addAPLProfile(base.AplProfile, add.AplProfile)
+		}
+	}
+
 	for i, addPet := range add.Pets {
 		rsrc.combineUnitMetrics(base.Pets[i], addPet, isLast, weight)
 	}
 }
 
+// Profiles are totals, so they are summed rather than weighted.
+func addAPLProfile(base *proto.APLProfile, add *proto.APLProfile) {
+	base.Iterations += add.Iterations
+	for i, item := range add.PriorityList {
+		addAPLActionProfile(base.PriorityList[i], item)
+	}
+	for i, list := range add.ActionLists {
+		for j, item := range list.Items {
+			addAPLActionProfile(base.ActionLists[i].Items[j], item)
+		}
+	}
+}
+
+func addAPLActionProfile(base *proto.APLActionProfile, add *proto.APLActionProfile) {
+	base.Evaluations += add.Evaluations
+	base.ConditionTrue += add.ConditionTrue
+	base.Executions += add.Executions
+	base.EvaluationSeconds += add.EvaluationSeconds
+	for _, addCount := range add.NotReady {
+		idx := slices.IndexFunc(base.NotReady, func(count *proto.APLNotReadyCount) bool { return count.Reason == addCount.Reason })
+		if idx == -1 {
+			base.NotReady = append(base.NotReady, &proto.APLNotReadyCount{Reason: addCount.Reason, Count: addCount.Count})
+		} else {
+			base.NotReady[idx].Count += addCount.Count
+		}
+	}
+}
+
 func (rsrc *raidSimResultCombiner) AddResult(result *proto.RaidSimResult, isLast bool, weight float64) {
 	rsrc.combineDistMetrics(rsrc.Combined.RaidMetrics.Dps, result.RaidMetrics.Dps, isLast, weight)
 	rsrc.combineDistMetrics(rsrc.Combined.RaidMetrics.Hps, result.RaidMetrics.Hps, isLast, weight)
+	rsrc.Combined.RaidMetrics.DpsLostToDeathsAvg += result.RaidMetrics.DpsLostToDeathsAvg * weight
 
 	for partyIdx, party := range result.RaidMetrics.Parties {
 		baseParty := rsrc.Combined.RaidMetrics.Parties[partyIdx]
@@ -296,6 +339,7 @@ func (rsrc *raidSimResultCombiner) AddResult(result *proto.RaidSimResult, isLast
 
 	if rsrc.Debug {
 		rsrc.Combined.Logs += "-SIMSTART-\n" + result.Logs
+		rsrc.Combined.LogEvents = append(rsrc.Combined.LogEvents, result.LogEvents...)
 	}
 }
 
@@ -314,6 +358,7 @@ func (rsrc *raidSimResultCombiner) SetBaseResult(baseRsr *proto.RaidSimResult) {
 
 	if !rsrc.Debug {
 		newRsr.Logs = baseRsr.Logs
+		newRsr.LogEvents = baseRsr.LogEvents
 	}
 
 	for i, party := range baseRsr.RaidMetrics.Parties {
@@ -358,6 +403,10 @@ type concurrentSimData struct {
 	IterationsTotal int32
 	IterationsDone  []int32
 
+	// Iterations completed by earlier batches, when running to a target precision.
+	IterationsBefore int32
+	Precision        float64
+
 	DpsValues []float64
 	HpsValues []float64
 
@@ -365,7 +414,7 @@ type concurrentSimData struct {
 }
 
 func (csd *concurrentSimData) GetIterationsDone() int32 {
-	var total int32 = 0
+	total := csd.IterationsBefore
 	for _, done := range csd.IterationsDone {
 		total += done
 	}
@@ -407,10 +456,80 @@ func (csd *concurrentSimData) MakeProgressMetrics() *proto.ProgressMetrics {
 		CompletedIterations: csd.GetIterationsDone(),
 		Dps:                 csd.GetDpsAvg(),
 		Hps:                 csd.GetHpsAvg(),
+		Precision:           csd.Precision,
+	}
+}
+
+// Iteration cap for SimOptions.TargetPrecision, when SimOptions.MaxIterations isn't set.
+const defaultMaxPrecisionIterations = 100000
+
+// Smallest batch worth scheduling when running to a target precision.
+const minPrecisionBatchIterations = 100
+
+// Returns the distributions of the chosen metric in a result: the raid's, or each player's for TPS.
+func metricDistributions(result *proto.RaidSimResult, metric proto.PrecisionMetric) []*proto.DistributionMetrics {
+	switch metric {
+	case proto.PrecisionMetric_PrecisionMetricHps:
+		return []*proto.DistributionMetrics{result.RaidMetrics.Hps}
+	case proto.PrecisionMetric_PrecisionMetricTps:
+		var dists []*proto.DistributionMetrics
+		for _, party := range result.RaidMetrics.Parties {
+			for _, player := range party.Players {
+				if player.Threat != nil {
+					dists = append(dists, player.Threat)
+				}
+			}
+		}
+		return dists
+	default:
+		return []*proto.DistributionMetrics{result.RaidMetrics.Dps}
+	}
+}
+
+// Returns the distribution of the chosen metric in a result, using the first player's for TPS, or nil if it has none.
+func metricDistribution(result *proto.RaidSimResult, metric proto.PrecisionMetric) *proto.DistributionMetrics {
+	if dists := metricDistributions(result, metric); len(dists) > 0 {
+		return dists[0]
+	}
+	return nil
+}
+
+// Returns the 95% confidence interval half-width of the chosen metric in a (combined) result.
+// With several distributions, such as each player's TPS, the widest one is returned.
+func precisionHalfWidth(result *proto.RaidSimResult, metric proto.PrecisionMetric) float64 {
+	dists := metricDistributions(result, metric)
+	if len(dists) == 0 {
+		return math.Inf(1)
+	}
+
+	halfWidth := 0.0
+	for _, dist := range dists {
+		if dist == nil || dist.AggregatorData == nil || dist.AggregatorData.N == 0 {
+			return math.Inf(1)
+		}
+		halfWidth = max(halfWidth, 1.96*dist.Stdev/math.Sqrt(float64(dist.AggregatorData.N)))
 	}
+	return halfWidth
+}
+
+// Estimates how many more iterations are needed to bring the CI half-width down to target.
+// Returns 0 once the target or the iteration cap is reached.
+func nextPrecisionBatchSize(halfWidth float64, target float64, done int32, maxIterations int32) int32 {
+	if halfWidth <= target || done >= maxIterations {
+		return 0
+	}
+
+	// Half-width shrinks with sqrt(n), so scale the iterations done so far.
+	needed := int32(math.Ceil(float64(done) * (halfWidth / target) * (halfWidth / target)))
+	next := max(needed-done, minPrecisionBatchIterations)
+
+	// Stdev estimates from small batches are noisy, so never more than double per batch.
+	next = min(next, done)
+	return min(next, maxIterations-done)
 }
 
 // Run sim on multiple threads concurrently by splitting interations over multiple sims, transparently combining results into the progress channel.
+// With SimOptions.TargetPrecision set, batches keep being scheduled until the requested confidence is reached.
 func runSimConcurrent(request *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, signals simsignals.Signals) (result *proto.RaidSimResult) {
 	defer func() {
 		if !request.SimOptions.IsTest {
@@ -439,7 +558,73 @@ func runSimConcurrent(request *proto.RaidSimRequest, progress chan *proto.Progre
 		}
 	}()
 
-	splitRes := SplitSimRequestForConcurrency(request, TernaryInt32(request.SimOptions.IsTest, 3, int32(runtime.NumCPU())))
+	targetPrecision := request.SimOptions.TargetPrecision
+	maxIterations := request.SimOptions.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxPrecisionIterations
+	}
+
+	var finalResults []*proto.RaidSimResult
+	var iterationsDone int32
+	precision := 0.0
+	batch := request
+
+	for {
+		csd, errResult := runSimConcurrentBatch(batch, progress, signals, iterationsDone, iterationsDone+batch.SimOptions.Iterations, precision)
+		if errResult != nil {
+			return errResult
+		}
+		finalResults = append(finalResults, csd.FinalResults...)
+		iterationsDone += batch.SimOptions.Iterations
+
+		result = CombineConcurrentSimResults(finalResults, request.SimOptions.Debug)
+		if targetPrecision <= 0 {
+			break
+		}
+
+		precision = precisionHalfWidth(result, request.SimOptions.PrecisionMetric)
+		nextIterations := nextPrecisionBatchSize(precision, targetPrecision, iterationsDone, maxIterations)
+		if nextIterations <= 0 {
+			break
+		}
+
+		if !request.SimOptions.IsTest {
+			log.Printf("Precision after %d iterations is +/- %0.2f, running %d more.", iterationsDone, precision, nextIterations)
+		}
+
+		// Continue the seed sequence where the previous batch left off, same as the splits do.
+		batch = googleProto.Clone(request).(*proto.RaidSimRequest)
+		batch.SimOptions.Iterations = nextIterations
+		batch.SimOptions.RandomSeed = request.SimOptions.RandomSeed + int64(iterationsDone)
+		batch.SimOptions.DebugFirstIteration = false
+	}
+
+	if progress != nil {
+		progress <- &proto.ProgressMetrics{
+			TotalIterations:     iterationsDone,
+			CompletedIterations: iterationsDone,
+			Dps:                 result.RaidMetrics.Dps.Avg,
+			Hps:                 result.RaidMetrics.Hps.Avg,
+			Precision:           precision,
+			FinalRaidResult:     result,
+		}
+	}
+
+	return result
+}
+
+// Number of threads to split a sim over, see SimOptions.max_threads.
+func simThreads(options *proto.SimOptions) int {
+	threads := runtime.NumCPU()
+	if options.GetMaxThreads() > 0 {
+		threads = min(threads, int(options.MaxThreads))
+	}
+	return threads
+}
+
+// Runs a single batch of iterations split over all threads. Returns a non-nil error result if the batch failed or was aborted.
+func runSimConcurrentBatch(request *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, signals simsignals.Signals, iterationsBefore int32, iterationsTotal int32, precision float64) (*concurrentSimData, *proto.RaidSimResult) {
+	splitRes := SplitSimRequestForConcurrency(request, TernaryInt32(request.SimOptions.IsTest, 3, int32(simThreads(request.SimOptions))))
 
 	if splitRes.ErrorResult != "" {
 		panic(splitRes.ErrorResult)
@@ -450,13 +635,15 @@ func runSimConcurrent(request *proto.RaidSimRequest, progress chan *proto.Progre
 	substituteCases := make([]reflect.SelectCase, threads)
 	running := threads
 
-	csd := concurrentSimData{
-		Concurrency:     threads,
-		IterationsTotal: request.SimOptions.Iterations,
-		IterationsDone:  make([]int32, threads),
-		DpsValues:       make([]float64, threads),
-		HpsValues:       make([]float64, threads),
-		FinalResults:    make([]*proto.RaidSimResult, threads),
+	csd := &concurrentSimData{
+		Concurrency:      threads,
+		IterationsTotal:  iterationsTotal,
+		IterationsDone:   make([]int32, threads),
+		IterationsBefore: iterationsBefore,
+		Precision:        precision,
+		DpsValues:        make([]float64, threads),
+		HpsValues:        make([]float64, threads),
+		FinalResults:     make([]*proto.RaidSimResult, threads),
 	}
 
 	for i := 0; i < int(threads); i++ {
@@ -465,7 +652,7 @@ func runSimConcurrent(request *proto.RaidSimRequest, progress chan *proto.Progre
 	}
 
 	if !request.SimOptions.IsTest {
-		log.Printf("Running %d iterations on %d concurrent sims.", csd.IterationsTotal, csd.Concurrency)
+		log.Printf("Running %d iterations on %d concurrent sims.", request.SimOptions.Iterations, csd.Concurrency)
 	}
 
 	for i, req := range splitRes.Requests {
@@ -482,7 +669,7 @@ func runSimConcurrent(request *proto.RaidSimRequest, progress chan *proto.Progre
 			if progress != nil {
 				progress <- &proto.ProgressMetrics{FinalRaidResult: quitResult}
 			}
-			return quitResult
+			return nil, quitResult
 		}
 
 		if !ok {
@@ -499,7 +686,7 @@ func runSimConcurrent(request *proto.RaidSimRequest, progress chan *proto.Progre
 				}
 				log.Printf("Thread %d had an error. Cancelling all sims!", i)
 				signals.Abort.Trigger()
-				return msg.FinalRaidResult
+				return nil, msg.FinalRaidResult
 			}
 			substituteCases[i].Chan = reflect.ValueOf(nil)
 			running -= 1
@@ -524,13 +711,5 @@ func runSimConcurrent(request *proto.RaidSimRequest, progress chan *proto.Progre
 		log.Printf("All %d sims finished successfully.", csd.Concurrency)
 	}
 
-	result = CombineConcurrentSimResults(csd.FinalResults, request.SimOptions.Debug)
-
-	if progress != nil {
-		pm := csd.MakeProgressMetrics()
-		pm.FinalRaidResult = result
-		progress <- pm
-	}
-
-	return result
+	return csd, nil
 }
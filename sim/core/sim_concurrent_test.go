@@ -0,0 +1,66 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func TestPrecisionHalfWidth(t *testing.T) {
+	dist := func(stdev float64, n int32) *proto.DistributionMetrics {
+		return &proto.DistributionMetrics{Stdev: stdev, AggregatorData: &proto.AggregatorData{N: n}}
+	}
+	raidResult := func(raidMetrics *proto.RaidMetrics) *proto.RaidSimResult {
+		return &proto.RaidSimResult{RaidMetrics: raidMetrics}
+	}
+	threatResult := func(threats ...*proto.DistributionMetrics) *proto.RaidSimResult {
+		party := &proto.PartyMetrics{}
+		for _, threat := range threats {
+			party.Players = append(party.Players, &proto.UnitMetrics{Threat: threat})
+		}
+		return raidResult(&proto.RaidMetrics{Parties: []*proto.PartyMetrics{party}})
+	}
+
+	cases := []struct {
+		name     string
+		result   *proto.RaidSimResult
+		metric   proto.PrecisionMetric
+		expected float64
+	}{
+		{"dps", raidResult(&proto.RaidMetrics{Dps: dist(50, 100)}), proto.PrecisionMetric_PrecisionMetricDps, 9.8},
+		{"hps", raidResult(&proto.RaidMetrics{Dps: dist(50, 100), Hps: dist(100, 100)}), proto.PrecisionMetric_PrecisionMetricHps, 19.6},
+		{"missing metric", raidResult(&proto.RaidMetrics{Dps: dist(50, 100)}), proto.PrecisionMetric_PrecisionMetricHps, math.Inf(1)},
+		{"no iterations", raidResult(&proto.RaidMetrics{Dps: dist(50, 0)}), proto.PrecisionMetric_PrecisionMetricDps, math.Inf(1)},
+		{"widest player tps", threatResult(dist(50, 100), nil, dist(100, 100)), proto.PrecisionMetric_PrecisionMetricTps, 19.6},
+		{"no threat", threatResult(nil), proto.PrecisionMetric_PrecisionMetricTps, math.Inf(1)},
+	}
+	for _, c := range cases {
+		if actual := precisionHalfWidth(c.result, c.metric); actual != c.expected && math.Abs(actual-c.expected) > 1e-9 {
+			t.Errorf("%s: expected a half-width of %f, got %f", c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestNextPrecisionBatchSize(t *testing.T) {
+	cases := []struct {
+		name          string
+		halfWidth     float64
+		target        float64
+		done          int32
+		maxIterations int32
+		expected      int32
+	}{
+		{"target reached", 5, 10, 1000, 100000, 0},
+		{"iteration cap reached", 20, 10, 1000, 1000, 0},
+		{"estimate from sqrt(n)", 10, 8, 1000, 100000, 563},
+		{"minimum batch", 10.1, 10, 1000, 100000, minPrecisionBatchIterations},
+		{"at most double", 20, 10, 1000, 100000, 1000},
+		{"up to the iteration cap", 20, 10, 1000, 1500, 500},
+	}
+	for _, c := range cases {
+		if actual := nextPrecisionBatchSize(c.halfWidth, c.target, c.done, c.maxIterations); actual != c.expected {
+			t.Errorf("%s: expected a batch of %d iterations, got %d", c.name, c.expected, actual)
+		}
+	}
+}
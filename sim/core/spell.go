@@ -571,7 +571,33 @@ func (spell *Spell) CanCast(sim *Simulation, target *Unit) bool {
 	return true
 }
 
+// Returns the first reason CanCast fails for, in the same order, or
+// APLNotReadyOther if it doesn't. Only used for APL profiling.
+func (spell *Spell) cantCastReason(sim *Simulation, target *Unit) proto.APLNotReadyReason {
+	switch {
+	case spell.ExtraCastCondition != nil && !spell.ExtraCastCondition(sim, target):
+		return proto.APLNotReadyReason_APLNotReadyCastCondition
+	case spell.DefaultCast.CastTime > 0 && spell.Unit.IsMoving():
+		return proto.APLNotReadyReason_APLNotReadyMoving
+	case spell.Unit.IsCasting(sim) && !spell.Flags.Matches(SpellFlagCastWhileCasting):
+		return proto.APLNotReadyReason_APLNotReadyCasting
+	case spell.Unit.IsChanneling(sim) && !spell.Flags.Matches(SpellFlagCastWhileChanneling) && (spell.Unit.Rotation.interruptChannelIf == nil || !spell.Unit.Rotation.interruptChannelIf.GetBool(sim)):
+		return proto.APLNotReadyReason_APLNotReadyCasting
+	case spell.DefaultCast.GCD > 0 && !spell.Unit.GCD.IsReady(sim):
+		return proto.APLNotReadyReason_APLNotReadyGcd
+	case !BothTimersReady(spell.CD.Timer, spell.SharedCD.Timer, sim):
+		return proto.APLNotReadyReason_APLNotReadyCooldown
+	case spell.Cost != nil && !spell.Cost.MeetsRequirement(sim, spell):
+		return proto.APLNotReadyReason_APLNotReadyCost
+	}
+	return proto.APLNotReadyReason_APLNotReadyOther
+}
+
 func (spell *Spell) Cast(sim *Simulation, target *Unit) bool {
+	if spell.Unit.Type == PlayerUnit && !spell.Unit.enabled {
+		// Dead players can't cast, see Character.die().
+		return false
+	}
 	if target == nil {
 		target = spell.Unit.CurrentTarget
 	}
@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 
+	"github.com/wowsims/sod/sim/core/proto"
 	"github.com/wowsims/sod/sim/core/stats"
 )
 
@@ -402,6 +403,12 @@ func (spell *Spell) CalcAndDealOutcome(sim *Simulation, target *Unit, outcomeApp
 
 // Applies the fully computed spell result to the sim.
 func (spell *Spell) dealDamageInternal(sim *Simulation, isPeriodic bool, result *SpellResult) {
+	// Damage can't be dealt to adds which haven't spawned yet or have already died.
+	if result.Target.Type == EnemyUnit && !result.Target.enabled {
+		spell.DisposeResult(result)
+		return
+	}
+
 	isPartialResist := result.DidResist()
 
 	if sim.CurrentTime >= 0 {
@@ -437,6 +444,9 @@ func (spell *Spell) dealDamageInternal(sim *Simulation, isPeriodic bool, result
 			spell.SpellMetrics[result.Target.UnitIndex].TotalCrushDamage += result.Damage
 		}
 		spell.SpellMetrics[result.Target.UnitIndex].TotalThreat += result.Threat
+		if result.Target.ThreatTable != nil {
+			result.Target.ThreatTable.AddThreat(sim, spell.Unit, result.Threat)
+		}
 	}
 
 	// Mark total damage done in raid so far for health based fights.
@@ -446,11 +456,16 @@ func (spell *Spell) dealDamageInternal(sim *Simulation, isPeriodic bool, result
 	}
 
 	if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
-		if isPeriodic {
-			spell.Unit.Log(sim, "%s %s tick %s (SpellSchool: %d). (Threat: %0.3f)", result.Target.LogLabel(), spell.ActionID, result.DamageString(), spell.SpellSchool, result.Threat)
-		} else {
-			spell.Unit.Log(sim, "%s %s %s (SpellSchool: %d). (Threat: %0.3f)", result.Target.LogLabel(), spell.ActionID, result.DamageString(), spell.SpellSchool, result.Threat)
-		}
+		spell.Unit.LogEvent(sim, &LogEvent{
+			Type:        proto.LogEventType_LogEventDamage,
+			Target:      result.Target,
+			ActionID:    spell.ActionID,
+			IsPeriodic:  isPeriodic,
+			SpellSchool: spell.SpellSchool,
+			Outcome:     result.Outcome,
+			Amount:      result.Damage,
+			Threat:      result.Threat,
+		})
 	}
 
 	if !spell.Flags.Matches(SpellFlagNoOnDamageDealt) {
@@ -463,6 +478,10 @@ func (spell *Spell) dealDamageInternal(sim *Simulation, isPeriodic bool, result
 		}
 	}
 
+	if result.Target.Type == EnemyUnit {
+		sim.Encounter.Targets[result.Target.Index].addDamageTaken(sim, result.Damage)
+	}
+
 	spell.DisposeResult(result)
 }
 func (spell *Spell) DealDamage(sim *Simulation, result *SpellResult) {
@@ -550,16 +569,23 @@ func (spell *Spell) dealHealingInternal(sim *Simulation, isPeriodic bool, result
 	}
 	spell.SpellMetrics[result.Target.UnitIndex].TotalHealing += result.Damage
 	spell.SpellMetrics[result.Target.UnitIndex].TotalThreat += result.Threat
+	if sim.CurrentTime >= 0 && spell.Unit.Type != EnemyUnit {
+		sim.Encounter.addHealingThreat(sim, spell.Unit, result.Threat)
+	}
 	if result.Target.HasHealthBar() {
 		result.Target.GainHealth(sim, result.Damage, spell.HealthMetrics(result.Target))
 	}
 
 	if sim.Log != nil {
-		if isPeriodic {
-			spell.Unit.Log(sim, "%s %s tick %s. (Threat: %0.3f)", result.Target.LogLabel(), spell.ActionID, result.HealingString(), result.Threat)
-		} else {
-			spell.Unit.Log(sim, "%s %s %s. (Threat: %0.3f)", result.Target.LogLabel(), spell.ActionID, result.HealingString(), result.Threat)
-		}
+		spell.Unit.LogEvent(sim, &LogEvent{
+			Type:       proto.LogEventType_LogEventHealing,
+			Target:     result.Target,
+			ActionID:   spell.ActionID,
+			IsPeriodic: isPeriodic,
+			Outcome:    result.Outcome,
+			Amount:     result.Damage,
+			Threat:     result.Threat,
+		})
 	}
 
 	if isPeriodic {
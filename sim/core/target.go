@@ -1,6 +1,7 @@
 package core
 
 import (
+	"slices"
 	"strconv"
 	"time"
 
@@ -14,6 +15,9 @@ type Encounter struct {
 	Targets           []*Target
 	TargetUnits       []*Unit
 
+	// Targets which are currently in the fight, see Target.Spawn().
+	ActiveTargetUnits []*Unit
+
 	ExecuteProportion_20 float64
 	ExecuteProportion_25 float64
 	ExecuteProportion_35 float64
@@ -25,6 +29,9 @@ type Encounter struct {
 	// In health fight: set to true until we get something to base on
 	DurationIsEstimate bool
 
+	// Whether targets keep threat tables and switch targets on aggro changes.
+	UseThreatTable bool
+
 	// Value to multiply by, for damage spells which are subject to the aoe cap.
 	aoeCapMultiplier float64
 }
@@ -70,6 +77,7 @@ func NewEncounter(options *proto.Encounter) Encounter {
 		encounter.DurationIsEstimate = true
 	}
 
+	encounter.ActiveTargetUnits = slices.Clone(encounter.TargetUnits)
 	encounter.updateAOECapMultiplier()
 
 	return encounter
@@ -79,13 +87,30 @@ func (encounter *Encounter) AOECapMultiplier() float64 {
 	return encounter.aoeCapMultiplier
 }
 func (encounter *Encounter) updateAOECapMultiplier() {
-	encounter.aoeCapMultiplier = min(10/float64(len(encounter.Targets)), 1)
+	encounter.aoeCapMultiplier = min(10/float64(max(len(encounter.ActiveTargetUnits), 1)), 1)
+}
+
+// Called whenever a target spawns or despawns.
+func (encounter *Encounter) updateActiveTargets() {
+	encounter.ActiveTargetUnits = encounter.ActiveTargetUnits[:0]
+	for _, targetUnit := range encounter.TargetUnits {
+		if targetUnit.enabled {
+			encounter.ActiveTargetUnits = append(encounter.ActiveTargetUnits, targetUnit)
+		}
+	}
+	encounter.updateAOECapMultiplier()
 }
 
 func (encounter *Encounter) doneIteration(sim *Simulation) {
 	for i := range encounter.Targets {
 		target := encounter.Targets[i]
 		target.doneIteration(sim)
+		if target.enabled {
+			target.Metrics.ActiveTime += sim.CurrentTime - target.spawnedAt
+		}
+		if target.ThreatTable != nil {
+			target.ThreatTable.doneIteration(sim)
+		}
 	}
 }
 
@@ -108,6 +133,21 @@ type Target struct {
 	Unit
 
 	AI TargetAI
+
+	// Whether the target starts each iteration despawned, waiting for Spawn().
+	spawnsLater bool
+	spawnTime   time.Duration
+	despawnTime time.Duration
+	// Permanent auras which were active on despawn, restored on spawn.
+	permanentAuras []*Aura
+
+	// If set, the target dies once it has taken this much damage.
+	healthPool  float64
+	damageTaken float64
+	spawnedAt   time.Duration
+
+	// Recent damage taken, for time to die estimates.
+	damageSamples []damageSample
 }
 
 func NewTarget(options *proto.Target, targetIndex int32) *Target {
@@ -148,8 +188,20 @@ func NewTarget(options *proto.Target, targetIndex int32) *Target {
 	target.PseudoStats.InFrontOfTarget = true
 	target.PseudoStats.DamageSpread = options.DamageSpread
 
+	// The first target is always present, since it defines the fight.
+	if targetIndex > 0 {
+		target.spawnTime = DurationFromSeconds(options.SpawnTime)
+		target.despawnTime = DurationFromSeconds(options.DespawnTime)
+		target.spawnsLater = target.spawnTime > 0
+		if options.UseHealth {
+			target.healthPool = unitStats[stats.Health]
+		}
+	}
+
 	preset := GetPresetTargetWithID(options.Id)
-	if preset != nil && preset.AI != nil {
+	if options.Script != nil && scriptedAIFactory != nil {
+		target.AI = scriptedAIFactory(options.Script)
+	} else if preset != nil && preset.AI != nil {
 		target.AI = preset.AI()
 	}
 
@@ -159,17 +211,145 @@ func NewTarget(options *proto.Target, targetIndex int32) *Target {
 func (target *Target) Reset(sim *Simulation) {
 	target.Unit.reset(sim, nil)
 	target.SetGCDTimer(sim, 0)
+	// Undo any retargeting from player deaths.
+	target.CurrentTarget = target.defaultTarget
+	if target.ThreatTable != nil {
+		target.ThreatTable.reset(sim)
+	}
 	if target.AI != nil {
 		target.AI.Reset(sim)
 	}
+	target.damageTaken = 0
+	target.spawnedAt = 0
+	target.permanentAuras = target.permanentAuras[:0]
+	target.resetDamageSamples(sim)
+	if target.spawnsLater {
+		target.enabled = false
+		if target.gcdAction != nil {
+			target.CancelGCDTimer(sim)
+		}
+	}
+
+	if target.spawnTime > 0 {
+		StartDelayedAction(sim, DelayedActionOptions{
+			DoAt:     target.spawnTime,
+			OnAction: target.Spawn,
+		})
+	}
+	if target.despawnTime > 0 {
+		StartDelayedAction(sim, DelayedActionOptions{
+			DoAt:     target.despawnTime,
+			OnAction: target.Despawn,
+		})
+	}
+}
+
+// Makes the target start each iteration despawned, for adds which join the
+// fight partway through. Must be called before the sim is finalized.
+func (target *Target) SpawnLater() {
+	if target.Index > 0 {
+		target.spawnsLater = true
+	}
+}
+
+// Brings a despawned target into the fight.
+func (target *Target) Spawn(sim *Simulation) {
+	if target.enabled {
+		return
+	}
+
+	target.enabled = true
+	target.damageTaken = 0
+	target.spawnedAt = sim.CurrentTime
+	target.resetDamageSamples(sim)
+	target.AutoAttacks.startPull(sim)
+	target.SetGCDTimer(sim, sim.CurrentTime)
+	target.Env.Encounter.updateActiveTargets()
+
+	for _, aura := range target.permanentAuras {
+		aura.Activate(sim)
+	}
+	target.permanentAuras = target.permanentAuras[:0]
+
+	if sim.Log != nil {
+		target.Log(sim, "Spawned")
+	}
 }
 
+// Removes the target from the fight until it is spawned again.
+func (target *Target) Despawn(sim *Simulation) {
+	if !target.enabled {
+		return
+	}
+
+	target.enabled = false
+	target.Metrics.ActiveTime += sim.CurrentTime - target.spawnedAt
+	if target.gcdAction != nil {
+		target.CancelGCDTimer(sim)
+	}
+	target.AutoAttacks.CancelAutoSwing(sim)
+	target.Env.Encounter.updateActiveTargets()
+
+	// DoTs and debuffs fall off, except for permanent ones such as the raid's
+	// debuffs, which come back on Spawn.
+	target.permanentAuras = target.permanentAuras[:0]
+	for _, aura := range target.activeAuras {
+		if aura.Duration == NeverExpires {
+			target.permanentAuras = append(target.permanentAuras, aura)
+		}
+	}
+	target.auraTracker.expireAll(sim)
+
+	// Anyone attacking this target moves on to the next one.
+	nextTarget := &target.NextTarget().Unit
+	for _, unit := range target.Env.Raid.AllUnits {
+		if unit.CurrentTarget == &target.Unit && nextTarget != &target.Unit {
+			unit.CurrentTarget = nextTarget
+		}
+	}
+
+	if sim.Log != nil {
+		target.Log(sim, "Despawned")
+	}
+}
+
+// Tracks damage taken for targets with their own health pool, which die once
+// it runs out.
+func (target *Target) addDamageTaken(sim *Simulation, damage float64) {
+	// In health based fights, damage to any target brings the first one closer to death.
+	if sim.Encounter.EndFightAtHealth > 0 && target.Index != 0 {
+		sim.Encounter.Targets[0].sampleDamage(sim)
+	}
+	if target.healthPool == 0 || !target.enabled {
+		target.sampleDamage(sim)
+		return
+	}
+
+	target.damageTaken += damage
+	target.sampleDamage(sim)
+	if target.damageTaken >= target.healthPool {
+		if sim.Log != nil {
+			target.Log(sim, "Died")
+		}
+		target.Despawn(sim)
+	}
+}
+
+// Returns the next target which is currently in the fight, or this target if
+// there are no others.
 func (target *Target) NextTarget() *Target {
-	nextIndex := target.Index + 1
-	if nextIndex >= target.Env.GetNumTargets() {
-		nextIndex = 0
+	nextTarget := target
+	for {
+		nextIndex := nextTarget.Index + 1
+		if nextIndex >= target.Env.GetNumTargets() {
+			nextIndex = 0
+		}
+		nextTarget = target.Env.GetTarget(nextIndex)
+
+		if nextTarget == target || nextTarget.enabled {
+			return nextTarget
+		}
 	}
-	return target.Env.GetTarget(nextIndex)
 }
 
 func (target *Target) GetMetricsProto() *proto.UnitMetrics {
@@ -65,6 +65,14 @@ func (target *Target) ExecuteCustomRotation(sim *Simulation) {
 
 type AIFactory func() TargetAI
 
+// Builds the AI for targets with a script. Registered by the encounters
+// package, so core doesn't need to depend on it.
+var scriptedAIFactory func(*proto.TargetScript) TargetAI
+
+func RegisterScriptedTargetAI(factory func(*proto.TargetScript) TargetAI) {
+	scriptedAIFactory = factory
+}
+
 type PresetTarget struct {
 	// String in folder-structure format identifying a category for this unit, e.g. "Black Temple/Bosses".
 	PathPrefix string
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"time"
+)
+
+// Time to die estimates use the damage rate over this long.
+const timeToDieWindow = time.Second * 10
+
+// Damage taken checkpoints are recorded at most this often.
+const timeToDieSampleInterval = time.Millisecond * 500
+
+type damageSample struct {
+	at     time.Duration
+	damage float64
+}
+
+// Returns the damage that counts towards this target's death so far and how
+// much it takes, or false if the target doesn't die from damage. The first
+// target of a health based fight dies with the encounter, so every target's
+// damage counts for it.
+func (target *Target) healthDamage(sim *Simulation) (float64, float64, bool) {
+	if target.healthPool > 0 {
+		return target.damageTaken, target.healthPool, true
+	}
+	if target.Index == 0 && sim.Encounter.EndFightAtHealth > 0 {
+		return sim.Encounter.DamageTaken, sim.Encounter.EndFightAtHealth, true
+	}
+	return 0, 0, false
+}
+
+func (target *Target) resetDamageSamples(sim *Simulation) {
+	target.damageSamples = append(target.damageSamples[:0], damageSample{at: sim.CurrentTime})
+}
+
+func (target *Target) sampleDamage(sim *Simulation) {
+	damage, _, ok := target.healthDamage(sim)
+	if !ok {
+		return
+	}
+
+	if last := target.damageSamples[len(target.damageSamples)-1]; sim.CurrentTime-last.at >= timeToDieSampleInterval {
+		target.damageSamples = append(target.damageSamples, damageSample{at: sim.CurrentTime, damage: damage})
+	}
+
+	// Keep the last checkpoint from before the window, so the rate always covers all of it.
+	windowStart := sim.CurrentTime - timeToDieWindow
+	drop := 0
+	for drop+1 < len(target.damageSamples) && target.damageSamples[drop+1].at <= windowStart {
+		drop++
+	}
+	if drop > 0 {
+		target.damageSamples = append(target.damageSamples[:0], target.damageSamples[drop:]...)
+	}
+}
+
+// Damage per second this target took over the last timeToDieWindow, or since
+// it spawned if that was more recent.
+func (target *Target) recentDamageRate(sim *Simulation, damage float64) float64 {
+	first := target.damageSamples[0]
+	elapsed := (sim.CurrentTime - first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return (damage - first.damage) / elapsed
+}
+
+// When the target leaves the fight without dying from damage: at its despawn
+// time, or the end of the fight.
+func (target *Target) expectedEnd(sim *Simulation) time.Duration {
+	end := sim.CurrentTime + sim.GetRemainingDuration()
+	if target.despawnTime > 0 {
+		end = min(end, target.despawnTime)
+	}
+	return end
+}
+
+// Remaining health as a value from 0-1. Targets without a health pool lose
+// health evenly between spawning and leaving the fight.
+func (target *Target) HealthPercent(sim *Simulation) float64 {
+	if !target.enabled {
+		return 0
+	}
+	if damage, pool, ok := target.healthDamage(sim); ok {
+		return max(0, 1-damage/pool)
+	}
+
+	end := target.expectedEnd(sim)
+	if end <= target.spawnedAt {
+		return 0
+	}
+	return max(0, float64(end-sim.CurrentTime)/float64(end-target.spawnedAt))
+}
+
+// Estimated time until the target's health drops to the given percent (0-1).
+func (target *Target) TimeToPercent(sim *Simulation, percent float64) time.Duration {
+	if !target.enabled {
+		return 0
+	}
+	untilEnd := max(0, target.expectedEnd(sim)-sim.CurrentTime)
+
+	damage, pool, ok := target.healthDamage(sim)
+	if !ok {
+		// Health drops evenly over the target's time in the fight.
+		lifetime := target.expectedEnd(sim) - target.spawnedAt
+		return max(0, untilEnd-time.Duration(percent*float64(lifetime)))
+	}
+
+	remaining := pool*(1-percent) - damage
+	if remaining <= 0 {
+		return 0
+	}
+	rate := target.recentDamageRate(sim, damage)
+	if rate <= 0 {
+		return untilEnd
+	}
+	return min(untilEnd, DurationFromSeconds(remaining/rate))
+}
+
+// Estimated time until the target dies or otherwise leaves the fight.
+func (target *Target) TimeToDie(sim *Simulation) time.Duration {
+	return target.TimeToPercent(sim, 0)
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTargetTimeToDie(t *testing.T) {
+	boss := &Target{Unit: Unit{Type: EnemyUnit, Index: 0, enabled: true}}
+	add := &Target{Unit: Unit{Type: EnemyUnit, Index: 1, enabled: true}, healthPool: 10000}
+	sim := &Simulation{Environment: &Environment{}}
+	sim.Encounter.Targets = []*Target{boss, add}
+	sim.Duration = time.Minute * 10
+	boss.resetDamageSamples(sim)
+	add.resetDamageSamples(sim)
+
+	// 100 DPS for 5s.
+	for i := 1; i <= 5; i++ {
+		sim.CurrentTime = time.Second * time.Duration(i)
+		add.addDamageTaken(sim, 100)
+	}
+	if percent := add.HealthPercent(sim); math.Abs(percent-0.95) > 1e-9 {
+		t.Fatalf("Expected 95%% health, got %f", percent)
+	}
+	if ttd := add.TimeToDie(sim); ttd != time.Second*95 {
+		t.Fatalf("Expected 95s to die, got %s", ttd)
+	}
+	if ttp := add.TimeToPercent(sim, 0.5); ttp != time.Second*45 {
+		t.Fatalf("Expected 45s to 50%%, got %s", ttp)
+	}
+
+	// Then 200 DPS, only the last 10s count.
+	for i := 6; i <= 20; i++ {
+		sim.CurrentTime = time.Second * time.Duration(i)
+		add.addDamageTaken(sim, 200)
+	}
+	if ttd := add.TimeToDie(sim); ttd != time.Millisecond*32500 {
+		t.Fatalf("Expected 32.5s to die, got %s", ttd)
+	}
+
+	// Without a health pool, health drops evenly until the target leaves the fight.
+	if percent := boss.HealthPercent(sim); percent != float64(580)/600 {
+		t.Fatalf("Expected %f health, got %f", float64(580)/600, percent)
+	}
+	if ttp := boss.TimeToPercent(sim, 0.5); ttp != time.Second*280 {
+		t.Fatalf("Expected 280s to 50%%, got %s", ttp)
+	}
+	boss.despawnTime = time.Second * 30
+	if ttd := boss.TimeToDie(sim); ttd != time.Second*10 {
+		t.Fatalf("Expected 10s to despawn, got %s", ttd)
+	}
+
+	add.enabled = false
+	if add.HealthPercent(sim) != 0 || add.TimeToDie(sim) != 0 {
+		t.Fatalf("Expected a despawned target to have no health or time left")
+	}
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+)
+
+func TestTargetDespawnClearsAuras(t *testing.T) {
+	request := fakeRaidSimRequest(nil, []*proto.Target{{Name: "boss", Level: 63}, {Name: "add", Level: 63}}, 180)
+	request.Raid.Debuffs = &proto.Debuffs{CurseOfShadow: true}
+	sim := NewSim(request, simsignals.CreateSignals())
+	sim.Reset()
+
+	fa := sim.Raid.Parties[0].Players[0].(*FakeAgent)
+	add := sim.Encounter.Targets[1]
+	dot := fa.Spell.Dot(&add.Unit)
+	curse := add.GetAura("Curse of Shadow")
+	if curse == nil || !curse.IsActive() {
+		t.Fatalf("Expected the raid's curse to be active on the add")
+	}
+
+	dot.Apply(sim)
+	add.Despawn(sim)
+	if dot.IsActive() || curse.IsActive() {
+		t.Fatalf("Expected the add's DoTs and debuffs to fall off on despawn")
+	}
+	if len(sim.Encounter.ActiveTargetUnits) != 1 {
+		t.Fatalf("Expected only the boss to be active, got %d targets", len(sim.Encounter.ActiveTargetUnits))
+	}
+
+	add.Spawn(sim)
+	if dot.IsActive() {
+		t.Fatalf("Expected the DoT to stay off after spawning again")
+	}
+	if !curse.IsActive() {
+		t.Fatalf("Expected the permanent curse to come back on spawn")
+	}
+	if len(sim.Encounter.ActiveTargetUnits) != 2 {
+		t.Fatalf("Expected both targets to be active, got %d targets", len(sim.Encounter.ActiveTargetUnits))
+	}
+}
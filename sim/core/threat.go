@@ -0,0 +1,192 @@
+package core
+
+import (
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+const (
+	// How much of the aggro holder's threat a unit needs to exceed to pull aggro.
+	MeleeAggroPullThreshold  = 1.1
+	RangedAggroPullThreshold = 1.3
+)
+
+// Tracks how much threat each unit has on a single enemy, and retargets the
+// enemy when someone pulls aggro. Only created when the encounter uses threat
+// tables, otherwise enemies always attack the unit given by their tank index.
+//
+// Threat from resource gains (see Spell.ApplyAOEThreat) is only credited at the
+// end of each iteration, so it shows up in the threat metrics but not here.
+type ThreatTable struct {
+	// The enemy which owns this table.
+	unit *Unit
+
+	// Threat per unit, indexed by UnitIndex.
+	threat []float64
+
+	// When the current holder gained aggro, for time-on-aggro metrics.
+	aggroSince time.Duration
+
+	// Set by taunt effects, the enemy can't switch targets until this expires.
+	forcedUntil time.Duration
+}
+
+func newThreatTable(unit *Unit, numUnits int) *ThreatTable {
+	return &ThreatTable{
+		unit:   unit,
+		threat: make([]float64, numUnits),
+	}
+}
+
+// The unit currently holding aggro, or nil if nobody has generated threat yet.
+func (tt *ThreatTable) Holder() *Unit {
+	return tt.unit.CurrentTarget
+}
+
+func (tt *ThreatTable) Threat(unit *Unit) float64 {
+	return tt.threat[unit.UnitIndex]
+}
+
+// Returns unit's threat as a fraction of the aggro holder's threat.
+func (tt *ThreatTable) ThreatPercentOfHolder(unit *Unit) float64 {
+	holder := tt.Holder()
+	if holder == nil || tt.threat[holder.UnitIndex] == 0 {
+		return 0
+	}
+	return tt.threat[unit.UnitIndex] / tt.threat[holder.UnitIndex]
+}
+
+func (tt *ThreatTable) AddThreat(sim *Simulation, unit *Unit, threat float64) {
+	if threat == 0 {
+		return
+	}
+
+	tt.threat[unit.UnitIndex] = max(0, tt.threat[unit.UnitIndex]+threat)
+
+	if unit == tt.Holder() {
+		if threat < 0 {
+			// Threat reductions on the holder let whoever has the most threat past their threshold take over.
+			var puller *Unit
+			for _, other := range sim.Environment.AllUnits {
+				if tt.canPull(sim, other) && (puller == nil || tt.threat[other.UnitIndex] > tt.threat[puller.UnitIndex]) {
+					puller = other
+				}
+			}
+			if puller != nil {
+				tt.setHolder(sim, puller)
+			}
+		}
+		return
+	}
+
+	if tt.canPull(sim, unit) {
+		tt.setHolder(sim, unit)
+	}
+}
+
+// Whether unit has enough threat to pull aggro from the current holder.
+func (tt *ThreatTable) canPull(sim *Simulation, unit *Unit) bool {
+	holder := tt.Holder()
+	if unit == holder || unit.Type == EnemyUnit || !unit.enabled || sim.CurrentTime < tt.forcedUntil {
+		return false
+	}
+
+	threshold := RangedAggroPullThreshold
+	if unit.DistanceFromTarget <= MaxMeleeAttackDistance {
+		threshold = MeleeAggroPullThreshold
+	}
+
+	if holder != nil && tt.threat[unit.UnitIndex] <= tt.threat[holder.UnitIndex]*threshold {
+		return false
+	}
+	if holder == nil && tt.threat[unit.UnitIndex] == 0 {
+		return false
+	}
+	return true
+}
+
+// Taunt effects raise unit's threat to match the highest threat on the table
+// and force the enemy to attack unit for the given duration.
+func (tt *ThreatTable) Taunt(sim *Simulation, unit *Unit, duration time.Duration) {
+	for _, threat := range tt.threat {
+		tt.threat[unit.UnitIndex] = max(tt.threat[unit.UnitIndex], threat)
+	}
+	tt.ForceTarget(sim, unit, duration)
+}
+
+// Forces the enemy to attack unit for the given duration, without changing threat.
+func (tt *ThreatTable) ForceTarget(sim *Simulation, unit *Unit, duration time.Duration) {
+	tt.forcedUntil = max(tt.forcedUntil, sim.CurrentTime+duration)
+	if unit != tt.Holder() {
+		tt.setHolder(sim, unit)
+	}
+}
+
+// Wipes unit's threat, e.g. when it dies. If unit held aggro, it passes to
+// whoever has the most threat left, or the first living player.
+func (tt *ThreatTable) dropUnit(sim *Simulation, unit *Unit) {
+	tt.threat[unit.UnitIndex] = 0
+	if unit != tt.Holder() {
+		return
+	}
+
+	var newHolder *Unit
+	for _, other := range sim.Environment.AllUnits {
+		if other.Type == EnemyUnit || !other.enabled || other == unit {
+			continue
+		}
+		if newHolder == nil || tt.threat[other.UnitIndex] > tt.threat[newHolder.UnitIndex] {
+			newHolder = other
+		}
+	}
+
+	tt.forcedUntil = 0
+	if newHolder != nil {
+		tt.setHolder(sim, newHolder)
+	}
+}
+
+func (tt *ThreatTable) setHolder(sim *Simulation, unit *Unit) {
+	tt.creditAggroTime(sim)
+	tt.unit.CurrentTarget = unit
+	tt.aggroSince = sim.CurrentTime
+
+	if sim.Log != nil {
+		tt.unit.LogEvent(sim, &LogEvent{
+			Type:   proto.LogEventType_LogEventTargetChanged,
+			Target: unit,
+		})
+	}
+}
+
+func (tt *ThreatTable) creditAggroTime(sim *Simulation) {
+	if holder := tt.Holder(); holder != nil {
+		holder.Metrics.AggroTime += sim.CurrentTime - max(0, tt.aggroSince)
+	}
+}
+
+func (tt *ThreatTable) reset(_ *Simulation) {
+	for i := range tt.threat {
+		tt.threat[i] = 0
+	}
+	tt.unit.CurrentTarget = tt.unit.defaultTarget
+	tt.aggroSince = 0
+	tt.forcedUntil = 0
+}
+
+func (tt *ThreatTable) doneIteration(sim *Simulation) {
+	tt.creditAggroTime(sim)
+}
+
+// Healing threat is split evenly between all enemies with a threat table.
+func (encounter *Encounter) addHealingThreat(sim *Simulation, unit *Unit, threat float64) {
+	if !encounter.UseThreatTable || threat == 0 {
+		return
+	}
+
+	splitThreat := threat / float64(len(encounter.TargetUnits))
+	for _, target := range encounter.TargetUnits {
+		target.ThreatTable.AddThreat(sim, unit, splitThreat)
+	}
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func newThreatTestSetup() (*Simulation, *ThreatTable, *Unit, *Unit, *Unit) {
+	boss := &Unit{Type: EnemyUnit, UnitIndex: 0}
+	tank := &Unit{Type: PlayerUnit, UnitIndex: 1, DistanceFromTarget: MaxMeleeAttackDistance, enabled: true}
+	caster := &Unit{Type: PlayerUnit, UnitIndex: 2, DistanceFromTarget: 30, enabled: true}
+
+	sim := &Simulation{Environment: &Environment{AllUnits: []*Unit{boss, tank, caster}}}
+	boss.CurrentTarget = tank
+	boss.defaultTarget = tank
+	boss.ThreatTable = newThreatTable(boss, 3)
+	return sim, boss.ThreatTable, boss, tank, caster
+}
+
+func TestThreatTablePullThresholds(t *testing.T) {
+	sim, tt, boss, tank, caster := newThreatTestSetup()
+
+	tt.AddThreat(sim, tank, 1000)
+	tt.AddThreat(sim, caster, 1250)
+	if boss.CurrentTarget != tank {
+		t.Fatalf("Ranged unit pulled aggro below 130%%")
+	}
+
+	sim.CurrentTime = time.Second * 10
+	tt.AddThreat(sim, caster, 100)
+	if boss.CurrentTarget != caster {
+		t.Fatalf("Ranged unit did not pull aggro above 130%%")
+	}
+
+	// The tank is in melee range, so only needs 110% of the caster's threat.
+	sim.CurrentTime = time.Second * 15
+	tt.AddThreat(sim, tank, 600)
+	if boss.CurrentTarget != tank {
+		t.Fatalf("Melee unit did not pull aggro above 110%%")
+	}
+
+	tt.doneIteration(sim)
+	if tank.Metrics.AggroTime != time.Second*10 {
+		t.Fatalf("Expected tank aggro time of 10s, got %s", tank.Metrics.AggroTime)
+	}
+	if caster.Metrics.AggroTime != time.Second*5 {
+		t.Fatalf("Expected caster aggro time of 5s, got %s", caster.Metrics.AggroTime)
+	}
+}
+
+func TestThreatTableTaunt(t *testing.T) {
+	sim, tt, boss, tank, caster := newThreatTestSetup()
+
+	tt.AddThreat(sim, tank, 100)
+	tt.AddThreat(sim, caster, 1000)
+	if boss.CurrentTarget != caster {
+		t.Fatalf("Caster did not pull aggro")
+	}
+
+	tt.Taunt(sim, tank, time.Second*3)
+	if boss.CurrentTarget != tank || tt.Threat(tank) != 1000 {
+		t.Fatalf("Taunt did not give the tank aggro and top threat")
+	}
+
+	// Aggro can't be pulled while the taunt is active.
+	tt.AddThreat(sim, caster, 1000)
+	if boss.CurrentTarget != tank {
+		t.Fatalf("Caster pulled aggro during taunt")
+	}
+
+	sim.CurrentTime = time.Second * 3
+	tt.AddThreat(sim, caster, 1)
+	if boss.CurrentTarget != caster {
+		t.Fatalf("Caster did not pull aggro after taunt expired")
+	}
+
+	tt.reset(sim)
+	if boss.CurrentTarget != tank || tt.Threat(caster) != 0 {
+		t.Fatalf("Threat table was not reset")
+	}
+}
+
+func TestThreatTableDropUnit(t *testing.T) {
+	sim, tt, boss, tank, caster := newThreatTestSetup()
+
+	tt.AddThreat(sim, tank, 1000)
+	tt.AddThreat(sim, caster, 500)
+
+	tank.enabled = false
+	tt.dropUnit(sim, tank)
+	if boss.CurrentTarget != caster || tt.Threat(tank) != 0 {
+		t.Fatalf("Aggro did not pass to the caster when the tank died")
+	}
+
+	// Dead units can't pull aggro back.
+	tt.AddThreat(sim, tank, 10000)
+	if boss.CurrentTarget != caster {
+		t.Fatalf("Dead unit pulled aggro")
+	}
+}
+
+func TestThreatTableHolderReduction(t *testing.T) {
+	sim, tt, boss, tank, caster := newThreatTestSetup()
+	// Ahead of the caster in the raid, so the first unit past its threshold isn't the highest.
+	melee := &Unit{Type: PlayerUnit, UnitIndex: 3, DistanceFromTarget: MaxMeleeAttackDistance, enabled: true}
+	sim.Environment.AllUnits = []*Unit{boss, tank, melee, caster}
+	tt.threat = make([]float64, 4)
+
+	tt.AddThreat(sim, tank, 1000)
+	tt.AddThreat(sim, melee, 1050)
+	tt.AddThreat(sim, caster, 1250)
+	if boss.CurrentTarget != tank {
+		t.Fatalf("Aggro was pulled below the thresholds")
+	}
+
+	// Both are past their threshold once the tank drops threat.
+	tt.AddThreat(sim, tank, -500)
+	if boss.CurrentTarget != caster {
+		t.Fatalf("Expected the unit with the most threat to take aggro, got unit %d", boss.CurrentTarget.UnitIndex)
+	}
+}
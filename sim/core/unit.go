@@ -153,13 +153,16 @@ type Unit struct {
 	CurrentTarget *Unit
 	defaultTarget *Unit
 
+	// Threat table for enemy units, nil unless the encounter uses threat tables.
+	ThreatTable *ThreatTable
+
 	// The currently-channeled DOT spell, otherwise nil.
 	ChanneledDot *Dot
 }
 
 // Units can be disabled for several reasons:
 //  1. Downtime for temporary pets (e.g. Water Elemental)
-//  2. Enemy units in various phases (not yet implemented)
+//  2. Enemy units which haven't spawned yet, see Target.Spawn()
 //  3. Dead units (not yet implemented)
 func (unit *Unit) IsEnabled() bool {
 	return unit.enabled
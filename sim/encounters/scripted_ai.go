@@ -0,0 +1,344 @@
+package encounters
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// How often phase and event triggers are checked.
+const scriptUpdatePeriod = time.Millisecond * 100
+
+func init() {
+	core.RegisterScriptedTargetAI(func(script *proto.TargetScript) core.TargetAI {
+		return &ScriptedAI{Script: script}
+	})
+}
+
+// Implementation of TargetAI which interprets a TargetScript, so encounters can
+// be described in the encounter settings instead of in Go.
+type ScriptedAI struct {
+	Target *core.Target
+	Script *proto.TargetScript
+
+	Abilities []*core.Spell
+
+	// Index of the active phase, -1 until the target has been pulled or spawned.
+	phaseIndex int
+	phaseStart time.Duration
+	// Next time each event of the active phase fires, 0 if not yet triggered.
+	eventNextAt []time.Duration
+
+	// Abilities queued by events, cast ahead of the rotation.
+	queued []int32
+}
+
+func (ai *ScriptedAI) Initialize(target *core.Target, config *proto.Target) {
+	ai.Target = target
+
+	ai.Abilities = make([]*core.Spell, len(ai.Script.Abilities))
+	for i, abilityConfig := range ai.Script.Abilities {
+		ai.Abilities[i] = ai.registerAbility(int32(i), abilityConfig)
+	}
+
+	for _, phase := range ai.Script.Phases {
+		for _, event := range phase.Events {
+			for _, targetIndex := range event.SpawnTargets {
+				if targetIndex >= 0 && targetIndex < target.Env.GetNumTargets() {
+					target.Env.GetTarget(targetIndex).SpawnLater()
+				}
+			}
+		}
+	}
+}
+
+func (ai *ScriptedAI) registerAbility(index int32, config *proto.TargetScriptAbility) *core.Spell {
+	if config.ActionId == nil {
+		panic(fmt.Sprintf("[USER_ERROR] %s script ability %d is missing an action ID", ai.Target.Label, index+1))
+	}
+	actionID := core.ProtoToActionID(config.ActionId)
+
+	school := core.SpellSchoolFromProto(config.School)
+	isPhysical := school == core.SpellSchoolPhysical
+	defenseType := core.DefenseTypeMagic
+	if isPhysical {
+		defenseType = core.DefenseTypeMelee
+	}
+
+	var debuffs core.AuraArray
+	if config.Debuff != nil && config.Debuff.Duration > 0 {
+		debuffs = ai.registerDebuffs(index, actionID, config.Debuff)
+	}
+
+	castTime := core.DurationFromSeconds(config.CastTime)
+
+	return ai.Target.RegisterSpell(core.SpellConfig{
+		ActionID:         actionID,
+		SpellSchool:      school,
+		DefenseType:      defenseType,
+		ProcMask:         core.ProcMaskEmpty,
+		DamageMultiplier: 1,
+
+		Cast: core.CastConfig{
+			DefaultCast: core.Cast{
+				CastTime: castTime,
+			},
+			CD: core.Cooldown{
+				Timer:    ai.Target.NewTimer(),
+				Duration: core.DurationFromSeconds(config.Cooldown),
+			},
+			ModifyCast: func(sim *core.Simulation, spell *core.Spell, cast *core.Cast) {
+				if cast.CastTime > 0 {
+					spell.Unit.AutoAttacks.StopMeleeUntil(sim, sim.CurrentTime+cast.CastTime, false)
+				}
+			},
+		},
+
+		ApplyEffects: func(sim *core.Simulation, target *core.Unit, spell *core.Spell) {
+			outcomeApplier := spell.OutcomeMagicHit
+			if isPhysical {
+				outcomeApplier = spell.OutcomeEnemyMeleeWhite
+			}
+
+			for _, unit := range ai.selectUnits(sim, config.Target) {
+				landed := true
+				if config.MaxDamage > 0 {
+					baseDamage := sim.Roll(config.MinDamage, max(config.MinDamage, config.MaxDamage))
+					landed = spell.CalcAndDealDamage(sim, unit, baseDamage, outcomeApplier).Landed()
+				}
+
+				if landed && debuffs != nil {
+					debuff := debuffs.Get(unit)
+					debuff.Activate(sim)
+					debuff.AddStack(sim)
+				}
+			}
+		},
+	})
+}
+
+func (ai *ScriptedAI) registerDebuffs(index int32, actionID core.ActionID, config *proto.TargetScriptDebuff) core.AuraArray {
+	label := config.Label
+	if label == "" {
+		label = fmt.Sprintf("Script Debuff %d", index+1)
+	}
+	maxStacks := max(config.MaxStacks, 1)
+	damageTakenMultiplier := core.TernaryFloat64(config.DamageTakenMultiplier != 0, config.DamageTakenMultiplier, 1)
+	damageDealtMultiplier := core.TernaryFloat64(config.DamageDealtMultiplier != 0, config.DamageDealtMultiplier, 1)
+
+	return ai.Target.NewRaidAuraArray(func(unit *core.Unit) *core.Aura {
+		return unit.RegisterAura(core.Aura{
+			Label:     label + "-" + ai.Target.Label,
+			ActionID:  actionID,
+			Duration:  core.DurationFromSeconds(config.Duration),
+			MaxStacks: maxStacks,
+			OnStacksChange: func(aura *core.Aura, sim *core.Simulation, oldStacks int32, newStacks int32) {
+				stacks := float64(newStacks - oldStacks)
+				aura.Unit.PseudoStats.DamageTakenMultiplier *= math.Pow(damageTakenMultiplier, stacks)
+				aura.Unit.PseudoStats.DamageDealtMultiplier *= math.Pow(damageDealtMultiplier, stacks)
+			},
+		})
+	})
+}
+
+func (ai *ScriptedAI) selectUnits(sim *core.Simulation, selection proto.TargetScript_Selection) []*core.Unit {
+	players := ai.Target.Env.Raid.AllPlayerUnits
+
+	switch selection {
+	case proto.TargetScript_RandomPlayer:
+		if len(players) == 0 {
+			return nil
+		}
+		idx := int(sim.RandomFloat("Target Script Selection") * float64(len(players)))
+		return []*core.Unit{players[min(idx, len(players)-1)]}
+	case proto.TargetScript_AllPlayers:
+		return players
+	default:
+		if ai.Target.CurrentTarget == nil {
+			return nil
+		}
+		return []*core.Unit{ai.Target.CurrentTarget}
+	}
+}
+
+func (ai *ScriptedAI) Reset(sim *core.Simulation) {
+	ai.phaseIndex = -1
+	ai.phaseStart = 0
+	ai.eventNextAt = nil
+	ai.queued = ai.queued[:0]
+
+	core.StartPeriodicAction(sim, core.PeriodicActionOptions{
+		Period: scriptUpdatePeriod,
+		OnAction: func(sim *core.Simulation) {
+			ai.update(sim)
+		},
+	})
+}
+
+func (ai *ScriptedAI) update(sim *core.Simulation) {
+	if sim.CurrentTime < 0 || !ai.Target.IsEnabled() || len(ai.Script.Phases) == 0 {
+		return
+	}
+
+	if ai.phaseIndex == -1 {
+		ai.enterPhase(sim, 0)
+	}
+
+	// Later phases can trigger directly from any earlier one.
+	for i := len(ai.Script.Phases) - 1; i > ai.phaseIndex; i-- {
+		if ai.triggerMet(sim, ai.Script.Phases[i].Trigger) {
+			ai.enterPhase(sim, i)
+			break
+		}
+	}
+
+	phase := ai.Script.Phases[ai.phaseIndex]
+	for i, event := range phase.Events {
+		nextAt := ai.eventNextAt[i]
+		if nextAt == 0 {
+			if !ai.triggerMet(sim, event.Trigger) {
+				continue
+			}
+		} else if nextAt == core.NeverExpires || sim.CurrentTime < nextAt {
+			continue
+		}
+
+		ai.eventNextAt[i] = core.NeverExpires
+		if event.RepeatInterval > 0 {
+			ai.eventNextAt[i] = sim.CurrentTime + core.DurationFromSeconds(event.RepeatInterval)
+		}
+		ai.fireEvent(sim, event)
+	}
+}
+
+func (ai *ScriptedAI) triggerMet(sim *core.Simulation, trigger *proto.TargetScriptTrigger) bool {
+	if trigger == nil {
+		return true
+	}
+	if sim.CurrentTime < core.DurationFromSeconds(trigger.Time) {
+		return false
+	}
+	if sim.CurrentTime-ai.phaseStart < core.DurationFromSeconds(trigger.PhaseTime) {
+		return false
+	}
+	if trigger.HealthPercent > 0 && sim.GetRemainingDurationPercent() > trigger.HealthPercent {
+		return false
+	}
+	return true
+}
+
+func (ai *ScriptedAI) enterPhase(sim *core.Simulation, index int) {
+	if ai.phaseIndex >= 0 {
+		oldPhase := ai.Script.Phases[ai.phaseIndex]
+		if oldPhase.DamageMultiplier != 0 {
+			ai.Target.PseudoStats.DamageDealtMultiplier /= oldPhase.DamageMultiplier
+		}
+		if oldPhase.DisableAutoAttacks {
+			ai.Target.AutoAttacks.EnableAutoSwing(sim)
+		}
+	}
+
+	phase := ai.Script.Phases[index]
+	ai.phaseIndex = index
+	ai.phaseStart = sim.CurrentTime
+	ai.eventNextAt = make([]time.Duration, len(phase.Events))
+
+	if phase.DamageMultiplier != 0 {
+		ai.Target.PseudoStats.DamageDealtMultiplier *= phase.DamageMultiplier
+	}
+	if phase.DisableAutoAttacks {
+		ai.Target.AutoAttacks.CancelAutoSwing(sim)
+	}
+
+	if sim.Log != nil {
+		ai.Target.Log(sim, "Entering phase %d: %s", index+1, phase.Name)
+	}
+}
+
+func (ai *ScriptedAI) fireEvent(sim *core.Simulation, event *proto.TargetScriptEvent) {
+	for _, abilityIndex := range event.CastAbilities {
+		if abilityIndex >= 0 && int(abilityIndex) < len(ai.Abilities) {
+			ai.queued = append(ai.queued, abilityIndex)
+		}
+	}
+
+	for _, targetIndex := range event.SpawnTargets {
+		if targetIndex >= 0 && targetIndex < ai.Target.Env.GetNumTargets() {
+			ai.Target.Env.GetTarget(targetIndex).Spawn(sim)
+		}
+	}
+	for _, targetIndex := range event.DespawnTargets {
+		if targetIndex >= 0 && targetIndex < ai.Target.Env.GetNumTargets() {
+			ai.Target.Env.GetTarget(targetIndex).Despawn(sim)
+		}
+	}
+
+	if event.Movement != nil && event.Movement.Distance != 0 {
+		ai.forceMovement(sim, event.Movement)
+	}
+}
+
+func (ai *ScriptedAI) forceMovement(sim *core.Simulation, movement *proto.TargetScriptMovement) {
+	distance := math.Round(movement.Distance)
+
+	for _, unit := range ai.selectUnits(sim, movement.Targets) {
+		if unit.IsMoving() {
+			continue
+		}
+
+		unit := unit
+		startDistance := unit.DistanceFromTarget
+		travelTime := core.DurationFromSeconds(math.Abs(distance) / unit.MovementHandler.MoveSpeed)
+
+		unit.MoveTo(startDistance+distance, sim)
+		core.StartDelayedAction(sim, core.DelayedActionOptions{
+			DoAt: sim.CurrentTime + travelTime + core.DurationFromSeconds(movement.Duration),
+			OnAction: func(sim *core.Simulation) {
+				unit.MoveTo(startDistance, sim)
+			},
+		})
+	}
+}
+
+func (ai *ScriptedAI) ExecuteCustomRotation(sim *core.Simulation) {
+	for i, abilityIndex := range ai.queued {
+		spell := ai.Abilities[abilityIndex]
+		if ai.castAbility(sim, spell) {
+			ai.queued = append(ai.queued[:i], ai.queued[i+1:]...)
+			return
+		}
+	}
+
+	if ai.phaseIndex < 0 {
+		return
+	}
+
+	for _, abilityIndex := range ai.Script.Phases[ai.phaseIndex].Rotation {
+		if abilityIndex < 0 || int(abilityIndex) >= len(ai.Abilities) {
+			continue
+		}
+		if ai.castAbility(sim, ai.Abilities[abilityIndex]) {
+			return
+		}
+	}
+}
+
+func (ai *ScriptedAI) castAbility(sim *core.Simulation, spell *core.Spell) bool {
+	target := ai.Target.CurrentTarget
+	if target == nil {
+		// For individual non tank sims we still want abilities to work
+		players := ai.Target.Env.Raid.AllPlayerUnits
+		if len(players) == 0 {
+			return false
+		}
+		target = players[0]
+	}
+
+	if !spell.CanCast(sim, target) {
+		return false
+	}
+	return spell.Cast(sim, target)
+}
@@ -0,0 +1,106 @@
+package encounters
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/shaman/elemental"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func init() {
+	elemental.RegisterElementalShaman()
+}
+
+// A boss which casts Shadow Bolt on cooldown, then switches to Frostbolt after 15 seconds.
+const testScriptJSON = `{
+	"abilities": [
+		{"actionId": {"spellId": 1}, "school": "SpellSchoolShadow", "minDamage": 100, "maxDamage": 200, "cooldown": 10, "target": "AllPlayers",
+			"debuff": {"label": "Test Debuff", "duration": 30, "maxStacks": 2, "damageTakenMultiplier": 1.1}},
+		{"actionId": {"spellId": 2}, "school": "SpellSchoolFrost", "minDamage": 100, "maxDamage": 100, "cooldown": 10}
+	],
+	"phases": [
+		{"name": "One", "rotation": [0]},
+		{"name": "Two", "trigger": {"time": 15}, "rotation": [1]}
+	]
+}`
+
+func scriptedRaidSimRequest(t *testing.T, scriptJSON string) *proto.RaidSimRequest {
+	script := &proto.TargetScript{}
+	if err := protojson.Unmarshal([]byte(scriptJSON), script); err != nil {
+		t.Fatalf("Failed to parse script: %s", err)
+	}
+
+	return &proto.RaidSimRequest{
+		Raid: core.SinglePlayerRaidProto(
+			&proto.Player{
+				Race:      proto.Race_RaceTroll,
+				Class:     proto.Class_ClassShaman,
+				Level:     60,
+				Equipment: &proto.EquipmentSpec{},
+				Spec:      &proto.Player_ElementalShaman{ElementalShaman: &proto.ElementalShaman{}},
+			},
+			&proto.PartyBuffs{},
+			&proto.RaidBuffs{},
+			&proto.Debuffs{}),
+		Encounter: &proto.Encounter{
+			Duration: 30,
+			Targets:  []*proto.Target{{Name: "Scripted Boss", Level: 63, Script: script}},
+		},
+		SimOptions: &proto.SimOptions{
+			Iterations: 1,
+			RandomSeed: 1,
+		},
+	}
+}
+
+func TestScriptParsing(t *testing.T) {
+	request := scriptedRaidSimRequest(t, testScriptJSON)
+	script := request.Encounter.Targets[0].Script
+
+	if len(script.Abilities) != 2 || len(script.Phases) != 2 {
+		t.Fatalf("Expected 2 abilities and 2 phases, got %v", script)
+	}
+	ability := script.Abilities[0]
+	if ability.School != proto.SpellSchool_SpellSchoolShadow || ability.Target != proto.TargetScript_AllPlayers || ability.Debuff.GetMaxStacks() != 2 {
+		t.Fatalf("Unexpected first ability: %v", ability)
+	}
+	if script.Phases[1].Trigger.GetTime() != 15 || len(script.Phases[1].Rotation) != 1 {
+		t.Fatalf("Unexpected second phase: %v", script.Phases[1])
+	}
+
+	// Abilities need an action ID to show up in the results.
+	result := core.RunRaidSim(scriptedRaidSimRequest(t, `{"abilities": [{"school": "SpellSchoolFire"}]}`))
+	if result.Error == nil || !strings.Contains(result.Error.Message, "missing an action ID") {
+		t.Fatalf("Expected an error for an ability without action ID, got %v", result.Error)
+	}
+}
+
+func TestScriptExecution(t *testing.T) {
+	result := core.RunRaidSim(scriptedRaidSimRequest(t, testScriptJSON))
+	if result.Error != nil {
+		t.Fatalf("Sim failed: %s", result.Error.Message)
+	}
+
+	casts := map[int32]int32{}
+	for _, action := range result.EncounterMetrics.Targets[0].Actions {
+		for _, target := range action.Targets {
+			casts[action.Id.GetSpellId()] += target.Casts
+		}
+	}
+	// Without a tank the boss casts at the player. Shadow Bolt is used at 0s
+	// and 10s, and Frostbolt from the second phase onwards.
+	if casts[1] != 2 {
+		t.Errorf("Expected 2 casts of the first phase ability, got %d", casts[1])
+	}
+	if casts[2] == 0 || casts[2] > 2 {
+		t.Errorf("Expected 1 or 2 casts of the second phase ability, got %d", casts[2])
+	}
+
+	player := result.RaidMetrics.Parties[0].Players[0]
+	if player.Dtps.GetAvg() == 0 {
+		t.Errorf("Expected the player to take damage from the script")
+	}
+}
@@ -9,6 +9,14 @@ import (
 	"github.com/wowsims/sod/sim/core/stats"
 )
 
+func init() {
+	core.RegisterRuneTalentRequirement(int32(proto.HunterRune_RuneBootsWyvernStrike), func(talentsString string) bool {
+		talents := &proto.HunterTalents{}
+		core.FillTalentsProto(talents.ProtoReflect(), talentsString, TalentTreeSizes)
+		return talents.WyvernSting
+	})
+}
+
 func (hunter *Hunter) ApplyRunes() {
 	hunter.applyShoulderRuneEffect()
 
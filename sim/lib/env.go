@@ -0,0 +1,263 @@
+package main
+
+// #include <stdlib.h>
+import "C"
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"unsafe"
+
+	"github.com/wowsims/sod/sim"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/rl"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// RL environments by handle. Unlike the single _active_sim, any number of
+// environments can be used at once, each from its own thread.
+var (
+	_envs_mut     sync.Mutex
+	_envs               = map[int32]*rl.Env{}
+	_next_env     int32 = 1
+	_env_error    string
+	_register_all sync.Once
+)
+
+type envConfig struct {
+	Request json.RawMessage `json:"request"`
+	rl.Config
+}
+
+func getEnv(handle int32) *rl.Env {
+	_envs_mut.Lock()
+	defer _envs_mut.Unlock()
+	return _envs[handle]
+}
+
+// Creates an environment from a JSON config with a protojson RaidSimRequest
+// under "request" and the rl.Config fields. Returns its handle, or -1 with
+// the reason available from envLastError.
+//
+//export envNew
+func envNew(json *C.char) int32 {
+	env, err := newEnvFromJSON(C.GoString(json))
+
+	_envs_mut.Lock()
+	defer _envs_mut.Unlock()
+	if err != nil {
+		_env_error = err.Error()
+		return -1
+	}
+	handle := _next_env
+	_next_env++
+	_envs[handle] = env
+	return handle
+}
+
+func newEnvFromJSON(jsonString string) (*rl.Env, error) {
+	config := &envConfig{}
+	if err := json.Unmarshal([]byte(jsonString), config); err != nil {
+		return nil, err
+	}
+	if len(config.Request) == 0 {
+		return nil, errors.New("config has no request")
+	}
+	config.Config.Request = &proto.RaidSimRequest{}
+	if err := protojson.Unmarshal(config.Request, config.Config.Request); err != nil {
+		return nil, err
+	}
+
+	_register_all.Do(sim.RegisterAll)
+	return rl.NewEnv(config.Config)
+}
+
+//export envLastError
+func envLastError() *C.char {
+	_envs_mut.Lock()
+	defer _envs_mut.Unlock()
+	return C.CString(_env_error)
+}
+
+// Returns the observation and action schema as JSON, or NULL for unknown handles.
+//
+//export envSchema
+func envSchema(handle int32) *C.char {
+	env := getEnv(handle)
+	if env == nil {
+		return nil
+	}
+	out, err := json.Marshal(env.Schema())
+	if err != nil {
+		panic(err)
+	}
+	return C.CString(string(out))
+}
+
+//export envObservationSize
+func envObservationSize(handle int32) int32 {
+	env := getEnv(handle)
+	if env == nil {
+		return -1
+	}
+	return int32(env.NumObservations())
+}
+
+//export envActionCount
+func envActionCount(handle int32) int32 {
+	env := getEnv(handle)
+	if env == nil {
+		return -1
+	}
+	return int32(env.NumActions())
+}
+
+// Starts a new episode and writes the first observation. Returns false for unknown handles.
+//
+//export envReset
+func envReset(handle int32, seed int64, obs *float64) bool {
+	env := getEnv(handle)
+	if env == nil {
+		return false
+	}
+	env.Reset(seed)
+	env.Observe(unsafe.Slice(obs, env.NumObservations()))
+	return true
+}
+
+// Takes an action and writes the next observation and the reward. Returns 1
+// once the episode is over, 0 if it continues and -1 for unknown handles.
+//
+//export envStep
+func envStep(handle int32, action int32, obs *float64, reward *float64) int32 {
+	env := getEnv(handle)
+	if env == nil {
+		return -1
+	}
+	r, done := env.Step(int(action))
+	env.Observe(unsafe.Slice(obs, env.NumObservations()))
+	*reward = r
+	if done {
+		return 1
+	}
+	return 0
+}
+
+// Writes 1 for each action which can be taken right now, 0 otherwise.
+//
+//export envActionMask
+func envActionMask(handle int32, storage *uint8) {
+	env := getEnv(handle)
+	if env == nil {
+		return
+	}
+	mask := make([]bool, env.NumActions())
+	env.ActionMask(mask)
+	out := unsafe.Slice(storage, len(mask))
+	for i, valid := range mask {
+		out[i] = 0
+		if valid {
+			out[i] = 1
+		}
+	}
+}
+
+//export envLastActionValid
+func envLastActionValid(handle int32) bool {
+	env := getEnv(handle)
+	return env != nil && env.LastActionValid()
+}
+
+//export envCurrentTime
+func envCurrentTime(handle int32) float64 {
+	env := getEnv(handle)
+	if env == nil {
+		return 0
+	}
+	return env.CurrentTime().Seconds()
+}
+
+//export envClose
+func envClose(handle int32) {
+	_envs_mut.Lock()
+	defer _envs_mut.Unlock()
+	delete(_envs, handle)
+}
+
+// Starts recording a decision trace of the following steps. Returns false for unknown handles.
+//
+//export envTraceStart
+func envTraceStart(handle int32) bool {
+	env := getEnv(handle)
+	if env == nil {
+		return false
+	}
+	env.StartTrace()
+	return true
+}
+
+// Stops recording and returns the trace as JSON, or NULL if none was recorded.
+//
+//export envTraceStop
+func envTraceStop(handle int32) *C.char {
+	env := getEnv(handle)
+	if env == nil {
+		return nil
+	}
+	trace := env.StopTrace()
+	if trace == nil {
+		return nil
+	}
+	out, err := json.Marshal(trace)
+	if err != nil {
+		panic(err)
+	}
+	return C.CString(string(out))
+}
+
+type distillConfig struct {
+	Trace *rl.Trace `json:"trace"`
+	rl.DistillConfig
+}
+
+type distillOutput struct {
+	Rotation json.RawMessage `json:"rotation"`
+	*rl.DistillResult
+}
+
+// Distills a trace into an APL rotation. Takes a JSON config with the trace
+// under "trace" and the rl.DistillConfig fields, and returns the rules, the
+// protojson APLRotation under "rotation" and the evaluation as JSON, or NULL
+// with the reason available from envLastError.
+//
+//export envDistill
+func envDistill(handle int32, json *C.char) *C.char {
+	out, err := distillFromJSON(handle, C.GoString(json))
+	if err != nil {
+		_envs_mut.Lock()
+		defer _envs_mut.Unlock()
+		_env_error = err.Error()
+		return nil
+	}
+	return C.CString(string(out))
+}
+
+func distillFromJSON(handle int32, jsonString string) ([]byte, error) {
+	env := getEnv(handle)
+	if env == nil {
+		return nil, errors.New("unknown environment")
+	}
+	config := &distillConfig{}
+	if err := json.Unmarshal([]byte(jsonString), config); err != nil {
+		return nil, err
+	}
+	result, err := env.Distill(config.Trace, config.DistillConfig)
+	if err != nil {
+		return nil, err
+	}
+	rotation, err := protojson.Marshal(result.Rotation)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(distillOutput{Rotation: rotation, DistillResult: result})
+}
@@ -0,0 +1,110 @@
+"""Drives the RL environment through the shared library.
+
+Uses the library at $WOWSIMS_LIB, or builds one with go. Run from the repo root:
+
+    python3 -m unittest discover sim/lib/python
+"""
+
+import os
+import subprocess
+import tempfile
+import threading
+import unittest
+
+import numpy as np
+
+from wowsims_env import WowsimsEnv, load_library
+
+REPO_ROOT = os.path.abspath(os.path.join(os.path.dirname(__file__), "..", "..", ".."))
+
+REQUEST = {
+    "raid": {"parties": [{"players": [{"race": "RaceTroll", "class": "ClassShaman", "level": 60, "elementalShaman": {}}]}]},
+    "encounter": {"duration": 30, "targets": [{}]},
+}
+
+
+def setUpModule():
+    global LIB
+    path = os.environ.get("WOWSIMS_LIB")
+    if not path:
+        path = os.path.join(tempfile.mkdtemp(), "wowsimsod.so")
+        subprocess.run(["go", "build", "-buildmode=c-shared", "-tags=with_db", "-o", path, "./sim/lib/"], cwd=REPO_ROOT, check=True)
+    LIB = load_library(path)
+
+
+def first_castable(env):
+    mask = env.action_mask()
+    for i, action in enumerate(env.schema["actions"]):
+        if i > 0 and mask[i] and not action["off_gcd"]:
+            return i
+    return 0
+
+
+def run_episode(env, seed):
+    obs, info = env.reset(seed=seed)
+    rewards = []
+    done = False
+    while not done:
+        obs, reward, done, truncated, info = env.step(first_castable(env))
+        assert obs.shape == (len(env.observation_names),)
+        rewards.append(reward)
+    return rewards
+
+
+class WowsimsEnvTest(unittest.TestCase):
+    def test_schema(self):
+        env = WowsimsEnv(LIB, REQUEST)
+        self.assertEqual(env.action_names[0], "wait")
+        self.assertIn("player.mana", env.observation_names)
+        self.assertIn("player.gcd_remaining", env.observation_names)
+        env.close()
+
+    def test_invalid_config(self):
+        with self.assertRaises(ValueError):
+            WowsimsEnv(LIB, REQUEST, reward="fun")
+
+    def test_deterministic_reset(self):
+        env = WowsimsEnv(LIB, REQUEST)
+        first = run_episode(env, 3)
+        self.assertGreater(sum(first), 0)
+        self.assertEqual(run_episode(env, 3), first)
+        env.close()
+
+    def test_distill(self):
+        env = WowsimsEnv(LIB, REQUEST)
+        env.start_trace()
+        for seed in range(1, 4):
+            run_episode(env, seed)
+        trace = env.stop_trace()
+        self.assertEqual(len(trace["episodes"]), 3)
+
+        result = env.distill(trace, eval_iterations=20)
+        self.assertGreaterEqual(result["agreement"], 0.9)
+        self.assertEqual(len(result["rotation"]["priorityList"]), len(result["rules"]))
+        self.assertGreater(result["distilled_per_second"], 0)
+
+        with self.assertRaises(ValueError):
+            env.distill({"schema": {"observations": [], "actions": []}})
+        env.close()
+
+    def test_concurrent_handles(self):
+        envs = [WowsimsEnv(LIB, REQUEST) for _ in range(4)]
+        results = [None] * len(envs)
+
+        def run(i):
+            results[i] = run_episode(envs[i], 11)
+
+        threads = [threading.Thread(target=run, args=(i,)) for i in range(len(envs))]
+        for thread in threads:
+            thread.start()
+        for thread in threads:
+            thread.join()
+
+        for result in results[1:]:
+            np.testing.assert_array_equal(result, results[0])
+        for env in envs:
+            env.close()
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,152 @@
+"""Gymnasium-style environment over the wowsims shared library.
+
+Build the library with `make locallib`, then:
+
+    env = WowsimsEnv("wowsimsod.so", request, reward="damage")
+    obs, info = env.reset(seed=1)
+    obs, reward, terminated, truncated, info = env.step(action)
+
+If gymnasium is installed, WowsimsEnv is a gymnasium.Env with matching
+observation and action spaces.
+"""
+
+import ctypes
+import json
+
+import numpy as np
+
+try:
+    import gymnasium
+    from gymnasium import spaces
+
+    _Base = gymnasium.Env
+except ImportError:
+    gymnasium = None
+    _Base = object
+
+
+def load_library(path):
+    lib = ctypes.CDLL(path)
+
+    lib.envNew.argtypes = [ctypes.c_char_p]
+    lib.envNew.restype = ctypes.c_int32
+    lib.envLastError.restype = ctypes.c_void_p
+    lib.envSchema.argtypes = [ctypes.c_int32]
+    lib.envSchema.restype = ctypes.c_void_p
+    lib.envObservationSize.argtypes = [ctypes.c_int32]
+    lib.envObservationSize.restype = ctypes.c_int32
+    lib.envActionCount.argtypes = [ctypes.c_int32]
+    lib.envActionCount.restype = ctypes.c_int32
+    lib.envReset.argtypes = [ctypes.c_int32, ctypes.c_int64, ctypes.POINTER(ctypes.c_double)]
+    lib.envReset.restype = ctypes.c_bool
+    lib.envStep.argtypes = [ctypes.c_int32, ctypes.c_int32, ctypes.POINTER(ctypes.c_double), ctypes.POINTER(ctypes.c_double)]
+    lib.envStep.restype = ctypes.c_int32
+    lib.envActionMask.argtypes = [ctypes.c_int32, ctypes.POINTER(ctypes.c_uint8)]
+    lib.envActionMask.restype = None
+    lib.envLastActionValid.argtypes = [ctypes.c_int32]
+    lib.envLastActionValid.restype = ctypes.c_bool
+    lib.envCurrentTime.argtypes = [ctypes.c_int32]
+    lib.envCurrentTime.restype = ctypes.c_double
+    lib.envTraceStart.argtypes = [ctypes.c_int32]
+    lib.envTraceStart.restype = ctypes.c_bool
+    lib.envTraceStop.argtypes = [ctypes.c_int32]
+    lib.envTraceStop.restype = ctypes.c_void_p
+    lib.envDistill.argtypes = [ctypes.c_int32, ctypes.c_char_p]
+    lib.envDistill.restype = ctypes.c_void_p
+    lib.envClose.argtypes = [ctypes.c_int32]
+    lib.envClose.restype = None
+    lib.FreeCString.argtypes = [ctypes.c_void_p]
+    lib.FreeCString.restype = None
+    return lib
+
+
+def _take_string(lib, ptr):
+    if not ptr:
+        return None
+    try:
+        return ctypes.string_at(ptr).decode()
+    finally:
+        lib.FreeCString(ptr)
+
+
+class WowsimsEnv(_Base):
+    """Controls the first player of a RaidSimRequest.
+
+    request is a RaidSimRequest as a dict in protojson form. The other keyword
+    arguments are the rl.Config fields: reward ("damage", "threat" or
+    "healing"), reward_scale, invalid_action_penalty, wait_seconds,
+    decision_interval_seconds, auras and target_auras.
+    """
+
+    def __init__(self, lib, request, **config):
+        self.lib = load_library(lib) if isinstance(lib, str) else lib
+        self.handle = self.lib.envNew(json.dumps(dict(config, request=request)).encode())
+        if self.handle < 0:
+            raise ValueError(_take_string(self.lib, self.lib.envLastError()))
+
+        self.schema = json.loads(_take_string(self.lib, self.lib.envSchema(self.handle)))
+        self.observation_names = [f["name"] for f in self.schema["observations"]]
+        self.action_names = [a["name"] for a in self.schema["actions"]]
+
+        self._obs = (ctypes.c_double * len(self.observation_names))()
+        self._mask = (ctypes.c_uint8 * len(self.action_names))()
+        self._reward = ctypes.c_double()
+
+        if gymnasium is not None:
+            self.observation_space = spaces.Box(-np.inf, np.inf, shape=(len(self.observation_names),), dtype=np.float64)
+            self.action_space = spaces.Discrete(len(self.action_names))
+
+    def _observation(self):
+        return np.frombuffer(self._obs, dtype=np.float64).copy()
+
+    def _info(self):
+        return {
+            "time": self.lib.envCurrentTime(self.handle),
+            "action_mask": self.action_mask(),
+            "last_action_valid": self.lib.envLastActionValid(self.handle),
+        }
+
+    def action_mask(self):
+        self.lib.envActionMask(self.handle, self._mask)
+        return np.frombuffer(self._mask, dtype=np.uint8).astype(bool)
+
+    def reset(self, *, seed=None, options=None):
+        if seed is None:
+            seed = np.random.randint(1, 2**62)
+        self.lib.envReset(self.handle, seed, self._obs)
+        return self._observation(), self._info()
+
+    def step(self, action):
+        done = self.lib.envStep(self.handle, int(action), self._obs, ctypes.byref(self._reward))
+        if done < 0:
+            raise RuntimeError("environment is closed")
+        # Episodes end with the encounter, there is no separate time limit.
+        return self._observation(), self._reward.value, done == 1, False, self._info()
+
+    def start_trace(self):
+        """Records every following step until stop_trace."""
+        if not self.lib.envTraceStart(self.handle):
+            raise RuntimeError("environment is closed")
+
+    def stop_trace(self):
+        """Returns the recorded trace as a dict, or None if none was started."""
+        trace = _take_string(self.lib, self.lib.envTraceStop(self.handle))
+        return json.loads(trace) if trace else None
+
+    def distill(self, trace, **config):
+        """Fits an APL rotation to a trace from this environment.
+
+        The keyword arguments are the rl.DistillConfig fields: max_rules,
+        max_conditions, min_support and eval_iterations. Returns a dict with the
+        APLRotation in protojson form under "rotation", the rules, their
+        agreement with the trace and the reward per second of both.
+        """
+        result = _take_string(self.lib, self.lib.envDistill(self.handle, json.dumps(dict(config, trace=trace)).encode()))
+        if result is None:
+            raise ValueError(_take_string(self.lib, self.lib.envLastError()))
+        return json.loads(result)
+
+    def close(self):
+        if self.handle >= 0:
+            self.lib.envClose(self.handle)
+            self.handle = -1
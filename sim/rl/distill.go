@@ -0,0 +1,401 @@
+package rl
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+type DistillConfig struct {
+	// Most rules found before the fallback rule, defaults to 8.
+	MaxRules int `json:"max_rules"`
+	// Most comparisons and-ed into one rule, defaults to 2.
+	MaxConditions int `json:"max_conditions"`
+	// Fewest traced casts a rule has to get right, defaults to 1% of them.
+	MinSupport int `json:"min_support"`
+	// Iterations to sim the distilled rotation for, defaults to 100. Negative
+	// skips the evaluation.
+	EvalIterations int32 `json:"eval_iterations"`
+}
+
+type Condition struct {
+	Feature string `json:"feature"`
+	// "<=" or ">=".
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+
+	index int
+}
+
+// Casts an action whenever all conditions hold and it can be cast.
+type Rule struct {
+	Action     string      `json:"action"`
+	Conditions []Condition `json:"conditions"`
+	// Traced casts the rule fires on whose action did and didn't match.
+	Correct int `json:"correct"`
+	Wrong   int `json:"wrong"`
+
+	action int
+}
+
+type DistillResult struct {
+	Rotation *proto.APLRotation `json:"-"`
+	Rules    []Rule             `json:"rules"`
+	// Fraction of the traced casts the rotation makes the same way.
+	Agreement float64 `json:"agreement"`
+	// Reward per second of the traced episodes and of the distilled rotation
+	// in a normal sim, e.g. DPS for damage rewards. Pets are included.
+	TracedPerSecond    float64 `json:"traced_per_second"`
+	DistilledPerSecond float64 `json:"distilled_per_second"`
+}
+
+// Fits a priority list of cast rules to the casts in a trace recorded from
+// this env, and converts it to an APL rotation. Rules are found greedily: each
+// is the action and conditions which decide the most remaining casts right,
+// minus the ones it decides wrong, which is how the APL will run them.
+func (env *Env) Distill(trace *Trace, config DistillConfig) (*DistillResult, error) {
+	if trace == nil || len(trace.Schema.Observations) != len(env.features) || len(trace.Schema.Actions) != len(env.actions) {
+		return nil, errors.New("trace doesn't match the environment")
+	}
+	if config.MaxRules <= 0 {
+		config.MaxRules = 8
+	}
+	if config.MaxConditions <= 0 {
+		config.MaxConditions = 2
+	}
+	if config.EvalIterations == 0 {
+		config.EvalIterations = 100
+	}
+
+	var casts []Sample
+	for _, sample := range trace.Samples {
+		if sample.Action > 0 && sample.Action < len(env.actions) && len(sample.Mask) == len(env.actions) &&
+			len(sample.Observation) == len(env.features) && sample.Mask[sample.Action] {
+			casts = append(casts, sample)
+		}
+	}
+	if len(casts) == 0 {
+		return nil, errors.New("trace has no casts")
+	}
+	if config.MinSupport <= 0 {
+		config.MinSupport = max(1, len(casts)/100)
+	}
+
+	d := newDistiller(env, casts, config)
+	rules := d.findRules()
+
+	agreed := 0
+	for _, sample := range casts {
+		if predict(rules, sample) == sample.Action {
+			agreed++
+		}
+	}
+	result := &DistillResult{
+		Rules:           rules,
+		Rotation:        env.rotation(rules),
+		Agreement:       float64(agreed) / float64(len(casts)),
+		TracedPerSecond: trace.meanPerSecond(),
+	}
+	if config.EvalIterations > 0 {
+		perSecond, err := env.evaluate(result.Rotation, config.EvalIterations)
+		if err != nil {
+			return nil, err
+		}
+		result.DistilledPerSecond = perSecond
+	}
+	return result, nil
+}
+
+type distiller struct {
+	env     *Env
+	samples []Sample
+	config  DistillConfig
+
+	// Features with an APL value, and the sample indices sorted by each.
+	features []int
+	order    [][]int
+}
+
+func newDistiller(env *Env, samples []Sample, config DistillConfig) *distiller {
+	d := &distiller{env: env, samples: samples, config: config}
+	for f, feature := range env.features {
+		if feature.value == nil {
+			continue
+		}
+		order := make([]int, len(samples))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) int {
+			return cmp.Compare(samples[a].Observation[f], samples[b].Observation[f])
+		})
+		d.features = append(d.features, f)
+		d.order = append(d.order, order)
+	}
+	return d
+}
+
+func (c Condition) holds(sample Sample) bool {
+	if c.Op == "<=" {
+		return sample.Observation[c.index] <= c.Threshold
+	}
+	return sample.Observation[c.index] >= c.Threshold
+}
+
+func (rule Rule) fires(sample Sample) bool {
+	if !sample.Mask[rule.action] {
+		return false
+	}
+	for _, c := range rule.Conditions {
+		if !c.holds(sample) {
+			return false
+		}
+	}
+	return true
+}
+
+// The action the first firing rule casts, 0 for waiting.
+func predict(rules []Rule, sample Sample) int {
+	for _, rule := range rules {
+		if rule.fires(sample) {
+			return rule.action
+		}
+	}
+	return 0
+}
+
+func (d *distiller) findRules() []Rule {
+	remaining := make([]bool, len(d.samples))
+	for i := range remaining {
+		remaining[i] = true
+	}
+
+	var rules []Rule
+	for len(rules) < d.config.MaxRules {
+		var best Rule
+		bestScore := 0
+		for action := 1; action < len(d.env.actions); action++ {
+			rule := d.growRule(action, remaining)
+			if score := rule.Correct - rule.Wrong; rule.Correct >= d.config.MinSupport && score > bestScore {
+				best, bestScore = rule, score
+			}
+		}
+		if bestScore == 0 {
+			break
+		}
+		rules = append(rules, best)
+		d.removeFired(best, remaining)
+	}
+
+	// Whatever is left most often goes last, without conditions.
+	counts := make([]int, len(d.env.actions))
+	for i, sample := range d.samples {
+		if remaining[i] {
+			counts[sample.Action]++
+		}
+	}
+	if fallback := argmax(counts); counts[fallback] > 0 {
+		rule := d.score(Rule{Action: d.env.actions[fallback].Name, action: fallback}, remaining)
+		rules = append(rules, rule)
+		d.removeFired(rule, remaining)
+	}
+	return rules
+}
+
+func argmax(values []int) int {
+	best := 0
+	for i, value := range values {
+		if value > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+func (d *distiller) removeFired(rule Rule, remaining []bool) {
+	for i, sample := range d.samples {
+		if remaining[i] && rule.fires(sample) {
+			remaining[i] = false
+		}
+	}
+}
+
+// Counts the remaining samples the rule fires on.
+func (d *distiller) score(rule Rule, remaining []bool) Rule {
+	rule.Correct, rule.Wrong = 0, 0
+	for i, sample := range d.samples {
+		if remaining[i] && rule.fires(sample) {
+			if sample.Action == rule.action {
+				rule.Correct++
+			} else {
+				rule.Wrong++
+			}
+		}
+	}
+	return rule
+}
+
+// Adds the best condition to a rule for the action until none improves it.
+func (d *distiller) growRule(action int, remaining []bool) Rule {
+	rule := d.score(Rule{Action: d.env.actions[action].Name, action: action}, remaining)
+	for len(rule.Conditions) < d.config.MaxConditions {
+		condition, ok := d.bestCondition(rule, remaining)
+		if !ok {
+			break
+		}
+		grown := rule
+		grown.Conditions = append(slices.Clone(rule.Conditions), condition)
+		grown = d.score(grown, remaining)
+		if grown.Correct < d.config.MinSupport || grown.Correct-grown.Wrong <= rule.Correct-rule.Wrong {
+			break
+		}
+		rule = grown
+	}
+	return rule
+}
+
+// Finds the threshold on a single feature which best separates the casts of
+// the rule's action from the rest, among the samples the rule fires on.
+func (d *distiller) bestCondition(rule Rule, remaining []bool) (Condition, bool) {
+	var best Condition
+	bestScore, found := math.MinInt, false
+
+	for k, f := range d.features {
+		var covered []int
+		for _, i := range d.order[k] {
+			if remaining[i] && rule.fires(d.samples[i]) {
+				covered = append(covered, i)
+			}
+		}
+
+		correct, wrong := 0, 0
+		for n, i := range covered {
+			if d.samples[i].Action == rule.action {
+				correct++
+			} else {
+				wrong++
+			}
+			if n+1 == len(covered) {
+				break
+			}
+			value, next := d.samples[i].Observation[f], d.samples[covered[n+1]].Observation[f]
+			if next <= value {
+				continue
+			}
+
+			threshold := d.env.roundThreshold(f, (value+next)/2)
+			if correct >= d.config.MinSupport && correct-wrong > bestScore {
+				best = Condition{Feature: d.env.features[f].Name, Op: "<=", Threshold: threshold, index: f}
+				bestScore, found = correct-wrong, true
+			}
+			above, aboveWrong := rule.Correct-correct, rule.Wrong-wrong
+			if above >= d.config.MinSupport && above-aboveWrong > bestScore {
+				best = Condition{Feature: d.env.features[f].Name, Op: ">=", Threshold: threshold, index: f}
+				bestScore, found = above-aboveWrong, true
+			}
+		}
+	}
+	return best, found
+}
+
+// Rounds to what the APL const will be written as.
+func (env *Env) roundThreshold(f int, threshold float64) float64 {
+	switch env.features[f].Unit {
+	case "seconds":
+		return math.Round(threshold*1000) / 1000
+	case "fraction":
+		return math.Round(threshold*10000) / 10000
+	case "flag":
+		return 0.5
+	}
+	return math.Round(threshold*100) / 100
+}
+
+func (env *Env) conditionValue(c Condition) *proto.APLValue {
+	feature := env.features[c.index]
+	if feature.Unit == "flag" {
+		if c.Op == ">=" {
+			return feature.value
+		}
+		return &proto.APLValue{Value: &proto.APLValue_Not{Not: &proto.APLValueNot{Val: feature.value}}}
+	}
+
+	var constVal string
+	switch feature.Unit {
+	case "seconds":
+		constVal = strconv.FormatFloat(c.Threshold, 'f', 3, 64) + "s"
+	case "fraction":
+		constVal = strconv.FormatFloat(c.Threshold*100, 'f', 2, 64) + "%"
+	default:
+		constVal = strconv.FormatFloat(c.Threshold, 'f', 2, 64)
+	}
+	op := proto.APLValueCompare_OpGe
+	if c.Op == "<=" {
+		op = proto.APLValueCompare_OpLe
+	}
+	return &proto.APLValue{Value: &proto.APLValue_Cmp{Cmp: &proto.APLValueCompare{
+		Op:  op,
+		Lhs: feature.value,
+		Rhs: &proto.APLValue{Value: &proto.APLValue_Const{Const: &proto.APLValueConst{Val: constVal}}},
+	}}}
+}
+
+func (env *Env) rotation(rules []Rule) *proto.APLRotation {
+	rotation := &proto.APLRotation{Type: proto.APLRotation_TypeAPL}
+	for _, rule := range rules {
+		action := &proto.APLAction{
+			Action: &proto.APLAction_CastSpell{CastSpell: &proto.APLActionCastSpell{
+				SpellId: env.actions[rule.action].spell.ActionID.ToProto(),
+			}},
+		}
+
+		conditions := core.MapSlice(rule.Conditions, env.conditionValue)
+		if len(conditions) == 1 {
+			action.Condition = conditions[0]
+		} else if len(conditions) > 1 {
+			action.Condition = &proto.APLValue{Value: &proto.APLValue_And{And: &proto.APLValueAnd{Vals: conditions}}}
+		}
+
+		rotation.PriorityList = append(rotation.PriorityList, &proto.APLListItem{
+			Action: action,
+			Notes:  fmt.Sprintf("Distilled: matched %d of %d traced decisions", rule.Correct, rule.Correct+rule.Wrong),
+		})
+	}
+	return rotation
+}
+
+// Sims the rotation on the controlled player and returns its reward per second.
+func (env *Env) evaluate(rotation *proto.APLRotation, iterations int32) (float64, error) {
+	request := googleProto.Clone(env.config.Request).(*proto.RaidSimRequest)
+	request.SimOptions.Interactive = false
+	request.SimOptions.Iterations = iterations
+	request.Raid.Parties[0].Players[0].Rotation = rotation
+
+	result := core.RunRaidSim(request)
+	if result.Error != nil {
+		return 0, errors.New(result.Error.Message)
+	}
+
+	perSecond := func(metrics *proto.UnitMetrics) float64 {
+		switch env.config.Reward {
+		case RewardThreat:
+			return metrics.Threat.GetAvg()
+		case RewardHealing:
+			return metrics.Hps.GetAvg()
+		}
+		return metrics.Dps.GetAvg()
+	}
+	player := result.RaidMetrics.Parties[0].Players[0]
+	total := perSecond(player)
+	for _, pet := range player.Pets {
+		total += perSecond(pet)
+	}
+	return total, nil
+}
@@ -0,0 +1,43 @@
+package rl
+
+import (
+	"testing"
+)
+
+func TestDistillFirstCastablePolicy(t *testing.T) {
+	env, err := NewEnv(testConfig())
+	if err != nil {
+		t.Fatalf("Failed to create env: %s", err)
+	}
+
+	env.StartTrace()
+	for seed := int64(1); seed <= 3; seed++ {
+		runEpisode(t, env, seed)
+	}
+	trace := env.StopTrace()
+	if len(trace.Episodes) != 3 || len(trace.Samples) == 0 {
+		t.Fatalf("Expected 3 traced episodes with samples, got %d episodes and %d samples", len(trace.Episodes), len(trace.Samples))
+	}
+
+	result, err := env.Distill(trace, DistillConfig{EvalIterations: 20})
+	if err != nil {
+		t.Fatalf("Failed to distill: %s", err)
+	}
+
+	// Casting the first castable spell is a priority list, so it should be learned almost exactly.
+	if result.Agreement < 0.9 {
+		t.Fatalf("Expected the rotation to agree with at least 90%% of the trace, got %f with rules %v", result.Agreement, result.Rules)
+	}
+	if len(result.Rotation.PriorityList) != len(result.Rules) || len(result.Rules) == 0 {
+		t.Fatalf("Expected one APL item per rule, got %d items for %d rules", len(result.Rotation.PriorityList), len(result.Rules))
+	}
+	if result.TracedPerSecond <= 0 || result.DistilledPerSecond <= 0 {
+		t.Fatalf("Expected positive DPS, got %f traced and %f distilled", result.TracedPerSecond, result.DistilledPerSecond)
+	}
+
+	mismatched := *trace
+	mismatched.Schema.Observations = mismatched.Schema.Observations[1:]
+	if _, err := env.Distill(&mismatched, DistillConfig{}); err == nil {
+		t.Fatalf("Expected an error for a trace from a different schema")
+	}
+}
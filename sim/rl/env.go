@@ -0,0 +1,475 @@
+// Package rl wraps the interactive sim mode in a reinforcement learning
+// environment: a fixed observation schema and action space for the first
+// player of a raid, a configurable reward, and deterministic resets.
+package rl
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+type Reward string
+
+const (
+	RewardDamage  Reward = "damage"
+	RewardThreat  Reward = "threat"
+	RewardHealing Reward = "healing"
+)
+
+// Default length of the wait action.
+const defaultWaitSeconds = 0.1
+
+type Config struct {
+	// Sim to run, the first player is controlled. Iterations are ignored.
+	Request *proto.RaidSimRequest `json:"-"`
+
+	// What the reward is a delta of, defaults to damage.
+	Reward Reward `json:"reward"`
+	// Reward multiplier, defaults to 1.
+	RewardScale float64 `json:"reward_scale"`
+	// Subtracted from the reward of actions which can't be cast right now.
+	InvalidActionPenalty float64 `json:"invalid_action_penalty"`
+
+	// How long the wait action waits for, defaults to 100ms.
+	WaitSeconds float64 `json:"wait_seconds"`
+	// If set, control returns at least this often even while the GCD is
+	// running, so off-GCD abilities can be used between GCDs. Otherwise control
+	// only returns once the GCD is ready.
+	DecisionIntervalSeconds float64 `json:"decision_interval_seconds"`
+
+	// Labels of player and target auras to observe. Default to all auras which
+	// track metrics and have a duration.
+	Auras       []string `json:"auras"`
+	TargetAuras []string `json:"target_auras"`
+}
+
+// A value in the observation vector.
+type Feature struct {
+	Name string `json:"name"`
+	// seconds, fraction, flag, stacks or the resource name.
+	Unit string `json:"unit"`
+}
+
+// An action in the action space. Action 0 is always waiting.
+type Action struct {
+	Name    string `json:"name"`
+	SpellID int32  `json:"spell_id,omitempty"`
+	ItemID  int32  `json:"item_id,omitempty"`
+	Tag     int32  `json:"tag,omitempty"`
+	// Off-GCD actions return control right away, so another action can follow.
+	OffGCD bool `json:"off_gcd"`
+
+	spell *core.Spell
+}
+
+type Schema struct {
+	Observations []Feature `json:"observations"`
+	Actions      []Action  `json:"actions"`
+	Reward       Reward    `json:"reward"`
+}
+
+type feature struct {
+	Feature
+	get func() float64
+	// The same value as an APL sees it, nil if there is no equivalent.
+	value *proto.APLValue
+}
+
+type Env struct {
+	config Config
+	sim    *core.Simulation
+	player *core.Character
+	target *core.Unit
+
+	features []feature
+	actions  []Action
+
+	seed            int64
+	lastTotal       float64
+	done            bool
+	lastActionValid bool
+
+	// The sim replaces the estimated duration of health based encounters
+	// with the length of the first iteration, so each episode restores them.
+	baseDuration       time.Duration
+	durationIsEstimate bool
+
+	trace *Trace
+}
+
+func NewEnv(config Config) (*Env, error) {
+	if config.Request == nil || config.Request.Raid == nil || len(config.Request.Raid.Parties) == 0 || len(config.Request.Raid.Parties[0].Players) == 0 {
+		return nil, errors.New("request has no player")
+	}
+	switch config.Reward {
+	case "":
+		config.Reward = RewardDamage
+	case RewardDamage, RewardThreat, RewardHealing:
+	default:
+		return nil, fmt.Errorf("unknown reward %q", config.Reward)
+	}
+	if config.RewardScale == 0 {
+		config.RewardScale = 1
+	}
+	if config.WaitSeconds <= 0 {
+		config.WaitSeconds = defaultWaitSeconds
+	}
+
+	request := googleProto.Clone(config.Request).(*proto.RaidSimRequest)
+	if request.SimOptions == nil {
+		request.SimOptions = &proto.SimOptions{}
+	}
+	request.SimOptions.Iterations = 1
+	request.SimOptions.Interactive = true
+	config.Request = request
+
+	env := &Env{
+		config: config,
+		sim:    core.NewSim(request, simsignals.CreateSignals()),
+	}
+	if len(env.sim.Raid.Parties) == 0 || len(env.sim.Raid.Parties[0].Players) == 0 {
+		return nil, errors.New("request has no player")
+	}
+	env.player = env.sim.Raid.Parties[0].Players[0].GetCharacter()
+	env.target = env.player.CurrentTarget
+	env.baseDuration = env.sim.BaseDuration
+	env.durationIsEstimate = env.sim.Encounter.DurationIsEstimate
+
+	env.buildActions()
+	if err := env.buildFeatures(); err != nil {
+		return nil, err
+	}
+	env.done = true
+	return env, nil
+}
+
+func actionName(id core.ActionID) string {
+	var name string
+	switch {
+	case id.SpellID != 0:
+		name = fmt.Sprintf("spell:%d", id.SpellID)
+	case id.ItemID != 0:
+		name = fmt.Sprintf("item:%d", id.ItemID)
+	default:
+		name = fmt.Sprintf("other:%d", id.OtherID)
+	}
+	if id.Tag != 0 {
+		name += fmt.Sprintf("/%d", id.Tag)
+	}
+	return name
+}
+
+// Every spell an APL could cast is an action, in spellbook order.
+func (env *Env) buildActions() {
+	env.actions = []Action{{Name: "wait", OffGCD: false}}
+	seen := map[core.ActionID]bool{}
+	for _, spell := range env.player.Spellbook {
+		if !spell.Flags.Matches(core.SpellFlagAPL) || seen[spell.ActionID] {
+			continue
+		}
+		seen[spell.ActionID] = true
+		env.actions = append(env.actions, Action{
+			Name:    actionName(spell.ActionID),
+			SpellID: spell.ActionID.SpellID,
+			ItemID:  spell.ActionID.ItemID,
+			Tag:     spell.ActionID.Tag,
+			OffGCD:  spell.DefaultCast.GCD == 0,
+			spell:   spell,
+		})
+	}
+}
+
+func (env *Env) addFeature(name string, unit string, value *proto.APLValue, get func() float64) {
+	env.features = append(env.features, feature{Feature: Feature{Name: name, Unit: unit}, get: get, value: value})
+}
+
+// Seconds until the given time, 0 if it has passed or never comes.
+func (env *Env) secondsUntil(at time.Duration) float64 {
+	if at >= core.NeverExpires || at <= env.sim.CurrentTime {
+		return 0
+	}
+	return (at - env.sim.CurrentTime).Seconds()
+}
+
+func (env *Env) addAuraFeatures(prefix string, unit *core.Unit, ref proto.UnitReference_Type, labels []string) error {
+	var auras []*core.Aura
+	if labels == nil {
+		for _, aura := range unit.GetAuras() {
+			if aura.ActionID != (core.ActionID{}) && aura.Duration > 0 && aura.Duration != core.NeverExpires {
+				auras = append(auras, aura)
+			}
+		}
+	} else {
+		for _, label := range labels {
+			aura := unit.GetAura(label)
+			if aura == nil {
+				return fmt.Errorf("%s has no aura %q", unit.Label, label)
+			}
+			auras = append(auras, aura)
+		}
+	}
+
+	for _, aura := range auras {
+		aura := aura
+		name := prefix + ".aura." + aura.Label
+		source := &proto.UnitReference{Type: ref}
+		if aura.Duration == core.NeverExpires {
+			env.addFeature(name+".active", "flag",
+				&proto.APLValue{Value: &proto.APLValue_AuraIsActive{AuraIsActive: &proto.APLValueAuraIsActive{SourceUnit: source, AuraId: aura.ActionID.ToProto()}}},
+				func() float64 {
+					return core.TernaryFloat64(aura.IsActive(), 1, 0)
+				})
+		} else {
+			env.addFeature(name+".remaining", "seconds",
+				&proto.APLValue{Value: &proto.APLValue_AuraRemainingTime{AuraRemainingTime: &proto.APLValueAuraRemainingTime{SourceUnit: source, AuraId: aura.ActionID.ToProto()}}},
+				func() float64 {
+					if !aura.IsActive() {
+						return 0
+					}
+					return aura.RemainingDuration(env.sim).Seconds()
+				})
+		}
+		if aura.MaxStacks > 0 {
+			env.addFeature(name+".stacks", "stacks",
+				&proto.APLValue{Value: &proto.APLValue_AuraNumStacks{AuraNumStacks: &proto.APLValueAuraNumStacks{SourceUnit: source, AuraId: aura.ActionID.ToProto()}}},
+				func() float64 {
+					return float64(aura.GetStacks())
+				})
+		}
+	}
+	return nil
+}
+
+func (env *Env) buildFeatures() error {
+	sim, player := env.sim, env.player
+	self := &proto.UnitReference{Type: proto.UnitReference_Self}
+
+	env.addFeature("encounter.elapsed", "seconds",
+		&proto.APLValue{Value: &proto.APLValue_CurrentTime{CurrentTime: &proto.APLValueCurrentTime{}}},
+		func() float64 { return max(0, sim.CurrentTime.Seconds()) })
+	env.addFeature("encounter.remaining", "seconds",
+		&proto.APLValue{Value: &proto.APLValue_RemainingTime{RemainingTime: &proto.APLValueRemainingTime{}}},
+		func() float64 { return sim.GetRemainingDuration().Seconds() })
+	env.addFeature("encounter.remaining_percent", "fraction",
+		&proto.APLValue{Value: &proto.APLValue_RemainingTimePercent{RemainingTimePercent: &proto.APLValueRemainingTimePercent{}}},
+		sim.GetRemainingDurationPercent)
+
+	env.addFeature("player.health_percent", "fraction",
+		&proto.APLValue{Value: &proto.APLValue_CurrentHealthPercent{CurrentHealthPercent: &proto.APLValueCurrentHealthPercent{SourceUnit: self}}},
+		player.CurrentHealthPercent)
+	if player.HasManaBar() {
+		env.addFeature("player.mana", "mana",
+			&proto.APLValue{Value: &proto.APLValue_CurrentMana{CurrentMana: &proto.APLValueCurrentMana{SourceUnit: self}}},
+			player.CurrentMana)
+		env.addFeature("player.mana_percent", "fraction",
+			&proto.APLValue{Value: &proto.APLValue_CurrentManaPercent{CurrentManaPercent: &proto.APLValueCurrentManaPercent{SourceUnit: self}}},
+			player.CurrentManaPercent)
+	}
+	if player.HasRageBar() {
+		env.addFeature("player.rage", "rage",
+			&proto.APLValue{Value: &proto.APLValue_CurrentRage{CurrentRage: &proto.APLValueCurrentRage{}}},
+			player.CurrentRage)
+	}
+	if player.HasEnergyBar() {
+		env.addFeature("player.energy", "energy",
+			&proto.APLValue{Value: &proto.APLValue_CurrentEnergy{CurrentEnergy: &proto.APLValueCurrentEnergy{}}},
+			player.CurrentEnergy)
+		env.addFeature("player.combo_points", "combo_points",
+			&proto.APLValue{Value: &proto.APLValue_CurrentComboPoints{CurrentComboPoints: &proto.APLValueCurrentComboPoints{}}},
+			func() float64 { return float64(player.ComboPoints()) })
+	}
+
+	env.addFeature("player.gcd_remaining", "seconds",
+		&proto.APLValue{Value: &proto.APLValue_GcdTimeToReady{GcdTimeToReady: &proto.APLValueGCDTimeToReady{}}},
+		func() float64 { return player.GCD.TimeToReady(sim).Seconds() })
+	env.addFeature("player.cast_remaining", "seconds", nil, func() float64 { return env.secondsUntil(player.Hardcast.Expires) })
+	autoTimeToNext := func(autoType proto.APLValueAutoTimeToNext_AttackType) *proto.APLValue {
+		return &proto.APLValue{Value: &proto.APLValue_AutoTimeToNext{AutoTimeToNext: &proto.APLValueAutoTimeToNext{AutoType: autoType}}}
+	}
+	if player.AutoAttacks.MH().SwingSpeed > 0 {
+		env.addFeature("player.mainhand_swing", "seconds", autoTimeToNext(proto.APLValueAutoTimeToNext_MainHand),
+			func() float64 { return env.secondsUntil(player.AutoAttacks.MainhandSwingAt()) })
+	}
+	if player.AutoAttacks.OH().SwingSpeed > 0 {
+		env.addFeature("player.offhand_swing", "seconds", autoTimeToNext(proto.APLValueAutoTimeToNext_OffHand),
+			func() float64 { return env.secondsUntil(player.AutoAttacks.OffhandSwingAt()) })
+	}
+	if player.AutoAttacks.Ranged().SwingSpeed > 0 {
+		env.addFeature("player.ranged_swing", "seconds", autoTimeToNext(proto.APLValueAutoTimeToNext_Ranged),
+			func() float64 { return env.secondsUntil(player.AutoAttacks.NextRangedAttackAt()) })
+	}
+
+	for _, action := range env.actions[1:] {
+		spell := action.spell
+		env.addFeature("action."+action.Name+".cooldown", "seconds",
+			&proto.APLValue{Value: &proto.APLValue_SpellTimeToReady{SpellTimeToReady: &proto.APLValueSpellTimeToReady{SpellId: spell.ActionID.ToProto()}}},
+			func() float64 { return spell.TimeToReady(sim).Seconds() })
+	}
+
+	if err := env.addAuraFeatures("player", &player.Unit, proto.UnitReference_Self, env.config.Auras); err != nil {
+		return err
+	}
+	return env.addAuraFeatures("target", env.target, proto.UnitReference_CurrentTarget, env.config.TargetAuras)
+}
+
+func (env *Env) Schema() Schema {
+	schema := Schema{Actions: env.actions, Reward: env.config.Reward}
+	for _, f := range env.features {
+		schema.Observations = append(schema.Observations, f.Feature)
+	}
+	return schema
+}
+
+func (env *Env) NumObservations() int {
+	return len(env.features)
+}
+
+func (env *Env) NumActions() int {
+	return len(env.actions)
+}
+
+// Writes the current observation into obs, which needs NumObservations() values.
+func (env *Env) Observe(obs []float64) {
+	for i, f := range env.features {
+		obs[i] = f.get()
+	}
+}
+
+// Whether each action can be taken right now. Waiting always can.
+func (env *Env) ActionMask(mask []bool) {
+	mask[0] = true
+	for i, action := range env.actions[1:] {
+		mask[i+1] = !env.done && action.spell.CanCast(env.sim, env.target)
+	}
+}
+
+func (env *Env) total() float64 {
+	damage, threat, healing := env.player.CurrentIterationTotals()
+	switch env.config.Reward {
+	case RewardThreat:
+		return threat
+	case RewardHealing:
+		return healing
+	}
+	return damage
+}
+
+// Starts a new episode. The same seed and actions always play out the same way.
+func (env *Env) Reset(seed int64) {
+	env.seed = seed
+	env.sim.Options.RandomSeed = seed
+	env.sim.Reseed(0)
+	env.sim.BaseDuration = env.baseDuration
+	env.sim.Encounter.DurationIsEstimate = env.durationIsEstimate
+	env.sim.Reset()
+	env.sim.PrePull()
+	env.done = false
+	env.lastActionValid = true
+
+	env.advance(0)
+	env.lastTotal = env.total()
+}
+
+// Takes an action and runs the sim until the player can act again. Returns
+// the reward and whether the episode is over. Actions which can't be taken
+// right now are treated as waiting.
+func (env *Env) Step(action int) (float64, bool) {
+	if env.done {
+		return 0, true
+	}
+	if action < 0 || action >= len(env.actions) {
+		action = -1
+	}
+	if env.trace != nil {
+		env.trace.addSample(env, action)
+	}
+
+	env.lastActionValid = true
+	switch {
+	case action == 0:
+		env.advance(env.waitDuration())
+	case action < 0 || !env.actions[action].spell.CanCast(env.sim, env.target):
+		env.lastActionValid = false
+		env.advance(env.waitDuration())
+	default:
+		spell := env.actions[action].spell
+		if !spell.Cast(env.sim, env.target) {
+			env.lastActionValid = false
+			env.advance(env.waitDuration())
+		} else if spell.CurCast.GCD > 0 || env.player.IsCasting(env.sim) {
+			env.advance(env.decisionInterval())
+		}
+		// Off-GCD casts leave time where it is, so the next action can follow right away.
+	}
+
+	total := env.total()
+	reward := (total - env.lastTotal) * env.config.RewardScale
+	env.lastTotal = total
+	if !env.lastActionValid {
+		reward -= env.config.InvalidActionPenalty
+	}
+	if env.done && env.trace != nil {
+		env.trace.addEpisode(env)
+	}
+	return reward, env.done
+}
+
+func (env *Env) waitDuration() time.Duration {
+	return core.DurationFromSeconds(env.config.WaitSeconds)
+}
+
+func (env *Env) decisionInterval() time.Duration {
+	return core.DurationFromSeconds(env.config.DecisionIntervalSeconds)
+}
+
+// Runs the sim until the player needs input, or until wait has passed if set.
+func (env *Env) advance(wait time.Duration) {
+	sim := env.sim
+	sim.NeedsInput = false
+
+	woke := false
+	if wait > 0 {
+		wake := &core.PendingAction{
+			NextActionAt: sim.CurrentTime + wait,
+			Priority:     core.ActionPriorityLow,
+			OnAction: func(_ *core.Simulation) {
+				woke = true
+			},
+		}
+		sim.AddPendingAction(wake)
+		defer wake.Cancel(sim)
+	}
+
+	for !woke && !sim.NeedsInput {
+		if sim.Step() {
+			env.done = true
+			sim.Cleanup()
+			return
+		}
+	}
+
+	// The GCD action only asks for input once, so it has to be asked again
+	// after waiting through it.
+	if !sim.NeedsInput && env.player.GCD.IsReady(sim) && !env.player.IsCasting(sim) {
+		sim.NeedsInput = true
+	}
+}
+
+func (env *Env) Done() bool {
+	return env.done
+}
+
+// Whether the last action could be taken.
+func (env *Env) LastActionValid() bool {
+	return env.lastActionValid
+}
+
+func (env *Env) CurrentTime() time.Duration {
+	return env.sim.CurrentTime
+}
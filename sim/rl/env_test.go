@@ -0,0 +1,168 @@
+package rl
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/wowsims/sod/sim"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+)
+
+func init() {
+	sim.RegisterAll()
+}
+
+func testConfig() Config {
+	return Config{
+		Request: &proto.RaidSimRequest{
+			Raid: core.SinglePlayerRaidProto(
+				&proto.Player{
+					Race:  proto.Race_RaceTroll,
+					Class: proto.Class_ClassShaman,
+					Level: 60,
+					Spec:  &proto.Player_ElementalShaman{ElementalShaman: &proto.ElementalShaman{}},
+				},
+				&proto.PartyBuffs{},
+				&proto.RaidBuffs{},
+				&proto.Debuffs{}),
+			Encounter: &proto.Encounter{
+				Duration: 30,
+				Targets:  []*proto.Target{{}},
+			},
+		},
+	}
+}
+
+// Casts the first castable spell, or waits. Returns the total reward of an episode.
+func runEpisode(t *testing.T, env *Env, seed int64) (float64, int) {
+	obs := make([]float64, env.NumObservations())
+	mask := make([]bool, env.NumActions())
+
+	env.Reset(seed)
+	total, steps := 0.0, 0
+	for !env.Done() {
+		env.Observe(obs)
+		env.ActionMask(mask)
+
+		action := 0
+		for i := 1; i < len(mask); i++ {
+			if mask[i] && !env.actions[i].OffGCD {
+				action = i
+				break
+			}
+		}
+
+		reward, _ := env.Step(action)
+		if !env.LastActionValid() {
+			t.Fatalf("Action %s was masked as valid but couldn't be taken", env.actions[action].Name)
+		}
+		total += reward
+		steps++
+		if steps > 10000 {
+			t.Fatalf("Episode didn't finish")
+		}
+	}
+	return total, steps
+}
+
+func TestEnvSchema(t *testing.T) {
+	env, err := NewEnv(testConfig())
+	if err != nil {
+		t.Fatalf("Failed to create env: %s", err)
+	}
+
+	schema := env.Schema()
+	if len(schema.Observations) != env.NumObservations() || len(schema.Actions) != env.NumActions() {
+		t.Fatalf("Schema doesn't match the env sizes")
+	}
+	if schema.Actions[0].Name != "wait" || len(schema.Actions) < 2 {
+		t.Fatalf("Expected wait followed by spells, got %v", schema.Actions)
+	}
+
+	config := testConfig()
+	config.Auras = []string{"Not An Aura"}
+	if _, err := NewEnv(config); err == nil {
+		t.Fatalf("Expected an error for an unknown aura")
+	}
+}
+
+func TestEnvDeterministicReset(t *testing.T) {
+	env, err := NewEnv(testConfig())
+	if err != nil {
+		t.Fatalf("Failed to create env: %s", err)
+	}
+	other, err := NewEnv(testConfig())
+	if err != nil {
+		t.Fatalf("Failed to create env: %s", err)
+	}
+
+	first, steps := runEpisode(t, env, 7)
+	if first <= 0 {
+		t.Fatalf("Expected damage as reward, got %f", first)
+	}
+
+	// Same seed on the same env and on a second handle.
+	if again, againSteps := runEpisode(t, env, 7); again != first || againSteps != steps {
+		t.Fatalf("Reset with the same seed gave %f in %d steps, first run gave %f in %d", again, againSteps, first, steps)
+	}
+	if otherReward, _ := runEpisode(t, other, 7); otherReward != first {
+		t.Fatalf("Second env gave %f, first gave %f", otherReward, first)
+	}
+}
+
+func TestEnvDeterministicResetWithHealth(t *testing.T) {
+	config := testConfig()
+	config.Request.Encounter.UseHealth = true
+	config.Request.Encounter.Targets[0].Stats = stats.Stats{stats.Health: 5000}.ToFloatArray()
+	env, err := NewEnv(config)
+	if err != nil {
+		t.Fatalf("Failed to create env: %s", err)
+	}
+
+	// The first episode mustn't change the estimated duration of the next ones.
+	env.Reset(7)
+	firstObs := make([]float64, env.NumObservations())
+	env.Observe(firstObs)
+
+	first, steps := runEpisode(t, env, 7)
+	if again, againSteps := runEpisode(t, env, 7); again != first || againSteps != steps {
+		t.Fatalf("Reset with the same seed gave %f in %d steps, first run gave %f in %d", again, againSteps, first, steps)
+	}
+
+	env.Reset(7)
+	againObs := make([]float64, env.NumObservations())
+	env.Observe(againObs)
+	if !slices.Equal(firstObs, againObs) {
+		t.Fatalf("Expected the same observations after each reset, got %v and %v", firstObs, againObs)
+	}
+}
+
+func TestEnvWaitAdvancesTime(t *testing.T) {
+	config := testConfig()
+	config.WaitSeconds = 0.5
+	env, err := NewEnv(config)
+	if err != nil {
+		t.Fatalf("Failed to create env: %s", err)
+	}
+
+	env.Reset(1)
+	start := env.CurrentTime()
+	env.Step(0)
+	if waited := env.CurrentTime() - start; waited.Seconds() != 0.5 {
+		t.Fatalf("Waited %s, expected 500ms", waited)
+	}
+
+	// Invalid actions wait too, with the penalty on top.
+	config.InvalidActionPenalty = 5
+	waiting, _ := NewEnv(config)
+	invalid, _ := NewEnv(config)
+	waiting.Reset(1)
+	invalid.Reset(1)
+	waitReward, _ := waiting.Step(0)
+	invalidReward, _ := invalid.Step(invalid.NumActions())
+	if invalid.LastActionValid() || invalidReward != waitReward-5 {
+		t.Fatalf("Expected an invalid action with %f reward, got %f", waitReward-5, invalidReward)
+	}
+}
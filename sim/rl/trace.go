@@ -0,0 +1,68 @@
+package rl
+
+// A decision trace: what the player saw and chose at every step while recording.
+type Trace struct {
+	Schema   Schema    `json:"schema"`
+	Samples  []Sample  `json:"samples"`
+	Episodes []Episode `json:"episodes"`
+}
+
+type Sample struct {
+	Observation []float64 `json:"observation"`
+	Mask        []bool    `json:"mask"`
+	// -1 if the chosen action was out of range.
+	Action int `json:"action"`
+}
+
+// An episode which ended while recording.
+type Episode struct {
+	Seed int64 `json:"seed"`
+	// Unscaled reward total of the whole episode, and its length.
+	Total   float64 `json:"total"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Starts recording a new trace of every following step.
+func (env *Env) StartTrace() {
+	env.trace = &Trace{Schema: env.Schema()}
+}
+
+// Stops recording and returns the trace, nil if none was started.
+func (env *Env) StopTrace() *Trace {
+	trace := env.trace
+	env.trace = nil
+	return trace
+}
+
+func (trace *Trace) addSample(env *Env, action int) {
+	sample := Sample{
+		Observation: make([]float64, env.NumObservations()),
+		Mask:        make([]bool, env.NumActions()),
+		Action:      action,
+	}
+	env.Observe(sample.Observation)
+	env.ActionMask(sample.Mask)
+	trace.Samples = append(trace.Samples, sample)
+}
+
+func (trace *Trace) addEpisode(env *Env) {
+	trace.Episodes = append(trace.Episodes, Episode{
+		Seed:    env.seed,
+		Total:   env.total(),
+		Seconds: env.sim.CurrentTime.Seconds(),
+	})
+}
+
+// Mean reward per second over the recorded episodes.
+func (trace *Trace) meanPerSecond() float64 {
+	if len(trace.Episodes) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, episode := range trace.Episodes {
+		if episode.Seconds > 0 {
+			sum += episode.Total / episode.Seconds
+		}
+	}
+	return sum / float64(len(trace.Episodes))
+}
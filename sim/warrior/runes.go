@@ -9,6 +9,14 @@ import (
 	"github.com/wowsims/sod/sim/core/stats"
 )
 
+func init() {
+	core.RegisterRuneTalentRequirement(int32(proto.WarriorRune_RuneSwordAndBoard), func(talentsString string) bool {
+		talents := &proto.WarriorTalents{}
+		core.FillTalentsProto(talents.ProtoReflect(), talentsString, TalentTreeSizes)
+		return talents.ShieldSlam
+	})
+}
+
 func (warrior *Warrior) ApplyRunes() {
 	// Head
 	warrior.applyVigilance()
@@ -0,0 +1,37 @@
+package warrior
+
+import (
+	"time"
+
+	"github.com/wowsims/sod/sim/core"
+)
+
+func (warrior *Warrior) registerTauntSpell() {
+	warrior.Taunt = warrior.RegisterSpell(DefensiveStance, core.SpellConfig{
+		ActionID:    core.ActionID{SpellID: 355},
+		SpellSchool: core.SpellSchoolPhysical,
+		DefenseType: core.DefenseTypeMagic,
+		ProcMask:    core.ProcMaskEmpty,
+		Flags:       core.SpellFlagAPL,
+
+		Cast: core.CastConfig{
+			IgnoreHaste: true,
+			CD: core.Cooldown{
+				Timer:    warrior.NewTimer(),
+				Duration: time.Second * 10,
+			},
+		},
+
+		// Taunt only does anything when the target keeps a threat table.
+		ExtraCastCondition: func(sim *core.Simulation, target *core.Unit) bool {
+			return target.ThreatTable != nil && target.ThreatTable.Holder() != &warrior.Unit
+		},
+
+		ApplyEffects: func(sim *core.Simulation, target *core.Unit, spell *core.Spell) {
+			result := spell.CalcOutcome(sim, target, spell.OutcomeMagicHit)
+			if result.Landed() {
+				target.ThreatTable.Taunt(sim, &warrior.Unit, time.Second*3)
+			}
+		},
+	})
+}
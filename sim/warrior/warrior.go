@@ -114,6 +114,7 @@ type Warrior struct {
 	SlamMH            *WarriorSpell
 	SlamOH            *WarriorSpell
 	SunderArmor       *WarriorSpell
+	Taunt             *WarriorSpell
 	Devastate         *WarriorSpell
 	ThunderClap       *WarriorSpell
 	Whirlwind         *WarriorSpell
@@ -251,6 +252,7 @@ func (warrior *Warrior) Initialize() {
 	warrior.registerWhirlwindSpell()
 	warrior.registerRendSpell()
 	warrior.registerHamstringSpell()
+	warrior.registerTauntSpell()
 
 	// The sim often re-enables heroic strike in an unrealistic amount of time.
 	// This can cause an unrealistic immediate double-hit around wild strikes procs
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wowsims/sod/sim/core"
+	proto "github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+const (
+	// How long /worker/task waits for a task before responding with nothing.
+	workerPollTimeout = 20 * time.Second
+	// Workers which haven't been heard from for this long are dropped, and
+	// their tasks handed to someone else.
+	workerTimeout = time.Minute
+)
+
+var errUnknownWorker = errors.New("unknown worker, register again")
+
+type coordinatorWorker struct {
+	id        string
+	name      string
+	threads   int32
+	lastSeen  time.Time
+	tasksDone int
+}
+
+// A single raid sim run by a worker.
+type workerTask struct {
+	id        string
+	request   *proto.RaidSimRequest
+	result    chan *proto.RaidSimResult
+	workerId  string // Empty while pending.
+	cancelled bool
+}
+
+// Hands out sims to workers, which poll for them over HTTP. Raid sims are split
+// by seed over all worker threads and merged with the usual combiner, while
+// bulk sims hand out one combination per task.
+type coordinator struct {
+	mut      sync.Mutex
+	workers  map[string]*coordinatorWorker
+	pending  []*workerTask
+	assigned map[string]*workerTask
+
+	// Closed and replaced whenever tasks are queued, to wake up polling workers.
+	notify chan struct{}
+}
+
+func newCoordinator() *coordinator {
+	c := &coordinator{
+		workers:  map[string]*coordinatorWorker{},
+		assigned: map[string]*workerTask{},
+		notify:   make(chan struct{}),
+	}
+	go func() {
+		for range time.Tick(workerTimeout / 4) {
+			c.dropStaleWorkers()
+		}
+	}()
+	return c
+}
+
+func (c *coordinator) register(msg *proto.WorkerRegisterRequest) *proto.WorkerRegisterResponse {
+	worker := &coordinatorWorker{
+		id:       uuid.NewString(),
+		name:     msg.Name,
+		threads:  max(msg.Threads, 1),
+		lastSeen: time.Now(),
+	}
+
+	c.mut.Lock()
+	c.workers[worker.id] = worker
+	c.mut.Unlock()
+
+	log.Printf("Worker %s (%s) registered with %d threads.", worker.name, worker.id, worker.threads)
+	return &proto.WorkerRegisterResponse{WorkerId: worker.id}
+}
+
+// Must hold c.mut.
+func (c *coordinator) touch(workerId string) (*coordinatorWorker, error) {
+	worker, ok := c.workers[workerId]
+	if !ok {
+		return nil, errUnknownWorker
+	}
+	worker.lastSeen = time.Now()
+	return worker, nil
+}
+
+func (c *coordinator) heartbeat(msg *proto.WorkerTaskRequest) (*proto.WorkerRegisterResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if _, err := c.touch(msg.WorkerId); err != nil {
+		return nil, err
+	}
+	return &proto.WorkerRegisterResponse{WorkerId: msg.WorkerId}, nil
+}
+
+// Waits for the next pending task, or returns an empty task after workerPollTimeout.
+func (c *coordinator) nextTask(msg *proto.WorkerTaskRequest) (*proto.WorkerTask, error) {
+	deadline := time.After(workerPollTimeout)
+	for {
+		c.mut.Lock()
+		if _, err := c.touch(msg.WorkerId); err != nil {
+			c.mut.Unlock()
+			return nil, err
+		}
+		if task := c.popTask(msg.WorkerId); task != nil {
+			c.mut.Unlock()
+			return task, nil
+		}
+		notify := c.notify
+		c.mut.Unlock()
+
+		select {
+		case <-notify:
+		case <-deadline:
+			return &proto.WorkerTask{}, nil
+		}
+	}
+}
+
+// Must hold c.mut.
+func (c *coordinator) popTask(workerId string) *proto.WorkerTask {
+	for len(c.pending) > 0 {
+		task := c.pending[0]
+		c.pending = c.pending[1:]
+		if task.cancelled {
+			continue
+		}
+
+		task.workerId = workerId
+		c.assigned[task.id] = task
+		return &proto.WorkerTask{TaskId: task.id, Request: task.request}
+	}
+	return nil
+}
+
+// Stores the result of a task, and hands out the next one if there is any.
+func (c *coordinator) taskDone(msg *proto.WorkerTaskResult) (*proto.WorkerTask, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	worker, err := c.touch(msg.WorkerId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tasks from dropped workers may have been finished by someone else already.
+	if task, ok := c.assigned[msg.TaskId]; ok && task.workerId == msg.WorkerId {
+		delete(c.assigned, msg.TaskId)
+		worker.tasksDone++
+		if msg.Result == nil {
+			msg.Result = &proto.RaidSimResult{Error: &proto.ErrorOutcome{Message: "worker returned no result"}}
+		}
+		task.result <- msg.Result
+	}
+
+	if task := c.popTask(msg.WorkerId); task != nil {
+		return task, nil
+	}
+	return &proto.WorkerTask{}, nil
+}
+
+func (c *coordinator) dropStaleWorkers() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for id, worker := range c.workers {
+		if time.Since(worker.lastSeen) < workerTimeout {
+			continue
+		}
+		log.Printf("Worker %s (%s) timed out.", worker.name, id)
+		delete(c.workers, id)
+
+		var requeued []*workerTask
+		for taskId, task := range c.assigned {
+			if task.workerId == id {
+				delete(c.assigned, taskId)
+				task.workerId = ""
+				requeued = append(requeued, task)
+			}
+		}
+		c.queue(requeued, true)
+	}
+}
+
+// Must hold c.mut.
+func (c *coordinator) queue(tasks []*workerTask, front bool) {
+	if len(tasks) == 0 {
+		return
+	}
+	if front {
+		c.pending = append(tasks, c.pending...)
+	} else {
+		c.pending = append(c.pending, tasks...)
+	}
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+func (c *coordinator) totalThreads() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	total := 0
+	for _, worker := range c.workers {
+		total += int(worker.threads)
+	}
+	return total
+}
+
+// Runs the requests on workers and waits for all of them. Returns nil if
+// aborted, and leaves any tasks still running on workers to finish unused.
+func (c *coordinator) runTasks(requests []*proto.RaidSimRequest, signals simsignals.Signals, onResult func(*proto.RaidSimResult)) []*proto.RaidSimResult {
+	tasks := make([]*workerTask, len(requests))
+	for i, request := range requests {
+		tasks[i] = &workerTask{
+			id:      uuid.NewString(),
+			request: request,
+			result:  make(chan *proto.RaidSimResult, 1),
+		}
+	}
+
+	c.mut.Lock()
+	c.queue(tasks, false)
+	c.mut.Unlock()
+
+	results := make([]*proto.RaidSimResult, len(tasks))
+	abortCheck := time.NewTicker(100 * time.Millisecond)
+	defer abortCheck.Stop()
+	for i, task := range tasks {
+		for results[i] == nil {
+			select {
+			case results[i] = <-task.result:
+				if onResult != nil {
+					onResult(results[i])
+				}
+			case <-abortCheck.C:
+				if signals.Abort.IsTriggered() {
+					c.cancel(tasks)
+					return nil
+				}
+			}
+		}
+	}
+	return results
+}
+
+func (c *coordinator) cancel(tasks []*workerTask) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for _, task := range tasks {
+		task.cancelled = true
+		delete(c.assigned, task.id)
+	}
+}
+
+// Splits the raid sim by seed over all worker threads. Only runs
+// SimOptions.iterations, target_precision isn't supported.
+func (c *coordinator) runRaidSim(request *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, signals simsignals.Signals) *proto.RaidSimResult {
+	threads := c.totalThreads()
+	if threads == 0 {
+		return &proto.RaidSimResult{Error: &proto.ErrorOutcome{Message: "No workers are registered with the coordinator"}}
+	}
+
+	splitRes := core.SplitSimRequestForConcurrency(request, int32(threads))
+	if splitRes.ErrorResult != "" {
+		return &proto.RaidSimResult{Error: &proto.ErrorOutcome{Message: splitRes.ErrorResult}}
+	}
+
+	var completedIterations int32
+	results := c.runTasks(splitRes.Requests, signals, func(result *proto.RaidSimResult) {
+		completedIterations += result.IterationsDone
+		progress <- &proto.ProgressMetrics{
+			TotalIterations:     request.SimOptions.Iterations,
+			CompletedIterations: completedIterations,
+			TotalSims:           splitRes.SplitsDone,
+		}
+	})
+	if results == nil {
+		return &proto.RaidSimResult{Error: &proto.ErrorOutcome{Type: proto.ErrorOutcomeType_ErrorOutcomeAborted}}
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			return &proto.RaidSimResult{Error: result.Error}
+		}
+	}
+
+	return core.CombineConcurrentSimResults(results, request.SimOptions.Debug)
+}
+
+// Runs a single sim of a bulk sim on a worker, see core.RaidSimRunner.
+func (c *coordinator) runBulkCombo(request *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, _ bool, signals simsignals.Signals) *proto.RaidSimResult {
+	var result *proto.RaidSimResult
+	if results := c.runTasks([]*proto.RaidSimRequest{googleProto.Clone(request).(*proto.RaidSimRequest)}, signals, nil); results != nil {
+		result = results[0]
+	} else {
+		result = &proto.RaidSimResult{Error: &proto.ErrorOutcome{Type: proto.ErrorOutcomeType_ErrorOutcomeAborted}}
+	}
+
+	progress <- &proto.ProgressMetrics{
+		CompletedIterations: result.IterationsDone,
+		FinalRaidResult:     result,
+	}
+	close(progress)
+	return result
+}
+
+func (c *coordinator) asyncAPIHandlers() map[string]asyncAPIHandler {
+	return map[string]asyncAPIHandler{
+		"/distributed/raidSimAsync": {msg: func() googleProto.Message { return &proto.RaidSimRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics, requestId string) {
+			signals, err := simsignals.RegisterWithId(requestId)
+			if err != nil {
+				reporter <- &proto.ProgressMetrics{FinalRaidResult: &proto.RaidSimResult{Error: &proto.ErrorOutcome{Message: "Couldn't register for signal API: " + err.Error()}}}
+				return
+			}
+			go func() {
+				defer simsignals.UnregisterId(requestId)
+				reporter <- &proto.ProgressMetrics{FinalRaidResult: c.runRaidSim(msg.(*proto.RaidSimRequest), reporter, signals)}
+			}()
+		}},
+		"/distributed/bulkSimAsync": {msg: func() googleProto.Message { return &proto.BulkSimRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics, requestId string) {
+			signals, err := simsignals.RegisterWithId(requestId)
+			if err != nil {
+				reporter <- &proto.ProgressMetrics{FinalBulkResult: &proto.BulkSimResult{Error: &proto.ErrorOutcome{Message: "Couldn't register for signal API: " + err.Error()}}}
+				return
+			}
+			go func() {
+				defer simsignals.UnregisterId(requestId)
+				core.BulkSimWithRunner(signals, msg.(*proto.BulkSimRequest), reporter, c.runBulkCombo, max(c.totalThreads(), 1))
+			}()
+		}},
+	}
+}
+
+type workerAPIHandler struct {
+	msg    func() googleProto.Message
+	resp   func() googleProto.Message // Only used for the OpenAPI spec.
+	handle func(*coordinator, googleProto.Message) (googleProto.Message, error)
+}
+
+var workerAPIHandlers = map[string]workerAPIHandler{
+	"/worker/register": {msg: func() googleProto.Message { return &proto.WorkerRegisterRequest{} }, resp: func() googleProto.Message { return &proto.WorkerRegisterResponse{} }, handle: func(c *coordinator, msg googleProto.Message) (googleProto.Message, error) {
+		return c.register(msg.(*proto.WorkerRegisterRequest)), nil
+	}},
+	"/worker/heartbeat": {msg: func() googleProto.Message { return &proto.WorkerTaskRequest{} }, resp: func() googleProto.Message { return &proto.WorkerRegisterResponse{} }, handle: func(c *coordinator, msg googleProto.Message) (googleProto.Message, error) {
+		return c.heartbeat(msg.(*proto.WorkerTaskRequest))
+	}},
+	"/worker/task": {msg: func() googleProto.Message { return &proto.WorkerTaskRequest{} }, resp: func() googleProto.Message { return &proto.WorkerTask{} }, handle: func(c *coordinator, msg googleProto.Message) (googleProto.Message, error) {
+		return c.nextTask(msg.(*proto.WorkerTaskRequest))
+	}},
+	"/worker/result": {msg: func() googleProto.Message { return &proto.WorkerTaskResult{} }, resp: func() googleProto.Message { return &proto.WorkerTask{} }, handle: func(c *coordinator, msg googleProto.Message) (googleProto.Message, error) {
+		return c.taskDone(msg.(*proto.WorkerTaskResult))
+	}},
+}
+
+// Coordinator routes are only served when running with --coordinator.
+func (s *server) setupCoordinatorServer() {
+	if s.coordinator == nil {
+		return
+	}
+
+	for route := range workerAPIHandlers {
+		http.Handle(route, corsMiddleware(http.HandlerFunc(s.handleWorkerAPI)))
+	}
+	for route, handler := range s.coordinator.asyncAPIHandlers() {
+		handler := handler
+		http.Handle(route, corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.runAsync(w, r, handler)
+		})))
+	}
+}
+
+func (s *server) handleWorkerAPI(w http.ResponseWriter, r *http.Request) {
+	handler, ok := workerAPIHandlers[r.URL.Path]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, fmt.Sprintf("Invalid endpoint: %s", r.URL.Path))
+		return
+	}
+
+	msg := handler.msg()
+	if !readRequest(w, r, msg) {
+		return
+	}
+
+	result, err := handler.handle(s.coordinator, msg)
+	if errors.Is(err, errUnknownWorker) {
+		writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeResponse(w, r, result)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	proto "github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+const (
+	contentTypeProto = "application/x-protobuf"
+	contentTypeJSON  = "application/json"
+)
+
+// Whether the request body is protojson instead of binary protobuf.
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == contentTypeJSON
+}
+
+// Responses use JSON if the request did, or if the client asks for it.
+func wantsJSONResponse(r *http.Request) bool {
+	return isJSONRequest(r) || strings.Contains(r.Header.Get("Accept"), contentTypeJSON)
+}
+
+// Reads the request body into msg, in either binary protobuf or protojson
+// depending on the Content-Type. Writes a 400 and returns false if the body
+// can't be parsed.
+func readRequest(w http.ResponseWriter, r *http.Request, msg googleProto.Message) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to read request body: %s", err))
+		return false
+	}
+
+	if isJSONRequest(r) {
+		err = protojson.Unmarshal(body, msg)
+	} else {
+		err = googleProto.Unmarshal(body, msg)
+	}
+	if err != nil {
+		log.Printf("Failed to parse request: %s", err.Error())
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to parse %s: %s", msg.ProtoReflect().Descriptor().Name(), err))
+		return false
+	}
+	return true
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, msg googleProto.Message) {
+	writeResponseWithStatus(w, r, http.StatusOK, msg)
+}
+
+// Writes an ErrorOutcome with the given status code.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeResponseWithStatus(w, r, status, &proto.ErrorOutcome{Message: message})
+}
+
+func writeResponseWithStatus(w http.ResponseWriter, r *http.Request, status int, msg googleProto.Message) {
+	var outbytes []byte
+	var err error
+	contentType := contentTypeProto
+	if wantsJSONResponse(r) {
+		contentType = contentTypeJSON
+		outbytes, err = protojson.Marshal(msg)
+	} else {
+		outbytes, err = googleProto.Marshal(msg)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(outbytes)
+}
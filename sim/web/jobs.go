@@ -0,0 +1,470 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	proto "github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+var (
+	errJobNotFound  = errors.New("job not found")
+	errJobNoRequest = errors.New("job has no request")
+)
+
+const jobFileExt = ".binpb"
+
+// Stores each job as a binary proto file in a directory, so jobs survive
+// restarts of the server.
+type jobStore struct {
+	dir string
+}
+
+func (store jobStore) path(id string) string {
+	return filepath.Join(store.dir, id+jobFileExt)
+}
+
+// Writes to a temporary file first so a crash never leaves a partial job behind.
+func (store jobStore) save(job *proto.Job) error {
+	data, err := googleProto.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := store.path(job.Id) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, store.path(job.Id))
+}
+
+func (store jobStore) loadAll() ([]*proto.Job, error) {
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*proto.Job
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), jobFileExt) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(store.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		job := &proto.Job{}
+		if err := googleProto.Unmarshal(data, job); err != nil {
+			log.Printf("Skipping unreadable job file %s: %s", entry.Name(), err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].SubmittedAt != jobs[j].SubmittedAt {
+			return jobs[i].SubmittedAt < jobs[j].SubmittedAt
+		}
+		return jobs[i].Id < jobs[j].Id
+	})
+	return jobs, nil
+}
+
+// Runs jobs in submission order, as long as their threads fit in the budget.
+// The queue doesn't skip ahead to smaller jobs, so large jobs can't starve.
+type jobQueue struct {
+	store  jobStore
+	budget int
+
+	mut     sync.Mutex
+	jobs    map[string]*proto.Job
+	order   []string // All job IDs, oldest first.
+	queued  []string
+	threads int // Threads used by running jobs.
+}
+
+// Loads all jobs from dir. Jobs which were running when the server stopped are
+// queued again ahead of newer jobs, and restart from scratch.
+func newJobQueue(dir string, budget int) (*jobQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	queue := &jobQueue{
+		store:  jobStore{dir: dir},
+		budget: max(budget, 1),
+		jobs:   map[string]*proto.Job{},
+	}
+
+	jobs, err := queue.store.loadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		queue.jobs[job.Id] = job
+		queue.order = append(queue.order, job.Id)
+
+		switch job.Status {
+		case proto.JobStatus_JobRunning:
+			log.Printf("Job %s was interrupted, queueing it again.", job.Id)
+			job.Status = proto.JobStatus_JobQueued
+			job.StartedAt = 0
+			job.Progress = nil
+			if err := queue.store.save(job); err != nil {
+				return nil, err
+			}
+			fallthrough
+		case proto.JobStatus_JobQueued:
+			job.Threads = queue.clampThreads(job.Threads)
+			queue.queued = append(queue.queued, job.Id)
+		}
+	}
+
+	queue.mut.Lock()
+	queue.schedule()
+	queue.mut.Unlock()
+	return queue, nil
+}
+
+func (queue *jobQueue) clampThreads(threads int32) int32 {
+	if threads <= 0 || int(threads) > queue.budget {
+		return int32(queue.budget)
+	}
+	return threads
+}
+
+func (queue *jobQueue) submit(job *proto.Job) (*proto.Job, error) {
+	request := job.ProtoReflect()
+	if field := request.WhichOneof(request.Descriptor().Oneofs().ByName("request")); field == nil || !request.Get(field).Message().IsValid() {
+		return nil, errJobNoRequest
+	}
+
+	job = googleProto.Clone(job).(*proto.Job)
+	job.Id = uuid.NewString()
+	job.Status = proto.JobStatus_JobQueued
+	job.Threads = queue.clampThreads(job.Threads)
+	job.SubmittedAt = time.Now().UnixMilli()
+	job.StartedAt = 0
+	job.FinishedAt = 0
+	job.Progress = nil
+	job.Error = ""
+
+	queue.mut.Lock()
+	defer queue.mut.Unlock()
+	if err := queue.store.save(job); err != nil {
+		return nil, err
+	}
+	queue.jobs[job.Id] = job
+	queue.order = append(queue.order, job.Id)
+	queue.queued = append(queue.queued, job.Id)
+	queue.schedule()
+
+	return googleProto.Clone(job).(*proto.Job), nil
+}
+
+// Starts queued jobs until the next one doesn't fit. Must hold queue.mut.
+func (queue *jobQueue) schedule() {
+	for len(queue.queued) > 0 {
+		job := queue.jobs[queue.queued[0]]
+		if queue.threads > 0 && queue.threads+int(job.Threads) > queue.budget {
+			return
+		}
+		queue.queued = queue.queued[1:]
+		queue.start(job)
+	}
+}
+
+// Must hold queue.mut.
+func (queue *jobQueue) start(job *proto.Job) {
+	route, request := jobRequest(job)
+	job.Status = proto.JobStatus_JobRunning
+	job.StartedAt = time.Now().UnixMilli()
+	queue.threads += int(job.Threads)
+	if err := queue.store.save(job); err != nil {
+		log.Printf("[ERROR] Failed to save job %s: %s", job.Id, err)
+	}
+
+	// The job ID doubles as the request ID, so cancel can abort it.
+	reporter := make(chan *proto.ProgressMetrics, 100)
+	asyncAPIHandlers[route].handle(request, reporter, job.Id)
+
+	go func() {
+		for progMetric := range reporter {
+			if progMetric == nil {
+				continue
+			}
+			queue.mut.Lock()
+			job.Progress = progMetric
+			if isFinalProgress(progMetric) {
+				queue.finish(job, progMetric)
+				queue.mut.Unlock()
+				return
+			}
+			queue.mut.Unlock()
+		}
+
+		// The reporter closed without a final result, e.g. because the sim panicked.
+		queue.mut.Lock()
+		queue.finish(job, nil)
+		queue.mut.Unlock()
+	}()
+}
+
+// Frees the job's threads and starts the next jobs. A nil final result marks
+// the job as failed. Must hold queue.mut.
+func (queue *jobQueue) finish(job *proto.Job, final *proto.ProgressMetrics) {
+	job.FinishedAt = time.Now().UnixMilli()
+	job.Status = proto.JobStatus_JobDone
+	if final == nil {
+		job.Status = proto.JobStatus_JobFailed
+		job.Error = "sim stopped without a result"
+	} else if errorOutcome := finalError(final); errorOutcome != nil {
+		if errorOutcome.Type == proto.ErrorOutcomeType_ErrorOutcomeAborted {
+			job.Status = proto.JobStatus_JobCancelled
+		} else {
+			job.Status = proto.JobStatus_JobFailed
+			job.Error = errorOutcome.Message
+		}
+	}
+	queue.threads -= int(job.Threads)
+
+	if err := queue.store.save(job); err != nil {
+		log.Printf("[ERROR] Failed to save job %s: %s", job.Id, err)
+	}
+	queue.schedule()
+}
+
+// Returns the async route running the job, and a copy of its request limited
+// to the job's threads.
+func jobRequest(job *proto.Job) (string, googleProto.Message) {
+	job = googleProto.Clone(job).(*proto.Job)
+	options := &proto.SimOptions{}
+
+	var route string
+	var request googleProto.Message
+	switch r := job.Request.(type) {
+	case *proto.Job_RaidSim:
+		route, request = "/raidSimAsync", r.RaidSim
+		if r.RaidSim.SimOptions == nil {
+			r.RaidSim.SimOptions = options
+		}
+		options = r.RaidSim.SimOptions
+	case *proto.Job_StatWeights:
+		route, request = "/statWeightsAsync", r.StatWeights
+		if r.StatWeights.SimOptions == nil {
+			r.StatWeights.SimOptions = options
+		}
+		options = r.StatWeights.SimOptions
+	case *proto.Job_BulkSim:
+		route, request = "/bulkSimAsync", r.BulkSim
+		if r.BulkSim.BaseSettings == nil {
+			r.BulkSim.BaseSettings = &proto.RaidSimRequest{}
+		}
+		if r.BulkSim.BaseSettings.SimOptions == nil {
+			r.BulkSim.BaseSettings.SimOptions = options
+		}
+		options = r.BulkSim.BaseSettings.SimOptions
+	case *proto.Job_GearOptimize:
+		route, request = "/gearOptimizeAsync", r.GearOptimize
+		if r.GearOptimize.BaseSettings == nil {
+			r.GearOptimize.BaseSettings = &proto.RaidSimRequest{}
+		}
+		if r.GearOptimize.BaseSettings.SimOptions == nil {
+			r.GearOptimize.BaseSettings.SimOptions = options
+		}
+		options = r.GearOptimize.BaseSettings.SimOptions
+	}
+	options.MaxThreads = job.Threads
+
+	return route, request
+}
+
+func finalError(final *proto.ProgressMetrics) *proto.ErrorOutcome {
+	switch {
+	case final.FinalRaidResult != nil:
+		return final.FinalRaidResult.Error
+	case final.FinalWeightResult != nil:
+		return final.FinalWeightResult.Error
+	case final.FinalBulkResult != nil:
+		return final.FinalBulkResult.Error
+	case final.FinalGearOptimizeResult != nil:
+		return final.FinalGearOptimizeResult.Error
+	}
+	return nil
+}
+
+// Cancels a queued job, or aborts a running one. Running jobs are marked as
+// cancelled once the sim stops.
+func (queue *jobQueue) cancel(id string) (*proto.Job, error) {
+	queue.mut.Lock()
+	defer queue.mut.Unlock()
+
+	job, ok := queue.jobs[id]
+	if !ok {
+		return nil, errJobNotFound
+	}
+
+	switch job.Status {
+	case proto.JobStatus_JobQueued:
+		for i, queuedId := range queue.queued {
+			if queuedId == id {
+				queue.queued = append(queue.queued[:i], queue.queued[i+1:]...)
+				break
+			}
+		}
+		job.Status = proto.JobStatus_JobCancelled
+		job.FinishedAt = time.Now().UnixMilli()
+		if err := queue.store.save(job); err != nil {
+			return nil, err
+		}
+		queue.schedule()
+	case proto.JobStatus_JobRunning:
+		simsignals.AbortById(id)
+	default:
+		return nil, fmt.Errorf("job %s already finished", id)
+	}
+
+	return jobSummary(job, true), nil
+}
+
+// Queues a copy of an existing job, e.g. to rerun a failed or interrupted one.
+func (queue *jobQueue) resubmit(id string) (*proto.Job, error) {
+	queue.mut.Lock()
+	job, ok := queue.jobs[id]
+	if ok {
+		job = googleProto.Clone(job).(*proto.Job)
+	}
+	queue.mut.Unlock()
+
+	if !ok {
+		return nil, errJobNotFound
+	}
+	job.ResubmittedFrom = id
+	return queue.submit(job)
+}
+
+func (queue *jobQueue) status(id string) (*proto.Job, error) {
+	queue.mut.Lock()
+	defer queue.mut.Unlock()
+
+	job, ok := queue.jobs[id]
+	if !ok {
+		return nil, errJobNotFound
+	}
+	return jobSummary(job, true), nil
+}
+
+func (queue *jobQueue) result(id string) (*proto.ProgressMetrics, error) {
+	queue.mut.Lock()
+	defer queue.mut.Unlock()
+
+	job, ok := queue.jobs[id]
+	if !ok {
+		return nil, errJobNotFound
+	}
+	if job.Progress == nil || !isFinalProgress(job.Progress) {
+		return nil, fmt.Errorf("job %s has no result, status is %s", id, job.Status)
+	}
+	return googleProto.Clone(job.Progress).(*proto.ProgressMetrics), nil
+}
+
+func (queue *jobQueue) list(owner string) *proto.JobList {
+	queue.mut.Lock()
+	defer queue.mut.Unlock()
+
+	list := &proto.JobList{}
+	for _, id := range queue.order {
+		if job := queue.jobs[id]; owner == "" || job.Owner == owner {
+			list.Jobs = append(list.Jobs, jobSummary(job, false))
+		}
+	}
+	return list
+}
+
+// Copy of the job without the final result, and optionally without the request.
+func jobSummary(job *proto.Job, withRequest bool) *proto.Job {
+	summary := googleProto.Clone(job).(*proto.Job)
+	if !withRequest {
+		summary.Request = nil
+	}
+	if summary.Progress != nil {
+		summary.Progress.FinalRaidResult = nil
+		summary.Progress.FinalWeightResult = nil
+		summary.Progress.FinalBulkResult = nil
+		summary.Progress.FinalGearOptimizeResult = nil
+	}
+	return summary
+}
+
+type jobAPIHandler struct {
+	msg    func() googleProto.Message
+	resp   func() googleProto.Message // Only used for the OpenAPI spec.
+	handle func(*jobQueue, googleProto.Message) (googleProto.Message, error)
+}
+
+var jobAPIHandlers = map[string]jobAPIHandler{
+	"/jobs/submit": {msg: func() googleProto.Message { return &proto.Job{} }, resp: func() googleProto.Message { return &proto.Job{} }, handle: func(queue *jobQueue, msg googleProto.Message) (googleProto.Message, error) {
+		return queue.submit(msg.(*proto.Job))
+	}},
+	"/jobs/list": {msg: func() googleProto.Message { return &proto.JobListRequest{} }, resp: func() googleProto.Message { return &proto.JobList{} }, handle: func(queue *jobQueue, msg googleProto.Message) (googleProto.Message, error) {
+		return queue.list(msg.(*proto.JobListRequest).Owner), nil
+	}},
+	"/jobs/status": {msg: func() googleProto.Message { return &proto.JobIdRequest{} }, resp: func() googleProto.Message { return &proto.Job{} }, handle: func(queue *jobQueue, msg googleProto.Message) (googleProto.Message, error) {
+		return queue.status(msg.(*proto.JobIdRequest).JobId)
+	}},
+	"/jobs/result": {msg: func() googleProto.Message { return &proto.JobIdRequest{} }, resp: func() googleProto.Message { return &proto.ProgressMetrics{} }, handle: func(queue *jobQueue, msg googleProto.Message) (googleProto.Message, error) {
+		return queue.result(msg.(*proto.JobIdRequest).JobId)
+	}},
+	"/jobs/cancel": {msg: func() googleProto.Message { return &proto.JobIdRequest{} }, resp: func() googleProto.Message { return &proto.Job{} }, handle: func(queue *jobQueue, msg googleProto.Message) (googleProto.Message, error) {
+		return queue.cancel(msg.(*proto.JobIdRequest).JobId)
+	}},
+	"/jobs/resubmit": {msg: func() googleProto.Message { return &proto.JobIdRequest{} }, resp: func() googleProto.Message { return &proto.Job{} }, handle: func(queue *jobQueue, msg googleProto.Message) (googleProto.Message, error) {
+		return queue.resubmit(msg.(*proto.JobIdRequest).JobId)
+	}},
+}
+
+// Job routes are only served when the server has a job store.
+func (s *server) setupJobServer() {
+	if s.jobs == nil {
+		return
+	}
+
+	for route := range jobAPIHandlers {
+		http.Handle(route, corsMiddleware(http.HandlerFunc(s.handleJobAPI)))
+	}
+}
+
+func (s *server) handleJobAPI(w http.ResponseWriter, r *http.Request) {
+	handler, ok := jobAPIHandlers[r.URL.Path]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, fmt.Sprintf("Invalid endpoint: %s", r.URL.Path))
+		return
+	}
+
+	msg := handler.msg()
+	if !readRequest(w, r, msg) {
+		return
+	}
+
+	result, err := handler.handle(s.jobs, msg)
+	if errors.Is(err, errJobNotFound) {
+		writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeResponse(w, r, result)
+}
@@ -11,6 +11,7 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"runtime"
 	"runtime/pprof"
 	"strings"
 	"sync"
@@ -48,6 +49,9 @@ func main() {
 	var host = flag.String("host", "localhost:3333", "URL to host the interface on.")
 	var launch = flag.Bool("launch", true, "auto launch browser")
 	var skipVersionCheck = flag.Bool("nvc", false, "set true to skip version check")
+	var jobsDir = flag.String("jobs_dir", "", "Directory to store queued jobs in. Enables the /jobs routes.")
+	var jobThreads = flag.Int("job_threads", runtime.NumCPU(), "Max threads used by all running jobs together.")
+	var runCoordinator = flag.Bool("coordinator", false, "Hand out sims to workers started with 'wowsimcli worker'. Enables the /worker and /distributed routes.")
 
 	flag.Parse()
 
@@ -86,27 +90,43 @@ func main() {
 		progMut:         sync.RWMutex{},
 		asyncProgresses: map[string]*asyncProgress{},
 	}
+	if *jobsDir != "" {
+		jobs, err := newJobQueue(*jobsDir, *jobThreads)
+		if err != nil {
+			log.Fatalf("Failed to load jobs from %s: %s", *jobsDir, err)
+		}
+		s.jobs = jobs
+	}
+	if *runCoordinator {
+		s.coordinator = newCoordinator()
+	}
 	s.runServer(*useFS, *host, *launch, *simName, *wasm, bufio.NewReader(os.Stdin))
 }
 
 // Handlers to decode and handle each proto function
 var handlers = map[string]apiHandler{
-	"/raidSim": {msg: func() googleProto.Message { return &proto.RaidSimRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
+	"/raidSim": {msg: func() googleProto.Message { return &proto.RaidSimRequest{} }, resp: func() googleProto.Message { return &proto.RaidSimResult{} }, handle: func(msg googleProto.Message) googleProto.Message {
 		return core.RunRaidSim(msg.(*proto.RaidSimRequest))
 	}},
-	"/statWeights": {msg: func() googleProto.Message { return &proto.StatWeightsRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
+	"/statWeights": {msg: func() googleProto.Message { return &proto.StatWeightsRequest{} }, resp: func() googleProto.Message { return &proto.StatWeightsResult{} }, handle: func(msg googleProto.Message) googleProto.Message {
 		return core.StatWeights(msg.(*proto.StatWeightsRequest))
 	}},
-	"/statWeightRequests": {msg: func() googleProto.Message { return &proto.StatWeightsRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
+	"/statWeightRequests": {msg: func() googleProto.Message { return &proto.StatWeightsRequest{} }, resp: func() googleProto.Message { return &proto.StatWeightRequestsData{} }, handle: func(msg googleProto.Message) googleProto.Message {
 		return core.StatWeightRequests(msg.(*proto.StatWeightsRequest))
 	}},
-	"/statWeightCompute": {msg: func() googleProto.Message { return &proto.StatWeightsCalcRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
+	"/statWeightCompute": {msg: func() googleProto.Message { return &proto.StatWeightsCalcRequest{} }, resp: func() googleProto.Message { return &proto.StatWeightsResult{} }, handle: func(msg googleProto.Message) googleProto.Message {
 		return core.StatWeightCompute(msg.(*proto.StatWeightsCalcRequest))
 	}},
-	"/computeStats": {msg: func() googleProto.Message { return &proto.ComputeStatsRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
+	"/compare": {msg: func() googleProto.Message { return &proto.CompareRequest{} }, resp: func() googleProto.Message { return &proto.CompareResult{} }, handle: func(msg googleProto.Message) googleProto.Message {
+		return core.RunCompare(msg.(*proto.CompareRequest))
+	}},
+	"/aplTune": {msg: func() googleProto.Message { return &proto.APLTuneRequest{} }, resp: func() googleProto.Message { return &proto.APLTuneResult{} }, handle: func(msg googleProto.Message) googleProto.Message {
+		return core.RunAPLTune(msg.(*proto.APLTuneRequest))
+	}},
+	"/computeStats": {msg: func() googleProto.Message { return &proto.ComputeStatsRequest{} }, resp: func() googleProto.Message { return &proto.ComputeStatsResult{} }, handle: func(msg googleProto.Message) googleProto.Message {
 		return core.ComputeStats(msg.(*proto.ComputeStatsRequest))
 	}},
-	"/abortById": {msg: func() googleProto.Message { return &proto.AbortRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
+	"/abortById": {msg: func() googleProto.Message { return &proto.AbortRequest{} }, resp: func() googleProto.Message { return &proto.AbortResponse{} }, handle: func(msg googleProto.Message) googleProto.Message {
 		requestId := msg.(*proto.AbortRequest).RequestId
 		triggered := simsignals.AbortById(requestId)
 		return &proto.AbortResponse{RequestId: requestId, WasTriggered: triggered}
@@ -123,15 +143,28 @@ var asyncAPIHandlers = map[string]asyncAPIHandler{
 	"/bulkSimAsync": {msg: func() googleProto.Message { return &proto.BulkSimRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics, requestId string) {
 		core.RunBulkSimAsync(msg.(*proto.BulkSimRequest), reporter, requestId)
 	}},
+	"/gearOptimizeAsync": {msg: func() googleProto.Message { return &proto.GearOptimizeRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics, requestId string) {
+		core.RunGearOptimizeAsync(msg.(*proto.GearOptimizeRequest), reporter, requestId)
+	}},
+	"/compareAsync": {msg: func() googleProto.Message { return &proto.CompareRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics, requestId string) {
+		core.RunCompareAsync(msg.(*proto.CompareRequest), reporter, requestId)
+	}},
+	"/aplTuneAsync": {msg: func() googleProto.Message { return &proto.APLTuneRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics, requestId string) {
+		core.RunAPLTuneAsync(msg.(*proto.APLTuneRequest), reporter, requestId)
+	}},
 }
 
 type server struct {
 	progMut         sync.RWMutex
 	asyncProgresses map[string]*asyncProgress
+
+	jobs        *jobQueue    // Only set when running with --jobs_dir.
+	coordinator *coordinator // Only set when running with --coordinator.
 }
 
 type apiHandler struct {
 	msg    func() googleProto.Message
+	resp   func() googleProto.Message // Only used for the OpenAPI spec.
 	handle func(googleProto.Message) googleProto.Message
 }
 type asyncAPIHandler struct {
@@ -141,13 +174,20 @@ type asyncAPIHandler struct {
 
 type asyncProgress struct {
 	id             string
+	requestId      string // Used to abort the request, see simsignals.
 	latestProgress atomic.Value
+
+	// Streaming clients, see handleProgressStream.
+	subMut      sync.Mutex
+	subscribers []chan *proto.ProgressMetrics
+	done        bool
 }
 
 func (s *server) addNewSim() *asyncProgress {
 	newID := uuid.NewString()
 	simProgress := &asyncProgress{
-		id: newID,
+		id:        newID,
+		requestId: newID,
 	}
 	simProgress.latestProgress.Store(&proto.ProgressMetrics{})
 
@@ -158,23 +198,34 @@ func (s *server) addNewSim() *asyncProgress {
 	return simProgress
 }
 
+func (s *server) removeSim(id string) {
+	s.progMut.Lock()
+	delete(s.asyncProgresses, id)
+	s.progMut.Unlock()
+}
+
+func (s *server) getSim(id string) (*asyncProgress, bool) {
+	s.progMut.RLock()
+	defer s.progMut.RUnlock()
+	progress, ok := s.asyncProgresses[id]
+	return progress, ok
+}
+
 func (s *server) handleAsyncAPI(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return
-	}
 	endpoint := r.URL.Path
 	handler, ok := asyncAPIHandlers[endpoint]
 	if !ok {
 		log.Printf("Invalid Endpoint: %s", endpoint)
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, r, http.StatusNotFound, fmt.Sprintf("Invalid endpoint: %s", endpoint))
 		return
 	}
+	s.runAsync(w, r, handler)
+}
 
+// Starts the handler in the background and responds with its progress ID.
+func (s *server) runAsync(w http.ResponseWriter, r *http.Request, handler asyncAPIHandler) {
 	msg := handler.msg()
-	if err := googleProto.Unmarshal(body, msg); err != nil {
-		log.Printf("Failed to parse request: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	if !readRequest(w, r, msg) {
 		return
 	}
 
@@ -182,47 +233,42 @@ func (s *server) handleAsyncAPI(w http.ResponseWriter, r *http.Request) {
 	//  as the simulation advances it will push changes to the channel
 	//  these changes will be consumed by the goroutine below so the asyncProgress endpoint can fetch the results.
 	reporter := make(chan *proto.ProgressMetrics, 100)
-	handler.handle(msg, reporter, r.URL.Query().Get("requestId"))
 
-	// Generate a new async simulation
+	// Generate a new async simulation. If the client didn't pick a request ID for
+	// aborting, the progress ID doubles as one.
 	simProgress := s.addNewSim()
+	if requestId := r.URL.Query().Get("requestId"); requestId != "" {
+		simProgress.requestId = requestId
+	}
+	handler.handle(msg, reporter, simProgress.requestId)
 
 	// Now launch a background process that pulls progress reports off the reporter channel
 	// and pushes it into the async progress cache.
 	go func() {
+		defer simProgress.finish()
 		for {
 			select {
 			case <-time.After(time.Minute * 10):
 				// if we get no progress after 10 minutes, delete the pending sim and exit.
-				s.progMut.Lock()
-				delete(s.asyncProgresses, simProgress.id)
-				s.progMut.Unlock()
+				s.removeSim(simProgress.id)
 				return
 			case progMetric := <-reporter:
 				if progMetric == nil {
 					return
 				}
-				simProgress.latestProgress.Store(progMetric)
-				if progMetric.FinalRaidResult != nil || progMetric.FinalWeightResult != nil || progMetric.FinalBulkResult != nil {
+				simProgress.publish(progMetric)
+				if isFinalProgress(progMetric) {
+					// Clean up results which are only streamed and never polled.
+					time.AfterFunc(time.Minute*10, func() { s.removeSim(simProgress.id) })
 					return
 				}
 			}
 		}
 	}()
 
-	protoResult := &proto.AsyncAPIResult{
+	writeResponse(w, r, &proto.AsyncAPIResult{
 		ProgressId: simProgress.id,
-	}
-
-	outbytes, err := googleProto.Marshal(protoResult)
-	if err != nil {
-		log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Add("Content-Type", "application/x-protobuf")
-	w.Write(outbytes)
+	})
 }
 
 func (s *server) setupAsyncServer() {
@@ -233,14 +279,8 @@ func (s *server) setupAsyncServer() {
 
 	// asyncProgress will fetch the current progress of a simulation by its UUID.
 	http.Handle("/asyncProgress", corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			return
-		}
 		msg := &proto.AsyncAPIResult{}
-		if err := googleProto.Unmarshal(body, msg); err != nil {
-			log.Printf("Failed to parse request: %s", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
+		if !readRequest(w, r, msg) {
 			return
 		}
 
@@ -253,22 +293,16 @@ func (s *server) setupAsyncServer() {
 			return
 		}
 		latest := progress.latestProgress.Load().(*proto.ProgressMetrics)
-		outbytes, err := googleProto.Marshal(latest)
-		if err != nil {
-			log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
 
 		// If this was the last result, delete the cache for this simulation.
-		if latest.FinalRaidResult != nil || latest.FinalWeightResult != nil || latest.FinalBulkResult != nil {
-			s.progMut.Lock()
-			delete(s.asyncProgresses, msg.ProgressId)
-			s.progMut.Unlock()
+		if isFinalProgress(latest) {
+			s.removeSim(msg.ProgressId)
 		}
-		w.Header().Add("Content-Type", "application/x-protobuf")
-		w.Write(outbytes)
+		writeResponse(w, r, latest)
 	})))
+
+	http.Handle("/asyncProgressStream", corsMiddleware(http.HandlerFunc(s.handleProgressStream)))
+	http.Handle("/asyncCancel", corsMiddleware(http.HandlerFunc(s.handleAsyncCancel)))
 }
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -284,6 +318,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName string, wasm bool, inputReader *bufio.Reader) {
 	s.setupAsyncServer()
+	s.setupJobServer()
+	s.setupCoordinatorServer()
 
 	var fs http.Handler
 	if useFS {
@@ -297,6 +333,7 @@ func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName
 	for route := range handlers {
 		http.Handle(route, corsMiddleware(http.HandlerFunc(handleAPI)))
 	}
+	http.Handle("/openapi.json", corsMiddleware(http.HandlerFunc(handleOpenAPISpec)))
 
 	http.HandleFunc("/version", func(resp http.ResponseWriter, req *http.Request) {
 		msg := fmt.Sprintf(`{"version": "%s", "outdated": %d}`, Version, outdated)
@@ -394,10 +431,29 @@ func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName
 				fmt.Printf("Process: %s (%d sims)\n\t  Progress: %d/%d\n", v.id, latest.TotalSims, latest.CompletedIterations, latest.TotalIterations)
 			}
 			s.progMut.RUnlock()
+		case "jobs":
+			if s.jobs == nil {
+				fmt.Printf("Job store is disabled, run with --jobs_dir to enable it.\n")
+				break
+			}
+			for _, job := range s.jobs.list("").Jobs {
+				fmt.Printf("Job: %s (%s, %s, %d threads)\n", job.Id, job.Owner, job.Status, job.Threads)
+			}
+		case "workers":
+			if s.coordinator == nil {
+				fmt.Printf("Coordinator is disabled, run with --coordinator to enable it.\n")
+				break
+			}
+			s.coordinator.mut.Lock()
+			fmt.Printf("Workers: %d, pending tasks: %d\n", len(s.coordinator.workers), len(s.coordinator.pending))
+			for _, worker := range s.coordinator.workers {
+				fmt.Printf("Worker: %s (%s, %d threads, %d tasks done)\n", worker.name, worker.id, worker.threads, worker.tasksDone)
+			}
+			s.coordinator.mut.Unlock()
 		case "quit":
 			os.Exit(1)
 		case "?":
-			fmt.Printf("Commands:\n\tsims - Lists all active async sims running currently.\n\tprofile - start a CPU profile for debugging performance\n\tquit - exits\n\n")
+			fmt.Printf("Commands:\n\tsims - Lists all active async sims running currently.\n\tjobs - Lists all jobs in the job store.\n\tworkers - Lists all workers registered with the coordinator.\n\tprofile - start a CPU profile for debugging performance\n\tquit - exits\n\n")
 		case "":
 			// nothing.
 		default:
@@ -406,43 +462,28 @@ func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName
 	}
 }
 
-// handleAPI is generic handler for any api function using protos.
+// handleAPI is generic handler for any api function using protos. Requests and
+// responses are binary protobuf, or protojson when sent as application/json.
 func handleAPI(w http.ResponseWriter, r *http.Request) {
 	endpoint := r.URL.Path
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return
-	}
 	handler, ok := handlers[endpoint]
 	if !ok {
 		log.Printf("Invalid Endpoint: %s", endpoint)
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, r, http.StatusNotFound, fmt.Sprintf("Invalid endpoint: %s", endpoint))
 		return
 	}
 
 	msg := handler.msg()
-	if err := googleProto.Unmarshal(body, msg); err != nil {
-		log.Printf("Failed to parse request: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	if !readRequest(w, r, msg) {
 		return
 	}
 
 	if googleProto.Equal(msg, msg.ProtoReflect().New().Interface()) {
 		log.Printf("Request is empty")
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	result := handler.handle(msg)
-
-	outbytes, err := googleProto.Marshal(result)
-	if err != nil {
-		log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, http.StatusBadRequest, "Request is empty")
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/x-protobuf")
-	w.Write(outbytes)
+	writeResponse(w, r, handler.handle(msg))
 }
@@ -3,9 +3,13 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -13,6 +17,7 @@ import (
 	_ "github.com/wowsims/sod/sim/common"
 	"github.com/wowsims/sod/sim/core"
 	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
 	googleProto "google.golang.org/protobuf/proto"
 )
 
@@ -49,6 +54,14 @@ func init() {
 		progMut:         sync.RWMutex{},
 		asyncProgresses: map[string]*asyncProgress{},
 	}
+	jobsDir, err := os.MkdirTemp("", "wowsimsod_jobs")
+	if err != nil {
+		log.Fatalf("Failed to create jobs dir: %s", err)
+	}
+	if s.jobs, err = newJobQueue(jobsDir, 2); err != nil {
+		log.Fatalf("Failed to create job queue: %s", err)
+	}
+	s.coordinator = newCoordinator()
 	go func() {
 		s.runServer(true, "localhost:3339", false, "", false, bufio.NewReader(bytes.NewBuffer([]byte{})))
 	}()
@@ -108,3 +121,374 @@ func TestIndividualSim(t *testing.T) {
 
 	log.Printf("RESULT: %#v", rsr)
 }
+
+func TestJSONRequest(t *testing.T) {
+	body := `{"raid": {"parties": [{"players": [{"race": "RaceTroll", "class": "ClassShaman", "elementalShaman": {}}]}]}, "encounter": {"duration": 30, "targets": [{}]}, "simOptions": {"iterations": 10, "randomSeed": "1"}}`
+
+	r, err := http.Post("http://localhost:3339/raidSim", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to POST request: %s", err.Error())
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK || r.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected a 200 JSON response, got %d %s", r.StatusCode, r.Header.Get("Content-Type"))
+	}
+
+	respBody, _ := io.ReadAll(r.Body)
+	rsr := &proto.RaidSimResult{}
+	if err := protojson.Unmarshal(respBody, rsr); err != nil {
+		t.Fatalf("Failed to parse JSON result: %s", err.Error())
+	}
+	if rsr.RaidMetrics.GetDps().GetAvg() == 0 {
+		t.Fatalf("Expected non-zero DPS, got result: %s", respBody)
+	}
+}
+
+func TestMalformedRequest(t *testing.T) {
+	r, err := http.Post("http://localhost:3339/raidSim", "application/json", strings.NewReader(`{"raid": 5}`))
+	if err != nil {
+		t.Fatalf("Failed to POST request: %s", err.Error())
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", r.StatusCode)
+	}
+
+	respBody, _ := io.ReadAll(r.Body)
+	errorOutcome := &proto.ErrorOutcome{}
+	if err := protojson.Unmarshal(respBody, errorOutcome); err != nil || errorOutcome.Message == "" {
+		t.Fatalf("Expected an ErrorOutcome, got: %s", respBody)
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	r, err := http.Get("http://localhost:3339/openapi.json")
+	if err != nil {
+		t.Fatalf("Failed to GET spec: %s", err.Error())
+	}
+	defer r.Body.Close()
+
+	spec := struct {
+		Paths      map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		t.Fatalf("Failed to decode spec: %s", err.Error())
+	}
+
+	for route := range handlers {
+		if _, ok := spec.Paths[route]; !ok {
+			t.Fatalf("Spec is missing route %s", route)
+		}
+	}
+	if _, ok := spec.Components.Schemas["proto.RaidSimRequest"]; !ok {
+		t.Fatalf("Spec is missing the RaidSimRequest schema")
+	}
+}
+
+func TestAsyncProgressStream(t *testing.T) {
+	body := `{"raid": {"parties": [{"players": [{"race": "RaceTroll", "class": "ClassShaman", "elementalShaman": {}}]}]}, "encounter": {"duration": 30, "targets": [{}]}, "simOptions": {"iterations": 100, "randomSeed": "1"}}`
+
+	r, err := http.Post("http://localhost:3339/raidSimAsync", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to POST request: %s", err.Error())
+	}
+	respBody, _ := io.ReadAll(r.Body)
+	r.Body.Close()
+	asyncResult := &proto.AsyncAPIResult{}
+	if err := protojson.Unmarshal(respBody, asyncResult); err != nil {
+		t.Fatalf("Failed to parse async result: %s", err.Error())
+	}
+
+	stream, err := http.Get("http://localhost:3339/asyncProgressStream?progressId=" + asyncResult.ProgressId)
+	if err != nil {
+		t.Fatalf("Failed to GET stream: %s", err.Error())
+	}
+	defer stream.Body.Close()
+	if stream.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s", stream.Header.Get("Content-Type"))
+	}
+
+	var final *proto.ProgressMetrics
+	scanner := bufio.NewScanner(stream.Body)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		progMetric := &proto.ProgressMetrics{}
+		if err := protojson.Unmarshal([]byte(data), progMetric); err != nil {
+			t.Fatalf("Failed to parse event: %s", err.Error())
+		}
+		final = progMetric
+	}
+
+	if final == nil || final.FinalRaidResult.GetRaidMetrics().GetDps().GetAvg() == 0 {
+		t.Fatalf("Expected the stream to end with the final result, got: %v", final)
+	}
+}
+
+func TestAsyncCancelUnknownId(t *testing.T) {
+	r, err := http.Post("http://localhost:3339/asyncCancel", "application/json", strings.NewReader(`{"progressId": "missing"}`))
+	if err != nil {
+		t.Fatalf("Failed to POST request: %s", err.Error())
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", r.StatusCode)
+	}
+}
+
+func postJSON(t *testing.T, route string, body string, result googleProto.Message) int {
+	r, err := http.Post("http://localhost:3339"+route, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to POST request: %s", err.Error())
+	}
+	defer r.Body.Close()
+
+	respBody, _ := io.ReadAll(r.Body)
+	if r.StatusCode == http.StatusOK {
+		if err := protojson.Unmarshal(respBody, result); err != nil {
+			t.Fatalf("Failed to parse %s response: %s", route, err.Error())
+		}
+	}
+	return r.StatusCode
+}
+
+func TestJobQueue(t *testing.T) {
+	body := `{"owner": "test", "threads": 1, "raidSim": {"raid": {"parties": [{"players": [{"race": "RaceTroll", "class": "ClassShaman", "elementalShaman": {}}]}]}, "encounter": {"duration": 30, "targets": [{}]}, "simOptions": {"iterations": 10, "randomSeed": "1"}}}`
+
+	submitted := &proto.Job{}
+	if status := postJSON(t, "/jobs/submit", body, submitted); status != http.StatusOK || submitted.Id == "" {
+		t.Fatalf("Failed to submit job, status %d", status)
+	}
+
+	job := &proto.Job{}
+	for start := time.Now(); time.Since(start) < time.Minute; time.Sleep(100 * time.Millisecond) {
+		postJSON(t, "/jobs/status", `{"jobId": "`+submitted.Id+`"}`, job)
+		if job.Status != proto.JobStatus_JobQueued && job.Status != proto.JobStatus_JobRunning {
+			break
+		}
+	}
+	if job.Status != proto.JobStatus_JobDone {
+		t.Fatalf("Expected job to finish, got status %s: %s", job.Status, job.Error)
+	}
+
+	result := &proto.ProgressMetrics{}
+	if status := postJSON(t, "/jobs/result", `{"jobId": "`+submitted.Id+`"}`, result); status != http.StatusOK || result.FinalRaidResult.GetRaidMetrics().GetDps().GetAvg() == 0 {
+		t.Fatalf("Expected a final result, got status %d", status)
+	}
+
+	list := &proto.JobList{}
+	postJSON(t, "/jobs/list", `{"owner": "test"}`, list)
+	if len(list.Jobs) == 0 || list.Jobs[0].Request != nil {
+		t.Fatalf("Expected a job listing without requests, got: %v", list)
+	}
+
+	if status := postJSON(t, "/jobs/status", `{"jobId": "missing"}`, job); status != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for unknown job, got %d", status)
+	}
+}
+
+func TestJobQueueRestartsInterruptedJobs(t *testing.T) {
+	request := &proto.RaidSimRequest{}
+	body := `{"raid": {"parties": [{"players": [{"race": "RaceTroll", "class": "ClassShaman", "elementalShaman": {}}]}]}, "encounter": {"duration": 30, "targets": [{}]}, "simOptions": {"iterations": 10, "randomSeed": "1"}}`
+	if err := protojson.Unmarshal([]byte(body), request); err != nil {
+		t.Fatalf("Failed to parse request: %s", err)
+	}
+
+	// Saved as running, as if the server stopped mid-sim.
+	store := jobStore{dir: t.TempDir()}
+	if err := store.save(&proto.Job{
+		Id:        "interrupted",
+		Status:    proto.JobStatus_JobRunning,
+		StartedAt: 1,
+		Request:   &proto.Job_RaidSim{RaidSim: request},
+	}); err != nil {
+		t.Fatalf("Failed to save job: %s", err)
+	}
+
+	queue, err := newJobQueue(store.dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to load job queue: %s", err)
+	}
+	job, _ := queue.status("interrupted")
+	if job.StartedAt == 1 {
+		t.Fatalf("Expected the interrupted job to be started again, got status %s", job.Status)
+	}
+
+	for start := time.Now(); time.Since(start) < time.Minute && job.Status == proto.JobStatus_JobRunning; time.Sleep(100 * time.Millisecond) {
+		job, _ = queue.status("interrupted")
+	}
+	if job.Status != proto.JobStatus_JobDone {
+		t.Fatalf("Expected the restarted job to finish, got status %s: %s", job.Status, job.Error)
+	}
+}
+
+func TestJobQueueFailsJobsWithoutResult(t *testing.T) {
+	// Closes the reporter straight away, as a sim which panicked would.
+	handler := asyncAPIHandlers["/raidSimAsync"]
+	defer func() { asyncAPIHandlers["/raidSimAsync"] = handler }()
+	asyncAPIHandlers["/raidSimAsync"] = asyncAPIHandler{msg: handler.msg, handle: func(_ googleProto.Message, reporter chan *proto.ProgressMetrics, _ string) {
+		close(reporter)
+	}}
+
+	queue, err := newJobQueue(t.TempDir(), 1)
+	if err != nil {
+		t.Fatalf("Failed to create job queue: %s", err)
+	}
+	request := &proto.Job{Threads: 1, Request: &proto.Job_RaidSim{RaidSim: &proto.RaidSimRequest{}}}
+	first, err := queue.submit(request)
+	if err != nil {
+		t.Fatalf("Failed to submit job: %s", err)
+	}
+	second, err := queue.submit(request)
+	if err != nil {
+		t.Fatalf("Failed to submit job: %s", err)
+	}
+
+	// The second job only starts once the first releases its thread.
+	for _, id := range []string{first.Id, second.Id} {
+		job, _ := queue.status(id)
+		for start := time.Now(); time.Since(start) < time.Minute && job.Status != proto.JobStatus_JobFailed; time.Sleep(10 * time.Millisecond) {
+			job, _ = queue.status(id)
+		}
+		if job.Status != proto.JobStatus_JobFailed || job.Error == "" {
+			t.Fatalf("Expected job %s to fail, got status %s", id, job.Status)
+		}
+	}
+}
+
+func TestJobQueueLimitsBulkSimThreads(t *testing.T) {
+	requests := make(chan *proto.BulkSimRequest, 1)
+	handler := asyncAPIHandlers["/bulkSimAsync"]
+	defer func() { asyncAPIHandlers["/bulkSimAsync"] = handler }()
+	asyncAPIHandlers["/bulkSimAsync"] = asyncAPIHandler{msg: handler.msg, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics, _ string) {
+		requests <- msg.(*proto.BulkSimRequest)
+		close(reporter)
+	}}
+
+	queue, err := newJobQueue(t.TempDir(), 2)
+	if err != nil {
+		t.Fatalf("Failed to create job queue: %s", err)
+	}
+	// No base settings, so the job has to create the sim options to limit the threads.
+	if _, err := queue.submit(&proto.Job{Threads: 2, Request: &proto.Job_BulkSim{BulkSim: &proto.BulkSimRequest{}}}); err != nil {
+		t.Fatalf("Failed to submit job: %s", err)
+	}
+
+	select {
+	case request := <-requests:
+		if threads := request.GetBaseSettings().GetSimOptions().GetMaxThreads(); threads != 2 {
+			t.Fatalf("Expected the bulk sim to be limited to 2 threads, got %d", threads)
+		}
+	case <-time.After(time.Minute):
+		t.Fatalf("Expected the bulk sim job to start")
+	}
+}
+
+func TestDistributedRaidSim(t *testing.T) {
+	registered := &proto.WorkerRegisterResponse{}
+	if status := postJSON(t, "/worker/register", `{"name": "test", "threads": 2}`, registered); status != http.StatusOK {
+		t.Fatalf("Failed to register worker, status %d", status)
+	}
+
+	// Stand-in for wowsimcli worker, running the tasks in process. Requests can
+	// finish after the test, so it can't use t.
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	post := func(route string, body []byte, result googleProto.Message) {
+		r, err := http.Post("http://localhost:3339"+route, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		defer r.Body.Close()
+		respBody, _ := io.ReadAll(r.Body)
+		protojson.Unmarshal(respBody, result)
+	}
+	for i := 0; i < 2; i++ {
+		go func() {
+			task := &proto.WorkerTask{}
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if task.TaskId == "" {
+					post("/worker/task", []byte(`{"workerId": "`+registered.WorkerId+`"}`), task)
+					if task.TaskId == "" {
+						time.Sleep(100 * time.Millisecond)
+					}
+					continue
+				}
+				taskResult, _ := protojson.Marshal(&proto.WorkerTaskResult{
+					WorkerId: registered.WorkerId,
+					TaskId:   task.TaskId,
+					Result:   core.RunRaidSim(task.Request),
+				})
+				task = &proto.WorkerTask{}
+				post("/worker/result", taskResult, task)
+			}
+		}()
+	}
+
+	body := `{"raid": {"parties": [{"players": [{"race": "RaceTroll", "class": "ClassShaman", "elementalShaman": {}}]}]}, "encounter": {"duration": 30, "targets": [{}]}, "simOptions": {"iterations": 20, "randomSeed": "1"}}`
+	asyncResult := &proto.AsyncAPIResult{}
+	if status := postJSON(t, "/distributed/raidSimAsync", body, asyncResult); status != http.StatusOK {
+		t.Fatalf("Failed to start distributed sim, status %d", status)
+	}
+
+	progress := &proto.ProgressMetrics{}
+	for start := time.Now(); time.Since(start) < time.Minute && progress.FinalRaidResult == nil; time.Sleep(100 * time.Millisecond) {
+		postJSON(t, "/asyncProgress", `{"progressId": "`+asyncResult.ProgressId+`"}`, progress)
+	}
+
+	result := progress.FinalRaidResult
+	if result == nil || result.Error != nil {
+		t.Fatalf("Expected a distributed result, got: %v", result)
+	}
+	if result.IterationsDone != 20 || result.RaidMetrics.Dps.Avg == 0 {
+		t.Fatalf("Expected 20 iterations with non-zero DPS, got %d iterations at %0.1f DPS", result.IterationsDone, result.RaidMetrics.Dps.Avg)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	player := `{"raid": {"parties": [{"players": [{"race": "%s", "class": "ClassShaman", "elementalShaman": {}}]}]}, "encounter": {"duration": 30, "targets": [{}]}}`
+	body := `{"requests": [` + fmt.Sprintf(player, "RaceTroll") + `, ` + fmt.Sprintf(player, "RaceTroll") + `, ` + fmt.Sprintf(player, "RaceOrc") + `], "simOptions": {"iterations": 50, "randomSeed": "1"}}`
+
+	result := &proto.CompareResult{}
+	if status := postJSON(t, "/compare", body, result); status != http.StatusOK || result.Error != nil {
+		t.Fatalf("Compare failed with status %d: %v", status, result.Error)
+	}
+	if len(result.Results) != 3 || len(result.Variants) != 2 {
+		t.Fatalf("Expected 3 results and 2 variants, got %d and %d", len(result.Results), len(result.Variants))
+	}
+
+	// Identical requests see identical rolls, so every iteration pairs up exactly.
+	same := result.Variants[0].Dps
+	if same.Mean != 0 || same.Stdev != 0 || same.PValue != 1 {
+		t.Fatalf("Expected no difference between identical requests, got %v", same)
+	}
+	if result.Results[0].RaidMetrics.Dps.AllValues != nil {
+		t.Fatalf("Expected per-iteration values to be left out of the results")
+	}
+}
+
+func TestAPLTuneWithoutTunables(t *testing.T) {
+	body := `{"baseSettings": {"raid": {"parties": [{"players": [{"race": "RaceTroll", "class": "ClassShaman", "elementalShaman": {}, "rotation": {"type": "TypeAPL"}}]}]}, "encounter": {"duration": 30, "targets": [{}]}, "simOptions": {"iterations": 10}}}`
+
+	result := &proto.APLTuneResult{}
+	if status := postJSON(t, "/aplTune", body, result); status != http.StatusOK {
+		t.Fatalf("APL tune failed with status %d", status)
+	}
+	if result.Error == nil || !strings.Contains(result.Error.Message, "marked tunable") {
+		t.Fatalf("Expected an error about missing tunables, got %v", result.Error)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	proto "github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Builds an OpenAPI 3 description of the API routes from the proto
+// descriptors, so it always matches api.proto. Schemas describe the protojson
+// encoding, which is what application/json requests use.
+func buildOpenAPISpec() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]any{}
+
+	addPath := func(route string, request googleProto.Message, response googleProto.Message, summary string) {
+		requestRef := addMessageSchema(schemas, request.ProtoReflect().Descriptor())
+		responseRef := addMessageSchema(schemas, response.ProtoReflect().Descriptor())
+		errorRef := addMessageSchema(schemas, (&proto.ErrorOutcome{}).ProtoReflect().Descriptor())
+
+		content := func(ref string) map[string]any {
+			return map[string]any{
+				contentTypeJSON:  map[string]any{"schema": map[string]any{"$ref": ref}},
+				contentTypeProto: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+			}
+		}
+
+		paths[route] = map[string]any{
+			"post": map[string]any{
+				"summary":     summary,
+				"operationId": strings.TrimPrefix(route, "/"),
+				"requestBody": map[string]any{"required": true, "content": content(requestRef)},
+				"responses": map[string]any{
+					"200": map[string]any{"description": "OK", "content": content(responseRef)},
+					"400": map[string]any{"description": "Malformed or empty request", "content": content(errorRef)},
+					"404": map[string]any{"description": "Unknown route", "content": content(errorRef)},
+				},
+			},
+		}
+	}
+
+	for _, route := range sortedKeys(handlers) {
+		handler := handlers[route]
+		addPath(route, handler.msg(), handler.resp(), "Runs "+strings.TrimPrefix(route, "/")+" and returns the result.")
+	}
+	for _, route := range sortedKeys(asyncAPIHandlers) {
+		addPath(route, asyncAPIHandlers[route].msg(), &proto.AsyncAPIResult{}, "Starts "+strings.TrimPrefix(route, "/")+" in the background. Poll /asyncProgress or stream /asyncProgressStream with the returned progress ID.")
+	}
+	addPath("/asyncProgress", &proto.AsyncAPIResult{}, &proto.ProgressMetrics{}, "Returns the latest progress of an async request, with the final result once done. Responds 204 for unknown IDs.")
+	for _, route := range sortedKeys(jobAPIHandlers) {
+		handler := jobAPIHandlers[route]
+		addPath(route, handler.msg(), handler.resp(), "Job store route, only served when running with --jobs_dir.")
+	}
+	for _, route := range sortedKeys(workerAPIHandlers) {
+		handler := workerAPIHandlers[route]
+		addPath(route, handler.msg(), handler.resp(), "Worker route, only served when running with --coordinator.")
+	}
+	distributedHandlers := (&coordinator{}).asyncAPIHandlers()
+	for _, route := range sortedKeys(distributedHandlers) {
+		addPath(route, distributedHandlers[route].msg(), &proto.AsyncAPIResult{}, "Starts "+strings.TrimPrefix(route, "/")+" on the workers, only served when running with --coordinator.")
+	}
+	addPath("/asyncCancel", &proto.AsyncAPIResult{}, &proto.AbortResponse{}, "Aborts an async request. Responds 404 for unknown IDs.")
+	paths["/asyncProgressStream"] = map[string]any{
+		"get": map[string]any{
+			"summary":     "Streams every progress update of an async request as server-sent 'progress' events with protojson data, ending with the final result.",
+			"operationId": "asyncProgressStream",
+			"parameters": []any{
+				map[string]any{"name": "progressId", "in": "query", "required": true, "schema": map[string]any{"type": "string"}},
+			},
+			"responses": map[string]any{
+				"200": map[string]any{"description": "Event stream", "content": map[string]any{"text/event-stream": map[string]any{"schema": map[string]any{"type": "string"}}}},
+				"404": map[string]any{"description": "Unknown progress ID", "content": map[string]any{contentTypeJSON: map[string]any{"schema": map[string]any{"$ref": addMessageSchema(schemas, (&proto.ErrorOutcome{}).ProtoReflect().Descriptor())}}}},
+			},
+		},
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "WoWSims SoD API",
+			"version": Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func schemaRef(name protoreflect.FullName) string {
+	return "#/components/schemas/" + string(name)
+}
+
+// Adds a schema for the message and everything it references, and returns a $ref to it.
+func addMessageSchema(schemas map[string]any, md protoreflect.MessageDescriptor) string {
+	name := string(md.FullName())
+	if _, ok := schemas[name]; ok {
+		return schemaRef(md.FullName())
+	}
+
+	properties := map[string]any{}
+	schemas[name] = map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+
+		var schema map[string]any
+		if fd.IsMap() {
+			schema = map[string]any{
+				"type":                 "object",
+				"additionalProperties": fieldSchema(schemas, fd.MapValue()),
+			}
+		} else if fd.IsList() {
+			schema = map[string]any{
+				"type":  "array",
+				"items": fieldSchema(schemas, fd),
+			}
+		} else {
+			schema = fieldSchema(schemas, fd)
+		}
+		properties[fd.JSONName()] = schema
+	}
+
+	return schemaRef(md.FullName())
+}
+
+// Schema for a single value of the field, following the protojson mapping.
+func fieldSchema(schemas map[string]any, fd protoreflect.FieldDescriptor) map[string]any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson encodes 64-bit integers as strings.
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		names := make([]string, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return map[string]any{"$ref": addMessageSchema(schemas, fd.Message())}
+	}
+	return map[string]any{}
+}
+
+func handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	spec, err := json.MarshalIndent(buildOpenAPISpec(), "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", contentTypeJSON)
+	w.Write(spec)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	proto "github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/simsignals"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Buffer per streaming client. Updates are dropped for clients which fall this
+// far behind, they still get the latest progress once the request finishes.
+const streamBufferSize = 256
+
+func isFinalProgress(progMetric *proto.ProgressMetrics) bool {
+	return progMetric.FinalRaidResult != nil || progMetric.FinalWeightResult != nil || progMetric.FinalBulkResult != nil || progMetric.FinalGearOptimizeResult != nil || progMetric.FinalCompareResult != nil || progMetric.FinalAplTuneResult != nil
+}
+
+// Stores the progress and forwards it to all streaming clients.
+func (progress *asyncProgress) publish(progMetric *proto.ProgressMetrics) {
+	progress.latestProgress.Store(progMetric)
+
+	progress.subMut.Lock()
+	defer progress.subMut.Unlock()
+	for _, ch := range progress.subscribers {
+		select {
+		case ch <- progMetric:
+		default:
+		}
+	}
+}
+
+// Closes all streams, called once no more progress will be reported.
+func (progress *asyncProgress) finish() {
+	progress.subMut.Lock()
+	defer progress.subMut.Unlock()
+	if progress.done {
+		return
+	}
+	progress.done = true
+	for _, ch := range progress.subscribers {
+		close(ch)
+	}
+	progress.subscribers = nil
+}
+
+// Returns a channel receiving all further progress, which is closed when the
+// request finishes. Returns nil if it already has.
+func (progress *asyncProgress) subscribe() chan *proto.ProgressMetrics {
+	progress.subMut.Lock()
+	defer progress.subMut.Unlock()
+	if progress.done {
+		return nil
+	}
+	ch := make(chan *proto.ProgressMetrics, streamBufferSize)
+	progress.subscribers = append(progress.subscribers, ch)
+	return ch
+}
+
+func (progress *asyncProgress) unsubscribe(ch chan *proto.ProgressMetrics) {
+	progress.subMut.Lock()
+	defer progress.subMut.Unlock()
+	for i, subscriber := range progress.subscribers {
+		if subscriber == ch {
+			progress.subscribers = append(progress.subscribers[:i], progress.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Streams every ProgressMetrics of an async request as server-sent events,
+// ending with the one holding the final result. Each event is a "progress"
+// event with the protojson encoded message as data.
+func (s *server) handleProgressStream(w http.ResponseWriter, r *http.Request) {
+	progressId := r.URL.Query().Get("progressId")
+	progress, ok := s.getSim(progressId)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, fmt.Sprintf("Unknown progress ID %q", progressId))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	ch := progress.subscribe()
+	if ch != nil {
+		defer progress.unsubscribe(ch)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	writeEvent := func(progMetric *proto.ProgressMetrics) bool {
+		data, err := protojson.Marshal(progMetric)
+		if err != nil {
+			log.Printf("[ERROR] Failed to marshal progress: %s", err.Error())
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	// Catch up with the latest progress first, which is all there is if the
+	// request already finished.
+	latest := progress.latestProgress.Load().(*proto.ProgressMetrics)
+	if !writeEvent(latest) || isFinalProgress(latest) || ch == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case progMetric, ok := <-ch:
+			if !ok {
+				// Updates may have been dropped, make sure the final result arrives.
+				if final := progress.latestProgress.Load().(*proto.ProgressMetrics); final != latest && isFinalProgress(final) {
+					writeEvent(final)
+				}
+				return
+			}
+			latest = progMetric
+			if !writeEvent(progMetric) || isFinalProgress(progMetric) {
+				return
+			}
+		}
+	}
+}
+
+// Aborts an async request by its progress ID.
+func (s *server) handleAsyncCancel(w http.ResponseWriter, r *http.Request) {
+	msg := &proto.AsyncAPIResult{}
+	if !readRequest(w, r, msg) {
+		return
+	}
+
+	progress, ok := s.getSim(msg.ProgressId)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, fmt.Sprintf("Unknown progress ID %q", msg.ProgressId))
+		return
+	}
+
+	triggered := simsignals.AbortById(progress.requestId)
+	writeResponse(w, r, &proto.AbortResponse{RequestId: progress.requestId, WasTriggered: triggered})
+}
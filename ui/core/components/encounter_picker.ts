@@ -106,6 +106,21 @@ export class EncounterPicker extends Component {
 				});
 			}
 
+			if (!simUI.isIndividualSim()) {
+				new BooleanPicker(this.rootElem, simUI.sim.raid, {
+					id: 'encounter-model-player-deaths',
+					label: 'Model Player Deaths',
+					labelTooltip: 'Players with a healing model stop acting when they die, until resurrected.',
+					inline: true,
+					reverse: true,
+					changedEvent: (raid: Raid) => raid.modelPlayerDeathsChangeEmitter,
+					getValue: (raid: Raid) => raid.getModelPlayerDeaths(),
+					setValue: (eventID: EventID, raid: Raid, newValue: boolean) => {
+						raid.setModelPlayerDeaths(eventID, newValue);
+					},
+				});
+			}
+
 			if (simUI.isIndividualSim() && isTankSpec((simUI as IndividualSimUI<any>).player.spec)) {
 				new NumberPicker(this.rootElem, modEncounter, {
 					id: 'encounter-min-base-damage',
@@ -175,6 +190,17 @@ class AdvancedEncounterModal extends BaseModal {
 				},
 			});
 		}
+		new BooleanPicker<Encounter>(header, encounter, {
+			id: 'encounter-use-threat-table',
+			label: 'Use Threat Table',
+			labelTooltip: 'Targets track threat and attack whoever has aggro, instead of always attacking the assigned tank.',
+			inline: true,
+			changedEvent: (encounter: Encounter) => encounter.changeEmitter,
+			getValue: (encounter: Encounter) => encounter.getUseThreatTable(),
+			setValue: (eventID: EventID, encounter: Encounter, newValue: boolean) => {
+				encounter.setUseThreatTable(eventID, newValue);
+			},
+		});
 		new ListPicker<Encounter, TargetProto>(targetsElem, this.encounter, {
 			extraCssClasses: ['targets-picker', 'mb-0'],
 			itemLabel: 'Target',
@@ -238,6 +264,9 @@ class TargetPicker extends Input<Encounter, TargetProto> {
 	private readonly parryHastePicker: Input<null, boolean>;
 	private readonly spellSchoolPicker: Input<null, number>;
 	private readonly damageSpreadPicker: Input<null, number>;
+	private readonly spawnTimePicker: Input<null, number>;
+	private readonly despawnTimePicker: Input<null, number>;
+	private readonly useHealthPicker: Input<null, boolean>;
 	private readonly targetInputPickers: ListPicker<Encounter, TargetInput>;
 
 	private getTarget(): TargetProto {
@@ -487,6 +516,46 @@ class TargetPicker extends Input<Encounter, TargetProto> {
 				encounter.targetsChangeEmitter.emit(eventID);
 			},
 		});
+		this.spawnTimePicker = new NumberPicker(section3, null, {
+			id: 'target-picker-spawn-time',
+			label: 'Spawn Time',
+			labelTooltip: 'Seconds after the pull at which this enemy joins the fight. Ignored for the first target.',
+			float: true,
+			changedEvent: () => encounter.targetsChangeEmitter,
+			getValue: () => this.getTarget().spawnTime,
+			setValue: (eventID: EventID, _: null, newValue: number) => {
+				this.getTarget().spawnTime = newValue;
+				encounter.targetsChangeEmitter.emit(eventID);
+			},
+			enableWhen: () => this.targetIndex > 0,
+		});
+		this.despawnTimePicker = new NumberPicker(section3, null, {
+			id: 'target-picker-despawn-time',
+			label: 'Despawn Time',
+			labelTooltip: 'Seconds after the pull at which this enemy leaves the fight, or 0 to stay until the end. Ignored for the first target.',
+			float: true,
+			changedEvent: () => encounter.targetsChangeEmitter,
+			getValue: () => this.getTarget().despawnTime,
+			setValue: (eventID: EventID, _: null, newValue: number) => {
+				this.getTarget().despawnTime = newValue;
+				encounter.targetsChangeEmitter.emit(eventID);
+			},
+			enableWhen: () => this.targetIndex > 0,
+		});
+		this.useHealthPicker = new BooleanPicker(section3, null, {
+			id: 'target-picker-use-health',
+			label: 'Dies at 0 Health',
+			labelTooltip: 'If checked, this enemy leaves the fight once it has taken damage equal to its Health stat. Ignored for the first target.',
+			inline: true,
+			reverse: true,
+			changedEvent: () => encounter.targetsChangeEmitter,
+			getValue: () => this.getTarget().useHealth,
+			setValue: (eventID: EventID, _: null, newValue: boolean) => {
+				this.getTarget().useHealth = newValue;
+				encounter.targetsChangeEmitter.emit(eventID);
+			},
+			enableWhen: () => this.targetIndex > 0,
+		});
 
 		this.init();
 	}
@@ -507,6 +576,10 @@ class TargetPicker extends Input<Encounter, TargetProto> {
 			parryHaste: this.parryHastePicker.getInputValue(),
 			spellSchool: this.spellSchoolPicker.getInputValue(),
 			damageSpread: this.damageSpreadPicker.getInputValue(),
+			spawnTime: this.spawnTimePicker.getInputValue(),
+			despawnTime: this.despawnTimePicker.getInputValue(),
+			useHealth: this.useHealthPicker.getInputValue(),
+			script: this.getTarget().script,
 			stats: this.statPickers
 				.map(picker => picker.getInputValue())
 				.map((statValue, i) => new Stats().withStat(ALL_TARGET_STATS[i].stat, statValue))
@@ -530,6 +603,9 @@ class TargetPicker extends Input<Encounter, TargetProto> {
 		this.parryHastePicker.setInputValue(newValue.parryHaste);
 		this.spellSchoolPicker.setInputValue(newValue.spellSchool);
 		this.damageSpreadPicker.setInputValue(newValue.damageSpread);
+		this.spawnTimePicker.setInputValue(newValue.spawnTime);
+		this.despawnTimePicker.setInputValue(newValue.despawnTime);
+		this.useHealthPicker.setInputValue(newValue.useHealth);
 		ALL_TARGET_STATS.forEach((statData, i) => this.statPickers[i].setInputValue(newValue.stats[statData.stat]));
 		this.targetInputPickers.setInputValue(newValue.targetInputs);
 	}
@@ -6,6 +6,7 @@ import {
 	APLActionActivateAuraWithStacks,
 	APLActionAddComboPoints,
 	APLActionAutocastOtherCooldowns,
+	APLActionCallActionList,
 	APLActionCancelAura,
 	APLActionCastPaladinPrimarySeal,
 	APLActionCastSpell,
@@ -19,10 +20,13 @@ import {
 	APLActionMultidot,
 	APLActionMultishield,
 	APLActionResetSequence,
+	APLActionRunActionList,
 	APLActionSchedule,
 	APLActionSequence,
 	APLActionStrictSequence,
 	APLActionTriggerICD,
+	APLActionVariable,
+	APLActionVariable_Operation as VariableOperation,
 	APLActionWait,
 	APLActionWaitUntil,
 	APLValue,
@@ -271,6 +275,30 @@ function itemSwapSetFieldConfig(field: string): AplHelpers.APLPickerBuilderField
 	};
 }
 
+function variableOperationFieldConfig(field: string): AplHelpers.APLPickerBuilderFieldConfig<any, any> {
+	return {
+		field: field,
+		newValue: () => VariableOperation.OpSet,
+		factory: (parent, player, config) =>
+			new TextDropdownPicker(parent, player, {
+				id: randomUUID(),
+				...config,
+				defaultLabel: 'Set',
+				equals: (a, b) => a == b,
+				values: [
+					{ value: VariableOperation.OpSet, label: 'Set' },
+					{ value: VariableOperation.OpAdd, label: 'Add' },
+					{ value: VariableOperation.OpSub, label: 'Subtract' },
+					{ value: VariableOperation.OpMul, label: 'Multiply' },
+					{ value: VariableOperation.OpDiv, label: 'Divide' },
+					{ value: VariableOperation.OpMin, label: 'Min' },
+					{ value: VariableOperation.OpMax, label: 'Max' },
+					{ value: VariableOperation.OpReset, label: 'Reset' },
+				],
+			}),
+	};
+}
+
 function actionFieldConfig(field: string): AplHelpers.APLPickerBuilderFieldConfig<any, any> {
 	return {
 		field: field,
@@ -531,6 +559,42 @@ const actionKindFactories: { [f in NonNullable<APLActionKind>]: ActionKindConfig
 		newValue: APLActionStrictSequence.create,
 		fields: [actionListFieldConfig('actions')],
 	}),
+	['variable']: inputBuilder({
+		label: 'Variable',
+		submenu: ['Variables'],
+		shortDescription: 'Sets or updates a named number, which can be read with the <b>Variable</b> value.',
+		fullDescription: `
+			<p>Variables start at 0 in each iteration. This action takes no time, so the list continues past it, and it is applied every time the list is evaluated up to it.</p>
+			<p><b>Reset</b> sets the variable back to 0 and ignores the value.</p>
+		`,
+		newValue: () =>
+			APLActionVariable.create({
+				op: VariableOperation.OpSet,
+			}),
+		fields: [AplHelpers.stringFieldConfig('name'), variableOperationFieldConfig('op'), AplValues.valueFieldConfig('value')],
+	}),
+	['callActionList']: inputBuilder({
+		label: 'Call Action List',
+		submenu: ['Action Lists'],
+		shortDescription: 'Performs the first ready action of a named action list, or continues with the next action if there is none.',
+		fullDescription: `
+			<p>Use the <b>name</b> field to refer to one of the <b>Action Lists</b> below the priority list.</p>
+		`,
+		includeIf: (player: Player<any>, isPrepull: boolean) => !isPrepull,
+		newValue: APLActionCallActionList.create,
+		fields: [AplHelpers.stringFieldConfig('name')],
+	}),
+	['runActionList']: inputBuilder({
+		label: 'Run Action List',
+		submenu: ['Action Lists'],
+		shortDescription: 'Performs the first ready action of a named action list. Actions after this one are never used while it applies.',
+		fullDescription: `
+			<p>Use the <b>name</b> field to refer to one of the <b>Action Lists</b> below the priority list. If nothing in the list is ready, nothing is done.</p>
+		`,
+		includeIf: (player: Player<any>, isPrepull: boolean) => !isPrepull,
+		newValue: APLActionRunActionList.create,
+		fields: [AplHelpers.stringFieldConfig('name')],
+	}),
 	['changeTarget']: inputBuilder({
 		label: 'Change Target',
 		submenu: ['Misc'],
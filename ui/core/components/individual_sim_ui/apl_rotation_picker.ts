@@ -1,11 +1,16 @@
 import tippy, { Instance as TippyInstance } from 'tippy.js';
 
+import { MAX_PARTY_SIZE } from '../../party';
 import { Player } from '../../player';
-import { APLAction, APLListItem, APLPrepullAction, APLValue } from '../../proto/apl';
+import { APLActionProfile, APLActionStats, APLNotReadyReason, APLProfile } from '../../proto/api';
+import { APLAction, APLActionList, APLListItem, APLPrepullAction, APLValue } from '../../proto/apl';
 import { ActionId } from '../../proto_utils/action_id';
+import { SimResult } from '../../proto_utils/sim_result';
+import { Sim } from '../../sim';
 import { SimUI } from '../../sim_ui';
 import { EventID, TypedEvent } from '../../typed_event';
 import { existsInDOM, randomUUID } from '../../utils';
+import { BooleanPicker } from '../boolean_picker';
 import { Component } from '../component';
 import { Input, InputConfig } from '../input';
 import { AdaptiveStringPicker } from '../inputs/string_picker';
@@ -17,6 +22,20 @@ export class APLRotationPicker extends Component {
 	constructor(parent: HTMLElement, simUI: SimUI, modPlayer: Player<any>) {
 		super(parent, 'apl-rotation-picker-root');
 
+		new BooleanPicker(this.rootElem, simUI.sim, {
+			id: 'apl-rotation-profile',
+			label: 'Profile Rotation',
+			labelTooltip:
+				'Records how often each action was checked, passed its condition and was performed, and why it was not ready, for the next sims. Slows the sim down a little.',
+			extraCssClasses: ['apl-rotation-profile-picker'],
+			inline: true,
+			changedEvent: (sim: Sim) => sim.profileAplChangeEmitter,
+			getValue: (sim: Sim) => sim.getProfileApl(),
+			setValue: (eventID: EventID, sim: Sim, newValue: boolean) => {
+				sim.setProfileApl(eventID, newValue);
+			},
+		});
+
 		new ListPicker<Player<any>, APLPrepullAction>(this.rootElem, modPlayer, {
 			extraCssClasses: ['apl-prepull-action-picker'],
 			title: 'Prepull Actions',
@@ -66,7 +85,36 @@ export class APLRotationPicker extends Component {
 				listPicker: ListPicker<Player<any>, APLListItem>,
 				index: number,
 				config: ListItemPickerConfig<Player<any>, APLListItem>,
-			) => new APLListItemPicker(parent, modPlayer, config, index),
+			) =>
+				new APLListItemPicker(
+					parent,
+					modPlayer,
+					config,
+					player => actionStatsWarnings(player.getCurrentStats().rotationStats?.priorityList[index]),
+					profile => profile.priorityList[index],
+				),
+			inlineMenuBar: true,
+		});
+
+		new ListPicker<Player<any>, APLActionList>(this.rootElem, modPlayer, {
+			extraCssClasses: ['apl-action-list-picker'],
+			title: 'Action Lists',
+			titleTooltip: 'Named lists of actions, used with the Call Action List and Run Action List actions.',
+			itemLabel: 'Action List',
+			changedEvent: (player: Player<any>) => player.rotationChangeEmitter,
+			getValue: (player: Player<any>) => player.aplRotation.actionLists,
+			setValue: (eventID: EventID, player: Player<any>, newValue: Array<APLActionList>) => {
+				player.aplRotation.actionLists = newValue;
+				player.rotationChangeEmitter.emit(eventID);
+			},
+			newItem: () => APLActionList.create(),
+			copyItem: (oldItem: APLActionList) => APLActionList.clone(oldItem),
+			newItemPicker: (
+				parent: HTMLElement,
+				listPicker: ListPicker<Player<any>, APLActionList>,
+				index: number,
+				config: ListItemPickerConfig<Player<any>, APLActionList>,
+			) => new APLActionListPicker(parent, modPlayer, config, index),
 			inlineMenuBar: true,
 		});
 
@@ -96,7 +144,7 @@ class APLPrepullActionPicker extends Input<Player<any>, APLPrepullAction> {
 		this.player = player;
 
 		const itemHeaderElem = ListPicker.getItemHeaderElem(this);
-		makeListItemWarnings(itemHeaderElem, player, player => player.getCurrentStats().rotationStats?.prepullActions[index]?.warnings || []);
+		makeListItemWarnings(itemHeaderElem, player, player => actionStatsWarnings(player.getCurrentStats().rotationStats?.prepullActions[index]));
 
 		this.hidePicker = new HidePicker(itemHeaderElem, player, {
 			changedEvent: () => this.player.rotationChangeEmitter,
@@ -190,13 +238,20 @@ class APLListItemPicker extends Input<Player<any>, APLListItem> {
 		);
 	}
 
-	constructor(parent: HTMLElement, player: Player<any>, config: ListItemPickerConfig<Player<any>, APLListItem>, index: number) {
+	constructor(
+		parent: HTMLElement,
+		player: Player<any>,
+		config: ListItemPickerConfig<Player<any>, APLListItem>,
+		getWarnings: (player: Player<any>) => Array<string>,
+		getProfile: (profile: APLProfile) => APLActionProfile | undefined,
+	) {
 		config.enableWhen = () => !this.getItem().hide;
 		super(parent, 'apl-list-item-picker-root', player, config);
 		this.player = player;
 
 		const itemHeaderElem = ListPicker.getItemHeaderElem(this);
-		makeListItemWarnings(itemHeaderElem, player, player => player.getCurrentStats().rotationStats?.priorityList[index]?.warnings || []);
+		makeListItemWarnings(itemHeaderElem, player, getWarnings);
+		makeListItemProfile(itemHeaderElem, player, getProfile);
 
 		this.hidePicker = new HidePicker(itemHeaderElem, player, {
 			changedEvent: () => this.player.rotationChangeEmitter,
@@ -239,6 +294,93 @@ class APLListItemPicker extends Input<Player<any>, APLListItem> {
 	}
 }
 
+class APLActionListPicker extends Input<Player<any>, APLActionList> {
+	private readonly player: Player<any>;
+
+	private readonly namePicker: Input<Player<any>, string>;
+	private readonly itemsPicker: ListPicker<Player<any>, APLListItem>;
+
+	private getItem(): APLActionList {
+		return this.getSourceValue() || APLActionList.create();
+	}
+
+	constructor(parent: HTMLElement, player: Player<any>, config: ListItemPickerConfig<Player<any>, APLActionList>, index: number) {
+		super(parent, 'apl-list-item-picker-root', player, config);
+		this.player = player;
+
+		const itemHeaderElem = ListPicker.getItemHeaderElem(this);
+		makeListItemWarnings(itemHeaderElem, player, player => player.getCurrentStats().rotationStats?.actionLists[index]?.warnings || []);
+
+		this.namePicker = new AdaptiveStringPicker(this.rootElem, this.player, {
+			id: randomUUID(),
+			label: 'Name',
+			extraCssClasses: ['apl-action-list-name'],
+			changedEvent: () => this.player.rotationChangeEmitter,
+			getValue: () => this.getItem().name,
+			setValue: (eventID: EventID, player: Player<any>, newValue: string) => {
+				this.getItem().name = newValue;
+				this.player.rotationChangeEmitter.emit(eventID);
+			},
+			inline: true,
+		});
+
+		this.itemsPicker = new ListPicker<Player<any>, APLListItem>(this.rootElem, this.player, {
+			extraCssClasses: ['apl-list-item-picker'],
+			itemLabel: 'Action',
+			changedEvent: () => this.player.rotationChangeEmitter,
+			getValue: () => this.getItem().items,
+			setValue: (eventID: EventID, player: Player<any>, newValue: Array<APLListItem>) => {
+				this.getItem().items = newValue;
+				this.player.rotationChangeEmitter.emit(eventID);
+			},
+			newItem: () =>
+				APLListItem.create({
+					action: {},
+				}),
+			copyItem: (oldItem: APLListItem) => APLListItem.clone(oldItem),
+			newItemPicker: (
+				parent: HTMLElement,
+				listPicker: ListPicker<Player<any>, APLListItem>,
+				itemIndex: number,
+				config: ListItemPickerConfig<Player<any>, APLListItem>,
+			) =>
+				new APLListItemPicker(
+					parent,
+					this.player,
+					config,
+					player => actionStatsWarnings(player.getCurrentStats().rotationStats?.actionLists[index]?.items[itemIndex]),
+					profile => profile.actionLists[index]?.items[itemIndex],
+				),
+			inlineMenuBar: true,
+		});
+		this.init();
+	}
+
+	getInputElem(): HTMLElement | null {
+		return this.rootElem;
+	}
+
+	getInputValue(): APLActionList {
+		return APLActionList.create({
+			name: this.namePicker.getInputValue(),
+			items: this.itemsPicker.getInputValue(),
+		});
+	}
+
+	setInputValue(newValue: APLActionList) {
+		if (!newValue) {
+			return;
+		}
+		this.namePicker.setInputValue(newValue.name);
+		this.itemsPicker.setInputValue(newValue.items);
+	}
+}
+
+// Validation warnings, followed by the findings of the APL linter.
+function actionStatsWarnings(stats: APLActionStats | undefined): Array<string> {
+	return [...(stats?.warnings || []), ...(stats?.lints || [])];
+}
+
 function makeListItemWarnings(itemHeaderElem: HTMLElement, player: Player<any>, getWarnings: (player: Player<any>) => Array<string>) {
 	const warningsElem = ListPicker.makeActionElem('apl-warnings', 'fa-exclamation-triangle');
 	warningsElem.classList.add('warning', 'link-warning');
@@ -277,6 +419,65 @@ function makeListItemWarnings(itemHeaderElem: HTMLElement, player: Player<any>,
 	player.currentStatsEmitter.on(updateWarnings);
 }
 
+const notReadyReasonNames: Record<APLNotReadyReason, string> = {
+	[APLNotReadyReason.APLNotReadyOther]: 'Other',
+	[APLNotReadyReason.APLNotReadyCastCondition]: 'Range or spell condition',
+	[APLNotReadyReason.APLNotReadyMoving]: 'Moving',
+	[APLNotReadyReason.APLNotReadyCasting]: 'Casting or channeling',
+	[APLNotReadyReason.APLNotReadyGcd]: 'GCD',
+	[APLNotReadyReason.APLNotReadyCooldown]: 'Cooldown',
+	[APLNotReadyReason.APLNotReadyCost]: 'Resource cost',
+};
+
+function getPlayerAPLProfile(simResult: SimResult, player: Player<any>): APLProfile | undefined {
+	const raidIndex = player.getRaidIndex();
+	return simResult.result.raidMetrics?.parties[Math.floor(raidIndex / MAX_PARTY_SIZE)]?.players[raidIndex % MAX_PARTY_SIZE]?.aplProfile;
+}
+
+function makeListItemProfile(itemHeaderElem: HTMLElement, player: Player<any>, getProfile: (profile: APLProfile) => APLActionProfile | undefined) {
+	const profileElem = ListPicker.makeActionElem('apl-profile', 'fa-chart-bar');
+	profileElem.setAttribute('data-bs-html', 'true');
+	profileElem.style.visibility = 'hidden';
+	const profileTooltip = tippy(profileElem, {
+		theme: 'dropdown-tooltip',
+		content: 'Profile',
+	});
+	itemHeaderElem.appendChild(profileElem);
+
+	const updateProfile = (_eventID: EventID, simResult: SimResult) => {
+		if (!existsInDOM(profileElem)) {
+			profileTooltip?.destroy();
+			profileElem?.remove();
+			player.sim.simResultEmitter.off(updateProfile);
+			return;
+		}
+		const playerProfile = getPlayerAPLProfile(simResult, player);
+		const profile = playerProfile && getProfile(playerProfile);
+		if (!profile) {
+			profileElem.style.visibility = 'hidden';
+			return;
+		}
+
+		const iterations = playerProfile.iterations || 1;
+		const perIteration = (count: bigint | number) => (Number(count) / iterations).toFixed(1);
+		const percentOf = (count: bigint, total: bigint) => (total ? ((Number(count) / Number(total)) * 100).toFixed(1) : '0.0') + '%';
+		const notReady = profile.notReady.map(count => `<li>${notReadyReasonNames[count.reason]}: ${perIteration(count.count)}</li>`).join('');
+
+		profileElem.style.visibility = 'visible';
+		profileTooltip.setContent(`
+			<p>Per iteration, this action was:</p>
+			<ul>
+				<li>Checked: ${perIteration(profile.evaluations)}</li>
+				<li>Condition true: ${perIteration(profile.conditionTrue)} (${percentOf(profile.conditionTrue, profile.evaluations)})</li>
+				<li>Performed: ${perIteration(profile.executions)}</li>
+			</ul>
+			${notReady ? `<p>Condition true but not ready, per iteration:</p><ul>${notReady}</ul>` : ''}
+			<p>Evaluation time: ${(profile.evaluationSeconds * 1000).toFixed(1)}ms over ${iterations} iterations.</p>
+		`);
+	};
+	player.sim.simResultEmitter.on(updateProfile);
+}
+
 class HidePicker extends Input<Player<any>, boolean> {
 	private readonly inputElem: HTMLElement;
 	private readonly iconElem: HTMLElement;
@@ -61,8 +61,13 @@ import {
 	APLValueSpellIsReady,
 	APLValueSpellTimeToReady,
 	APLValueSpellTravelTime,
+	APLValueTargetHealthPercent,
+	APLValueTargetTimeToDie,
+	APLValueTargetTimeToPercent,
+	APLValueThreatPercentOfTank,
 	APLValueTimeToEnergyTick,
 	APLValueTotemRemainingTime,
+	APLValueVariable,
 	APLValueWarlockCurrentPetMana,
 	APLValueWarlockCurrentPetManaPercent,
 	APLValueWarlockPetIsActive,
@@ -523,6 +528,13 @@ const valueKindFactories: { [f in NonNullable<APLValueKind>]: ValueKindConfig<AP
 		newValue: APLValueMin.create,
 		fields: [valueListFieldConfig('vals')],
 	}),
+	variable: inputBuilder({
+		label: 'Variable',
+		submenu: ['Logic'],
+		shortDescription: 'The current value of a variable set by <b>Variable</b> actions, or 0 if it was not set yet.',
+		newValue: APLValueVariable.create,
+		fields: [AplHelpers.stringFieldConfig('name')],
+	}),
 	and: inputBuilder({
 		label: 'All of',
 		submenu: ['Logic'],
@@ -596,6 +608,46 @@ const valueKindFactories: { [f in NonNullable<APLValueKind>]: ValueKindConfig<AP
 		newValue: APLValueFrontOfTarget.create,
 		fields: [],
 	}),
+	threatPercentOfTank: inputBuilder({
+		label: 'Threat % of Tank',
+		submenu: ['Encounter'],
+		shortDescription: 'Your threat on the target, as a percentage of the threat of whoever currently has aggro.',
+		fullDescription: `
+		<p>Requires the encounter to use threat tables. Targets switch to you above 110% in melee range, or 130% at range.</p>
+		`,
+		newValue: APLValueThreatPercentOfTank.create,
+		fields: [AplHelpers.unitFieldConfig('targetUnit', 'targets')],
+	}),
+	targetHealthPercent: inputBuilder({
+		label: 'Target Health %',
+		submenu: ['Encounter'],
+		shortDescription: 'Remaining health of the target, as a percentage.',
+		fullDescription: `
+		<p>Targets with a health pool lose health as they take damage. Other targets lose health evenly over their time in the fight, which is how execute phases work in duration based fights.</p>
+		`,
+		newValue: APLValueTargetHealthPercent.create,
+		fields: [AplHelpers.unitFieldConfig('targetUnit', 'targets')],
+	}),
+	targetTimeToDie: inputBuilder({
+		label: 'Target Time To Die',
+		submenu: ['Encounter'],
+		shortDescription: 'Estimated time until the target dies or leaves the fight.',
+		fullDescription: `
+		<p>For targets with a health pool, this uses the damage they took over the last 10 seconds. It is never more than the time until the target despawns or the fight ends.</p>
+		`,
+		newValue: APLValueTargetTimeToDie.create,
+		fields: [AplHelpers.unitFieldConfig('targetUnit', 'targets')],
+	}),
+	targetTimeToPercent: inputBuilder({
+		label: 'Target Time To Health %',
+		submenu: ['Encounter'],
+		shortDescription: 'Estimated time until the target drops to the given health percentage, estimated like <b>Target Time To Die</b>.',
+		newValue: () =>
+			APLValueTargetTimeToPercent.create({
+				percent: 20,
+			}),
+		fields: [AplHelpers.unitFieldConfig('targetUnit', 'targets'), AplHelpers.numberFieldConfig('percent', true, { label: 'Health %' })],
+	}),
 
 	// Resources
 	currentHealth: inputBuilder({
@@ -20,6 +20,7 @@ export class Encounter {
 	private executeProportion25 = DEFAULT_EXECUTE_25;
 	private executeProportion35 = DEFAULT_EXECUTE_35;
 	private useHealth = false;
+	private useThreatTable = false;
 
 	targets!: Array<TargetProto>;
 	targetsMetadata: UnitMetadataList;
@@ -111,6 +112,16 @@ export class Encounter {
 		this.executeProportionChangeEmitter.emit(eventID);
 	}
 
+	getUseThreatTable(): boolean {
+		return this.useThreatTable;
+	}
+	setUseThreatTable(eventID: EventID, newUseThreatTable: boolean) {
+		if (newUseThreatTable == this.useThreatTable) return;
+
+		this.useThreatTable = newUseThreatTable;
+		this.targetsChangeEmitter.emit(eventID);
+	}
+
 	matchesPreset(preset: PresetEncounter): boolean {
 		return preset.targets.length == this.targets.length && this.targets.every((t, i) => TargetProto.equals(t, preset.targets[i].target));
 	}
@@ -133,6 +144,7 @@ export class Encounter {
 			executeProportion25: this.executeProportion25,
 			executeProportion35: this.executeProportion35,
 			useHealth: this.useHealth,
+			useThreatTable: this.useThreatTable,
 			targets: this.targets,
 		});
 	}
@@ -145,6 +157,7 @@ export class Encounter {
 			this.setExecuteProportion25(eventID, proto.executeProportion25);
 			this.setExecuteProportion35(eventID, proto.executeProportion35);
 			this.setUseHealth(eventID, proto.useHealth);
+			this.setUseThreatTable(eventID, proto.useThreatTable);
 			this.targets = proto.targets;
 			this.targetsChangeEmitter.emit(eventID);
 		});
@@ -21,6 +21,7 @@ export class Raid {
 	private debuffs: Debuffs = Debuffs.create();
 	private tanks: Array<UnitReference> = [];
 	private targetDummies = 0;
+	private modelPlayerDeaths = false;
 	private numActiveParties = 5;
 
 	// Emits when a raid member is added/removed/moved.
@@ -30,6 +31,7 @@ export class Raid {
 	readonly debuffsChangeEmitter = new TypedEvent<void>();
 	readonly tanksChangeEmitter = new TypedEvent<void>();
 	readonly targetDummiesChangeEmitter = new TypedEvent<void>();
+	readonly modelPlayerDeathsChangeEmitter = new TypedEvent<void>();
 	readonly numActivePartiesChangeEmitter = new TypedEvent<void>();
 
 	// Emits when anything in the raid changes.
@@ -62,6 +64,7 @@ export class Raid {
 			this.debuffsChangeEmitter,
 			this.tanksChangeEmitter,
 			this.targetDummiesChangeEmitter,
+			this.modelPlayerDeathsChangeEmitter,
 		], 'RaidChange');
 
 		this.changeEmitter.on(() => {
@@ -173,6 +176,18 @@ export class Raid {
 		this.targetDummiesChangeEmitter.emit(eventID);
 	}
 
+	getModelPlayerDeaths(): boolean {
+		return this.modelPlayerDeaths;
+	}
+
+	setModelPlayerDeaths(eventID: EventID, newModelPlayerDeaths: boolean) {
+		if (this.modelPlayerDeaths == newModelPlayerDeaths)
+			return;
+
+		this.modelPlayerDeaths = newModelPlayerDeaths;
+		this.modelPlayerDeathsChangeEmitter.emit(eventID);
+	}
+
 	getNumActiveParties(): number {
 		return this.numActiveParties;
 	}
@@ -200,6 +215,7 @@ export class Raid {
 			debuffs: this.getDebuffs(),
 			tanks: this.getTanks(),
 			targetDummies: this.getTargetDummies(),
+			modelPlayerDeaths: this.getModelPlayerDeaths(),
 			numActiveParties: this.getNumActiveParties(),
 		});
 	}
@@ -220,6 +236,7 @@ export class Raid {
 			this.setDebuffs(eventID, proto.debuffs || Debuffs.create());
 			this.setTanks(eventID, proto.tanks);
 			this.setTargetDummies(eventID, proto.targetDummies);
+			this.setModelPlayerDeaths(eventID, proto.modelPlayerDeaths);
 			this.setNumActiveParties(eventID, proto.numActiveParties || 5);
 
 			for (let i = 0; i < MAX_NUM_PARTIES; i++) {
@@ -76,6 +76,8 @@ export class Sim {
 	private wasmConcurrency = 0;
 	private showEPValues = false;
 	private language = '';
+	// Not saved with the settings, since profiling slows the sim down.
+	private profileApl = false;
 
 	readonly raid: Raid;
 	readonly encounter: Encounter;
@@ -95,6 +97,7 @@ export class Sim {
 	readonly wasmConcurrencyChangeEmitter = new TypedEvent<void>();
 	readonly showEPValuesChangeEmitter = new TypedEvent<void>();
 	readonly languageChangeEmitter = new TypedEvent<void>();
+	readonly profileAplChangeEmitter = new TypedEvent<void>();
 	readonly crashEmitter = new TypedEvent<SimError>();
 
 	// Emits when any of the settings change (but not the raid / encounter).
@@ -232,6 +235,7 @@ export class Sim {
 				iterations: debug ? 1 : this.getIterations(),
 				randomSeed: BigInt(this.nextRngSeed()),
 				debugFirstIteration: true,
+				profileApl: this.getProfileApl(),
 			}),
 		});
 	}
@@ -252,6 +256,7 @@ export class Sim {
 
 		if (request.baseSettings != null && request.baseSettings.simOptions != null) {
 			request.baseSettings.simOptions.debugFirstIteration = false;
+			request.baseSettings.simOptions.profileApl = false;
 		}
 
 		if (!request.baseSettings?.raid || request.baseSettings?.raid?.parties.length == 0 || request.baseSettings?.raid?.parties[0].players.length == 0) {
@@ -625,6 +630,16 @@ export class Sim {
 		}
 	}
 
+	getProfileApl(): boolean {
+		return this.profileApl;
+	}
+	setProfileApl(eventID: EventID, newProfileApl: boolean) {
+		if (newProfileApl != this.profileApl) {
+			this.profileApl = newProfileApl;
+			this.profileAplChangeEmitter.emit(eventID);
+		}
+	}
+
 	getIterations(): number {
 		return this.iterations;
 	}